@@ -0,0 +1,167 @@
+// Package ambilight mirrors the screen onto an entertainment area: every
+// channel takes the color of the part of the screen at its position.
+//
+//	areas, err := huestream.Areas(ctx, host, username)
+//	...
+//	// With areas[0] the area streamed.
+//	err = ambilight.RunScreenSync(ctx, stream, ambilight.Display(0), areas[0].Channels)
+//
+// Every capture is downscaled, cropped to its content when letterboxed, and
+// sampled in the Regions of the channels. The colors are smoothed over the
+// captures, so that cuts and flickering do not strobe the room.
+//
+// The package is a module of its own, so that the screen capture library is
+// only a dependency of the programs using it.
+package ambilight
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+// ScreenSource captures the screen.
+type ScreenSource interface {
+	// Capture returns an image of the screen. It may be downscaled
+	// already, RunScreenSync downscales it further if needed.
+	Capture(ctx context.Context) (image.Image, error)
+}
+
+// Option configures RunScreenSync.
+type Option func(*config)
+
+type config struct {
+	rate      float64
+	smoothing float64
+	width     int
+	height    int
+	size      float64
+	letterbox bool
+}
+
+// WithRate sets the captures per second, by default 20. A capture slower
+// than the period delays the next one: the ticks missed are skipped, not
+// queued.
+func WithRate(hz float64) Option {
+	return func(c *config) { c.rate = hz }
+}
+
+// WithSmoothing sets the weight of the past colors, from 0 to 1, by default
+// 0.5. 0 follows every capture at once.
+func WithSmoothing(s float64) Option {
+	return func(c *config) { c.smoothing = s }
+}
+
+// WithResolution sets the size the captures are downscaled to, by default
+// 64x36. Bigger sizes sample finer details and take longer.
+func WithResolution(width, height int) Option {
+	return func(c *config) { c.width, c.height = width, height }
+}
+
+// WithRegionSize sets the size of the Regions, by default 0.3.
+func WithRegionSize(size float64) Option {
+	return func(c *config) { c.size = size }
+}
+
+// WithLetterbox sets whether the black bars of letterboxed and pillarboxed
+// pictures are ignored, true by default.
+func WithLetterbox(on bool) Option {
+	return func(c *config) { c.letterbox = on }
+}
+
+// RunScreenSync captures src and streams its colors to the channels until
+// ctx is done or a capture or a send fails. It returns nil when ctx is
+// done.
+func RunScreenSync(ctx context.Context, st huestream.Streamer, src ScreenSource, channels []huestream.Channel, opts ...Option) error {
+	cfg := config{rate: 20, smoothing: 0.5, width: 64, height: 36, size: 0.3, letterbox: true}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.rate <= 0 || cfg.width <= 0 || cfg.height <= 0 {
+		return fmt.Errorf("ambilight: invalid rate %v or resolution %dx%d", cfg.rate, cfg.width, cfg.height)
+	}
+
+	regions := Regions(channels, cfg.size)
+	var lb letterbox
+	var prev huestream.Frame
+
+	tick := time.NewTicker(time.Duration(float64(time.Second) / cfg.rate))
+	defer tick.Stop()
+	for {
+		img, err := src.Capture(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("ambilight: capture: %w", err)
+		}
+		small := Downscale(img, cfg.width, cfg.height)
+		content := small.Bounds()
+		if cfg.letterbox {
+			content = lb.update(ContentBounds(small))
+		}
+
+		f := make(huestream.Frame, len(regions))
+		for id, r := range regions {
+			f[id] = Sample(small, content, r)
+		}
+		if prev != nil {
+			for id, c := range f {
+				f[id] = blend(c, prev[id], cfg.smoothing)
+			}
+		}
+		prev = f
+
+		if err := st.SendContext(ctx, f); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("ambilight: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+		}
+	}
+}
+
+// letterbox holds the content bounds, changing them only when the same new
+// bounds are detected on several captures in a row: dark scenes and fades
+// would make them flicker otherwise.
+type letterbox struct {
+	bounds    image.Rectangle
+	candidate image.Rectangle
+	seen      int
+}
+
+const letterboxCaptures = 3
+
+func (l *letterbox) update(r image.Rectangle) image.Rectangle {
+	switch {
+	case l.bounds.Empty():
+		l.bounds = r
+	case r == l.bounds:
+		l.candidate, l.seen = image.Rectangle{}, 0
+	case r == l.candidate:
+		l.seen++
+		if l.seen >= letterboxCaptures {
+			l.bounds, l.candidate, l.seen = r, image.Rectangle{}, 0
+		}
+	default:
+		l.candidate, l.seen = r, 1
+	}
+	return l.bounds
+}
+
+// blend returns a weighted mean of c and prev, the weight of prev being w.
+func blend(c, prev color.Color, w float64) color.Color {
+	cr, cg, cb, _ := c.RGBA()
+	pr, pg, pb, _ := prev.RGBA()
+	mean := func(x, y uint32) uint16 { return uint16(float64(x)*(1-w) + float64(y)*w) }
+	return color.RGBA64{R: mean(cr, pr), G: mean(cg, pg), B: mean(cb, pb), A: 0xffff}
+}
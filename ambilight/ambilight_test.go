@@ -0,0 +1,203 @@
+package ambilight
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+// chanStreamer is a fake Streamer passing the frames sent to a channel.
+type chanStreamer chan huestream.Frame
+
+func (c chanStreamer) Send(f huestream.Frame) error { return c.SendContext(context.Background(), f) }
+
+func (c chanStreamer) SendContext(ctx context.Context, f huestream.Frame) error {
+	select {
+	case c <- f:
+	default: // The test does not read it.
+	}
+	return nil
+}
+
+func (c chanStreamer) Close() error { return nil }
+
+// imageSource is a ScreenSource capturing the same image.
+type imageSource struct{ img image.Image }
+
+func (s imageSource) Capture(ctx context.Context) (image.Image, error) { return s.img, nil }
+
+// letterboxed returns a 1280x720 picture, red on its left half and blue on
+// its right one, with black bars of 80 pixels above and below.
+func letterboxed() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 1280, 720))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 80, 640, 640), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(640, 80, 1280, 640), image.NewUniform(color.RGBA{B: 255, A: 255}), image.Point{}, draw.Src)
+	return img
+}
+
+func next(t *testing.T, st chanStreamer) huestream.Frame {
+	t.Helper()
+
+	select {
+	case f := <-st:
+		return f
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame sent")
+		return nil
+	}
+}
+
+func rgb8(c color.Color) [3]uint32 {
+	r, g, b, _ := c.RGBA()
+	return [3]uint32{r >> 8, g >> 8, b >> 8}
+}
+
+func TestRegions(t *testing.T) {
+	regions := Regions([]huestream.Channel{
+		{ID: 0, Position: huestream.Position{X: -1, Y: 1, Z: 0}},
+		{ID: 1, Position: huestream.Position{X: 0, Y: 1, Z: 1}},
+		{ID: 2, Position: huestream.Position{X: 1, Y: -1, Z: -1}},
+		{ID: 3, Position: huestream.Position{X: 0, Y: 0, Z: 0}},
+	}, 0.5)
+	want := map[int]Region{
+		0: {X0: 0, Y0: 0.25, X1: 0.5, Y1: 0.75},
+		1: {X0: 0.25, Y0: 0, X1: 0.75, Y1: 0.5},
+		2: {X0: 0.5, Y0: 0.5, X1: 1, Y1: 1},
+		3: {X0: 0.25, Y0: 0.25, X1: 0.75, Y1: 0.75},
+	}
+	for id, w := range want {
+		if regions[id] != w {
+			t.Errorf("channel %d: got %+v, want %+v", id, regions[id], w)
+		}
+	}
+}
+
+func TestContentBounds(t *testing.T) {
+	small := Downscale(letterboxed(), 64, 36)
+	if got, want := ContentBounds(small), image.Rect(0, 4, 64, 32); got != want {
+		t.Errorf("letterboxed: got %v, want %v", got, want)
+	}
+
+	black := image.NewRGBA(image.Rect(0, 0, 64, 36))
+	if got := ContentBounds(black); got != black.Bounds() {
+		t.Errorf("black: got %v, want the bounds", got)
+	}
+
+	// A small bright spot in a dark scene is not a letterbox.
+	black.Set(32, 18, color.White)
+	if got := ContentBounds(black); got != black.Bounds() {
+		t.Errorf("dark scene: got %v, want the bounds", got)
+	}
+}
+
+func TestLetterboxHysteresis(t *testing.T) {
+	var l letterbox
+	full, boxed := image.Rect(0, 0, 64, 36), image.Rect(0, 4, 64, 32)
+	for i, tc := range []struct {
+		detected, want image.Rectangle
+	}{
+		{full, full},
+		{boxed, full},
+		{full, full}, // A single capture is not enough.
+		{boxed, full},
+		{boxed, full},
+		{boxed, boxed},
+		{boxed, boxed},
+	} {
+		if got := l.update(tc.detected); got != tc.want {
+			t.Errorf("capture %d: got %v, want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestRunScreenSync(t *testing.T) {
+	channels := []huestream.Channel{
+		{ID: 0, Position: huestream.Position{X: -1, Z: 1}},
+		{ID: 1, Position: huestream.Position{X: 1, Z: -1}},
+	}
+	st := make(chanStreamer, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- RunScreenSync(ctx, st, imageSource{letterboxed()}, channels, WithRate(200))
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// The corners are in the bars, the content is sampled instead. The
+	// smoothing starts from the first capture, so every frame is the same.
+	for range 5 {
+		f := next(t, st)
+		if got := rgb8(f[0]); got != [3]uint32{255, 0, 0} {
+			t.Errorf("channel 0 = %v, want red", got)
+		}
+		if got := rgb8(f[1]); got != [3]uint32{0, 0, 255} {
+			t.Errorf("channel 1 = %v, want blue", got)
+		}
+	}
+}
+
+func TestRunScreenSyncSmoothing(t *testing.T) {
+	src := &switchSource{}
+	src.imgs = []image.Image{
+		image.NewUniform(color.White),
+		image.NewUniform(color.Black),
+	}
+	st := make(chanStreamer, 100)
+	channels := []huestream.Channel{{ID: 0}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- RunScreenSync(ctx, st, src, channels, WithRate(1000), WithSmoothing(0.5), WithResolution(4, 4))
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	want := []uint32{255, 127, 63}
+	for i, w := range want {
+		f := next(t, st)
+		if got := rgb8(f[0])[0]; got != w {
+			t.Errorf("frame %d = %d, want %d", i, got, w)
+		}
+	}
+}
+
+// switchSource captures its images in turn, then the last one.
+type switchSource struct {
+	imgs []image.Image
+	n    int
+}
+
+func (s *switchSource) Capture(ctx context.Context) (image.Image, error) {
+	img := s.imgs[min(s.n, len(s.imgs)-1)]
+	s.n++
+	return img, nil
+}
+
+type failingSource struct{}
+
+func (failingSource) Capture(ctx context.Context) (image.Image, error) {
+	return nil, errors.New("no screen")
+}
+
+func TestRunScreenSyncCaptureError(t *testing.T) {
+	err := RunScreenSync(context.Background(), make(chanStreamer), failingSource{}, nil)
+	if err == nil {
+		t.Fatal("RunScreenSync succeeded")
+	}
+}
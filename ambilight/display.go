@@ -0,0 +1,28 @@
+package ambilight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/kbinani/screenshot"
+)
+
+// Display returns a ScreenSource capturing the active display i, from 0,
+// with github.com/kbinani/screenshot: X11, or the screenshot portal on
+// Wayland, on Linux and BSD, and the system APIs on Windows and macOS.
+// The portal may ask the user to allow the captures.
+func Display(i int) ScreenSource { return display(i) }
+
+// errNoDisplay is returned by the captures of missing displays.
+var errNoDisplay = errors.New("no such display")
+
+type display int
+
+func (d display) Capture(ctx context.Context) (image.Image, error) {
+	if n := screenshot.NumActiveDisplays(); int(d) >= n {
+		return nil, fmt.Errorf("display %d: %w, %d active", int(d), errNoDisplay, n)
+	}
+	return screenshot.CaptureDisplay(int(d))
+}
@@ -0,0 +1,22 @@
+module github.com/rschio/huestream/ambilight
+
+go 1.23.2
+
+require (
+	github.com/kbinani/screenshot v0.0.0-20250624051815-089614a94018
+	github.com/rschio/huestream v0.0.0
+)
+
+require (
+	github.com/gen2brain/shm v0.1.0 // indirect
+	github.com/godbus/dbus/v5 v5.1.0 // indirect
+	github.com/jezek/xgb v1.1.1 // indirect
+	github.com/lxn/win v0.0.0-20210218163916-a377121e959e // indirect
+	github.com/pion/dtls/v3 v3.0.4 // indirect
+	github.com/pion/logging v0.2.2 // indirect
+	github.com/pion/transport/v3 v3.0.7 // indirect
+	golang.org/x/crypto v0.28.0 // indirect
+	golang.org/x/sys v0.26.0 // indirect
+)
+
+replace github.com/rschio/huestream => ../
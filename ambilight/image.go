@@ -0,0 +1,109 @@
+package ambilight
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/canvas"
+)
+
+// Region is a part of the picture, in fractions of its width and height
+// from its top left corner.
+type Region = canvas.Region
+
+// Regions maps the channels to the parts of the screen at their positions,
+// as seen from the seat of the entertainment area: x goes from the left
+// edge, at -1, to the right one, at 1, and z from the bottom, at -1, to the
+// top, at 1. The depth y is ignored. Every region is a square of size, a
+// fraction of the width and height, centered on the channel and kept
+// inside the screen: the channels at the edges sample the edges.
+func Regions(channels []huestream.Channel, size float64) map[int]Region {
+	return canvas.Regions(channels, size)
+}
+
+// Downscale returns img scaled down to width x height, every pixel being
+// the mean of a grid of samples of its block. Images smaller than that are
+// scaled up.
+func Downscale(img image.Image, width, height int) *image.RGBA {
+	const grid = 4 // Samples per side of a block.
+
+	b := img.Bounds()
+	src, _ := img.(*image.RGBA)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			var r, g, bl, n uint32
+			for sy := range grid {
+				py := b.Min.Y + (y*grid+sy)*b.Dy()/(height*grid)
+				for sx := range grid {
+					px := b.Min.X + (x*grid+sx)*b.Dx()/(width*grid)
+					if src != nil {
+						i := src.PixOffset(px, py)
+						r += uint32(src.Pix[i])
+						g += uint32(src.Pix[i+1])
+						bl += uint32(src.Pix[i+2])
+					} else {
+						cr, cg, cb, _ := img.At(px, py).RGBA()
+						r, g, bl = r+cr>>8, g+cg>>8, bl+cb>>8
+					}
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/n), uint8(g/n), uint8(bl/n), 255
+		}
+	}
+	return dst
+}
+
+// blackLevel is the highest mean luminance, out of 255, of a black bar.
+const blackLevel = 12
+
+// ContentBounds returns the bounds of img without its black bars, the rows
+// and columns of the edges that are black. A black image, or one whose
+// content would be less than half of it, is not letterboxed: it returns
+// its bounds.
+func ContentBounds(img *image.RGBA) image.Rectangle {
+	b := img.Bounds()
+	dark := func(x0, y0, x1, y1 int) bool {
+		var sum, n int
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				i := img.PixOffset(x, y)
+				sum += luminance(img.Pix[i], img.Pix[i+1], img.Pix[i+2])
+				n++
+			}
+		}
+		return sum <= blackLevel*n
+	}
+
+	r := b
+	for r.Min.Y < r.Max.Y && dark(b.Min.X, r.Min.Y, b.Max.X, r.Min.Y+1) {
+		r.Min.Y++
+	}
+	for r.Max.Y > r.Min.Y && dark(b.Min.X, r.Max.Y-1, b.Max.X, r.Max.Y) {
+		r.Max.Y--
+	}
+	for r.Min.X < r.Max.X && dark(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y) {
+		r.Min.X++
+	}
+	for r.Max.X > r.Min.X && dark(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y) {
+		r.Max.X--
+	}
+	if r.Dx()*2 < b.Dx() || r.Dy()*2 < b.Dy() {
+		return b
+	}
+	return r
+}
+
+// luminance returns the luma of an sRGB color, out of 255.
+func luminance(r, g, b uint8) int {
+	return (299*int(r) + 587*int(g) + 114*int(b)) / 1000
+}
+
+// Sample returns the mean color of the region r of the content bounds of
+// img.
+func Sample(img *image.RGBA, content image.Rectangle, r Region) color.Color {
+	return canvas.Sample(img, content, r)
+}
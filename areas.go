@@ -0,0 +1,310 @@
+package huestream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+)
+
+// Area is an entertainment area, an entertainment configuration of the
+// bridge.
+type Area struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Type     string    `json:"type"`   // As "screen", "music" or "3dspace".
+	Status   string    `json:"status"` // "active" while streamed.
+	Channels []Channel `json:"channels"`
+	Proxy    Proxy     `json:"proxy"`
+}
+
+// Proxy is the entertainment proxy of an Area, the light relaying the
+// stream to the others over Zigbee. A proxy far from the lights of the area
+// adds a hop, and latency, to each of them.
+type Proxy struct {
+	Mode  string `json:"mode"`  // "auto" if chosen by the bridge, or "manual".
+	Node  string `json:"node"`  // ID of the entertainment service of the light.
+	Light string `json:"light"` // Name of the light, empty if unknown.
+}
+
+// Channel is a channel of an Area, the IDs of the frames sent to it.
+type Channel struct {
+	ID       int      `json:"id"`
+	Position Position `json:"position"`
+	Lights   []string `json:"lights"` // The names of the lights rendering it.
+	Members  []Member `json:"members"`
+}
+
+// Member is a light rendering a Channel, or a segment of it for a light
+// with several segments, as a gradient strip.
+type Member struct {
+	Light   string `json:"light"`   // ID of the light.
+	Name    string `json:"name"`    // Name of the light.
+	Segment int    `json:"segment"` // Index of the segment, from 0.
+}
+
+// Position is the position of a channel in the room, as set in the Hue
+// app: x goes from left (-1) to right (1), y from back (-1) to front (1)
+// and z from bottom (-1) to top (1).
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// Areas lists the entertainment areas of the bridge at host with their
+// channels, using the CLIP v2 API.
+func Areas(ctx context.Context, host, username string, opts ...Option) ([]Area, error) {
+	c := newClient(host, username, "")
+	c.cfg = newConfig(opts)
+
+	var areas []Area
+	err := c.traced(ctx, "areas", "", func(ctx context.Context) (err error) {
+		areas, err = c.areas(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("areas: %w", err)
+	}
+	return areas, nil
+}
+
+func (c *client) areas(ctx context.Context) ([]Area, error) {
+	type ref struct {
+		RID string `json:"rid"`
+	}
+	var configs []struct {
+		ID       string `json:"id"`
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Type   string `json:"configuration_type"`
+		Status string `json:"status"`
+		Proxy  struct {
+			Mode string `json:"mode"`
+			Node ref    `json:"node"`
+		} `json:"stream_proxy"`
+		Channels []struct {
+			ID       int      `json:"channel_id"`
+			Position Position `json:"position"`
+			Members  []struct {
+				Service ref `json:"service"`
+				Index   int `json:"index"`
+			} `json:"members"`
+		} `json:"channels"`
+	}
+	if err := c.getResource(ctx, "entertainment_configuration", &configs); err != nil {
+		return nil, err
+	}
+
+	// The members of the channels are entertainment services, owned by
+	// the device of the light.
+	var services []struct {
+		ID    string `json:"id"`
+		Owner ref    `json:"owner"`
+	}
+	if err := c.getResource(ctx, "entertainment", &services); err != nil {
+		return nil, err
+	}
+	var lights []light
+	if err := c.getResource(ctx, "light", &lights); err != nil {
+		return nil, err
+	}
+	byDevice := make(map[string]light)
+	for _, l := range lights {
+		byDevice[l.Owner.RID] = l
+	}
+	owners := make(map[string]string) // By entertainment service.
+	for _, s := range services {
+		owners[s.ID] = s.Owner.RID
+	}
+	// lightOf returns the light of an entertainment service.
+	lightOf := func(service string) (light, bool) {
+		l, ok := byDevice[owners[service]]
+		return l, ok
+	}
+
+	areas := make([]Area, 0, len(configs))
+	for _, cfg := range configs {
+		a := Area{ID: cfg.ID, Name: cfg.Metadata.Name, Type: cfg.Type, Status: cfg.Status}
+		a.Proxy = Proxy{Mode: cfg.Proxy.Mode, Node: cfg.Proxy.Node.RID}
+		if l, ok := lightOf(cfg.Proxy.Node.RID); ok {
+			a.Proxy.Light = l.Metadata.Name
+		}
+		for _, ch := range cfg.Channels {
+			channel := Channel{ID: ch.ID, Position: ch.Position}
+			for _, m := range ch.Members {
+				if l, ok := lightOf(m.Service.RID); ok {
+					channel.Lights = append(channel.Lights, l.Metadata.Name)
+					channel.Members = append(channel.Members, Member{Light: l.ID, Name: l.Metadata.Name, Segment: m.Index})
+				}
+			}
+			a.Channels = append(a.Channels, channel)
+		}
+		areas = append(areas, a)
+	}
+	return areas, nil
+}
+
+// The configuration types of an Area, which set how the Hue app syncs it.
+var areaTypes = []string{"screen", "monitor", "music", "3dspace", "other"}
+
+// AreaUpdate is a change of the configuration of an Area, its zero fields
+// are left as they are.
+type AreaUpdate struct {
+	Name      string            // Up to 32 bytes.
+	Type      string            // "screen", "monitor", "music", "3dspace" or "other".
+	Locations []ServiceLocation // Replace the locations of the lights.
+}
+
+// ServiceLocation is the location of a light of an Area, its entertainment
+// service.
+type ServiceLocation struct {
+	Service   string     // ID of the entertainment service of the light.
+	Positions []Position // One, or two for the ends of a gradient light.
+
+	// Equalization scales the brightness of the light in the sync of the
+	// Hue app, from 0 to 1.
+	Equalization float64
+}
+
+func (u AreaUpdate) validate() error {
+	if u.Name == "" && u.Type == "" && u.Locations == nil {
+		return errors.New("empty area update")
+	}
+	if len(u.Name) > 32 {
+		return fmt.Errorf("area name %q longer than 32 bytes", u.Name)
+	}
+	if u.Type != "" && !slices.Contains(areaTypes, u.Type) {
+		return fmt.Errorf("unknown area type %q", u.Type)
+	}
+	for _, l := range u.Locations {
+		if l.Service == "" {
+			return errors.New("location without service")
+		}
+		if len(l.Positions) == 0 || len(l.Positions) > 2 {
+			return fmt.Errorf("service %s: %d positions, want 1 or 2", l.Service, len(l.Positions))
+		}
+		for _, p := range l.Positions {
+			if math.Abs(p.X) > 1 || math.Abs(p.Y) > 1 || math.Abs(p.Z) > 1 {
+				return fmt.Errorf("service %s: position %+v out of [-1, 1]", l.Service, p)
+			}
+		}
+		if l.Equalization < 0 || l.Equalization > 1 {
+			return fmt.Errorf("service %s: equalization %v out of [0, 1]", l.Service, l.Equalization)
+		}
+	}
+	return nil
+}
+
+// UpdateArea changes the configuration of the area areaID, using the CLIP
+// v2 API.
+func UpdateArea(ctx context.Context, host, username, areaID string, u AreaUpdate, opts ...Option) error {
+	if err := u.validate(); err != nil {
+		return fmt.Errorf("update area %s: %w", areaID, err)
+	}
+	c := newClient(host, username, "")
+	c.cfg = newConfig(opts)
+
+	type ref struct {
+		RID   string `json:"rid"`
+		RType string `json:"rtype"`
+	}
+	type location struct {
+		Service      ref        `json:"service"`
+		Positions    []Position `json:"positions"`
+		Equalization float64    `json:"equalization_factor"`
+	}
+	var body struct {
+		Metadata *struct {
+			Name string `json:"name"`
+		} `json:"metadata,omitempty"`
+		Type      string `json:"configuration_type,omitempty"`
+		Locations *struct {
+			ServiceLocations []location `json:"service_locations"`
+		} `json:"locations,omitempty"`
+	}
+	if u.Name != "" {
+		body.Metadata = &struct {
+			Name string `json:"name"`
+		}{u.Name}
+	}
+	body.Type = u.Type
+	if u.Locations != nil {
+		body.Locations = &struct {
+			ServiceLocations []location `json:"service_locations"`
+		}{make([]location, len(u.Locations))}
+		for i, l := range u.Locations {
+			body.Locations.ServiceLocations[i] = location{
+				Service:      ref{RID: l.Service, RType: "entertainment"},
+				Positions:    l.Positions,
+				Equalization: l.Equalization,
+			}
+		}
+	}
+
+	err := c.traced(ctx, "update area", areaID, func(ctx context.Context) error {
+		return c.putResource(ctx, "entertainment_configuration", areaID, body)
+	})
+	if err != nil {
+		return fmt.Errorf("update area %s: %w", areaID, err)
+	}
+	return nil
+}
+
+// getResource decodes the data of the CLIP v2 resource list of rtype into
+// v.
+func (c *client) getResource(ctx context.Context, rtype string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.apiURL()+"/clip/v2/resource/"+rtype, nil)
+	if err != nil {
+		return err
+	}
+	c.setAuthHeader(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Code: resp.StatusCode}
+	}
+	body := struct {
+		Data any `json:"data"`
+	}{Data: v}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("%s: %w", rtype, err)
+	}
+	return nil
+}
+
+// putResource updates the CLIP v2 resource id of rtype with the JSON
+// encoding of v.
+func (c *client) putResource(ctx context.Context, rtype, id string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	url := c.apiURL() + "/clip/v2/resource/" + rtype + "/" + id
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	c.setAuthHeader(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Code: resp.StatusCode}
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+// Package artnet receives the DMX data of a lighting console over Art-Net
+// and streams it to an entertainment area, so that the Hue lights join a
+// lighting rig:
+//
+//	r, err := artnet.Listen(":6454", 0, artnet.Patch{0: 1, 1: 4, 2: 7})
+//	...
+//	err = r.Run(ctx, stream)
+//
+// A Patch maps every Hue channel to the DMX address of its red slot, green
+// and blue being the next two slots. The frames are sent at most at the
+// rate of the Receiver, the latest data wins. When the console stops
+// sending for the timeout the channels are set to black, as a console
+// turned off would leave a DMX rig.
+package artnet
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/wire"
+)
+
+// Art-Net constants of ArtDmx packets.
+const (
+	opDmx        = 0x5000
+	headerSize   = 18
+	protoVersion = 14
+	dmxSlots     = 512
+)
+
+var artNetID = []byte("Art-Net\x00")
+
+// Patch maps the Hue channel IDs to DMX addresses, from 1 to 510, of their
+// red slot.
+type Patch map[int]int
+
+// Stats holds counters about a Receiver.
+type Stats struct {
+	Packets    uint64 // ArtDmx packets of the universe received.
+	Lost       uint64 // Packets missing from the sequence.
+	OutOfOrder uint64 // Packets older than the last one, dropped.
+	Invalid    uint64 // Datagrams that are not valid ArtDmx packets.
+	FramesSent uint64
+	Timeouts   uint64 // Times the console stopped and the lights went black.
+}
+
+// Option configures a Receiver.
+type Option func(*Receiver)
+
+// WithRate sets the maximum rate of the frames, by default 50 Hz.
+func WithRate(hz float64) Option {
+	return func(r *Receiver) { r.period = time.Duration(float64(time.Second) / hz) }
+}
+
+// WithTimeout sets the time without packets after which the channels are
+// set to black, by default 4s. Zero keeps the last colors.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Receiver) { r.timeout = d }
+}
+
+// Receiver receives the ArtDmx packets of a universe.
+type Receiver struct {
+	conn     net.PacketConn
+	universe uint16
+	patch    Patch
+	period   time.Duration
+	timeout  time.Duration
+
+	packets, lost, outOfOrder, invalid, framesSent, timeouts atomic.Uint64
+}
+
+// Listen listens for the packets of the Art-Net universe, the 15-bit port
+// address, on the UDP address addr, usually ":6454".
+func Listen(addr string, universe uint16, patch Patch, opts ...Option) (*Receiver, error) {
+	if len(patch) > wire.MaxChannels {
+		return nil, fmt.Errorf("artnet: %w: maximum is %d, got %d", huestream.ErrTooManyChannels, wire.MaxChannels, len(patch))
+	}
+	for id, a := range patch {
+		if a < 1 || a > dmxSlots-2 {
+			return nil, fmt.Errorf("artnet: channel %d: DMX address %d out of 1 to %d", id, a, dmxSlots-2)
+		}
+	}
+	if universe > 0x7fff {
+		return nil, fmt.Errorf("artnet: universe %d out of 0 to 32767", universe)
+	}
+
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("artnet: %w", err)
+	}
+	r := &Receiver{
+		conn:     conn,
+		universe: universe,
+		patch:    patch,
+		period:   time.Second / 50,
+		timeout:  4 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// Addr returns the address the Receiver listens on.
+func (r *Receiver) Addr() net.Addr { return r.conn.LocalAddr() }
+
+// Stats returns the counters of the Receiver.
+func (r *Receiver) Stats() Stats {
+	return Stats{
+		Packets:    r.packets.Load(),
+		Lost:       r.lost.Load(),
+		OutOfOrder: r.outOfOrder.Load(),
+		Invalid:    r.invalid.Load(),
+		FramesSent: r.framesSent.Load(),
+		Timeouts:   r.timeouts.Load(),
+	}
+}
+
+// Run streams the received data to st until ctx is done or a send fails.
+// The Receiver stops listening when Run returns, it returns nil when ctx is
+// done.
+func (r *Receiver) Run(ctx context.Context, st huestream.Streamer) error {
+	// pending holds the last frame not sent yet.
+	pending := make(chan huestream.Frame, 1)
+	readErr := make(chan error, 1)
+	go func() { readErr <- r.read(pending) }()
+	reading := true
+	defer func() {
+		r.conn.Close()
+		if reading {
+			<-readErr
+		}
+	}()
+
+	var timeout <-chan time.Time
+	var last time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			reading = false
+			return fmt.Errorf("artnet: %w", err)
+		case <-timeout:
+			timeout = nil
+			r.timeouts.Add(1)
+			if err := r.send(ctx, st, r.black()); err != nil {
+				return err
+			}
+		case f := <-pending:
+			if wait := r.period - time.Since(last); wait > 0 {
+				t := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					t.Stop()
+					return nil
+				case <-t.C:
+				}
+				// A newer frame may have arrived while waiting.
+				select {
+				case f = <-pending:
+				default:
+				}
+			}
+			last = time.Now()
+			if err := r.send(ctx, st, f); err != nil {
+				return err
+			}
+			if r.timeout > 0 {
+				timeout = time.After(r.timeout - time.Since(last))
+			}
+		}
+	}
+}
+
+func (r *Receiver) send(ctx context.Context, st huestream.Streamer, f huestream.Frame) error {
+	if err := st.SendContext(ctx, f); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("artnet: %w", err)
+	}
+	r.framesSent.Add(1)
+	return nil
+}
+
+// read reads the packets until the connection is closed, and puts the
+// frame of the last one in pending.
+func (r *Receiver) read(pending chan huestream.Frame) error {
+	var dmx [dmxSlots]byte // The slots received, partial packets update them.
+	var seq byte           // The sequence of the last packet, 0 if none.
+	buf := make([]byte, headerSize+dmxSlots)
+	for {
+		n, _, err := r.conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p, ok := parseDmx(buf[:n])
+		if !ok {
+			r.invalid.Add(1)
+			continue
+		}
+		if p.universe != r.universe {
+			continue
+		}
+		if p.sequence != 0 && seq != 0 {
+			// The sequence goes from 1 to 255 and wraps, a packet up to
+			// half of it behind the last one is late.
+			d := (int(p.sequence) - int(seq) + 255) % 255
+			if d == 0 || d > 127 {
+				r.outOfOrder.Add(1)
+				continue
+			}
+			r.lost.Add(uint64(d - 1))
+		}
+		if p.sequence != 0 {
+			seq = p.sequence
+		}
+		r.packets.Add(1)
+
+		copy(dmx[:], p.data)
+		f := make(huestream.Frame, len(r.patch))
+		for id, a := range r.patch {
+			f[id] = color.RGBA{R: dmx[a-1], G: dmx[a], B: dmx[a+1], A: 255}
+		}
+		// Replace the pending frame, if any.
+		select {
+		case <-pending:
+		default:
+		}
+		pending <- f
+	}
+}
+
+func (r *Receiver) black() huestream.Frame {
+	f := make(huestream.Frame, len(r.patch))
+	for id := range r.patch {
+		f[id] = color.Black
+	}
+	return f
+}
+
+type dmxPacket struct {
+	sequence byte
+	universe uint16
+	data     []byte
+}
+
+// parseDmx parses an ArtDmx packet.
+func parseDmx(b []byte) (dmxPacket, bool) {
+	if len(b) < headerSize || !bytes.Equal(b[:8], artNetID) ||
+		binary.LittleEndian.Uint16(b[8:]) != opDmx ||
+		binary.BigEndian.Uint16(b[10:]) < protoVersion {
+		return dmxPacket{}, false
+	}
+	n := int(binary.BigEndian.Uint16(b[16:]))
+	if n < 2 || n > dmxSlots || len(b) < headerSize+n {
+		return dmxPacket{}, false
+	}
+	return dmxPacket{
+		sequence: b[12],
+		universe: uint16(b[15]&0x7f)<<8 | uint16(b[14]),
+		data:     b[headerSize : headerSize+n],
+	}, true
+}
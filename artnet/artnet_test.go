@@ -0,0 +1,162 @@
+package artnet
+
+import (
+	"context"
+	"encoding/binary"
+	"image/color"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+// chanStreamer is a fake Streamer passing the frames sent to a channel.
+type chanStreamer chan huestream.Frame
+
+func (c chanStreamer) Send(f huestream.Frame) error { return c.SendContext(context.Background(), f) }
+
+func (c chanStreamer) SendContext(ctx context.Context, f huestream.Frame) error {
+	select {
+	case c <- f:
+	default: // The test does not read it.
+	}
+	return nil
+}
+
+func (c chanStreamer) Close() error { return nil }
+
+func dmxPacketBytes(seq byte, universe uint16, data []byte) []byte {
+	b := append([]byte("Art-Net\x00"), 0, 0x50, 0, 14, seq, 0, byte(universe), byte(universe>>8), 0, 0)
+	binary.BigEndian.PutUint16(b[16:], uint16(len(data)))
+	return append(b, data...)
+}
+
+// start runs a Receiver on a local port and returns a connection to it.
+func start(t *testing.T, patch Patch, opts ...Option) (*Receiver, net.Conn, chanStreamer) {
+	t.Helper()
+
+	r, err := Listen("127.0.0.1:0", 1, patch, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := make(chanStreamer, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx, st) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	conn, err := net.Dial("udp", r.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return r, conn, st
+}
+
+func next(t *testing.T, st chanStreamer) huestream.Frame {
+	t.Helper()
+
+	select {
+	case f := <-st:
+		return f
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame sent")
+		return nil
+	}
+}
+
+func rgb(c color.Color) [3]uint32 {
+	r, g, b, _ := c.RGBA()
+	return [3]uint32{r >> 8, g >> 8, b >> 8}
+}
+
+func TestReceive(t *testing.T) {
+	_, conn, st := start(t, Patch{0: 1, 1: 4})
+
+	conn.Write(dmxPacketBytes(0, 1, []byte{255, 0, 0, 0, 128, 255}))
+	f := next(t, st)
+	if rgb(f[0]) != [3]uint32{255, 0, 0} || rgb(f[1]) != [3]uint32{0, 128, 255} {
+		t.Errorf("got %v", f)
+	}
+
+	// Packets of other universes and invalid ones are ignored.
+	conn.Write(dmxPacketBytes(0, 2, []byte{0, 0, 0, 0, 0, 0}))
+	conn.Write([]byte("not Art-Net"))
+	// A partial packet updates its slots only.
+	conn.Write(dmxPacketBytes(0, 1, []byte{0, 0}))
+	f = next(t, st)
+	if rgb(f[0]) != [3]uint32{0, 0, 0} || rgb(f[1]) != [3]uint32{0, 128, 255} {
+		t.Errorf("after a partial packet: got %v", f)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	r, conn, st := start(t, Patch{0: 1})
+
+	// 1 is lost then late, 3 and 4 are lost.
+	for _, seq := range []byte{254, 255, 2, 1, 5} {
+		conn.Write(dmxPacketBytes(seq, 1, []byte{seq, 0}))
+		time.Sleep(10 * time.Millisecond)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Stats().Packets+r.Stats().OutOfOrder < 5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	got := r.Stats()
+	if got.Packets != 4 || got.OutOfOrder != 1 || got.Lost != 3 {
+		t.Errorf("got %+v, want 4 packets, 1 out of order and 3 lost", got)
+	}
+	for f := next(t, st); rgb(f[0])[0] != 5; f = next(t, st) {
+		if rgb(f[0])[0] == 1 {
+			t.Fatal("the late packet was sent")
+		}
+	}
+}
+
+func TestTimeoutToBlack(t *testing.T) {
+	r, conn, st := start(t, Patch{0: 1}, WithTimeout(50*time.Millisecond))
+
+	conn.Write(dmxPacketBytes(0, 1, []byte{255, 255, 255}))
+	if f := next(t, st); rgb(f[0]) != [3]uint32{255, 255, 255} {
+		t.Errorf("got %v, want white", f)
+	}
+	if f := next(t, st); rgb(f[0]) != [3]uint32{0, 0, 0} {
+		t.Errorf("got %v, want black after the timeout", f)
+	}
+	if got := r.Stats().Timeouts; got != 1 {
+		t.Errorf("Timeouts = %d, want 1", got)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	_, conn, st := start(t, Patch{0: 1}, WithRate(5), WithTimeout(0))
+
+	for i := range 20 {
+		conn.Write(dmxPacketBytes(0, 1, []byte{byte(i + 1), 0}))
+	}
+	time.Sleep(500 * time.Millisecond)
+	if n := len(st); n == 0 || n > 3 {
+		t.Fatalf("sent %d frames, want 1 to 3", n)
+	}
+	var last huestream.Frame
+	for len(st) > 0 {
+		last = <-st
+	}
+	if got := rgb(last[0])[0]; got != 20 {
+		t.Errorf("last frame red %d, want the last one received 20", got)
+	}
+}
+
+func TestListenInvalidPatch(t *testing.T) {
+	for _, p := range []Patch{{0: 0}, {0: 511}} {
+		if _, err := Listen("127.0.0.1:0", 0, p); err == nil {
+			t.Errorf("Listen accepted %v", p)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package huestream
+
+import (
+	"image/color"
+	"testing"
+)
+
+// fullFrame has the maximum number of channels.
+func fullFrame() Frame {
+	f := make(Frame, 20)
+	for i := range 20 {
+		f[i] = color.RGBA{R: uint8(i * 12), G: 128, B: 255 - uint8(i*12), A: 255}
+	}
+	return f
+}
+
+// discardStream returns a Stream whose writes always succeed.
+func discardStream() *Stream {
+	discard := &funcConn{write: func(b []byte) (int, error) { return len(b), nil }}
+	return newStream(discard, nil, testAreaID, newConfig(nil))
+}
+
+func BenchmarkMarshalBinary(b *testing.B) {
+	m := message{areaID: testAreaID, idColors: fullFrame()}
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := m.MarshalBinary(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSend(b *testing.B) {
+	s := discardStream()
+	f := fullFrame()
+	b.ReportAllocs()
+	for range b.N {
+		if err := s.Send(f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// sendAllocBudget is the number of allocations allowed for a steady state
+// Send: the sorted channel IDs, the channels and the message buffer.
+const sendAllocBudget = 3
+
+func TestSendAllocs(t *testing.T) {
+	s := discardStream()
+	f := fullFrame()
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := s.Send(f); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > sendAllocBudget {
+		t.Errorf("Send allocates %v times per frame, the budget is %d", allocs, sendAllocBudget)
+	}
+}
@@ -0,0 +1,650 @@
+//go:build !e2e
+
+package huestream_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huetest"
+	"github.com/rschio/huestream/wire"
+)
+
+// These tests run the e2e scenarios against a fake bridge, build with the
+// e2e tag to run them against a real one. The CLIP answers recorded by the
+// e2e tests with HUESTREAM_RECORD=1, if any, are replayed by the fake.
+
+// newBridge returns a fake bridge replaying the recording of the test.
+func newBridge(t *testing.T) *huetest.Bridge {
+	t.Helper()
+
+	b := huetest.NewBridge(t)
+	rec, err := huetest.LoadRecording(recordingPath(t))
+	switch {
+	case err == nil:
+		b.Replay(rec)
+	case !errors.Is(err, fs.ErrNotExist):
+		t.Fatal(err)
+	}
+	return b
+}
+
+func start(t *testing.T, b *huetest.Bridge) *huestream.Stream {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, b.Options()...)
+	if err != nil {
+		t.Fatalf("huestream.Start: %v", err)
+	}
+	return stream
+}
+
+func nextFrame(t *testing.T, b *huetest.Bridge) wire.Frame {
+	t.Helper()
+
+	select {
+	case f := <-b.Frames():
+		return f
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame received")
+		return wire.Frame{}
+	}
+}
+
+func TestNilMessage(t *testing.T) {
+	b := newBridge(t)
+	stream := start(t, b)
+	defer stream.Close()
+
+	if err := stream.Send(nil); err != nil {
+		t.Fatal(err)
+	}
+	if f := nextFrame(t, b); f.AreaID != b.AreaID || len(f.Channels) != 0 {
+		t.Errorf("received %+v, want an empty frame of the area", f)
+	}
+}
+
+func TestMoreThan20Channels(t *testing.T) {
+	b := newBridge(t)
+	stream := start(t, b)
+	defer stream.Close()
+
+	m := make(map[int]color.Color)
+	for i := range 21 {
+		m[i] = color.White
+	}
+
+	if err := stream.Send(m); err == nil {
+		t.Error("should reject more than 20 channelIDs")
+	}
+}
+
+func TestOnlyOneLamp(t *testing.T) {
+	b := newBridge(t)
+	stream := start(t, b)
+	defer stream.Close()
+
+	for i := range colors {
+		if err := stream.Send(map[int]color.Color{1: colors[i][1]}); err != nil {
+			t.Fatal(err)
+		}
+		f := nextFrame(t, b)
+		if len(f.Channels) != 1 || f.Channels[0].ID != 1 {
+			t.Fatalf("received %+v, want only channel 1", f.Channels)
+		}
+		r, g, bl, _ := colors[i][1].RGBA()
+		if want := [3]uint16{uint16(r), uint16(g), uint16(bl)}; f.Channels[0].Values != want {
+			t.Errorf("channel 1 is %v, want %v", f.Channels[0].Values, want)
+		}
+	}
+}
+
+func TestE2E(t *testing.T) {
+	b := newBridge(t)
+
+	// Call test to 2x in sequence to see if it's closing the connection correctly
+	// and releasing the resources for a second connection.
+	testE2E(t, b)
+	testE2E(t, b)
+
+	var actions []string
+	for _, r := range b.Requests() {
+		actions = append(actions, r.Method+" "+r.Body)
+	}
+	want := `[GET  PUT {"action":"start"} PUT {"action":"stop"} GET  PUT {"action":"start"} PUT {"action":"stop"}]`
+	if got := fmt.Sprint(actions); got != want {
+		t.Errorf("requests %s, want %s", got, want)
+	}
+}
+
+func testE2E(t *testing.T, b *huetest.Bridge) {
+	stream := start(t, b)
+	if !b.Active() {
+		t.Error("stream not active after Start")
+	}
+
+	for i := range colors {
+		if err := stream.Send(colors[i]); err != nil {
+			t.Fatal(err)
+		}
+		if f := nextFrame(t, b); len(f.Channels) != len(colors[i]) {
+			t.Errorf("received %d channels, want %d", len(f.Channels), len(colors[i]))
+		}
+	}
+
+	if err := stream.Close(); err != nil {
+		t.Errorf("stream.Close: %v", err)
+	}
+	if b.Active() {
+		t.Error("stream still active after Close")
+	}
+}
+
+func TestStartFailure(t *testing.T) {
+	b := huetest.NewBridge(t)
+	b.SetStatus("PUT", 503)
+
+	_, err := huestream.Start(context.Background(), b.Host, b.Username, b.ClientKey, b.AreaID, b.Options()...)
+	if err == nil {
+		t.Fatal("Start should fail when the bridge refuses the start action")
+	}
+}
+
+func TestAutoClose(t *testing.T) {
+	for _, auto := range []bool{false, true} {
+		t.Run(fmt.Sprint("auto=", auto), func(t *testing.T) {
+			b := huetest.NewBridge(t)
+			opts := b.Options()
+			if auto {
+				opts = append(opts, huestream.WithAutoClose())
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			stream, err := huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+
+			cancel()
+			deadline := time.Now().Add(5 * time.Second)
+			for auto && b.Active() && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if b.Active() == auto {
+				t.Fatalf("stream active %v after the start context was canceled, want %v", b.Active(), !auto)
+			}
+
+			err = stream.Send(huestream.Frame{0: color.White})
+			if auto && !errors.Is(err, huestream.ErrClosed) {
+				t.Errorf("Send after the auto close returned %v, want ErrClosed", err)
+			}
+			if !auto && err != nil {
+				t.Errorf("Send after the start context was canceled: %v", err)
+			}
+			if err := stream.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+		})
+	}
+}
+
+func TestDetachHandover(t *testing.T) {
+	b := huetest.NewBridge(t)
+	old, err := huestream.Start(context.Background(), b.Host, b.Username, b.ClientKey, b.AreaID, b.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Send(huestream.Frame{0: color.White}); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Detach(); err != nil {
+		t.Fatalf("Detach: %v", err)
+	}
+	if !b.Active() {
+		t.Fatal("stream stopped by Detach")
+	}
+	if err := old.Send(huestream.Frame{0: color.White}); !errors.Is(err, huestream.ErrClosed) {
+		t.Errorf("Send after Detach returned %v, want ErrClosed", err)
+	}
+	if err := old.Close(); err != nil || !b.Active() {
+		t.Fatalf("Close after Detach: %v, active %v, want a no-op", err, b.Active())
+	}
+
+	// The new process takes the session over.
+	opts := append(b.Options(), huestream.WithExternalLifecycle())
+	next, err := huestream.Start(context.Background(), b.Host, b.Username, b.ClientKey, b.AreaID, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := color.RGBA{R: 0xff, A: 0xff}
+	if err := next.Send(huestream.Frame{0: red}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		select {
+		case f := <-b.Frames():
+			if len(f.Channels) == 0 || f.Channels[0].Values != [3]uint16{0xffff, 0, 0} {
+				continue // Sent by the old Stream.
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no frame of the new Stream received")
+		}
+		break
+	}
+	if err := next.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Active() {
+		t.Error("stream stopped by Close with WithExternalLifecycle")
+	}
+}
+
+func TestPlaySeqFakeClock(t *testing.T) {
+	b := huetest.NewBridge(t)
+	clk := huetest.NewClock(time.Unix(0, 0))
+
+	opts := append(b.Options(), clk.Option(), huestream.WithIdleThreshold(0))
+	stream, err := huestream.Start(context.Background(), b.Host, b.Username, b.ClientKey, b.AreaID, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	// Play 30 seconds at 50Hz, a frame per tick of the clock.
+	const n = 30 * 50
+	seq := func(yield func(huestream.Frame) bool) {
+		for i := 0; ; i++ {
+			if !yield(huestream.Frame{i % 10: color.White}) {
+				return
+			}
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() { errc <- stream.PlaySeq(ctx, seq, 50) }()
+
+	for i := range n {
+		clk.BlockUntil(1)
+		clk.Advance(20 * time.Millisecond)
+		if f := nextFrame(t, b); len(f.Channels) != 1 || int(f.Channels[0].ID) != i%10 {
+			t.Fatalf("frame %d has channels %+v", i, f.Channels)
+		}
+	}
+	clk.BlockUntil(1)
+	cancel()
+
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("PlaySeq returned %v, want %v", err, context.Canceled)
+	}
+	if got := stream.Stats().FramesSent; got != n {
+		t.Errorf("sent %d frames, want %d", got, n)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 30*time.Second {
+		t.Errorf("clock at %v, want 30s", got)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	bridge := huetest.NewBridge(t)
+	rec := huetest.NewRecorder(t, bridge.URL(), bridge.Username, bridge.ClientKey, bridge.AreaID)
+
+	opts := append(bridge.Options(), rec.Options()...)
+	stream, err := huestream.Start(context.Background(), bridge.Host, bridge.Username, bridge.ClientKey, bridge.AreaID, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(colors[0]); err != nil {
+		t.Fatal(err)
+	}
+	nextFrame(t, bridge)
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "rec.json")
+	if err := rec.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{bridge.Username, bridge.ClientKey, bridge.AreaID} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("recording holds %q:\n%s", secret, raw)
+		}
+	}
+	recording, err := huetest.LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := recording.Events; len(ev) < 3 || ev[0].Kind != "dial" || ev[len(ev)-1].Kind != "close" {
+		t.Errorf("stream events %+v, want a dial, records and a close", ev)
+	}
+
+	// Replay the recording with another area.
+	fake := huetest.NewBridge(t)
+	fake.AreaID = "5b8f4c34-98b8-4c8e-a7b5-6dd3d1b5c0e4"
+	fake.Replay(recording)
+
+	stream, err = huestream.Start(context.Background(), fake.Host, fake.Username, fake.ClientKey, fake.AreaID, fake.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fake.Active() {
+		t.Error("replayed start did not activate the area")
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(fake.Requests()), len(recording.Exchanges); got != want {
+		t.Errorf("replayed %d requests, recorded %d", got, want)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	b := huetest.NewBridge(t)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	opts := append(b.Options(), huestream.WithLogger(logger))
+	stream, err := huestream.Start(context.Background(), b.Host, b.Username, b.ClientKey, b.AreaID, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := buf.String()
+	for _, want := range []string{
+		"msg=\"stream action\" bridge_host=127.0.0.1 action=start area_id=" + b.AreaID,
+		"msg=\"handshake completed\" bridge_host=127.0.0.1 duration=",
+		"msg=\"stream started\" area_id=" + b.AreaID + " bridge_host=127.0.0.1 version=2",
+		"action=stop",
+		"msg=\"stream closed\"",
+		"component=dtls",
+	} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("logs do not contain %q:\n%s", want, logs)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Unix(0, 0))
+	defer cancel()
+
+	tests := []struct {
+		name string
+		// start returns the error of Start, or of the use of the Stream.
+		start func(t *testing.T, b *huetest.Bridge) error
+		want  error
+	}{{
+		name: "unauthorized",
+		start: func(t *testing.T, b *huetest.Bridge) error {
+			_, err := huestream.Start(context.Background(), b.Host, "intruder", b.ClientKey, b.AreaID, b.Options()...)
+			return err
+		},
+		want: huestream.ErrUnauthorized,
+	}, {
+		name: "area not found",
+		start: func(t *testing.T, b *huetest.Bridge) error {
+			_, err := huestream.Start(context.Background(), b.Host, b.Username, b.ClientKey, "missing", b.Options()...)
+			return err
+		},
+		want: huestream.ErrAreaNotFound,
+	}, {
+		name: "stream active",
+		start: func(t *testing.T, b *huetest.Bridge) error {
+			b.SetStatus("PUT", http.StatusConflict)
+			_, err := huestream.Start(context.Background(), b.Host, b.Username, b.ClientKey, b.AreaID, b.Options()...)
+			return err
+		},
+		want: huestream.ErrStreamActive,
+	}, {
+		name: "invalid client key",
+		start: func(t *testing.T, b *huetest.Bridge) error {
+			_, err := huestream.Start(context.Background(), b.Host, b.Username, "not hex", b.AreaID, b.Options()...)
+			return err
+		},
+		want: huestream.ErrInvalidClientKey,
+	}, {
+		name: "invalid WithClientKey",
+		start: func(t *testing.T, b *huetest.Bridge) error {
+			opts := append(b.Options(), huestream.WithClientKey([]byte("short")))
+			_, err := huestream.Start(context.Background(), b.Host, b.Username, "", b.AreaID, opts...)
+			return err
+		},
+		want: huestream.ErrInvalidClientKey,
+	}, {
+		name: "timeout",
+		start: func(t *testing.T, b *huetest.Bridge) error {
+			_, err := huestream.Start(expired, b.Host, b.Username, b.ClientKey, b.AreaID, b.Options()...)
+			var timeout *huestream.TimeoutError
+			if !errors.As(err, &timeout) || timeout.Op != "start" {
+				return fmt.Errorf("%v is not a start *TimeoutError", err)
+			}
+			return err
+		},
+		want: context.DeadlineExceeded,
+	}, {
+		name: "too many channels",
+		start: func(t *testing.T, b *huetest.Bridge) error {
+			stream := start(t, b)
+			defer stream.Close()
+			f := make(huestream.Frame)
+			for i := range 21 {
+				f[i] = color.White
+			}
+			return stream.Send(f)
+		},
+		want: huestream.ErrTooManyChannels,
+	}, {
+		name: "closed",
+		start: func(t *testing.T, b *huetest.Bridge) error {
+			stream := start(t, b)
+			stream.Close()
+			return stream.Send(nil)
+		},
+		want: huestream.ErrClosed,
+	}, {
+		name: "idle",
+		start: func(t *testing.T, b *huetest.Bridge) error {
+			errs := make(chan error, 16)
+			opts := append(b.Options(),
+				huestream.WithIdleThreshold(10*time.Millisecond),
+				huestream.WithErrorHandler(func(err error) { errs <- err }),
+			)
+			stream, err := huestream.Start(context.Background(), b.Host, b.Username, b.ClientKey, b.AreaID, opts...)
+			if err != nil {
+				return err
+			}
+			defer stream.Close()
+			select {
+			case err := <-errs:
+				return err
+			case <-time.After(5 * time.Second):
+				return nil
+			}
+		},
+		want: huestream.ErrStreamIdle,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.start(t, huetest.NewBridge(t))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want an error wrapping %v", err, tt.want)
+			}
+		})
+	}
+}
+
+var colors = [...]map[int]color.Color{
+	{
+		0: color.RGBA{R: 87, G: 139, B: 45},
+		1: color.RGBA{R: 163, G: 173, B: 193},
+		2: color.RGBA{R: 115, G: 37, B: 178},
+	},
+	{
+		0: color.RGBA{R: 236, G: 210, B: 224},
+		1: color.RGBA{R: 98, G: 42, B: 29},
+		2: color.RGBA{R: 185, G: 65, B: 73},
+	},
+	{
+		0: color.RGBA{R: 178, G: 154, B: 78},
+		1: color.RGBA{R: 252, G: 68, B: 165},
+		2: color.RGBA{R: 243, G: 223, B: 137},
+	},
+}
+
+func TestRegister(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	_, err := huestream.Register(context.Background(), b.Host, "huestream#test", b.Options()...)
+	if !errors.Is(err, huestream.ErrLinkButton) {
+		t.Fatalf("Register before the link button: got %v, want ErrLinkButton", err)
+	}
+
+	b.PressLinkButton()
+	creds, err := huestream.Register(context.Background(), b.Host, "huestream#test", b.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := huestream.Credentials{Host: b.Host, Username: b.Username, ClientKey: b.ClientKey}
+	if creds != want {
+		t.Errorf("got %+v, want %+v", creds, want)
+	}
+}
+
+func TestAreas(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	areas, err := huestream.Areas(context.Background(), b.Host, b.Username, b.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(areas) != 1 {
+		t.Fatalf("got %d areas, want 1", len(areas))
+	}
+	a := areas[0]
+	if a.ID != b.AreaID || a.Name != "huetest" || a.Type != "screen" || a.Status != "inactive" {
+		t.Errorf("got area %+v", a)
+	}
+	if len(a.Channels) != huetest.Lights {
+		t.Fatalf("got %d channels, want %d", len(a.Channels), huetest.Lights)
+	}
+	for i, ch := range a.Channels {
+		member := huestream.Member{Light: fmt.Sprintf("light-%d", i), Name: huetest.LightName(i)}
+		if ch.ID != i || len(ch.Lights) != 1 || ch.Lights[0] != huetest.LightName(i) || len(ch.Members) != 1 || ch.Members[0] != member {
+			t.Errorf("channel %d: got %+v", i, ch)
+		}
+	}
+	if a.Channels[0].Position.X != -1 || a.Channels[huetest.Lights-1].Position.X != 1 {
+		t.Errorf("channels not in a row: %+v", a.Channels)
+	}
+	want := huestream.Proxy{Mode: "auto", Node: "entertainment-0", Light: huetest.LightName(0)}
+	if a.Proxy != want {
+		t.Errorf("got proxy %+v, want %+v", a.Proxy, want)
+	}
+
+	if _, err := huestream.Areas(context.Background(), b.Host, "intruder", b.Options()...); !errors.Is(err, huestream.ErrUnauthorized) {
+		t.Errorf("Areas with a wrong username: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestAreaSegments(t *testing.T) {
+	resources := map[string]string{
+		"entertainment_configuration": `[{"id":"a","metadata":{"name":"TV"},"channels":[` +
+			`{"channel_id":0,"members":[{"service":{"rid":"ent-1","rtype":"entertainment"},"index":0}]},` +
+			`{"channel_id":1,"members":[{"service":{"rid":"ent-1","rtype":"entertainment"},"index":1}]}]}]`,
+		"entertainment": `[{"id":"ent-1","owner":{"rid":"dev-1","rtype":"device"}}]`,
+		"light":         `[{"id":"light-1","owner":{"rid":"dev-1","rtype":"device"},"metadata":{"name":"Gradient strip"}}]`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"errors":[],"data":%s}`, resources[strings.TrimPrefix(r.URL.Path, "/clip/v2/resource/")])
+	}))
+	defer srv.Close()
+
+	areas, err := huestream.Areas(context.Background(), "127.0.0.1", "user", huestream.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(areas) != 1 || len(areas[0].Channels) != 2 {
+		t.Fatalf("got areas %+v, want one with 2 channels", areas)
+	}
+	for i, ch := range areas[0].Channels {
+		want := huestream.Member{Light: "light-1", Name: "Gradient strip", Segment: i}
+		if len(ch.Members) != 1 || ch.Members[0] != want {
+			t.Errorf("channel %d: got members %+v, want %+v", ch.ID, ch.Members, want)
+		}
+	}
+}
+
+func TestUpdateArea(t *testing.T) {
+	b := huetest.NewBridge(t)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		fixture string
+		u       huestream.AreaUpdate
+	}{
+		{"type", huestream.AreaUpdate{Type: "music"}},
+		{"all", huestream.AreaUpdate{
+			Name: "Living room",
+			Type: "3dspace",
+			Locations: []huestream.ServiceLocation{
+				{Service: "entertainment-0", Positions: []huestream.Position{{X: -1, Y: 0.5}}, Equalization: 1},
+				{Service: "entertainment-1", Positions: []huestream.Position{{X: -0.5, Y: 1}, {X: 0.5, Y: 1}}, Equalization: 0.4},
+			},
+		}},
+	} {
+		want, err := os.ReadFile(filepath.Join("testdata", "areaupdate", tt.fixture+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := huestream.UpdateArea(ctx, b.Host, b.Username, b.AreaID, tt.u, b.Options()...); err != nil {
+			t.Fatal(err)
+		}
+		reqs := b.Requests()
+		if got := reqs[len(reqs)-1]; got.Method != "PUT" || got.Body != strings.TrimSpace(string(want)) {
+			t.Errorf("%s: got request %s %s, want the body\n%s", tt.fixture, got.Method, got.Body, want)
+		}
+	}
+	areas, err := huestream.Areas(ctx, b.Host, b.Username, b.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := areas[0]; a.Name != "Living room" || a.Type != "3dspace" {
+		t.Errorf("got area %s of type %s after the update", a.Name, a.Type)
+	}
+
+	for _, u := range []huestream.AreaUpdate{
+		{},
+		{Type: "cinema"},
+		{Name: strings.Repeat("x", 33)},
+		{Locations: []huestream.ServiceLocation{{Service: "entertainment-0"}}},
+		{Locations: []huestream.ServiceLocation{{Service: "entertainment-0", Positions: []huestream.Position{{X: 2}}}}},
+		{Locations: []huestream.ServiceLocation{{Service: "entertainment-0", Positions: []huestream.Position{{}}, Equalization: 1.5}}},
+	} {
+		if err := huestream.UpdateArea(ctx, b.Host, b.Username, b.AreaID, u, b.Options()...); err == nil {
+			t.Errorf("%+v accepted", u)
+		}
+	}
+}
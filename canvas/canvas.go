@@ -0,0 +1,159 @@
+// Package canvas renders the room as a small image: every frame is drawn
+// on a Canvas with image/draw or any code producing images, and every
+// channel takes the color of the part of the image at its position.
+//
+//	c := canvas.New(stream, area.Channels)
+//	err := c.Run(ctx, func(c *canvas.Canvas, tick int) bool {
+//		draw.Draw(c, c.Bounds(), image.Black, image.Point{}, draw.Src)
+//		x := tick % c.Bounds().Dx()
+//		draw.Draw(c, image.Rect(x, 0, x+8, 36), image.NewUniform(color.White), image.Point{}, draw.Src)
+//		return true
+//	})
+//
+// The image is the room seen from the seat of the entertainment area, as
+// the screen of the ambilight package: its left edge is at x -1, its right
+// edge at x 1, its top at z 1 and its bottom at z -1. The depth y is
+// ignored.
+package canvas
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"iter"
+
+	"github.com/rschio/huestream"
+)
+
+// Rate is the rate Run draws the frames at, in Hz, the rate of the effects
+// of the huestream command.
+const Rate = 25
+
+// Region is a part of the picture, in fractions of its width and height
+// from its top left corner.
+type Region struct {
+	X0, Y0, X1, Y1 float64
+}
+
+// Regions maps the channels to the parts of the picture at their
+// positions. Every region is a square of size, a fraction of the width and
+// height, centered on the channel and kept inside the picture: the
+// channels at the edges sample the edges.
+func Regions(channels []huestream.Channel, size float64) map[int]Region {
+	size = min(max(size, 0.01), 1)
+	regions := make(map[int]Region, len(channels))
+	for _, ch := range channels {
+		u := (ch.Position.X + 1) / 2
+		v := (1 - ch.Position.Z) / 2
+		x0 := min(max(u-size/2, 0), 1-size)
+		y0 := min(max(v-size/2, 0), 1-size)
+		regions[ch.ID] = Region{X0: x0, Y0: y0, X1: x0 + size, Y1: y0 + size}
+	}
+	return regions
+}
+
+// Sample returns the mean color of the region r of the bounds content of
+// img.
+func Sample(img *image.RGBA, content image.Rectangle, r Region) color.Color {
+	x0 := content.Min.X + int(r.X0*float64(content.Dx()))
+	y0 := content.Min.Y + int(r.Y0*float64(content.Dy()))
+	x1 := max(content.Min.X+int(r.X1*float64(content.Dx())+0.5), x0+1)
+	y1 := max(content.Min.Y+int(r.Y1*float64(content.Dy())+0.5), y0+1)
+	x1, y1 = min(x1, content.Max.X), min(y1, content.Max.Y)
+
+	var sr, sg, sb, n uint32
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			i := img.PixOffset(x, y)
+			sr += uint32(img.Pix[i])
+			sg += uint32(img.Pix[i+1])
+			sb += uint32(img.Pix[i+2])
+			n++
+		}
+	}
+	if n == 0 {
+		return color.Black
+	}
+	return color.RGBA{R: uint8(sr / n), G: uint8(sg / n), B: uint8(sb / n), A: 255}
+}
+
+// Option configures a Canvas.
+type Option func(*config)
+
+type config struct {
+	width, height int
+	size          float64
+}
+
+// WithResolution sets the size of the image, by default 64x36.
+func WithResolution(width, height int) Option {
+	return func(c *config) { c.width, c.height = width, height }
+}
+
+// WithRegionSize sets the size of the Regions the channels sample, by
+// default 0.1: the larger, the softer the picture on the lights.
+func WithRegionSize(size float64) Option {
+	return func(c *config) { c.size = size }
+}
+
+// Canvas is an image sampled to the channels of an entertainment area. Draw
+// on it, then Commit it to send its colors. A Canvas is not safe for
+// concurrent use.
+type Canvas struct {
+	*image.RGBA
+	st      huestream.Streamer
+	regions map[int]Region
+}
+
+// New returns a black Canvas sending the colors of the channels to st.
+// The sizes below 1 are raised to 1.
+func New(st huestream.Streamer, channels []huestream.Channel, opts ...Option) *Canvas {
+	cfg := config{width: 64, height: 36, size: 0.1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, max(cfg.width, 1), max(cfg.height, 1)))
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	return &Canvas{RGBA: img, st: st, regions: Regions(channels, cfg.size)}
+}
+
+// Frame returns the colors of the channels sampled from the image.
+func (c *Canvas) Frame() huestream.Frame {
+	f := make(huestream.Frame, len(c.regions))
+	for id, r := range c.regions {
+		f[id] = Sample(c.RGBA, c.Bounds(), r)
+	}
+	return f
+}
+
+// Commit sends the colors of the channels sampled from the image.
+func (c *Canvas) Commit() error {
+	return c.st.Send(c.Frame())
+}
+
+// CommitContext is Commit, giving up when ctx is done.
+func (c *Canvas) CommitContext(ctx context.Context) error {
+	return c.st.SendContext(ctx, c.Frame())
+}
+
+// Frames returns the frames of render drawing on c: at every tick render
+// draws the image of the tick, from 0, then its sample is the frame. The
+// image keeps the drawing of the tick before. The sequence ends when
+// render returns false.
+func (c *Canvas) Frames(render func(c *Canvas, tick int) bool) iter.Seq[huestream.Frame] {
+	return func(yield func(huestream.Frame) bool) {
+		for tick := 0; render(c, tick); tick++ {
+			if !yield(c.Frame()) {
+				return
+			}
+		}
+	}
+}
+
+// Run draws the frames of render and sends them at Rate until ctx is done
+// or render returns false, as huestream.Play.
+func (c *Canvas) Run(ctx context.Context, render func(c *Canvas, tick int) bool) error {
+	return huestream.Play(ctx, c.st, c.Frames(render), Rate)
+}
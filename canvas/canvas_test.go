@@ -0,0 +1,123 @@
+package canvas
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/rschio/huestream"
+)
+
+// recorder is a Streamer keeping the frames sent.
+type recorder struct{ frames []huestream.Frame }
+
+func (r *recorder) Send(f huestream.Frame) error {
+	r.frames = append(r.frames, f)
+	return nil
+}
+
+func (r *recorder) SendContext(ctx context.Context, f huestream.Frame) error {
+	return r.Send(f)
+}
+
+func (r *recorder) Close() error { return nil }
+
+var (
+	black = color.RGBA{A: 0xff}
+	red   = color.RGBA{R: 0xff, A: 0xff}
+	blue  = color.RGBA{B: 0xff, A: 0xff}
+
+	// The channels at the left, at the right and at the top.
+	channels = []huestream.Channel{
+		{ID: 0, Position: huestream.Position{X: -1}},
+		{ID: 1, Position: huestream.Position{X: 1}},
+		{ID: 2, Position: huestream.Position{Z: 1}},
+	}
+)
+
+func TestRegions(t *testing.T) {
+	regions := Regions(channels, 0.2)
+	want := map[int]Region{
+		0: {X0: 0, Y0: 0.4, X1: 0.2, Y1: 0.6},
+		1: {X0: 0.8, Y0: 0.4, X1: 1, Y1: 0.6},
+		2: {X0: 0.4, Y0: 0, X1: 0.6, Y1: 0.2},
+	}
+	for id, r := range want {
+		got := regions[id]
+		for _, d := range []float64{got.X0 - r.X0, got.Y0 - r.Y0, got.X1 - r.X1, got.Y1 - r.Y1} {
+			if d > 1e-9 || d < -1e-9 {
+				t.Errorf("channel %d: got %+v, want %+v", id, got, r)
+				break
+			}
+		}
+	}
+}
+
+func TestCommit(t *testing.T) {
+	var rec recorder
+	c := New(&rec, channels)
+	if b := c.Bounds(); b.Dx() != 64 || b.Dy() != 36 {
+		t.Fatalf("got bounds %v, want 64x36", b)
+	}
+	if err := c.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// The left half red, the right half blue, the top row white.
+	draw.Draw(c, image.Rect(0, 0, 32, 36), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(c, image.Rect(32, 0, 64, 36), image.NewUniform(blue), image.Point{}, draw.Src)
+	draw.Draw(c, image.Rect(0, 0, 64, 4), image.White, image.Point{}, draw.Src)
+	if err := c.CommitContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(rec.frames))
+	}
+	for id, got := range rec.frames[0] {
+		if got != black {
+			t.Errorf("first frame, channel %d: got %v, want black", id, got)
+		}
+	}
+	white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	for id, want := range map[int]color.Color{0: red, 1: blue, 2: white} {
+		if got := rec.frames[1][id]; got != want {
+			t.Errorf("second frame, channel %d: got %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	var rec recorder
+	c := New(&rec, channels[:2], WithResolution(8, 2), WithRegionSize(0.25))
+	// A red column moving from the left edge to the right one.
+	err := c.Run(context.Background(), func(c *Canvas, tick int) bool {
+		if tick == 8 {
+			return false
+		}
+		draw.Draw(c, c.Bounds(), image.Black, image.Point{}, draw.Src)
+		draw.Draw(c, image.Rect(tick, 0, tick+1, 2), image.NewUniform(red), image.Point{}, draw.Src)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.frames) != 8 {
+		t.Fatalf("got %d frames, want 8", len(rec.frames))
+	}
+	half := color.RGBA{R: 0x7f, A: 0xff} // One column red out of the two sampled.
+	for _, tc := range []struct {
+		tick        int
+		left, right color.Color
+	}{
+		{0, half, black},
+		{3, black, black},
+		{7, black, half},
+	} {
+		f := rec.frames[tc.tick]
+		if f[0] != tc.left || f[1] != tc.right {
+			t.Errorf("tick %d: got %v and %v, want %v and %v", tc.tick, f[0], f[1], tc.left, tc.right)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package huestream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rschio/huestream/wire"
+)
+
+// Capture configures the capture of the sent messages, see WithCapture.
+type Capture struct {
+	// Path is the pcapng file. When it is rotated the older files are
+	// renamed Path.1, Path.2 and so on.
+	Path string
+
+	// MaxSize is the size a file can't exceed, the file is rotated before
+	// it would. The default is 64 MiB.
+	MaxSize int64
+
+	// MaxFiles is the number of rotated files kept besides Path, the oldest
+	// are deleted. Zero keeps none, so a capture never takes more than
+	// (MaxFiles+1)*MaxSize bytes.
+	MaxFiles int
+}
+
+const defaultCaptureSize = 64 << 20
+
+// The messages are captured as UDP datagrams in raw IPv4 packets, with the
+// addresses of the connection when they are IPv4 UDP addresses or these
+// documentation ones otherwise.
+var (
+	captureSrc = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2100}
+	captureDst = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 2100}
+)
+
+// pcapng block types and constants.
+const (
+	blockSHB = 0x0a0d0d0a // Section header.
+	blockIDB = 0x00000001 // Interface description.
+	blockEPB = 0x00000006 // Enhanced packet.
+
+	byteOrderMagic = 0x1a2b3c4d
+	linkTypeRaw    = 101 // Raw IPv4 or IPv6 packets.
+	optTSResol     = 9   // if_tsresol.
+	snapLen        = 0xffff
+
+	ipv4HeaderSize = 20
+	udpHeaderSize  = 8
+)
+
+// captureWriter writes the messages to a rotated pcapng file.
+type captureWriter struct {
+	cfg Capture
+
+	mu   sync.Mutex    // Guards the fields below.
+	f    *os.File      // Nil once closed or stopped by a failure.
+	w    *bufio.Writer // Buffers f.
+	size int64         // The size of f.
+}
+
+// newCaptureWriter creates the capture file.
+func newCaptureWriter(cfg Capture) (*captureWriter, error) {
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = defaultCaptureSize
+	}
+	c := &captureWriter{cfg: cfg}
+	if c.cfg.MaxSize < fileHeaderSize+blockSize(ipv4HeaderSize+udpHeaderSize+wire.MaxMessageSize) {
+		return nil, fmt.Errorf("capture: max size %d can't hold a message", cfg.MaxSize)
+	}
+	if err := c.open(); err != nil {
+		return nil, fmt.Errorf("capture: %w", err)
+	}
+	return c, nil
+}
+
+// write records b, sent at t from src to dst. It returns the error that
+// stopped the capture, only once.
+func (c *captureWriter) write(t time.Time, src, dst net.Addr, b []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.f == nil {
+		return nil // Stopped.
+	}
+
+	pkt := ipv4UDP(src, dst, b)
+	if c.size+blockSize(len(pkt)) > c.cfg.MaxSize {
+		if err := c.rotate(); err != nil {
+			return c.stop(err)
+		}
+	}
+	if err := c.writeEPB(t, pkt); err != nil {
+		return c.stop(err)
+	}
+	// Flushing each packet keeps the file readable while the Stream runs.
+	if err := c.w.Flush(); err != nil {
+		return c.stop(err)
+	}
+	return nil
+}
+
+// Close flushes and closes the file.
+func (c *captureWriter) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.f == nil {
+		return nil
+	}
+	err := errors.Join(c.w.Flush(), c.f.Close())
+	c.f = nil
+	return err
+}
+
+// stop closes the file after a failure and returns err.
+func (c *captureWriter) stop(err error) error {
+	c.f.Close()
+	c.f = nil
+	return fmt.Errorf("capture: %w", err)
+}
+
+func (c *captureWriter) open() error {
+	f, err := os.Create(c.cfg.Path)
+	if err != nil {
+		return err
+	}
+	c.f, c.w, c.size = f, bufio.NewWriter(f), 0
+	if err := c.writeHeader(); err != nil {
+		f.Close()
+		return err
+	}
+	return c.w.Flush()
+}
+
+// rotate shifts the rotated files, dropping the oldest, and starts a new
+// file.
+func (c *captureWriter) rotate() error {
+	if err := errors.Join(c.w.Flush(), c.f.Close()); err != nil {
+		return err
+	}
+	if c.cfg.MaxFiles > 0 {
+		for i := c.cfg.MaxFiles - 1; i > 0; i-- {
+			err := os.Rename(rotatedName(c.cfg.Path, i), rotatedName(c.cfg.Path, i+1))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if err := os.Rename(c.cfg.Path, rotatedName(c.cfg.Path, 1)); err != nil {
+			return err
+		}
+	}
+	return c.open()
+}
+
+func rotatedName(path string, i int) string { return fmt.Sprintf("%s.%d", path, i) }
+
+// fileHeaderSize is the size of the section header and interface
+// description blocks starting every file.
+const fileHeaderSize = 28 + 32
+
+func (c *captureWriter) writeHeader() error {
+	var b []byte
+
+	b = binary.LittleEndian.AppendUint32(b, blockSHB)
+	b = binary.LittleEndian.AppendUint32(b, 28)
+	b = binary.LittleEndian.AppendUint32(b, byteOrderMagic)
+	b = binary.LittleEndian.AppendUint16(b, 1) // Version 1.0.
+	b = binary.LittleEndian.AppendUint16(b, 0)
+	b = binary.LittleEndian.AppendUint64(b, ^uint64(0)) // Unknown section length.
+	b = binary.LittleEndian.AppendUint32(b, 28)
+
+	b = binary.LittleEndian.AppendUint32(b, blockIDB)
+	b = binary.LittleEndian.AppendUint32(b, 32)
+	b = binary.LittleEndian.AppendUint16(b, linkTypeRaw)
+	b = binary.LittleEndian.AppendUint16(b, 0) // Reserved.
+	b = binary.LittleEndian.AppendUint32(b, snapLen)
+	b = binary.LittleEndian.AppendUint16(b, optTSResol)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = append(b, 9, 0, 0, 0)                  // Nanoseconds, padded.
+	b = binary.LittleEndian.AppendUint32(b, 0) // End of options.
+	b = binary.LittleEndian.AppendUint32(b, 32)
+
+	return c.writeBlock(b)
+}
+
+func (c *captureWriter) writeEPB(t time.Time, pkt []byte) error {
+	size := blockSize(len(pkt))
+	ts := uint64(t.UnixNano())
+
+	var b []byte
+	b = binary.LittleEndian.AppendUint32(b, blockEPB)
+	b = binary.LittleEndian.AppendUint32(b, uint32(size))
+	b = binary.LittleEndian.AppendUint32(b, 0) // Interface ID.
+	b = binary.LittleEndian.AppendUint32(b, uint32(ts>>32))
+	b = binary.LittleEndian.AppendUint32(b, uint32(ts))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(pkt)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(pkt)))
+	b = append(b, pkt...)
+	b = append(b, make([]byte, pad4(len(pkt)))...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(size))
+
+	return c.writeBlock(b)
+}
+
+func (c *captureWriter) writeBlock(b []byte) error {
+	n, err := c.w.Write(b)
+	c.size += int64(n)
+	return err
+}
+
+// blockSize is the size of an enhanced packet block holding n bytes.
+func blockSize(n int) int64 { return int64(32 + n + pad4(n)) }
+
+func pad4(n int) int { return (4 - n%4) % 4 }
+
+// ipv4UDP returns payload in a UDP datagram from src to dst, in an IPv4
+// packet.
+func ipv4UDP(src, dst net.Addr, payload []byte) []byte {
+	s, d := captureAddr(src, captureSrc), captureAddr(dst, captureDst)
+	total := ipv4HeaderSize + udpHeaderSize + len(payload)
+
+	b := make([]byte, 0, total)
+	b = append(b, 0x45, 0) // Version 4, header of 5 words.
+	b = binary.BigEndian.AppendUint16(b, uint16(total))
+	b = append(b, 0, 0, 0x40, 0) // ID, don't fragment.
+	b = append(b, 64, 17)        // TTL, UDP.
+	b = append(b, 0, 0)          // Checksum, set below.
+	b = append(b, s.IP.To4()...)
+	b = append(b, d.IP.To4()...)
+	binary.BigEndian.PutUint16(b[10:], ipChecksum(b))
+
+	b = binary.BigEndian.AppendUint16(b, uint16(s.Port))
+	b = binary.BigEndian.AppendUint16(b, uint16(d.Port))
+	b = binary.BigEndian.AppendUint16(b, uint16(udpHeaderSize+len(payload)))
+	b = append(b, 0, 0) // No checksum.
+
+	return append(b, payload...)
+}
+
+func captureAddr(a net.Addr, fallback *net.UDPAddr) *net.UDPAddr {
+	if u, ok := a.(*net.UDPAddr); ok && u.IP.To4() != nil {
+		return u
+	}
+	return fallback
+}
+
+func ipChecksum(h []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(h); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(h[i:]))
+	}
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,150 @@
+package huestream
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image/color"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/wire"
+)
+
+// readCapture returns the packets of the enhanced packet blocks of a pcapng
+// file and their timestamps.
+func readCapture(t *testing.T, path string) (pkts [][]byte, times []time.Time) {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(b) > 0 {
+		typ := binary.LittleEndian.Uint32(b)
+		size := binary.LittleEndian.Uint32(b[4:])
+		if size < 12 || int(size) > len(b) || binary.LittleEndian.Uint32(b[size-4:]) != size {
+			t.Fatalf("malformed block of type %#x", typ)
+		}
+		if typ == blockEPB {
+			ts := uint64(binary.LittleEndian.Uint32(b[12:]))<<32 | uint64(binary.LittleEndian.Uint32(b[16:]))
+			n := binary.LittleEndian.Uint32(b[20:])
+			pkts = append(pkts, b[28:28+n])
+			times = append(times, time.Unix(0, int64(ts)))
+		}
+		b = b[size:]
+	}
+	return pkts, times
+}
+
+func TestCaptureWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.pcapng")
+	c, err := newCaptureWriter(Capture{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 50000}
+	now := time.Unix(1700000000, 123456789)
+	for i := range 3 {
+		if err := c.write(now.Add(time.Duration(i)*time.Millisecond), src, nil, []byte("HueStream frame")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pkts, times := readCapture(t, path)
+	if len(pkts) != 3 {
+		t.Fatalf("got %d packets, want 3", len(pkts))
+	}
+	if !times[2].Equal(now.Add(2 * time.Millisecond)) {
+		t.Errorf("timestamp %v, want %v", times[2], now.Add(2*time.Millisecond))
+	}
+	p := pkts[0]
+	if !bytes.Equal(p[12:16], []byte{10, 0, 0, 1}) || !bytes.Equal(p[16:20], []byte{192, 0, 2, 2}) {
+		t.Errorf("addresses %v -> %v", net.IP(p[12:16]), net.IP(p[16:20]))
+	}
+	if port := binary.BigEndian.Uint16(p[20:]); port != 50000 {
+		t.Errorf("source port %d, want 50000", port)
+	}
+	if ipChecksum(p[:ipv4HeaderSize]) != 0 {
+		t.Error("bad IPv4 header checksum")
+	}
+	if payload := string(p[ipv4HeaderSize+udpHeaderSize:]); payload != "HueStream frame" {
+		t.Errorf("payload %q", payload)
+	}
+}
+
+func TestCaptureRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.pcapng")
+	payload := make([]byte, 100)
+	maxSize := fileHeaderSize + 2*blockSize(ipv4HeaderSize+udpHeaderSize+len(payload)) + blockSize(ipv4HeaderSize+udpHeaderSize+wire.MaxMessageSize)
+	c, err := newCaptureWriter(Capture{Path: path, MaxSize: maxSize, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 20 {
+		if err := c.write(time.Now(), nil, nil, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Close()
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() > maxSize {
+			t.Errorf("%s has %d bytes, more than %d", name, fi.Size(), maxSize)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 should not exist: %v", path, err)
+	}
+}
+
+func TestCaptureTooSmall(t *testing.T) {
+	_, err := newCaptureWriter(Capture{Path: filepath.Join(t.TempDir(), "x"), MaxSize: 100})
+	if err == nil {
+		t.Error("newCaptureWriter should reject a max size that can't hold a message")
+	}
+}
+
+func TestStreamCapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.pcapng")
+	local, remote := net.Pipe()
+	defer remote.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := remote.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	c := fakeBridge(t, nil)
+	c.dial = func(ctx context.Context) (net.Conn, error) { return local, nil }
+	s, err := c.initStream(context.Background(), testAreaID, newConfig([]Option{WithCapture(Capture{Path: path}), WithoutPreflight()}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(Frame{1: color.White}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pkts, _ := readCapture(t, path)
+	want, _ := message{areaID: testAreaID, idColors: Frame{1: color.White}}.MarshalBinary()
+	if len(pkts) != 1 || !bytes.Equal(pkts[0][ipv4HeaderSize+udpHeaderSize:], want) {
+		t.Errorf("captured %x, want one packet with %x", pkts, want)
+	}
+}
@@ -1,62 +1,591 @@
 package huestream
 
 import (
-	"cmp"
 	"context"
 	"crypto/tls"
-	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"image/color"
+	"log/slog"
 	"net"
 	"net/http"
+	"os"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pion/dtls/v3"
+	dtlsnet "github.com/pion/dtls/v3/pkg/net"
+	"github.com/rschio/huestream/internal/clock"
+	"github.com/rschio/huestream/wire"
 )
 
 // Start initiates a new stream in the given area. Use the stream to change the
 // colors of the lamps.
-func Start(ctx context.Context, host, username, clientKey, areaID string) (*Stream, error) {
+//
+// The failures wrap the errors of the package, such as ErrUnauthorized,
+// ErrAreaNotFound or ErrInvalidClientKey. When ctx expires Start returns a
+// *TimeoutError.
+func Start(ctx context.Context, host, username, clientKey, areaID string, opts ...Option) (*Stream, error) {
+	cfg := newConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.clientKey == nil && cfg.pskProvider == nil {
+		if key, err := hex.DecodeString(clientKey); err != nil || len(key) != clientKeySize {
+			return nil, fmt.Errorf("%w: clientKey must be %d hex encoded bytes", ErrInvalidClientKey, clientKeySize)
+		}
+	}
 	c := newClient(host, username, clientKey)
-	return c.initStream(ctx, areaID)
+	c.cfg = cfg
+	c.key = cfg.clientKey
+	if cfg.streamPort != 0 {
+		c.streamPort = cfg.streamPort
+	}
+	s, err := c.initStream(ctx, areaID, cfg)
+	if err != nil && errors.Is(err, context.DeadlineExceeded) {
+		return nil, &TimeoutError{Op: "start", Err: err}
+	}
+	return s, err
 }
 
 // Stream manages the Hue Entertainment Stream of an Entertainment Area.
+//
+// All the methods of a Stream are safe for concurrent use. Concurrent sends
+// are written one at a time, in an unspecified order. Close may be called
+// concurrently with the other methods and more than once, once it has
+// started the sends fail with ErrClosed.
 type Stream struct {
 	once   sync.Once
-	conn   *dtls.Conn
 	client *client
 	areaID string
+	cfg    config
+
+	errs *errorDispatcher
+	log  *slog.Logger
+
+	span    Span            // Traces the Stream from Start to Close.
+	spanCtx context.Context // Holds span.
+	quit    chan struct{}   // Closed by Close to stop the background goroutines.
+	wg      sync.WaitGroup  // Tracks the background goroutines.
+
+	clk        clock.Clock
+	timing     timing
+	latency    histogram
+	framesSent atomic.Uint64
+	bytesSent  atomic.Uint64
+	sendErrors atomic.Uint64
+	reconnects atomic.Uint64
+	expvars    []*expvarSlot  // Published by PublishExpvar, guarded by expvars.mu.
+	capture    *captureWriter // Nil unless WithCapture is used.
+	mirror     net.Conn       // Nil unless WithMirror is used.
+	sequence   atomic.Uint32  // The sequence number of the next message.
+
+	transientErrors  atomic.Uint64
+	framesSuppressed atomic.Uint64
+	mirrorErrors     atomic.Uint64
+
+	smartScenes []string  // Active at Start, see WithSmartSceneRestore.
+	channels    []Channel // Of the area, nil without the preflight of Start.
+
+	dither ditherer   // The error carried over by WithDithering.
+	queue  *sendQueue // Nil unless WithSendBuffer is used.
+
+	prio priorityLane // Arbitrates Send and SendPriority.
+	term terminal     // Set once the Stream ends, see Done.
+
+	followMu sync.Mutex
+	follower *follower // Started by SetTarget, guarded by followMu.
+
+	recovering atomic.Bool
+	pause      atomic.Pointer[pauseState] // Nil unless paused.
+
+	mu       sync.Mutex // Serializes writes and guards the fields below.
+	last     []byte     // The last message written.
+	lastSend time.Time  // When the last message was written.
+
+	connMu sync.Mutex // Guards the fields below, never held during I/O.
+	conn   net.Conn   // Replaced when the session is recovered.
+	closed bool       // Set by Close, no goroutine may be started after it.
+
+	stopAutoClose func() bool // Unregisters WithAutoClose, nil without it.
+}
+
+// newStream returns a Stream writing to conn and starts its background
+// goroutines.
+func newStream(conn net.Conn, c *client, areaID string, cfg config) *Stream {
+	s := &Stream{
+		conn:   conn,
+		client: c,
+		areaID: areaID,
+		cfg:    cfg,
+		clk:    cfg.clock,
+		errs:   newErrorDispatcher(cfg.errorHandler, cfg.errorChan),
+		log:    cfg.logger().With("area_id", areaID),
+		quit:   make(chan struct{}),
+
+		capture: cfg.captureWriter,
+		mirror:  cfg.mirrorConn,
+	}
+	s.lastSend = s.clk.Now()
+	if c != nil {
+		s.log = s.log.With("bridge_host", c.host)
+	}
+
+	if cfg.keepAlive > 0 {
+		s.goBackground(func() { s.keepAlive(cfg.keepAlive) })
+	}
+	if cfg.reportEvery > 0 {
+		// Not tracked by Close, so that the report function may close the
+		// Stream.
+		f := cfg.reportFunc
+		if f == nil {
+			f = s.logReport
+		}
+		go s.report(s.newReporter(), s.clk.NewTicker(cfg.reportEvery), nil, f)
+	}
+	if cfg.sendBuffer > 0 {
+		s.queue = newSendQueue(cfg.sendBuffer, cfg.dropPolicy)
+		s.goBackground(func() { s.writeQueued(s.queue) })
+	}
+	if cfg.idleThreshold > 0 && (s.errs != nil || cfg.keepAlive > 0) {
+		s.goBackground(func() { s.watchdog(cfg.idleThreshold) })
+	}
+
+	return s
 }
 
 // Close closes the connection, stops the stream and release the resources.
+//
+// Both the connection close and the stop action are always attempted, the
+// returned error joins their failures. The stop action is retried when the
+// bridge can't be reached or answers with a server error, if it still fails
+// the error wraps ErrStopFailed. Only the first call does the work, later
+// calls return nil, as the calls after Detach. With WithExternalLifecycle
+// the stop action is skipped.
 func (s *Stream) Close() error {
+	return s.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but gives up retrying the stop action when ctx
+// is done.
+func (s *Stream) CloseContext(ctx context.Context) error {
 	var err error
+	s.once.Do(func() { err = s.release(ctx, false) })
+	return err
+}
 
-	s.once.Do(func() {
-		err = cmp.Or(
-			s.client.stopStream(context.Background(), s.areaID),
-			s.conn.Close(),
-		)
-	})
+// Detach closes the connection and releases the resources like Close, but
+// leaves the stream active on the bridge: the stop action is not sent. It
+// returns once the connection is closed, the sends fail with ErrClosed
+// after it and Close does nothing.
+//
+// Detach hands the session over to another process, for a restart without
+// the lights going back to their state before streaming: the new process
+// starts its Stream on the same area with WithExternalLifecycle, which does
+// not send the start action, and streams in its turn. The bridge ends a
+// stream it receives nothing on for about 10 seconds, the new process must
+// send its first frame within that window, or the handshake fails and the
+// area is back to idle. The process that sends last must stop the stream,
+// with a Stream started without WithExternalLifecycle or by the CLIP API.
+func (s *Stream) Detach() error {
+	var err error
+	s.once.Do(func() { err = s.release(context.Background(), true) })
+	return err
+}
 
+// release closes the connection and releases the resources, then sends the
+// stop action unless detach is set or the lifecycle is external.
+func (s *Stream) release(ctx context.Context, detach bool) error {
+	var connErr, stopErr, captureErr, restoreErr error
+	if connErr = s.shutdown(); connErr != nil {
+		connErr = fmt.Errorf("close connection: %w", connErr)
+	}
+	s.unpublishExpvars()
+	if s.capture != nil {
+		if captureErr = s.capture.Close(); captureErr != nil {
+			captureErr = fmt.Errorf("close capture: %w", captureErr)
+		}
+	}
+	if s.mirror != nil {
+		s.mirror.Close() // Best effort, as its writes.
+	}
+	if s.client != nil {
+		if !detach && !s.cfg.externalLifecycle {
+			if stopErr = s.stop(ctx); stopErr != nil {
+				stopErr = fmt.Errorf("stop stream: %w: %w", ErrStopFailed, stopErr)
+			} else {
+				restoreErr = s.restoreSmartScenes()
+			}
+		}
+		s.client.clearKey()
+	}
+	err := errors.Join(stopErr, connErr, captureErr, restoreErr)
+	event := "close"
+	if detach {
+		event = "detach"
+	}
+	s.traceSpan().AddEvent(event)
+	s.traceSpan().End(err)
+	switch {
+	case err != nil:
+		s.logger().Warn("stream closed with errors", "error", err)
+	case detach:
+		s.logger().Info("stream detached, left active on the bridge")
+	default:
+		s.logger().Info("stream closed")
+	}
+
+	s.errs.close()
 	return err
 }
 
+// Retry policy of the stop action of Close.
+const (
+	stopAttempts = 3
+	stopBackoff  = 100 * time.Millisecond // Doubled after each attempt.
+)
+
+// stop sends the stop action, retrying it while the bridge can't be reached
+// or answers with a server error, until ctx is done.
+func (s *Stream) stop(ctx context.Context) error {
+	stopCtx, cancel := context.WithCancel(s.traceContext())
+	defer cancel()
+	defer context.AfterFunc(ctx, cancel)()
+
+	backoff := stopBackoff
+	for attempt := 1; ; attempt++ {
+		err := s.client.stopStream(stopCtx, s.areaID)
+		if err == nil || attempt == stopAttempts || !retryStop(err) {
+			return err
+		}
+		s.logger().Warn("stop action failed, retrying", "error", err, "attempt", attempt)
+
+		t := s.clk.NewTimer(backoff)
+		select {
+		case <-stopCtx.Done():
+			t.Stop()
+			return err
+		case <-t.C():
+		}
+		backoff *= 2
+	}
+}
+
+// retryStop reports whether the stop action failing with err is worth
+// retrying: the bridge did not answer, or answered with a server error.
+func retryStop(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code >= 500 || status.Code == http.StatusTooManyRequests
+	}
+	var bridgeErr *BridgeError
+	return !errors.As(err, &bridgeErr) && !errors.Is(err, context.Canceled)
+}
+
+// restoreSmartScenes activates the smart scenes active at Start again.
+func (s *Stream) restoreSmartScenes() error {
+	var errs []error
+	for _, id := range s.smartScenes {
+		if err := s.client.recallSmartScene(s.traceContext(), id, true); err != nil {
+			errs = append(errs, fmt.Errorf("restore smart scene %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shutdown stops the background goroutines and closes the connection.
+func (s *Stream) shutdown() error {
+	s.term.enter(ErrClosed)
+
+	s.connMu.Lock()
+	s.closed = true
+	conn := s.conn
+	if s.stopAutoClose != nil {
+		s.stopAutoClose()
+	}
+	s.connMu.Unlock()
+
+	if s.quit != nil {
+		close(s.quit)
+	}
+	if p := s.pause.Load(); p != nil {
+		p.end()
+	}
+
+	// Closing the conn first unblocks a background goroutine stuck in a
+	// write.
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+	s.wg.Wait()
+
+	return err
+}
+
+// AreaID returns the ID of the entertainment area of the Stream.
+func (s *Stream) AreaID() string { return s.areaID }
+
+// NumChannels returns the number of channels of the area, 0 if unknown:
+// they are fetched by the preflight of Start, see WithoutPreflight.
+func (s *Stream) NumChannels() int { return len(s.channels) }
+
+// ChannelIDs returns the IDs of the channels of the area in increasing
+// order, nil if unknown as for NumChannels. The slice is a copy.
+func (s *Stream) ChannelIDs() []int {
+	if s.channels == nil {
+		return nil
+	}
+	ids := make([]int, len(s.channels))
+	for i, ch := range s.channels {
+		ids[i] = ch.ID
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// LocalAddr returns the local address of the stream connection.
+//
+// When the session is recovered (see WithRecovery) or renewed by Resume, the
+// address of the new connection is returned from then on. After Close it
+// returns the address of the last connection. It returns nil if the Stream
+// has no connection.
+func (s *Stream) LocalAddr() net.Addr {
+	if conn := s.currentConn(); conn != nil {
+		return conn.LocalAddr()
+	}
+	return nil
+}
+
+// RemoteAddr returns the address of the bridge the Stream sends to, with
+// the same rules as LocalAddr.
+func (s *Stream) RemoteAddr() net.Addr {
+	if conn := s.currentConn(); conn != nil {
+		return conn.RemoteAddr()
+	}
+	return nil
+}
+
+// currentConn returns the connection of the current session.
+func (s *Stream) currentConn() net.Conn {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	return s.conn
+}
+
+// goBackground runs f on a goroutine tracked by Close. It reports false,
+// without running f, if the Stream is already closed.
+func (s *Stream) goBackground(f func()) bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		f()
+	}()
+
+	return true
+}
+
+// Frame maps Channel IDs (lamp IDs) to the colors they should display.
+type Frame map[int]color.Color
+
 // Send a command to change the color of the lamps.
 // The int value is the Channel ID (lamp ID).
-func (s *Stream) Send(idColors map[int]color.Color) error {
-	msg := message{areaID: s.areaID, idColors: idColors}
-	b, err := msg.MarshalBinary()
+//
+// During the hold of a priority frame, see SendPriority, the channels of the
+// priority frame keep its colors.
+func (s *Stream) Send(idColors Frame) error {
+	if s.Paused() {
+		return ErrPaused
+	}
+	return s.send(s.prio.overlay(idColors))
+}
+
+// send writes the message of idColors, recovering the session on failure.
+func (s *Stream) send(idColors Frame) error {
+	b, err := s.message(idColors).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if s.duplicate(b) {
+		return nil
+	}
+	if err := s.write(b); err != nil {
+		s.recoverOnFailure(err)
+		return err
+	}
+	return nil
+}
+
+// SendContext is like Send but aborts the write when ctx is done.
+//
+// The deadline of ctx is applied as the write deadline. If ctx is canceled
+// SendContext returns ctx.Err(), if its deadline expires it returns a
+// *TimeoutError wrapping context.DeadlineExceeded.
+func (s *Stream) SendContext(ctx context.Context, idColors Frame) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.Paused() {
+		return ErrPaused
+	}
+
+	b, err := s.message(s.prio.overlay(idColors)).MarshalBinary()
 	if err != nil {
 		return err
 	}
-	_, err = s.conn.Write(b)
+	if s.duplicate(b) {
+		return nil
+	}
+
+	err = s.writeContext(ctx, b)
+	if err == nil {
+		return nil
+	}
+
+	// The write deadline may expire slightly before ctx notices its own.
+	_, hasDeadline := ctx.Deadline()
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return ctx.Err()
+	case errors.Is(ctx.Err(), context.DeadlineExceeded),
+		hasDeadline && errors.Is(err, os.ErrDeadlineExceeded):
+		return &TimeoutError{Op: "send", Err: context.DeadlineExceeded}
+	}
+
 	return err
 }
 
+// write sends b to the bridge and records it as the last message.
+func (s *Stream) write(b []byte) error {
+	return s.writeContext(context.Background(), b)
+}
+
+// writeContext is like write but interrupts the write when ctx is done.
+func (s *Stream) writeContext(ctx context.Context, b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.connMu.Lock()
+	conn, closed := s.conn, s.closed
+	s.connMu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	if ctx.Done() != nil {
+		deadline, _ := ctx.Deadline()
+		if err := conn.SetWriteDeadline(deadline); err != nil {
+			return err
+		}
+
+		interrupted := make(chan struct{})
+		stop := context.AfterFunc(ctx, func() {
+			defer close(interrupted)
+			conn.SetWriteDeadline(time.Now())
+		})
+		defer func() {
+			if !stop() {
+				<-interrupted
+			}
+			conn.SetWriteDeadline(time.Time{})
+		}()
+	}
+
+	start := s.clk.Now()
+	if _, err := conn.Write(b); err != nil {
+		s.sendErrors.Add(1)
+		if s.cfg.metrics != nil {
+			s.cfg.metrics.Error(err)
+		}
+		return err
+	}
+	end := s.clk.Now()
+
+	s.last = b
+	s.lastSend = end
+	s.framesSent.Add(1)
+	s.bytesSent.Add(uint64(len(b)))
+
+	if s.mirror != nil {
+		if _, err := s.mirror.Write(b); err != nil {
+			s.mirrorErrors.Add(1)
+		}
+	}
+	if s.capture != nil {
+		s.errs.report(s.capture.write(start, conn.LocalAddr(), conn.RemoteAddr(), b))
+	}
+	if s.cfg.dump != nil {
+		if err := s.cfg.dump.write(start, b); err != nil {
+			s.errs.report(fmt.Errorf("frame dump: %w", err))
+		}
+	}
+
+	s.latency.observe(end.Sub(start))
+	if s.cfg.metrics != nil {
+		s.cfg.metrics.SendDuration(end.Sub(start))
+		s.cfg.metrics.FrameSize(len(b))
+	}
+
+	return nil
+}
+
+// keepAliveChecks is how many times per interval the keepalive checks
+// whether the stream is idle.
+const keepAliveChecks = 4
+
+// keepAlive resends the last message so that no more than interval passes
+// without sending a message.
+//
+// It checks every interval/keepAliveChecks and resends once the stream has
+// been idle for the whole interval minus one check period, so a frame sent
+// just after a check can't make the gap reach interval.
+func (s *Stream) keepAlive(interval time.Duration) {
+	period := max(interval/keepAliveChecks, 1)
+	p := s.newPacer(period)
+	for p.wait(s.quit) {
+		if err := s.resend(interval - period); err != nil {
+			s.errs.report(fmt.Errorf("keepalive: %w", err))
+			s.recoverOnFailure(err)
+		}
+	}
+}
+
+// resend writes the last message again if it is older than maxAge. If no
+// message was sent yet, it sends a message without channels.
+func (s *Stream) resend(maxAge time.Duration) error {
+	if s.Paused() {
+		return nil // The pause sends its own hold frames.
+	}
+
+	s.mu.Lock()
+	idle := s.clk.Now().Sub(s.lastSend) >= maxAge
+	b := s.last
+	s.mu.Unlock()
+
+	if !idle {
+		return nil
+	}
+	if b == nil {
+		var err error
+		b, err = s.message(nil).MarshalBinary()
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.writeRetry(b)
+}
+
 // client is used to initiate a Stream.
 type client struct {
 	http *http.Client
@@ -65,16 +594,29 @@ type client struct {
 	username   string // The username returned when creating a Hue user.
 	clientKey  string // The clientKey returned when creating a Hue user.
 	streamPort int    // The streamPort is always 2100.
+	cfg        config // The protocol version and DTLS settings are used.
+
+	keyMu      sync.Mutex
+	key        []byte // The decoded clientKey set by WithClientKey, if any.
+	keyCleared bool   // Set when Close zeroes key.
+
+	hsMu      sync.Mutex
+	handshake HandshakeDiagnostics // Of the last successful handshake.
+
+	sendBuffer atomic.Int64 // The send buffer of the socket, zero if unknown.
+
+	// dial, if set, replaces handshakeUDP to open the stream connection.
+	dial func(ctx context.Context) (net.Conn, error)
 }
 
 // newClient creates a new client used to start a Hue Entertainment Stream.
 //
 // See the Example to know how to get the host, username and clientKey.
 func newClient(host, username, clientKey string) *client {
-	transport := *http.DefaultTransport.(*http.Transport)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
 	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	c := &http.Client{
-		Transport: &transport,
+		Transport: transport,
 	}
 
 	return &client{
@@ -83,38 +625,147 @@ func newClient(host, username, clientKey string) *client {
 		username:   username,
 		clientKey:  clientKey,
 		streamPort: 2100,
+		cfg:        newConfig(nil),
 	}
 }
 
 // initStream initiates a stream in the given area.
 // Only one stream session can take place at a time.
-func (c *client) initStream(ctx context.Context, areaID string) (*Stream, error) {
-	if err := c.startStream(ctx, areaID); err != nil {
-		return nil, err
+//
+// If it fails after the start action may have reached the bridge, the stream
+// is stopped again so the area is not left busy.
+func (c *client) initStream(ctx context.Context, areaID string, cfg config) (s *Stream, err error) {
+	ctx, span := cfg.tracer().Start(ctx, "huestream.stream",
+		slog.String("bridge.host", c.host),
+		slog.String("huestream.area_id", areaID),
+	)
+	defer func() {
+		if err != nil {
+			span.End(err)
+		}
+	}()
+
+	if cfg.capture != nil {
+		if cfg.captureWriter, err = newCaptureWriter(*cfg.capture); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				cfg.captureWriter.Close()
+			}
+		}()
 	}
-	conn, err := c.handshakeUDP(ctx)
+
+	if cfg.mirror != "" {
+		if cfg.mirrorConn, err = (&net.Dialer{}).DialContext(ctx, "udp", cfg.mirror); err != nil {
+			return nil, fmt.Errorf("mirror: %w", err)
+		}
+		defer func() {
+			if err != nil {
+				cfg.mirrorConn.Close()
+			}
+		}()
+	}
+
+	var channels []Channel
+	if !cfg.noPreflight && cfg.version != wire.Version1 {
+		if channels, err = c.preflight(ctx, areaID); err != nil {
+			return nil, err
+		}
+	}
+
+	var smartScenes []string
+	if cfg.restoreSmartScenes && cfg.version != wire.Version1 {
+		if smartScenes, err = c.activeSmartScenes(ctx); err != nil {
+			return nil, fmt.Errorf("smart scenes: %w", err)
+		}
+	}
+
+	if !cfg.externalLifecycle {
+		if err := c.startStream(ctx, areaID); err != nil {
+			// When ctx is canceled mid-request the bridge may still have
+			// started the stream.
+			if ctx.Err() != nil {
+				return nil, c.undoStart(ctx, areaID, err)
+			}
+			return nil, err
+		}
+	}
+	conn, err := c.connect(ctx)
 	if err != nil {
-		return nil, err
+		if cfg.externalLifecycle {
+			return nil, err
+		}
+		return nil, c.undoStart(ctx, areaID, err)
 	}
 
-	stream := &Stream{
-		conn:   conn,
-		areaID: areaID,
-		client: c,
+	span.AddEvent("handshake")
+	s = newStream(conn, c, areaID, cfg)
+	s.span, s.spanCtx = span, ctx
+	s.smartScenes = smartScenes
+	s.channels = channels
+	if cfg.autoClose {
+		s.connMu.Lock()
+		s.stopAutoClose = context.AfterFunc(ctx, func() {
+			s.logger().Info("start context done, closing the stream")
+			s.Close()
+		})
+		s.connMu.Unlock()
 	}
+	s.logger().Info("stream started", "version", cfg.version)
+	return s, nil
+}
+
+// undoStartTimeout bounds the stop action issued when Start fails.
+const undoStartTimeout = 3 * time.Second
 
-	return stream, nil
+// undoStart stops the stream after a failed start and returns err, joined
+// with the stop failure if any. The stop action gets its own timeout since
+// ctx is likely already done.
+func (c *client) undoStart(ctx context.Context, areaID string, err error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), undoStartTimeout)
+	defer cancel()
+
+	if stopErr := c.stopStream(ctx, areaID); stopErr != nil {
+		return errors.Join(err, fmt.Errorf("stop stream: %w", stopErr))
+	}
+	return err
+}
+
+// connect opens the DTLS connection used to send the frames.
+func (c *client) connect(ctx context.Context) (net.Conn, error) {
+	if c.dial != nil {
+		return c.dial(ctx)
+	}
+	conn, err := c.handshakeUDP(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
 }
 
 func (c *client) setAuthHeader(req *http.Request) {
 	req.Header.Set("hue-application-key", c.username)
 }
 
+// apiURL returns the URL of the bridge API, where the paths of both API
+// versions start.
+func (c *client) apiURL() string {
+	if c.cfg.baseURL != "" {
+		return strings.TrimSuffix(c.cfg.baseURL, "/")
+	}
+	return "https://" + c.host
+}
+
 func (c *client) baseURL() string {
-	return fmt.Sprintf("https://%s/clip/v2/resource/entertainment_configuration", c.host)
+	return c.apiURL() + "/clip/v2/resource/entertainment_configuration"
 }
 
 func (c *client) streamAction(ctx context.Context, areaID, action string) error {
+	if c.cfg.version == wire.Version1 {
+		return c.streamActionV1(ctx, areaID, action == "start")
+	}
+
 	url := c.baseURL() + "/" + areaID
 	data := strings.NewReader(fmt.Sprintf(`{"action":%q}`, action))
 	req, err := http.NewRequestWithContext(ctx, "PUT", url, data)
@@ -130,74 +781,280 @@ func (c *client) streamAction(ctx context.Context, areaID, action string) error
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("status code not OK, got %d", resp.StatusCode)
+		return &StatusError{Code: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// preflight returns the channels of the area, checking that it exists and
+// has channels to stream to.
+func (c *client) preflight(ctx context.Context, areaID string) ([]Channel, error) {
+	var configs []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Channels []struct {
+			ID       int      `json:"channel_id"`
+			Position Position `json:"position"`
+		} `json:"channels"`
+	}
+	err := c.traced(ctx, "preflight", areaID, func(ctx context.Context) error {
+		return c.getResource(ctx, "entertainment_configuration/"+areaID, &configs)
+	})
+	var status *StatusError
+	switch {
+	case errors.As(err, &status) && status.Code == http.StatusNotFound, err == nil && len(configs) == 0:
+		return nil, &AreaError{AreaID: areaID, Err: ErrAreaNotFound}
+	case err != nil:
+		return nil, fmt.Errorf("preflight: %w", err)
+	}
+
+	cfg := configs[0]
+	if len(cfg.Channels) == 0 {
+		return nil, &AreaError{AreaID: areaID, Name: cfg.Metadata.Name, Err: ErrNoChannels}
+	}
+	channels := make([]Channel, len(cfg.Channels))
+	for i, ch := range cfg.Channels {
+		channels[i] = Channel{ID: ch.ID, Position: ch.Position}
+	}
+	return channels, nil
+}
+
+// getConfiguration checks that the bridge serves the entertainment
+// configuration of the area.
+func (c *client) getConfiguration(ctx context.Context, areaID string) error {
+	return c.traced(ctx, "configuration", areaID, func(ctx context.Context) error {
+		return c.fetchConfiguration(ctx, areaID)
+	})
+}
+
+func (c *client) fetchConfiguration(ctx context.Context, areaID string) error {
+	if c.cfg.version == wire.Version1 {
+		return c.getGroupV1(ctx, areaID)
+	}
+
+	url := c.baseURL() + "/" + areaID
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	c.setAuthHeader(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Code: resp.StatusCode}
 	}
 
 	return nil
 }
 
 func (c *client) startStream(ctx context.Context, areaID string) error {
-	return c.streamAction(ctx, areaID, "start")
+	return c.traced(ctx, "start", areaID, func(ctx context.Context) error {
+		return c.loggedAction(ctx, areaID, "start")
+	})
 }
 
 func (c *client) stopStream(ctx context.Context, areaID string) error {
-	return c.streamAction(ctx, areaID, "stop")
+	return c.traced(ctx, "stop", areaID, func(ctx context.Context) error {
+		return c.loggedAction(ctx, areaID, "stop")
+	})
+}
+
+// loggedAction runs the stream action and logs its outcome.
+func (c *client) loggedAction(ctx context.Context, areaID, action string) error {
+	start := c.cfg.clock.Now()
+	err := c.streamAction(ctx, areaID, action)
+	attrs := []any{"action", action, "area_id", areaID, "duration", c.cfg.clock.Now().Sub(start)}
+	if err != nil {
+		c.logger().WarnContext(ctx, "stream action failed", append(attrs, "error", err)...)
+	} else {
+		c.logger().DebugContext(ctx, "stream action", attrs...)
+	}
+	return err
+}
+
+// logger returns the logger of the client, with the bridge_host attribute.
+func (c *client) logger() *slog.Logger {
+	return c.cfg.logger().With("bridge_host", c.host)
 }
 
 func (c *client) handshakeUDP(ctx context.Context) (*dtls.Conn, error) {
 	addr := &net.UDPAddr{IP: net.ParseIP(c.host), Port: c.streamPort}
+	identity := c.username
+	if c.cfg.pskIdentity != "" {
+		identity = c.cfg.pskIdentity
+	}
+	provider := c.cfg.pskProvider
+	if provider == nil {
+		provider = c.staticKey
+	}
+	// The PSK callback of pion has no context, the key is fetched before
+	// dialing.
+	psk, err := provider(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("handshake: psk provider: %w", err)
+	}
+	defer clear(psk) // The handshake is done with it once this returns.
 	config := &dtls.Config{
 		PSK: func(hint []byte) ([]byte, error) {
-			return hex.DecodeString(c.clientKey)
+			return psk, nil
 		},
-		PSKIdentityHint: []byte(c.username),
-		CipherSuites:    []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+		PSKIdentityHint: []byte(identity),
+		CipherSuites:    c.cfg.cipherSuites,
+		MTU:             c.cfg.mtu, // Zero is the pion default.
+	}
+	if c.cfg.log != nil {
+		config.LoggerFactory = pionLoggers{c.logger()}
 	}
+	start := c.cfg.clock.Now()
 
-	conn, err := dtls.Dial("udp", addr, config)
+	rec := newHandshakeRecorder(start)
+	conn, err := c.dialDTLS(ctx, addr, config, rec)
 	if err != nil {
 		return nil, fmt.Errorf("dial %v: %w", addr, err)
 	}
 
 	if err := conn.HandshakeContext(ctx); err != nil {
-		return nil, fmt.Errorf("handshake: %w", err)
+		conn.Close()
+		d := rec.finish(c.cfg.clock.Now())
+		c.logger().WarnContext(ctx, "handshake failed", handshakeAttrs(d, "cause", d.Cause(), "error", err)...)
+		// The alert sent by a bridge not supporting any of the suites does
+		// not name them.
+		return nil, &HandshakeError{Suites: suiteNames(config.CipherSuites), Diagnostics: d, Err: err}
 	}
+	d := rec.finish(c.cfg.clock.Now())
+	c.hsMu.Lock()
+	c.handshake = d
+	c.hsMu.Unlock()
+	c.logger().DebugContext(ctx, "handshake completed", handshakeAttrs(d)...)
 
 	return conn, nil
 }
 
+// dialDTLS opens the DTLS connection to addr, over a connection returned by
+// the dialer set by WithDialer if any, followed by rec.
+//
+// The default is a connected UDP socket, on which the ICMP port unreachable
+// answers are reported.
+func (c *client) dialDTLS(ctx context.Context, addr *net.UDPAddr, config *dtls.Config, rec *handshakeRecorder) (*dtls.Conn, error) {
+	dial := c.cfg.dialer
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+
+	conn, err := dial(ctx, "udp", addr.String())
+	if err != nil {
+		return nil, err
+	}
+	if err := c.tuneSocket(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	rc := &recordedConn{Conn: conn, r: rec}
+	dc, err := dtls.Client(dtlsnet.PacketConnFromConn(rc), conn.RemoteAddr(), config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return dc, nil
+}
+
+// staticKey is the default PSK provider, it returns the key set by
+// WithClientKey or decodes the clientKey.
+func (c *client) staticKey(context.Context) ([]byte, error) {
+	c.keyMu.Lock()
+	defer c.keyMu.Unlock()
+
+	if c.keyCleared {
+		return nil, ErrClosed
+	}
+	if c.key != nil {
+		return slices.Clone(c.key), nil
+	}
+	return hex.DecodeString(c.clientKey)
+}
+
+// clearKey zeroes the key set by WithClientKey.
+func (c *client) clearKey() {
+	c.keyMu.Lock()
+	defer c.keyMu.Unlock()
+	clear(c.key)
+	c.keyCleared = c.key != nil
+}
+
 type message struct {
 	areaID   string
+	version  int // Zero means wire.VersionMajor.
+	sequence uint8
 	idColors map[int]color.Color
 }
 
+// message returns the message setting idColors in the protocol version of
+// the Stream, numbered with the next sequence number.
+func (s *Stream) message(idColors Frame) message {
+	return message{
+		areaID:   s.areaID,
+		version:  s.cfg.version,
+		sequence: uint8(s.sequence.Add(1) - 1),
+		idColors: s.render(idColors),
+	}
+}
+
+// SetSequence sets the sequence number of the next message built by the
+// Stream, a frame passed to Send or one built by keepalive or Pause.
+//
+// Every message takes the next number, wrapping from 255 to 0, even if its
+// write fails. Resent messages keep their number. The bridge ignores the
+// sequence, it is useful to match captures of the traffic with the
+// application logs.
+func (s *Stream) SetSequence(n uint8) { s.sequence.Store(uint32(n)) }
+
+// MarshalBinary encodes the message in the HueStream format, with the
+// channels sorted by ID.
 func (m message) MarshalBinary() ([]byte, error) {
-	if len(m.idColors) > 20 {
-		return nil, fmt.Errorf("maximum number of channels is 20, got %d", len(m.idColors))
-	}
-
-	// https://developers.meethue.com/develop/hue-entertainment/hue-entertainment-api/#StreamCaption
-	// MaxSize = 192 bytes.
-	var buf []byte
-	buf = append(buf, "HueStream"...) // Protocol name.
-	buf = append(buf, 0x2, 0x0)       // Version 2.0.
-	buf = append(buf, 0x0)            // Sequence ID - ignored.
-	buf = append(buf, 0x0, 0x0)       // Reserved 2 bytes.
-	buf = append(buf, 0x0)            // ColorSpace = RGB.
-	buf = append(buf, 0x0)            // Reserved 1 byte.
-	buf = append(buf, m.areaID...)    // EntertainmentConfID.
-
-	for channelID, color := range m.idColors {
-		// An int can overflow, but it would be a callers error,
-		// the max channelID is 20, even a uint8 would not solve the issue.
-		buf = append(buf, byte(channelID))
+	h := wire.Header{
+		Version:    wire.VersionMajor,
+		Sequence:   m.sequence,
+		ColorSpace: wire.ColorSpaceRGB,
+		AreaID:     m.areaID,
+	}
+	if m.version == wire.Version1 {
+		// Version 1 messages do not carry the group ID.
+		h.Version, h.AreaID = wire.Version1, ""
+	}
+	f := wire.Frame{Header: h}
+	if len(m.idColors) > 0 {
+		f.Channels = make([]wire.Channel, 0, len(m.idColors))
+	}
 
+	// Sized keys instead of slices.Sorted, which grows its slice.
+	ids := make([]int, 0, len(m.idColors))
+	for id := range m.idColors {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	for _, channelID := range ids {
 		// RGBA returns alpha-premultiplied colors, so just discard the alpha.
-		r, g, b, _ := color.RGBA()
-		buf = binary.BigEndian.AppendUint16(buf, uint16(r))
-		buf = binary.BigEndian.AppendUint16(buf, uint16(g))
-		buf = binary.BigEndian.AppendUint16(buf, uint16(b))
+		r, g, b, _ := m.idColors[channelID].RGBA()
+		// An int can overflow, but it would be a callers error,
+		// the max channelID is 20, even a uint8 would not solve the issue.
+		id := uint16(byte(channelID))
+		if m.version == wire.Version1 {
+			id = uint16(channelID) // A light ID.
+		}
+		f.Channels = append(f.Channels, wire.Channel{
+			ID:     id,
+			Values: [3]uint16{uint16(r), uint16(g), uint16(b)},
+		})
 	}
 
-	return buf, nil
+	return wire.Encode(f)
 }
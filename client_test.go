@@ -0,0 +1,481 @@
+package huestream
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"image/color"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/wire"
+)
+
+// pipeStream returns a Stream writing to one end of a net.Pipe and a channel
+// receiving every datagram written to it.
+func pipeStream(t *testing.T, opts ...Option) (*Stream, <-chan []byte) {
+	t.Helper()
+
+	local, remote := net.Pipe()
+
+	frames := make(chan []byte, 64)
+	go func() {
+		defer close(frames)
+		buf := make([]byte, 1024)
+		for {
+			n, err := remote.Read(buf)
+			if err != nil {
+				return
+			}
+			frames <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+
+	s := newStream(local, nil, testAreaID, newConfig(opts))
+	t.Cleanup(func() {
+		remote.Close()
+
+		s.Close()
+	})
+
+	return s, frames
+}
+
+const testAreaID = "1a8d99cc-967b-44f2-9202-43f976c0fa6e"
+
+// blockedStream returns a Stream whose writes block until their deadline,
+// as if the network stack were stuck.
+func blockedStream(t *testing.T) *Stream {
+	t.Helper()
+
+	local, remote := net.Pipe()
+	s := newStream(local, nil, testAreaID, newConfig(nil))
+	t.Cleanup(func() {
+		remote.Close()
+		s.Close()
+	})
+
+	return s
+}
+
+func TestSendContextCanceled(t *testing.T) {
+	s := blockedStream(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := s.SendContext(ctx, Frame{0: color.White})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("SendContext took %v to return after cancel", elapsed)
+	}
+}
+
+func TestSendContextDeadline(t *testing.T) {
+	s := blockedStream(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := s.SendContext(ctx, Frame{0: color.White})
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("got %v, want a *TimeoutError", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("%v does not wrap %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSendContextClearsDeadline(t *testing.T) {
+	s, frames := pipeStream(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	if err := s.SendContext(ctx, Frame{0: color.White}); err != nil {
+		t.Fatal(err)
+	}
+	<-frames
+	cancel()
+
+	// A later Send must not inherit the expired deadline.
+	time.Sleep(30 * time.Millisecond)
+	if err := s.Send(Frame{0: color.Black}); err != nil {
+		t.Fatalf("Send after SendContext: %v", err)
+	}
+}
+
+// funcConn is a net.Conn whose writes are handled by write.
+type funcConn struct {
+	net.Conn // Nil, only the methods below are implemented.
+	write    func(b []byte) (int, error)
+	closeErr error
+}
+
+func (c *funcConn) Write(b []byte) (int, error)      { return c.write(b) }
+func (c *funcConn) Close() error                     { return c.closeErr }
+func (c *funcConn) SetWriteDeadline(time.Time) error { return nil }
+func (c *funcConn) LocalAddr() net.Addr              { return &net.UDPAddr{} }
+func (c *funcConn) RemoteAddr() net.Addr             { return &net.UDPAddr{} }
+
+// fakeBridge returns a client talking to a CLIP server served by h. A nil h
+// answers every request with 200 OK.
+func fakeBridge(t *testing.T, h http.Handler) *client {
+	t.Helper()
+
+	if h == nil {
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	}
+	srv := httptest.NewTLSServer(h)
+	t.Cleanup(srv.Close)
+
+	return newClient(srv.Listener.Addr().String(), "username", "clientkey")
+}
+
+func TestCloseUnblocksBackgroundWrite(t *testing.T) {
+	local, remote := net.Pipe() // Nobody reads remote, writes block.
+	defer remote.Close()
+
+	s := newStream(local, fakeBridge(t, nil), testAreaID, newConfig([]Option{
+		WithKeepAlive(time.Millisecond),
+		WithIdleThreshold(0),
+	}))
+	time.Sleep(20 * time.Millisecond) // Let the keepalive block in Write.
+
+	done := make(chan error)
+	go func() { done <- s.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked by a pending keepalive write")
+	}
+}
+
+func TestClose(t *testing.T) {
+	errConnClose := errors.New("conn close failed")
+
+	tests := []struct {
+		name               string
+		stopStatus         []int // The answers to the stop actions, then 200.
+		conn               net.Conn
+		noClient           bool
+		wantStop, wantConn bool // Whether the error reports each failure.
+		wantStops          int32
+	}{
+		{name: "ok", conn: &funcConn{}, wantStops: 1},
+		{name: "stop fails", stopStatus: []int{500, 500, 500}, conn: &funcConn{}, wantStop: true, wantStops: stopAttempts},
+		{name: "stop fails once", stopStatus: []int{503}, conn: &funcConn{}, wantStops: 2},
+		{name: "stop refused", stopStatus: []int{404}, conn: &funcConn{}, wantStop: true, wantStops: 1},
+		{name: "conn close fails", conn: &funcConn{closeErr: errConnClose}, wantConn: true, wantStops: 1},
+		{name: "both fail", stopStatus: []int{500, 500, 500}, conn: &funcConn{closeErr: errConnClose}, wantStop: true, wantConn: true, wantStops: stopAttempts},
+		{name: "nil conn", conn: nil, wantStops: 1},
+		{name: "nil conn and stop fails", stopStatus: []int{500, 500, 500}, conn: nil, wantStop: true, wantStops: stopAttempts},
+		{name: "nil client", noClient: true, conn: &funcConn{closeErr: errConnClose}, wantConn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stops atomic.Int32
+			c := fakeBridge(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if n := int(stops.Add(1)); n <= len(tt.stopStatus) {
+					w.WriteHeader(tt.stopStatus[n-1])
+				}
+			}))
+			if tt.noClient {
+				c = nil
+			}
+
+			s := newStream(tt.conn, c, testAreaID, newConfig(nil))
+			err := s.Close()
+
+			if got := errors.Is(err, ErrStopFailed); got != tt.wantStop {
+				t.Errorf("Close() = %v, stop failure reported: %v, want %v", err, got, tt.wantStop)
+			}
+			if got := errors.Is(err, errConnClose); got != tt.wantConn {
+				t.Errorf("Close() = %v, conn failure reported: %v, want %v", err, got, tt.wantConn)
+			}
+			if stops.Load() != tt.wantStops {
+				t.Errorf("stop action sent %d times, want %d", stops.Load(), tt.wantStops)
+			}
+
+			if err := s.Close(); err != nil {
+				t.Errorf("second Close() = %v, want nil", err)
+			}
+			if stops.Load() != tt.wantStops {
+				t.Errorf("second Close sent the stop action again")
+			}
+		})
+	}
+}
+
+func TestCloseContextStopsRetrying(t *testing.T) {
+	var stops atomic.Int32
+	c := fakeBridge(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stops.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	s := newStream(&funcConn{}, c, testAreaID, newConfig(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), stopBackoff/2)
+	defer cancel()
+	if err := s.CloseContext(ctx); !errors.Is(err, ErrStopFailed) {
+		t.Errorf("CloseContext() = %v, want ErrStopFailed", err)
+	}
+	if stops.Load() != 1 {
+		t.Errorf("stop action sent %d times after ctx was done, want 1", stops.Load())
+	}
+}
+
+func TestCloseZeroStream(t *testing.T) {
+	var s Stream
+	if err := s.Close(); err != nil {
+		t.Errorf("Close() = %v, want nil", err)
+	}
+}
+
+func TestStartCanceledTearsDown(t *testing.T) {
+	tests := []struct {
+		name string
+		// startDelay delays the start action answer, dialBlocks makes the
+		// handshake wait for ctx.
+		startDelay time.Duration
+		dialBlocks bool
+	}{
+		{name: "during start action", startDelay: time.Second},
+		{name: "during handshake", dialBlocks: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var actions []string
+			c := fakeBridge(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				actions = append(actions, string(body))
+				mu.Unlock()
+				if strings.Contains(string(body), "start") {
+					time.Sleep(tt.startDelay)
+				}
+			}))
+
+			var dialed bool
+			c.dial = func(ctx context.Context) (net.Conn, error) {
+				dialed = true
+				if tt.dialBlocks {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+				return &funcConn{}, nil
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			if _, err := c.initStream(ctx, testAreaID, newConfig([]Option{WithoutPreflight()})); err == nil {
+				t.Fatal("initStream should fail when ctx expires")
+			}
+			if tt.dialBlocks != dialed {
+				t.Errorf("dialed = %v, want %v", dialed, tt.dialBlocks)
+			}
+
+			// The start handler may still be sleeping, wait for the stop.
+			deadline := time.Now().Add(3 * time.Second)
+			for {
+				mu.Lock()
+				got := strings.Join(actions, " ")
+				mu.Unlock()
+				if strings.Contains(got, `"stop"`) {
+					return
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("no stop action after a canceled start, got %s", got)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestStartFailureWithoutStartNoStop(t *testing.T) {
+	var actions atomic.Int32
+	c := fakeBridge(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actions.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	if _, err := c.initStream(context.Background(), testAreaID, newConfig([]Option{WithoutPreflight()})); err == nil {
+		t.Fatal("initStream should fail on a 404")
+	}
+	if got := actions.Load(); got != 1 {
+		t.Errorf("bridge got %d requests, want only the start action", got)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr error
+		want    []Channel
+	}{
+		{
+			name: "ok",
+			body: `{"data":[{"metadata":{"name":"TV"},"channels":[{"channel_id":0,"position":{"x":-1}},{"channel_id":3,"position":{"x":1}}]}]}`,
+			want: []Channel{{ID: 0, Position: Position{X: -1}}, {ID: 3, Position: Position{X: 1}}},
+		},
+		{name: "not found", status: http.StatusNotFound, body: `{"data":[]}`, wantErr: ErrAreaNotFound},
+		{name: "no channels", body: `{"data":[{"metadata":{"name":"TV"},"channels":[]}]}`, wantErr: ErrNoChannels},
+		{name: "bridge failure", status: http.StatusServiceUnavailable, body: `{"data":[]}`, wantErr: &StatusError{Code: 503}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actions atomic.Int32
+			c := fakeBridge(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "GET" {
+					actions.Add(1)
+					return
+				}
+				w.WriteHeader(cmp.Or(tt.status, http.StatusOK))
+				io.WriteString(w, tt.body)
+			}))
+			c.dial = func(ctx context.Context) (net.Conn, error) { return &funcConn{}, nil }
+
+			s, err := c.initStream(context.Background(), testAreaID, newConfig(nil))
+			if tt.wantErr != nil {
+				var areaErr *AreaError
+				var statusErr *StatusError
+				switch {
+				case errors.As(tt.wantErr, &statusErr):
+					if !errors.As(err, &statusErr) || statusErr.Code != 503 {
+						t.Errorf("got %v, want %v", err, tt.wantErr)
+					}
+				case !errors.Is(err, tt.wantErr) || !errors.As(err, &areaErr) || areaErr.AreaID != testAreaID:
+					t.Errorf("got %v, want an *AreaError wrapping %v", err, tt.wantErr)
+				}
+				if actions.Load() != 0 {
+					t.Error("stream started after a failed preflight")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			if !reflect.DeepEqual(s.channels, tt.want) {
+				t.Errorf("got channels %+v, want %+v", s.channels, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelIDs(t *testing.T) {
+	s := newStream(&funcConn{}, nil, testAreaID, newConfig(nil))
+	defer s.Close()
+	if n, ids := s.NumChannels(), s.ChannelIDs(); n != 0 || ids != nil {
+		t.Errorf("without preflight got %d channels %v, want 0 and nil", n, ids)
+	}
+
+	s.channels = []Channel{{ID: 4}, {ID: 0}, {ID: 2}}
+	ids := s.ChannelIDs()
+	if n := s.NumChannels(); n != 3 || !slices.Equal(ids, []int{0, 2, 4}) {
+		t.Errorf("got %d channels %v, want 3 channels [0 2 4]", n, ids)
+	}
+	ids[0] = 9
+	if got := s.ChannelIDs(); got[0] != 0 {
+		t.Error("ChannelIDs returned the internal slice")
+	}
+}
+
+// addrConn is a net.Conn with fixed addresses.
+type addrConn struct {
+	funcConn
+	local, remote net.Addr
+}
+
+func (c *addrConn) LocalAddr() net.Addr  { return c.local }
+func (c *addrConn) RemoteAddr() net.Addr { return c.remote }
+
+func TestStreamAddrs(t *testing.T) {
+	bridge := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 2), Port: 2100}
+	first := &addrConn{local: &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50000}, remote: bridge}
+	second := &addrConn{local: &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50001}, remote: bridge}
+
+	s := newStream(first, nil, testAreaID, newConfig(nil))
+	if got := s.AreaID(); got != testAreaID {
+		t.Errorf("AreaID() = %q, want %q", got, testAreaID)
+	}
+	if got := s.LocalAddr(); got != first.local {
+		t.Errorf("LocalAddr() = %v, want %v", got, first.local)
+	}
+	if got := s.RemoteAddr(); got != bridge {
+		t.Errorf("RemoteAddr() = %v, want %v", got, bridge)
+	}
+
+	// A recovered session reports the new connection.
+	s.replaceConn(second)
+	if got := s.LocalAddr(); got != second.local {
+		t.Errorf("LocalAddr() after recovery = %v, want %v", got, second.local)
+	}
+
+	// The last known values survive Close.
+	s.Close()
+	if got := s.LocalAddr(); got != second.local {
+		t.Errorf("LocalAddr() after Close = %v, want %v", got, second.local)
+	}
+	if got := s.AreaID(); got != testAreaID {
+		t.Errorf("AreaID() after Close = %q, want %q", got, testAreaID)
+	}
+
+	var zero Stream
+	if zero.LocalAddr() != nil || zero.RemoteAddr() != nil {
+		t.Error("a Stream without connection should report nil addresses")
+	}
+}
+
+func TestSetSequence(t *testing.T) {
+	s, frames := pipeStream(t)
+
+	var got []uint8
+	send := func() {
+		t.Helper()
+		if err := s.Send(Frame{1: color.White}); err != nil {
+			t.Fatal(err)
+		}
+		f, err := wire.Decode(<-frames)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, f.Sequence)
+	}
+
+	send()
+	send()
+	s.SetSequence(254)
+	send()
+	send()
+	send() // Wraps around.
+
+	if want := []uint8{0, 1, 254, 255, 0}; !slices.Equal(got, want) {
+		t.Errorf("sequences %v, want %v", got, want)
+	}
+	if seq := s.Stats().Sequence; seq != 1 {
+		t.Errorf("Stats().Sequence = %d, want 1", seq)
+	}
+}
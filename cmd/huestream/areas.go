@@ -0,0 +1,73 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rschio/huestream"
+)
+
+func areas(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("areas", "[-json] [bridge flags]", stderr)
+	asJSON := fs.Bool("json", false, "print JSON")
+	bridge := addBridgeFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	creds, opts, err := bridge.credentials()
+	if err != nil {
+		return err
+	}
+
+	areas, err := huestream.Areas(ctx, creds.Host, creds.Username, opts...)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(stdout, areas)
+	}
+	for i, a := range areas {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintf(stdout, "%s  %s (%s, %s)\n", a.ID, a.Name, a.Type, a.Status)
+		if a.Proxy.Node != "" {
+			fmt.Fprintf(stdout, "  proxy: %s (%s)\n", cmp.Or(a.Proxy.Light, a.Proxy.Node), a.Proxy.Mode)
+		}
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  CHANNEL\tX\tY\tZ\tLIGHTS")
+		for _, ch := range a.Channels {
+			p := ch.Position
+			fmt.Fprintf(tw, "  %d\t%.2f\t%.2f\t%.2f\t%s\n", ch.ID, p.X, p.Y, p.Z, strings.Join(ch.Lights, ", "))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findArea returns the area id, or the only area of the bridge if id is
+// empty.
+func findArea(ctx context.Context, creds huestream.Credentials, id string, opts []huestream.Option) (huestream.Area, error) {
+	areas, err := huestream.Areas(ctx, creds.Host, creds.Username, opts...)
+	if err != nil {
+		return huestream.Area{}, err
+	}
+	if id == "" {
+		if len(areas) != 1 {
+			return huestream.Area{}, fmt.Errorf("the bridge has %d areas, choose one with -area", len(areas))
+		}
+		return areas[0], nil
+	}
+	for _, a := range areas {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+	return huestream.Area{}, fmt.Errorf("area %s: %w", id, huestream.ErrAreaNotFound)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rschio/huestream"
+)
+
+// credentialsPath returns the file of the saved credentials.
+func credentialsPath() (string, error) {
+	if p := os.Getenv("HUESTREAM_CREDENTIALS"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "huestream", "credentials.json"), nil
+}
+
+// saveCredentials saves c, readable only by the user as the client key
+// gives control of the lights.
+func saveCredentials(c huestream.Credentials) (path string, err error) {
+	path, err = credentialsPath()
+	if err != nil {
+		return "", err
+	}
+	b, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// loadCredentials returns the saved credentials.
+func loadCredentials() (huestream.Credentials, error) {
+	var c huestream.Credentials
+	path, err := credentialsPath()
+	if err != nil {
+		return c, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("no saved credentials, run huestream register -save: %w", err)
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// bridgeFlags are the flags of the commands talking to a bridge. Unset
+// flags default to the environment variables of the e2e tests, then to the
+// saved credentials.
+type bridgeFlags struct {
+	host      string
+	username  string
+	clientKey string
+	baseURL   string
+	port      int
+	mirror    string
+}
+
+func addBridgeFlags(fs *flag.FlagSet) *bridgeFlags {
+	f := new(bridgeFlags)
+	fs.StringVar(&f.host, "host", os.Getenv("HUESTREAM_BRIDGE_HOST"), "`host` of the bridge ($HUESTREAM_BRIDGE_HOST)")
+	fs.StringVar(&f.username, "username", os.Getenv("HUESTREAM_USERNAME"), "application `key` ($HUESTREAM_USERNAME)")
+	fs.StringVar(&f.clientKey, "clientkey", os.Getenv("HUESTREAM_CLIENT_KEY"), "hex `key` of the stream ($HUESTREAM_CLIENT_KEY)")
+	fs.StringVar(&f.baseURL, "base-url", "", "`URL` of the bridge API, if not https://host")
+	fs.IntVar(&f.port, "stream-port", 0, "UDP `port` of the stream, if not 2100")
+	fs.StringVar(&f.mirror, "mirror", "", "copy the frames streamed to the UDP `address` of huestream watch, as "+defaultMirrorAddr)
+	return f
+}
+
+// credentials returns the credentials of the flags, completed by the saved
+// ones, and the options of the bridge.
+func (f *bridgeFlags) credentials() (huestream.Credentials, []huestream.Option, error) {
+	c := huestream.Credentials{Host: f.host, Username: f.username, ClientKey: f.clientKey}
+	if c.Host == "" || c.Username == "" || c.ClientKey == "" {
+		saved, err := loadCredentials()
+		if err != nil {
+			return c, nil, err
+		}
+		c.Host = cmp.Or(c.Host, saved.Host)
+		c.Username = cmp.Or(c.Username, saved.Username)
+		c.ClientKey = cmp.Or(c.ClientKey, saved.ClientKey)
+	}
+
+	var opts []huestream.Option
+	if f.baseURL != "" {
+		opts = append(opts, huestream.WithBaseURL(f.baseURL))
+	}
+	if f.port != 0 {
+		opts = append(opts, huestream.WithStreamPort(f.port))
+	}
+	if f.mirror != "" {
+		opts = append(opts, huestream.WithMirror(f.mirror))
+	}
+	return c, opts, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/rschio/huestream"
+)
+
+func discover(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("discover", "[-json]", stderr)
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	bridges, err := huestream.Discover(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(stdout, bridges)
+	}
+	if len(bridges) == 0 {
+		fmt.Fprintln(stderr, "no bridge found")
+		return nil
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tHOST")
+	for _, b := range bridges {
+		fmt.Fprintf(tw, "%s\t%s\n", b.ID, b.Host)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+// minAPIVersion is the first bridge API version serving the CLIP v2 API
+// and its entertainment streaming.
+var minAPIVersion = [3]int{1, 48, 0}
+
+// checkTimeout bounds each check of doctor.
+const checkTimeout = 10 * time.Second
+
+// A check is a step of doctor.
+type check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // "pass", "fail" or "skip".
+	Detail string `json:"detail,omitempty"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// doctorRun holds the state of a doctor run, shared by the checks.
+type doctorRun struct {
+	creds  huestream.Credentials
+	opts   []huestream.Option
+	areaID string
+	api    *url.URL
+	http   *http.Client
+	config struct {
+		Name       string `json:"name"`
+		BridgeID   string `json:"bridgeid"`
+		APIVersion string `json:"apiversion"`
+		SWVersion  string `json:"swversion"`
+	}
+}
+
+func doctor(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("doctor", "[-json] [-area id] [bridge flags]", stderr)
+	asJSON := fs.Bool("json", false, "print JSON")
+	areaID := fs.String("area", os.Getenv("HUESTREAM_AREA_ID"), "`ID` of the area streamed by the handshake check ($HUESTREAM_AREA_ID)")
+	bridge := addBridgeFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	creds, opts, err := bridge.credentials()
+	if err != nil {
+		return err
+	}
+	d := &doctorRun{creds: creds, opts: opts, areaID: *areaID}
+	d.api, err = url.Parse(cmp.Or(bridge.baseURL, "https://"+creds.Host))
+	if err != nil {
+		return fmt.Errorf("doctor: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	d.http = &http.Client{Transport: transport}
+
+	steps := []struct {
+		name string
+		run  func(context.Context) (detail string, err error)
+		hint string
+	}{
+		{"resolve host", d.resolve, "check the -host flag and the DNS of the network"},
+		{"reach bridge", d.reach, "check that the bridge is on and on the same network"},
+		{"certificate", d.certificate, "the host may not be a Hue Bridge, or the connection is intercepted"},
+		{"credentials", d.credentials, "register again with huestream register -save"},
+		{"firmware", d.firmware, "update the bridge in the Hue app"},
+		{"stream handshake", d.handshake, "check the clientKey, and that UDP port 2100 is not filtered"},
+	}
+	checks := make([]check, 0, len(steps))
+	failed := 0
+	for _, step := range steps {
+		c := check{Name: step.name, Status: "skip", Detail: "an earlier check failed"}
+		if failed == 0 {
+			stepCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			detail, err := step.run(stepCtx)
+			cancel()
+			c = check{Name: step.name, Status: "pass", Detail: detail}
+			if err != nil {
+				c = check{Name: step.name, Status: "fail", Detail: err.Error(), Hint: step.hint}
+				failed++
+			}
+		}
+		checks = append(checks, c)
+	}
+
+	if *asJSON {
+		if err := printJSON(stdout, checks); err != nil {
+			return err
+		}
+	} else {
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		for _, c := range checks {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", strings.ToUpper(c.Status), c.Name, c.Detail)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		for _, c := range checks {
+			if c.Hint != "" {
+				fmt.Fprintf(stdout, "\n%s: %s.\n", c.Name, c.Hint)
+			}
+		}
+	}
+	if failed > 0 {
+		return errors.New("doctor: the bridge is not ready to stream")
+	}
+	return nil
+}
+
+func (d *doctorRun) resolve(ctx context.Context) (string, error) {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, d.api.Hostname())
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(addrs, ", "), nil
+}
+
+// apiAddr returns the address of the bridge API.
+func (d *doctorRun) apiAddr() string {
+	return net.JoinHostPort(d.api.Hostname(), cmp.Or(d.api.Port(), "443"))
+}
+
+func (d *doctorRun) reach(ctx context.Context) (string, error) {
+	var dialer net.Dialer
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", d.apiAddr())
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+	return fmt.Sprintf("%s in %v", d.apiAddr(), time.Since(start).Round(time.Millisecond)), nil
+}
+
+// certificate checks that the certificate of the bridge names the bridge
+// ID of its configuration. The certificate is self-signed, or signed by
+// the Signify CA, so the common name is the only thing to verify.
+func (d *doctorRun) certificate(ctx context.Context) (string, error) {
+	dialer := tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", d.apiAddr())
+	if err != nil {
+		return "", err
+	}
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	conn.Close()
+	if len(certs) == 0 {
+		return "", errors.New("no certificate")
+	}
+	cn := certs[0].Subject.CommonName
+
+	if err := d.get(ctx, "/api/0/config", &d.config); err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(cn, d.config.BridgeID) {
+		return "", fmt.Errorf("certificate of %q, bridge ID %q", cn, d.config.BridgeID)
+	}
+	return fmt.Sprintf("%s, bridge ID %s", d.config.Name, strings.ToLower(d.config.BridgeID)), nil
+}
+
+func (d *doctorRun) credentials(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", d.api.JoinPath("/auth/v1").String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("hue-application-key", d.creds.Username)
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("username rejected: %w", &huestream.StatusError{Code: resp.StatusCode})
+	}
+	return "application " + resp.Header.Get("hue-application-id"), nil
+}
+
+func (d *doctorRun) firmware(ctx context.Context) (string, error) {
+	v, err := parseVersion(d.config.APIVersion)
+	if err != nil {
+		return "", err
+	}
+	detail := fmt.Sprintf("API %s, software %s", d.config.APIVersion, d.config.SWVersion)
+	if !versionAtLeast(v, minAPIVersion) {
+		return "", fmt.Errorf("%s, entertainment v2 needs API %d.%d.%d", detail, minAPIVersion[0], minAPIVersion[1], minAPIVersion[2])
+	}
+	return detail, nil
+}
+
+func (d *doctorRun) handshake(ctx context.Context) (string, error) {
+	area, err := findArea(ctx, d.creds, d.areaID, d.opts)
+	if err != nil {
+		return "", err
+	}
+	stream, err := huestream.Start(ctx, d.creds.Host, d.creds.Username, d.creds.ClientKey, area.ID, d.opts...)
+	if err != nil {
+		return "", err
+	}
+	hs := stream.Handshake()
+	if err := stream.Close(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("area %s, %s in %v", area.Name, hs.CipherSuite, hs.Elapsed.Round(time.Millisecond)), nil
+}
+
+// get decodes the JSON answer of the bridge API at path into v.
+func (d *doctorRun) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", d.api.JoinPath(path).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %w", path, &huestream.StatusError{Code: resp.StatusCode})
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// parseVersion parses a version as "1.65.0".
+func parseVersion(s string) ([3]int, error) {
+	var v [3]int
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid API version %q", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return v, fmt.Errorf("invalid API version %q", s)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+func versionAtLeast(v, want [3]int) bool {
+	for i := range v {
+		if v[i] != want[i] {
+			return v[i] > want[i]
+		}
+	}
+	return true
+}
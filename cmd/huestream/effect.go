@@ -0,0 +1,205 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/effects"
+	"github.com/rschio/huestream/huesim"
+)
+
+// effectRate is the rate of the effects at speed 1, in Hz.
+const effectRate = 25
+
+func effect(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("effect", "[-area id] [-speed x] [-duration d] [-fade d] [-seed n] [-preview] [bridge flags] name [effect flags]\n       huestream effect list", stderr)
+	pf := addPlayFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("effect: missing effect name, list them with huestream effect list")
+	}
+	name := fs.Arg(0)
+	if name == "list" {
+		return listEffects(stdout)
+	}
+	e, ok := effects.Lookup(name)
+	if !ok {
+		return fmt.Errorf("effect: unknown effect %q, list them with huestream effect list", name)
+	}
+	params := make(effects.Args)
+	addParamFlags(fs, e, params)
+	rest, err := parseInterspersed(fs, fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		fs.Usage()
+		return fmt.Errorf("effect: unexpected argument %q", rest[0])
+	}
+	return pf.play(ctx, "effect", stdout, func(ids []int, opts ...effects.Option) iter.Seq[huestream.Frame] {
+		return e.Frames(ids, effectRate, params, opts...)
+	})
+}
+
+// playFlags are the flags of the commands playing effects.
+type playFlags struct {
+	areaID   *string
+	speed    *float64
+	duration *time.Duration
+	fade     *time.Duration
+	seed     *uint64
+	preview  *bool
+	channels *int
+	bridge   *bridgeFlags
+}
+
+func addPlayFlags(fs *flag.FlagSet) *playFlags {
+	return &playFlags{
+		areaID:   fs.String("area", os.Getenv("HUESTREAM_AREA_ID"), "`ID` of the area, needed if the bridge has more than one ($HUESTREAM_AREA_ID)"),
+		speed:    fs.Float64("speed", 1, "speed of the effect, from 0 to 2"),
+		duration: fs.Duration("duration", 0, "play for `d`, by default until interrupted"),
+		fade:     fs.Duration("fade", time.Second, "fade out for `d` before stopping"),
+		seed:     fs.Uint64("seed", 0, "seed of the random effects, by default the time: the same seed plays the same show"),
+		preview:  fs.Bool("preview", false, "draw the effect in the terminal instead of streaming it"),
+		channels: fs.Int("channels", 6, "`number` of channels drawn by -preview"),
+		bridge:   addBridgeFlags(fs),
+	}
+}
+
+// play plays the frames of an effect for the channels of the area, or in
+// the terminal with -preview, for -duration or until interrupted, then
+// fades out. cmd names the command in the errors.
+func (pf *playFlags) play(ctx context.Context, cmd string, stdout io.Writer, frames func(ids []int, opts ...effects.Option) iter.Seq[huestream.Frame]) (err error) {
+	if *pf.speed <= 0 || *pf.speed > 2 {
+		return fmt.Errorf("%s: speed %v out of 0 to 2", cmd, *pf.speed)
+	}
+	if *pf.duration < 0 || *pf.fade < 0 {
+		return fmt.Errorf("%s: negative -duration or -fade", cmd)
+	}
+
+	var st huestream.Streamer
+	var ids []int
+	const period = time.Second / effectRate
+	if *pf.preview {
+		if *pf.channels < 1 {
+			return fmt.Errorf("%s: invalid number of channels %d", cmd, *pf.channels)
+		}
+		for id := range *pf.channels {
+			ids = append(ids, id)
+		}
+		st = huesim.New(stdout)
+	} else {
+		creds, opts, err := pf.bridge.credentials()
+		if err != nil {
+			return err
+		}
+		area, err := findArea(ctx, creds, *pf.areaID, opts)
+		if err != nil {
+			return err
+		}
+		for _, ch := range area.Channels {
+			ids = append(ids, ch.ID)
+		}
+		// Slow effects send less often than the bridge needs.
+		opts = append(opts, huestream.WithKeepAlive(period))
+		st, err = huestream.Start(ctx, creds.Host, creds.Username, creds.ClientKey, area.ID, opts...)
+		if err != nil {
+			return err
+		}
+	}
+	defer func() { err = cmp.Or(err, st.Close()) }()
+
+	var effectOpts []effects.Option
+	if *pf.seed != 0 {
+		effectOpts = append(effectOpts, effects.WithSeed(*pf.seed))
+	}
+	playCtx, cancel := ctx, context.CancelFunc(func() {})
+	if *pf.duration > 0 {
+		playCtx, cancel = context.WithTimeout(ctx, *pf.duration)
+	}
+	defer cancel()
+	last := &lastFrame{Streamer: st}
+	err = huestream.Play(playCtx, last, frames(ids, effectOpts...), effectRate**pf.speed)
+	if err != nil && playCtx.Err() == nil {
+		return err
+	}
+	if *pf.fade > 0 && last.f != nil {
+		// The fade out also follows an interruption.
+		return fadeOut(context.WithoutCancel(ctx), st, last.f, *pf.fade, period)
+	}
+	return nil
+}
+
+// lastFrame is a Streamer keeping the last frame sent.
+type lastFrame struct {
+	huestream.Streamer
+	f huestream.Frame
+}
+
+func (l *lastFrame) Send(f huestream.Frame) error {
+	l.f = f
+	return l.Streamer.Send(f)
+}
+
+func (l *lastFrame) SendContext(ctx context.Context, f huestream.Frame) error {
+	l.f = f
+	return l.Streamer.SendContext(ctx, f)
+}
+
+// listEffects prints the effects and their flags.
+func listEffects(w io.Writer) error {
+	for _, e := range effects.Registry() {
+		fmt.Fprintf(w, "%s: %s\n", e.Name, e.Help)
+		fs := flag.NewFlagSet(e.Name, flag.ContinueOnError)
+		fs.SetOutput(w)
+		addParamFlags(fs, e, make(effects.Args))
+		fs.PrintDefaults()
+	}
+	return nil
+}
+
+// addParamFlags adds the flags of the parameters of e to fs, setting args.
+func addParamFlags(fs *flag.FlagSet, e effects.Effect, args effects.Args) {
+	for _, p := range e.Params {
+		fs.Var(argValue{p, args}, p.Name, p.Help)
+	}
+}
+
+// argValue is the flag.Value of a parameter of an effect, set in args.
+type argValue struct {
+	p    effects.Param
+	args effects.Args
+}
+
+func (a argValue) String() string {
+	if a.args == nil {
+		return "" // The zero Value of flag.isZeroValue.
+	}
+	if v, ok := a.args[a.p.Name]; ok {
+		return a.p.Format(v)
+	}
+	return a.p.Format(a.p.Default)
+}
+
+func (a argValue) Set(s string) error {
+	v, err := a.p.Parse(s)
+	if err != nil {
+		return err
+	}
+	a.args[a.p.Name] = v
+	return nil
+}
+
+// IsBoolFlag makes the parameters of type bool flags without value.
+func (a argValue) IsBoolFlag() bool { return a.p.Type == effects.BoolParam }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+// flashPeriod is the time a flashing channel stays on, then off.
+var flashPeriod = 300 * time.Millisecond
+
+// identifyColors are the colors of the flashing channels, in turn, so that
+// neighbors are told apart.
+var identifyColors = []color.Color{
+	color.RGBA{R: 255, A: 255},
+	color.RGBA{G: 255, A: 255},
+	color.RGBA{B: 255, A: 255},
+	color.RGBA{R: 255, G: 255, A: 255},
+	color.RGBA{G: 255, B: 255, A: 255},
+	color.RGBA{R: 255, B: 255, A: 255},
+}
+
+func identify(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("identify", "[-area id] [-interval d] [bridge flags]", stderr)
+	areaID := fs.String("area", os.Getenv("HUESTREAM_AREA_ID"), "`ID` of the area, needed if the bridge has more than one ($HUESTREAM_AREA_ID)")
+	interval := fs.Duration("interval", 0, "flash every channel for `d`, instead of waiting for Enter")
+	bridge := addBridgeFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	creds, opts, err := bridge.credentials()
+	if err != nil {
+		return err
+	}
+	area, err := findArea(ctx, creds, *areaID, opts)
+	if err != nil {
+		return err
+	}
+
+	// The bridge restores the lights when the stream stops.
+	stream, err := huestream.Start(ctx, creds.Host, creds.Username, creds.ClientKey, area.ID, opts...)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	next := make(chan bool)
+	done := make(chan struct{})
+	defer close(done)
+	if *interval <= 0 {
+		fmt.Fprintln(stderr, "Press Enter to flash the next channel.")
+		go func() {
+			defer close(next)
+			sc := bufio.NewScanner(stdin)
+			for sc.Scan() {
+				select {
+				case next <- true:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	for i, ch := range area.Channels {
+		fmt.Fprintf(stdout, "now flashing channel %d (light: %s)\n", ch.ID, strings.Join(ch.Lights, ", "))
+
+		var timeout <-chan time.Time
+		if *interval > 0 {
+			timeout = time.After(*interval)
+		}
+		c := identifyColors[i%len(identifyColors)]
+		err := flash(ctx, stream, area.Channels, ch.ID, c, timeout, next)
+		if err == io.EOF || ctx.Err() != nil {
+			return nil // Stopped by the user.
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flash flashes the channel id with c, the other channels off, until
+// timeout or next. It returns io.EOF if next is closed.
+func flash(ctx context.Context, stream *huestream.Stream, channels []huestream.Channel, id int, c color.Color, timeout <-chan time.Time, next <-chan bool) error {
+	tick := time.NewTicker(flashPeriod)
+	defer tick.Stop()
+
+	for on := true; ; on = !on {
+		frame := make(huestream.Frame, len(channels))
+		for _, ch := range channels {
+			frame[ch.ID] = color.Black
+		}
+		if on {
+			frame[id] = c
+		}
+		if err := stream.SendContext(ctx, frame); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return nil
+		case _, ok := <-next:
+			if !ok {
+				return io.EOF
+			}
+			return nil
+		case <-tick.C:
+		}
+	}
+}
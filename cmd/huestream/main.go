@@ -0,0 +1,214 @@
+// Command huestream streams colors to the entertainment areas of a Hue
+// Bridge from the command line.
+//
+// Usage:
+//
+//	huestream discover [-json]
+//	huestream register [-json] [-save] [-name app#device] [-timeout d] host
+//	huestream areas [-json] [bridge flags]
+//	huestream identify [-area id] [-interval d] [bridge flags]
+//	huestream pipe [-area id] [-rate hz] [-fade d] [bridge flags]
+//	huestream record -out file [-area id] [-rate hz] [-fade d] [bridge flags]
+//	huestream replay [-area id] [-loop] [bridge flags] file
+//	huestream set [-area id] [-brightness b] [-hold d] [-fade d] [bridge flags] color
+//	huestream effect [-area id] [-speed x] [-duration d] [-fade d] [-seed n] [-preview] [bridge flags] name [effect flags]
+//	huestream effect list
+//	huestream preset [-pack file] [-area id] [-speed x] [-duration d] [-fade d] [-seed n] [-preview] [bridge flags] name
+//	huestream preset [-pack file] list
+//	huestream script [-area id] [-preview] [bridge flags] file
+//	huestream doctor [-json] [-area id] [bridge flags]
+//	huestream watch [-listen addr]
+//
+// discover lists the bridges of the local network. register registers an
+// application on a bridge, asking to press its link button, and prints the
+// credentials or saves them with -save for the other commands. areas lists
+// the entertainment areas with their channels and lights. identify flashes
+// the channels of an area one at a time, to find which light is which.
+//
+// pipe streams the frames read from stdin, one frame document of the
+// jsonframe package per line: the colors by channel ID, and "all" for the
+// other channels, with an optional transition from the colors before:
+//
+//	{"0":"#ff0000","1":"#0000ff"}
+//	{"all":[30,100],"transition":2}
+//
+// The transitions are played before the next line is read. The last frame
+// is resent at -rate until the next line, the stream is
+// closed at the end of the input. record is pipe also recording the frames
+// with their timing to a show file, that replay plays back, for instance:
+//
+//	my-effect | huestream record -out show.hsr
+//	huestream replay -loop show.hsr
+//
+// set sets every channel of an area to a color, as "#ff8800", "rgb(255,
+// 136, 0)" or "orange", holds it for -hold or until interrupted, then fades
+// out and stops:
+//
+//	huestream set -brightness 0.6 -hold 30s "#ff8800"
+//
+// effect plays an effect of the effects package, for -duration or until
+// interrupted, then fades out. effect list prints the effects and their
+// flags, given after the name of the effect:
+//
+//	huestream effect rainbow -speed 0.5 -duration 2m -period 20s
+//
+// sunrise is a wake-up light, brightening from off to cool white over
+// -length, then holding until interrupted:
+//
+//	huestream effect sunrise -length 20m -end 5000
+//
+// With -preview the effect is drawn in the terminal, no bridge needed.
+//
+// preset plays a preset of the preset package, an effect with its flags
+// saved under a name, with the flags of effect. The seasonal packs of the
+// package are built in, -pack plays the presets of a pack file of your own
+// instead. preset list prints the packs and their presets:
+//
+//	huestream preset -duration 1h pumpkins
+//	huestream preset -pack party.json list
+//
+// script plays a light script of the script package, from a file or from
+// stdin with -, its steps setting colors and playing effects at their time:
+//
+//	0:00 all #000000
+//	0:05 ch1 #ff0000 fade 2s
+//	0:10 effect rainbow 30s
+//
+// The script is checked before streaming, its errors locating the fault by
+// line and column. -preview draws it in the terminal as for effect.
+//
+// watch draws in the terminal the frames another command streams to the
+// bridge, mirrored to it with the -mirror bridge flag:
+//
+//	huestream watch &
+//	huestream effect -mirror 127.0.0.1:2101 rainbow
+//
+// doctor checks step by step that the bridge can be streamed to, from the
+// resolution of its host to a stream handshake, and hints at the fix of the
+// first failure. It exits with 1 if a check fails.
+//
+// The commands talking to a bridge take its credentials from the -host,
+// -username and -clientkey flags, from the $HUESTREAM_BRIDGE_HOST,
+// $HUESTREAM_USERNAME and $HUESTREAM_CLIENT_KEY variables, then from the
+// saved credentials. They are in huestream/credentials.json of the user
+// configuration directory, or in the file named by $HUESTREAM_CREDENTIALS.
+//
+// discover, register, areas and doctor print text for humans, or JSON with -json.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/rschio/huestream"
+)
+
+const usage = `usage: huestream <command> [flags] [args]
+
+commands:
+  discover   list the bridges of the local network
+  register   register an application on a bridge
+  areas      list the entertainment areas and their channels
+  identify   flash the channels of an area one at a time
+  pipe       stream the JSON frames read from stdin
+  record     pipe, recording the frames to a show file
+  replay     play a show file back
+  set        set a color and hold it
+  effect     play an effect, or list them
+  preset     play a preset, or list them
+  script     play a light script
+  doctor     diagnose the connection to a bridge
+  watch      draw the frames mirrored by another command
+
+Run huestream <command> -h for the flags of a command.
+`
+
+// commands are the subcommands, by name.
+var commands = map[string]func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error{
+	"discover": discover,
+	"register": register,
+	"areas":    areas,
+	"identify": identify,
+	"pipe":     pipe,
+	"record":   record,
+	"replay":   replay,
+	"set":      set,
+	"effect":   effect,
+	"preset":   runPreset,
+	"script":   runScript,
+	"doctor":   doctor,
+	"watch":    watch,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, "huestream:", err)
+		if h := errorHint(err); h != "" {
+			fmt.Fprintln(os.Stderr, "hint:", h)
+		}
+		os.Exit(1)
+	}
+}
+
+// errorHint returns a hint at the fix of err, if known.
+func errorHint(err error) string {
+	var timeout *huestream.TimeoutError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, huestream.ErrUnauthorized):
+		return "the bridge does not know the username, register with huestream register -save"
+	case errors.Is(err, huestream.ErrInvalidClientKey):
+		return "the client key is the 32 hex digits printed by huestream register"
+	case errors.Is(err, huestream.ErrAreaNotFound):
+		return "list the entertainment areas with huestream areas"
+	case errors.Is(err, huestream.ErrNoChannels):
+		return "add lights to the entertainment area in the Hue app"
+	case errors.Is(err, huestream.ErrStreamActive):
+		return "another application streams to the area, stop it or wait for its stream to end"
+	case errors.Is(err, huestream.ErrLinkButton):
+		return "press the link button of the bridge"
+	case errors.As(err, &timeout), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr):
+		return "the bridge does not answer, run huestream doctor"
+	}
+	return ""
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		io.WriteString(stderr, usage)
+		return flag.ErrHelp
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		io.WriteString(stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return cmd(ctx, args[1:], stdin, stdout, stderr)
+}
+
+// newFlagSet returns the flag set of a command, printing its usage line
+// and flags to stderr.
+func newFlagSet(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: huestream %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
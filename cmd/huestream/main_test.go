@@ -0,0 +1,648 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huetest"
+	"github.com/rschio/huestream/wire"
+)
+
+func TestRegister(t *testing.T) {
+	t.Setenv("HUESTREAM_CREDENTIALS", filepath.Join(t.TempDir(), "credentials.json"))
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+
+	b := huetest.NewBridge(t)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		b.PressLinkButton()
+	}()
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"register", "-json", "-save", "-base-url", b.URL(), b.Host}
+	if err := run(context.Background(), args, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+
+	want := huestream.Credentials{Host: b.Host, Username: b.Username, ClientKey: b.ClientKey}
+	var got huestream.Credentials
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("printed %+v, want %+v", got, want)
+	}
+	saved, err := loadCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved != want {
+		t.Errorf("saved %+v, want %+v", saved, want)
+	}
+}
+
+func TestRegisterTimeout(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"register", "-timeout", "100ms", "-base-url", b.URL(), b.Host}
+	if err := run(context.Background(), args, nil, &stdout, &stderr); err == nil {
+		t.Fatal("register succeeded without the link button")
+	}
+	if !bytes.Contains(stderr.Bytes(), []byte("Press the link button")) {
+		t.Errorf("no link button prompt: %q", stderr.String())
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"dance"}, nil, &stdout, &stderr); err == nil {
+		t.Error("unknown command accepted")
+	}
+}
+
+func TestAreas(t *testing.T) {
+	t.Setenv("HUESTREAM_CREDENTIALS", filepath.Join(t.TempDir(), "credentials.json"))
+	b := huetest.NewBridge(t)
+	if _, err := saveCredentials(huestream.Credentials{Host: b.Host, Username: b.Username, ClientKey: b.ClientKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"areas", "-base-url", b.URL()}, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+	for _, want := range []string{b.AreaID, "huetest (screen, inactive)", huetest.LightName(2), "proxy: huetest light 0 (auto)"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	args := []string{"areas", "-json", "-base-url", b.URL(), "-username", "intruder"}
+	if err := run(context.Background(), args, nil, &stdout, &stderr); !errors.Is(err, huestream.ErrUnauthorized) {
+		t.Errorf("-username flag not used: got %v", err)
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	defer func(d time.Duration) { flashPeriod = d }(flashPeriod)
+	flashPeriod = 10 * time.Millisecond
+
+	for _, tt := range []struct {
+		name  string
+		args  []string
+		stdin string
+	}{
+		{name: "enter", stdin: strings.Repeat("\n", huetest.Lights+1)},
+		{name: "interval", args: []string{"-interval", "30ms"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := huetest.NewBridge(t)
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"identify"}, bridgeArgs(b)...)
+			args = append(args, tt.args...)
+			if err := run(context.Background(), args, strings.NewReader(tt.stdin), &stdout, &stderr); err != nil {
+				t.Fatal(err, stderr.String())
+			}
+			for i := range huetest.Lights {
+				want := fmt.Sprintf("now flashing channel %d (light: %s)\n", i, huetest.LightName(i))
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("output does not contain %q:\n%s", want, stdout.String())
+				}
+			}
+			if b.Active() {
+				t.Error("stream not stopped")
+			}
+
+			f := <-b.Frames()
+			for _, ch := range f.Channels {
+				if on := ch.Values != [3]uint16{}; on != (ch.ID == 0) {
+					t.Errorf("channel 0 not flashed alone first: %+v", f.Channels)
+				}
+			}
+		})
+	}
+}
+
+// bridgeArgs returns the bridge flags of b.
+func bridgeArgs(b *huetest.Bridge) []string {
+	return []string{
+		"-host", b.Host, "-username", b.Username, "-clientkey", b.ClientKey,
+		"-base-url", b.URL(), "-stream-port", fmt.Sprint(b.StreamPort()),
+	}
+}
+
+func TestPipe(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	stdin := strings.NewReader(`{"0":"#ff0000","1":"#00f"}` + "\n\n" + `{"all":"ffffff","2":"#000000"}` + "\n")
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"pipe", "-rate", "100", "-fade", "50ms"}, bridgeArgs(b)...)
+	if err := run(context.Background(), args, stdin, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+
+	want := [][3]uint16{{0xffff, 0, 0}, {0, 0, 0xffff}}
+	if f := <-b.Frames(); !sameValues(f, want) {
+		t.Errorf("first frame: got %+v, want %v", f.Channels, want)
+	}
+	want = [][3]uint16{{0xffff, 0xffff, 0xffff}, {0xffff, 0xffff, 0xffff}, {}}
+	black := make([][3]uint16, huetest.Lights)
+	white := false
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case f := <-b.Frames():
+			white = white || sameValues(f, want)
+			if !sameValues(f, black) {
+				continue
+			}
+		case <-timeout:
+			t.Fatal("not faded out")
+		}
+		break
+	}
+	if !white {
+		t.Errorf("second frame %v not sent before the fade out", want)
+	}
+}
+
+func TestPipeTransition(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	stdin := strings.NewReader(`{"all":"#000000"}` + "\n" + `{"all":[240,100],"transition":"100ms"}` + "\n")
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"pipe", "-rate", "100"}, bridgeArgs(b)...)
+	if err := run(context.Background(), args, stdin, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+
+	steps := 0
+	for f := nextFrame(t, b); f.Channels[0].Values != [3]uint16{0, 0, 0xffff}; f = nextFrame(t, b) {
+		if b := f.Channels[0].Values[2]; b != 0 && b != 0xffff {
+			steps++
+		}
+	}
+	if steps == 0 {
+		t.Error("blue set at once, want a fade")
+	}
+}
+
+func TestPipeInvalidLine(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	stdin := strings.NewReader(`{"0":"#ff0000"}` + "\n" + `{"0":"reddish"}` + "\n")
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"pipe"}, bridgeArgs(b)...)
+	err := run(context.Background(), args, stdin, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got %v, want an error on line 2", err)
+	}
+}
+
+// sameValues reports whether the channels of f, ordered by ID, have the
+// values want.
+func sameValues(f wire.Frame, want [][3]uint16) bool {
+	if len(f.Channels) != len(want) {
+		return false
+	}
+	for _, ch := range f.Channels {
+		if int(ch.ID) >= len(want) || ch.Values != want[ch.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRecordReplay(t *testing.T) {
+	b := huetest.NewBridge(t)
+	show := filepath.Join(t.TempDir(), "show.hsr")
+
+	stdin := strings.NewReader(`{"all":"#ff0000"}` + "\n" + `{"all":"#0000ff"}` + "\n")
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"record", "-out", show}, bridgeArgs(b)...)
+	if err := run(context.Background(), args, stdin, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+
+	// A new bridge, not to receive the frames of the recording.
+	b = huetest.NewBridge(t)
+	args = append([]string{"replay"}, bridgeArgs(b)...)
+	if err := run(context.Background(), append(args, show), nil, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+	red := [][3]uint16{{0xffff, 0, 0}, {0xffff, 0, 0}, {0xffff, 0, 0}}
+	blue := [][3]uint16{{0, 0, 0xffff}, {0, 0, 0xffff}, {0, 0, 0xffff}}
+	for i, want := range [][][3]uint16{red, blue} {
+		if f := nextFrame(t, b); !sameValues(f, want) {
+			t.Errorf("frame %d: got %+v, want %v", i, f.Channels, want)
+		}
+	}
+}
+
+func nextFrame(t *testing.T, b *huetest.Bridge) wire.Frame {
+	t.Helper()
+
+	select {
+	case f := <-b.Frames():
+		return f
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame received")
+		return wire.Frame{}
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"doctor", "-json"}, bridgeArgs(b)...)
+	if err := run(context.Background(), args, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err, stdout.String())
+	}
+	var checks []check
+	if err := json.Unmarshal(stdout.Bytes(), &checks); err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 6 {
+		t.Fatalf("got %d checks, want 6", len(checks))
+	}
+	for _, c := range checks {
+		if c.Status != "pass" {
+			t.Errorf("check %+v did not pass", c)
+		}
+	}
+
+	stdout.Reset()
+	args = append([]string{"doctor"}, bridgeArgs(b)...)
+	if err := run(context.Background(), append(args, "-username", "intruder"), nil, &stdout, &stderr); err == nil {
+		t.Error("doctor passed with a wrong username")
+	}
+	for _, want := range []string{"FAIL  credentials", "SKIP  stream handshake", "huestream register -save"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, stdout.String())
+		}
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	for _, tt := range []struct {
+		v    string
+		want bool
+	}{
+		{"1.48.0", true},
+		{"1.65.0", true},
+		{"2.0", true},
+		{"1.47.9", false},
+		{"1.9.0", false},
+	} {
+		v, err := parseVersion(tt.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := versionAtLeast(v, minAPIVersion); got != tt.want {
+			t.Errorf("versionAtLeast(%s) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	var stdout, stderr bytes.Buffer
+	// The flags after the color are parsed too.
+	args := append([]string{"set", "orange", "-brightness", "0.5", "-hold", "100ms", "-fade", "50ms"}, bridgeArgs(b)...)
+	if err := run(context.Background(), args, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+
+	half := [3]uint16{0x7fff, 0x52d2, 0}
+	if f := nextFrame(t, b); f.Channels[0].Values != half {
+		t.Errorf("first frame: got %v, want %v", f.Channels[0].Values, half)
+	}
+	for f := nextFrame(t, b); !sameValues(f, make([][3]uint16, huetest.Lights)); f = nextFrame(t, b) {
+		// Skip the keepalive resends and the fade, up to black.
+	}
+	if b.Active() {
+		t.Error("stream not stopped")
+	}
+}
+
+func TestSetInvalid(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{}, "want one color"},
+		{[]string{"red", "blue"}, "want one color"},
+		{[]string{"reddish"}, `invalid color "reddish"`},
+		{[]string{"rgb(1,2)"}, "rgb() takes 3 components, got 2"},
+		{[]string{"rgb(1,2,300)"}, `component "300" out of 0 to 255`},
+		{[]string{"-brightness", "2", "red"}, "brightness 2 out of 0 to 1"},
+		{[]string{"-area", "missing", "red"}, "not found"},
+	} {
+		var stdout, stderr bytes.Buffer
+		args := append(append([]string{"set"}, tc.args...), bridgeArgs(b)...)
+		err := run(context.Background(), args, nil, &stdout, &stderr)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("set %v: got %v, want an error with %q", tc.args, err, tc.want)
+		}
+	}
+}
+
+func TestErrorHint(t *testing.T) {
+	for _, err := range []error{
+		fmt.Errorf("start: %w", huestream.ErrUnauthorized),
+		&huestream.StatusError{Code: 409},
+		&huestream.AreaError{AreaID: "a", Err: huestream.ErrNoChannels},
+		&huestream.TimeoutError{Op: "handshake", Err: context.DeadlineExceeded},
+		&net.OpError{Op: "dial", Err: errors.New("connection refused")},
+	} {
+		if errorHint(err) == "" {
+			t.Errorf("no hint for %v", err)
+		}
+	}
+	if h := errorHint(errors.New("other")); h != "" {
+		t.Errorf("hint %q for an unknown error", h)
+	}
+}
+
+func TestEffectList(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"effect", "list"}, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"rainbow: ", "-period value", "(default 10s)",
+		"sparkle: ", "-color value", "(default #000000)", "-density value",
+		"candle: ", "lightning: ", "-chance value",
+		"sunrise: ", "-length value", "(default 30m0s)", "-floor value",
+		"palette: ", "-palette value", "(default #ff0000,#00ff00,#0000ff)", "-blend",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("%q missing from:\n%s", want, stdout.String())
+		}
+	}
+}
+
+func TestEffect(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"effect", "rainbow", "-period", "1s", "-spread", "0", "-duration", "200ms", "-fade", "40ms"}, bridgeArgs(b)...)
+	if err := run(context.Background(), args, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+	f := nextFrame(t, b)
+	for len(f.Channels) == 0 {
+		f = nextFrame(t, b) // Keepalives before the first frame.
+	}
+	if f.Channels[0].Values != [3]uint16{0xffff, 0, 0} {
+		t.Errorf("first frame: got %v, want red", f.Channels[0].Values)
+	}
+	for f := nextFrame(t, b); !sameValues(f, make([][3]uint16, huetest.Lights)); f = nextFrame(t, b) {
+		if v := f.Channels[0].Values; v != f.Channels[1].Values {
+			t.Fatalf("got %v and %v, want the same hue without spread", v, f.Channels[1].Values)
+		}
+	}
+	if b.Active() {
+		t.Error("stream not stopped")
+	}
+}
+
+func TestEffectPreview(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"effect", "-preview", "-channels", "2", "-duration", "100ms", "-fade", "0", "-seed", "1", "sparkle", "-color", "blue"}
+	if err := run(context.Background(), args, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "\x1b[") {
+		t.Errorf("nothing drawn: %q", stdout.String())
+	}
+}
+
+func TestEffectInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{}, "missing effect name"},
+		{[]string{"fireworks"}, `unknown effect "fireworks"`},
+		{[]string{"candle", "-period", "1s"}, "flag provided but not defined: -period"},
+		{[]string{"rainbow", "-period", "soon"}, `invalid duration "soon"`},
+		{[]string{"sparkle", "-color", "reddish"}, `invalid color "reddish"`},
+		{[]string{"palette", "-palette", " , "}, "empty palette"},
+		{[]string{"-speed", "3", "candle"}, "speed 3 out of 0 to 2"},
+		{[]string{"candle", "extra"}, `unexpected argument "extra"`},
+	} {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"effect", "-preview"}, tc.args...)
+		err := run(context.Background(), args, nil, &stdout, &stderr)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("effect %v: got %v, want an error with %q", tc.args, err, tc.want)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ln, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.LocalAddr().String()
+	ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stdoutR, stdoutW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		var stderr bytes.Buffer
+		done <- run(ctx, []string{"watch", "-listen", addr}, nil, stdoutW, &stderr)
+		stdoutW.Close()
+	}()
+
+	// Mirror a stream to watch, its keepalive resends the color until
+	// watch listens.
+	b := huetest.NewBridge(t)
+	args := append([]string{"set", "-fade", "0", "-mirror", addr, "red"}, bridgeArgs(b)...)
+	setDone := make(chan error, 1)
+	go func() { setDone <- run(ctx, args, nil, io.Discard, io.Discard) }()
+
+	drawn := make(chan bool, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := stdoutR.Read(buf)
+			if err != nil {
+				drawn <- false
+				return
+			}
+			if bytes.Contains(buf[:n], []byte("\x1b[48;2;255;0;0m")) {
+				drawn <- true
+				io.Copy(io.Discard, stdoutR)
+				return
+			}
+		}
+	}()
+	select {
+	case ok := <-drawn:
+		if !ok {
+			t.Error("watch ended without drawing red")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("watch did not draw the mirrored frames")
+	}
+
+	cancel()
+	for name, done := range map[string]<-chan error{"watch": done, "set": setDone} {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s not stopped by the context", name)
+		}
+	}
+}
+
+func TestEffectPalette(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"effect", "palette", "-palette", "rgb(0, 0, 255),red", "-step", "1s", "-blend", "-duration", "100ms", "-fade", "0"}, bridgeArgs(b)...)
+	if err := run(context.Background(), args, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+	f := nextFrame(t, b)
+	for len(f.Channels) == 0 {
+		f = nextFrame(t, b) // Keepalives before the first frame.
+	}
+	if f.Channels[0].Values != [3]uint16{0, 0, 0xffff} {
+		t.Errorf("first frame: got %v, want blue", f.Channels[0].Values)
+	}
+}
+
+// writePack writes the preset pack data to a file, returning its path.
+func writePack(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pack.json")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPresetList(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"preset", "list"}, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"halloween: ", "pumpkins ", "(palette)", "holidays: ", "spring: ", "dawn "} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("%q missing from:\n%s", want, stdout.String())
+		}
+	}
+}
+
+func TestPreset(t *testing.T) {
+	b := huetest.NewBridge(t)
+	pack := writePack(t, `{"name": "test", "presets": [
+		{"name": "alarm", "description": "red and blue", "effect": "palette", "params": {"palette": ["red", "blue"], "step": "1s"}}
+	]}`)
+
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"preset", "-pack", pack, "-duration", "100ms", "-fade", "0"}, bridgeArgs(b)...)
+	if err := run(context.Background(), append(args, "alarm"), nil, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+	f := nextFrame(t, b)
+	for len(f.Channels) == 0 {
+		f = nextFrame(t, b) // Keepalives before the first frame.
+	}
+	if f.Channels[0].Values != [3]uint16{0xffff, 0, 0} {
+		t.Errorf("first frame: got %v, want red", f.Channels[0].Values)
+	}
+}
+
+func TestPresetInvalid(t *testing.T) {
+	pack := writePack(t, `{"name": "test", "presets": [{"name": "a", "effect": "strobe"}]}`)
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{}, "want a preset name"},
+		{[]string{"fireworks"}, `unknown preset "fireworks"`},
+		{[]string{"-pack", pack, "a"}, pack + `: preset: pack test: preset a: unknown effect "strobe"`},
+		{[]string{"-pack", filepath.Join(t.TempDir(), "none.json"), "a"}, "no such file"},
+	} {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"preset", "-preview"}, tc.args...)
+		err := run(context.Background(), args, nil, &stdout, &stderr)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("preset %v: got %v, want an error with %q", tc.args, err, tc.want)
+		}
+	}
+}
+
+func TestScript(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	var stdout, stderr bytes.Buffer
+	stdin := strings.NewReader("0:00 all red\n0:00.2 ch0 blue\n")
+	args := append([]string{"script"}, bridgeArgs(b)...)
+	if err := run(context.Background(), append(args, "-"), stdin, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+	f := nextFrame(t, b)
+	for len(f.Channels) == 0 {
+		f = nextFrame(t, b) // Keepalives before the first frame.
+	}
+	if f.Channels[1].Values != [3]uint16{0xffff, 0, 0} {
+		t.Errorf("first frame: got %v, want red", f.Channels[1].Values)
+	}
+	for f.Channels[0].Values != [3]uint16{0, 0, 0xffff} {
+		f = nextFrame(t, b)
+	}
+	if b.Active() {
+		t.Error("stream not stopped")
+	}
+}
+
+func TestScriptInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "show.script")
+	if err := os.WriteFile(path, []byte("0:00 all red\n0:01 all redish\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{}, "missing script file"},
+		{[]string{path}, path + `: line 2:10: expected a color as #ff8800 or orange, got "redish"`},
+		{[]string{filepath.Join(t.TempDir(), "none.script")}, "no such file"},
+	} {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"script", "-preview"}, tc.args...)
+		err := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("script %v: got %v, want an error with %q", tc.args, err, tc.want)
+		}
+	}
+}
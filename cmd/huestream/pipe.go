@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"maps"
+	"os"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/jsonframe"
+)
+
+func pipe(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return pipeFrames(ctx, "pipe", args, stdin, stderr)
+}
+
+func record(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return pipeFrames(ctx, "record", args, stdin, stderr)
+}
+
+// pipeFrames runs the pipe command, and the record command which also
+// records the frames to a show file.
+func pipeFrames(ctx context.Context, name string, args []string, stdin io.Reader, stderr io.Writer) (err error) {
+	usage := "[-area id] [-rate hz] [-fade d] [bridge flags]"
+	if name == "record" {
+		usage = "-out file " + usage
+	}
+	fs := newFlagSet(name, usage, stderr)
+	areaID := fs.String("area", os.Getenv("HUESTREAM_AREA_ID"), "`ID` of the area, needed if the bridge has more than one ($HUESTREAM_AREA_ID)")
+	rate := fs.Float64("rate", 25, "frames per second resent between the input lines")
+	fade := fs.Duration("fade", 0, "fade the last frame out for `d` at the end of the input")
+	var out *string
+	if name == "record" {
+		out = fs.String("out", "", "show `file` to write")
+	}
+	bridge := addBridgeFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *rate <= 0 {
+		return fmt.Errorf("%s: invalid rate %v", name, *rate)
+	}
+	if out != nil && *out == "" {
+		fs.Usage()
+		return errors.New("record: missing -out")
+	}
+	creds, opts, err := bridge.credentials()
+	if err != nil {
+		return err
+	}
+	area, err := findArea(ctx, creds, *areaID, opts)
+	if err != nil {
+		return err
+	}
+
+	period := time.Duration(float64(time.Second) / *rate)
+	opts = append(opts, huestream.WithKeepAlive(period))
+	stream, err := huestream.Start(ctx, creds.Host, creds.Username, creds.ClientKey, area.ID, opts...)
+	if err != nil {
+		return err
+	}
+	var st huestream.Streamer = stream
+	if out != nil {
+		f, err := os.Create(*out)
+		if err != nil {
+			stream.Close()
+			return err
+		}
+		defer func() { err = cmp.Or(err, f.Close()) }()
+		st = huestream.NewShowRecorder(stream, f)
+	}
+	defer func() { err = cmp.Or(err, st.Close()) }()
+
+	done := make(chan struct{})
+	defer close(done)
+	lines, scanErr := scanLines(stdin, done)
+	ids := make([]int, len(area.Channels))
+	for i, ch := range area.Channels {
+		ids[i] = ch.ID
+	}
+	var last huestream.Frame // The colors set by the lines so far.
+	for n := 1; ; n++ {
+		var line []byte
+		select {
+		case <-ctx.Done():
+			return nil // Stopped by the user.
+		case line = <-lines:
+		}
+		if line == nil {
+			break
+		}
+		if len(line) == 0 {
+			continue
+		}
+		doc, err := jsonframe.DecodeFrame(line, ids)
+		if err != nil {
+			return fmt.Errorf("%s: line %d: %w", name, n, err)
+		}
+		from := last
+		last = maps.Clone(last)
+		if last == nil {
+			last = make(huestream.Frame, len(doc.Colors))
+		}
+		maps.Copy(last, doc.Colors)
+		if doc.Transition > 0 {
+			if err := huestream.Play(ctx, st, jsonframe.Fade(from, last, doc.Transition, *rate), *rate); err != nil {
+				if ctx.Err() != nil {
+					return nil // Stopped by the user.
+				}
+				return err
+			}
+			continue
+		}
+		if err := st.SendContext(ctx, doc.Colors); err != nil {
+			return err
+		}
+	}
+	if err := scanErr(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if *fade > 0 && last != nil {
+		return fadeOut(ctx, st, last, *fade, period)
+	}
+	return nil
+}
+
+// scanLines reads the lines of r on a goroutine, so that reading can be
+// interrupted. The channel receives nil at the end of r, then err returns
+// the error of the reading. The goroutine returns when done is closed.
+func scanLines(r io.Reader, done <-chan struct{}) (lines <-chan []byte, err func() error) {
+	ch := make(chan []byte)
+	var scanErr error
+	go func() {
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			select {
+			case ch <- append([]byte{}, sc.Bytes()...):
+			case <-done:
+				return
+			}
+		}
+		scanErr = sc.Err()
+		close(ch)
+	}()
+	return ch, func() error { return scanErr }
+}
+
+// fadeOut sends f dimmed to black in d, a frame every period.
+func fadeOut(ctx context.Context, st huestream.Streamer, f huestream.Frame, d, period time.Duration) error {
+	tick := time.NewTicker(period)
+	defer tick.Stop()
+
+	for start := time.Now(); ; {
+		k := 1 - float64(time.Since(start))/float64(d)
+		dimmed := make(huestream.Frame, len(f))
+		for id, c := range f {
+			dimmed[id] = scale(c, max(k, 0))
+		}
+		if err := st.SendContext(ctx, dimmed); err != nil {
+			return err
+		}
+		if k <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil // Stopped by the user.
+		case <-tick.C:
+		}
+	}
+}
+
+// scale returns c with its channels multiplied by k, in [0, 1].
+func scale(c color.Color, k float64) color.Color {
+	r, g, b, _ := c.RGBA()
+	return color.RGBA64{
+		R: uint16(float64(r) * k),
+		G: uint16(float64(g) * k),
+		B: uint16(float64(b) * k),
+		A: 0xffff,
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rschio/huestream/preset"
+)
+
+func runPreset(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("preset", "[-pack file] [-area id] [-speed x] [-duration d] [-fade d] [-seed n] [-preview] [bridge flags] name\n       huestream preset [-pack file] list", stderr)
+	packFile := fs.String("pack", "", "play the presets of the pack `file` instead of the built-in ones")
+	pf := addPlayFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("preset: want a preset name, list them with huestream preset list")
+	}
+	packs := preset.Builtin()
+	if *packFile != "" {
+		f, err := os.Open(*packFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		pk, err := preset.Load(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", *packFile, err)
+		}
+		packs = []*preset.Pack{pk}
+	}
+
+	name := fs.Arg(0)
+	if name == "list" {
+		for _, pk := range packs {
+			fmt.Fprintf(stdout, "%s: %s\n", pk.Name, pk.Description)
+			for _, p := range pk.Presets {
+				fmt.Fprintf(stdout, "  %-16s %s (%s)\n", p.Name, p.Description, p.Effect.Name)
+			}
+		}
+		return nil
+	}
+	for _, pk := range packs {
+		if p, ok := pk.Lookup(name); ok {
+			return pf.play(ctx, "preset", stdout, p.Frames)
+		}
+	}
+	return fmt.Errorf("preset: unknown preset %q, list them with huestream preset list", name)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+// pollInterval is the interval between the registration attempts while
+// waiting for the link button.
+var pollInterval = time.Second
+
+func register(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("register", "[-json] [-save] [-name app#device] [-timeout d] host", stderr)
+	asJSON := fs.Bool("json", false, "print JSON")
+	save := fs.Bool("save", false, "save the credentials for the other commands")
+	name := fs.String("name", "huestream#cli", "application `name`, as app#device")
+	timeout := fs.Duration("timeout", time.Minute, "time to wait for the link button")
+	baseURL := fs.String("base-url", "", "`URL` of the bridge API, if not https://host")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("register: missing host")
+	}
+	host := fs.Arg(0)
+
+	var opts []huestream.Option
+	if *baseURL != "" {
+		opts = append(opts, huestream.WithBaseURL(*baseURL))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	creds, err := huestream.Register(ctx, host, *name, opts...)
+	if errors.Is(err, huestream.ErrLinkButton) {
+		fmt.Fprintf(stderr, "Press the link button of the bridge at %s.\n", host)
+	}
+	for errors.Is(err, huestream.ErrLinkButton) {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("register: link button not pressed within %v", *timeout)
+		case <-time.After(pollInterval):
+		}
+		creds, err = huestream.Register(ctx, host, *name, opts...)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *save {
+		path, err := saveCredentials(creds)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stderr, "Credentials saved in %s.\n", path)
+	}
+	if *asJSON {
+		return printJSON(stdout, creds)
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "host\t%s\n", creds.Host)
+	fmt.Fprintf(tw, "username\t%s\n", creds.Username)
+	fmt.Fprintf(tw, "clientkey\t%s\n", creds.ClientKey)
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+func replay(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) (err error) {
+	fs := newFlagSet("replay", "[-area id] [-loop] [bridge flags] file", stderr)
+	areaID := fs.String("area", os.Getenv("HUESTREAM_AREA_ID"), "`ID` of the area, needed if the bridge has more than one ($HUESTREAM_AREA_ID)")
+	loop := fs.Bool("loop", false, "replay the show until interrupted")
+	bridge := addBridgeFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("replay: missing show file")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	show, err := huestream.ReadShow(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	if len(show) == 0 {
+		return fmt.Errorf("%s: empty show", fs.Arg(0))
+	}
+
+	creds, opts, err := bridge.credentials()
+	if err != nil {
+		return err
+	}
+	area, err := findArea(ctx, creds, *areaID, opts)
+	if err != nil {
+		return err
+	}
+
+	// Shows recorded by pipe may have long pauses between the frames.
+	opts = append(opts, huestream.WithKeepAlive(time.Second))
+	stream, err := huestream.Start(ctx, creds.Host, creds.Username, creds.ClientKey, area.ID, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() { err = cmp.Or(err, stream.Close()) }()
+
+	for {
+		err := huestream.PlayShow(ctx, stream, show)
+		if ctx.Err() != nil {
+			return nil // Stopped by the user.
+		}
+		if err != nil || !*loop {
+			return err
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huesim"
+	"github.com/rschio/huestream/script"
+)
+
+func runScript(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) (err error) {
+	fs := newFlagSet("script", "[-area id] [-preview] [bridge flags] file", stderr)
+	areaID := fs.String("area", os.Getenv("HUESTREAM_AREA_ID"), "`ID` of the area, needed if the bridge has more than one ($HUESTREAM_AREA_ID)")
+	preview := fs.Bool("preview", false, "draw the script in the terminal instead of streaming it")
+	channels := fs.Int("channels", 6, "`number` of channels drawn by -preview")
+	bridge := addBridgeFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("script: missing script file, - for stdin")
+	}
+	name, r := fs.Arg(0), stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	tl, err := script.Parse(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if len(tl) == 0 {
+		return fmt.Errorf("%s: empty script", name)
+	}
+
+	var st huestream.Streamer
+	var ids []int
+	if *preview {
+		if *channels < 1 {
+			return fmt.Errorf("script: invalid number of channels %d", *channels)
+		}
+		for id := range *channels {
+			ids = append(ids, id)
+		}
+		st = huesim.New(stdout)
+	} else {
+		creds, opts, err := bridge.credentials()
+		if err != nil {
+			return err
+		}
+		area, err := findArea(ctx, creds, *areaID, opts)
+		if err != nil {
+			return err
+		}
+		for _, ch := range area.Channels {
+			ids = append(ids, ch.ID)
+		}
+		st, err = huestream.Start(ctx, creds.Host, creds.Username, creds.ClientKey, area.ID, opts...)
+		if err != nil {
+			return err
+		}
+	}
+	defer func() { err = cmp.Or(err, st.Close()) }()
+
+	err = huestream.Play(ctx, st, tl.Frames(ids), script.Rate)
+	if ctx.Err() != nil {
+		return nil // Stopped by the user.
+	}
+	return err
+}
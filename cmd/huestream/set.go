@@ -0,0 +1,98 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+func set(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) (err error) {
+	fs := newFlagSet("set", "[-area id] [-brightness b] [-hold d] [-fade d] [bridge flags] color", stderr)
+	areaID := fs.String("area", os.Getenv("HUESTREAM_AREA_ID"), "`ID` of the area, needed if the bridge has more than one ($HUESTREAM_AREA_ID)")
+	brightness := fs.Float64("brightness", 1, "brightness from 0 to 1")
+	hold := fs.Duration("hold", 0, "hold the color for `d`, by default until interrupted")
+	fade := fs.Duration("fade", time.Second, "fade out for `d` before stopping")
+	bridge := addBridgeFlags(fs)
+	colors, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(colors) != 1 {
+		fs.Usage()
+		return errors.New("set: want one color, as #ff8800, rgb(255, 136, 0) or orange")
+	}
+	c, err := huestream.ParseColor(colors[0])
+	if err != nil {
+		return fmt.Errorf("set: %w", err)
+	}
+	if *brightness < 0 || *brightness > 1 {
+		return fmt.Errorf("set: brightness %v out of 0 to 1", *brightness)
+	}
+	if *hold < 0 || *fade < 0 {
+		return errors.New("set: negative -hold or -fade")
+	}
+	creds, opts, err := bridge.credentials()
+	if err != nil {
+		return err
+	}
+	area, err := findArea(ctx, creds, *areaID, opts)
+	if err != nil {
+		return err
+	}
+
+	const period = time.Second / 25
+	opts = append(opts, huestream.WithKeepAlive(period))
+	stream, err := huestream.Start(ctx, creds.Host, creds.Username, creds.ClientKey, area.ID, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() { err = cmp.Or(err, stream.Close()) }()
+
+	f := make(huestream.Frame, len(area.Channels))
+	for _, ch := range area.Channels {
+		f[ch.ID] = scale(c, *brightness)
+	}
+	if err := stream.SendContext(ctx, f); err != nil {
+		if ctx.Err() != nil {
+			return nil // Stopped by the user.
+		}
+		return err
+	}
+
+	var timeout <-chan time.Time
+	if *hold > 0 {
+		timeout = time.After(*hold)
+	}
+	select {
+	case <-ctx.Done():
+	case <-timeout:
+	}
+	if *fade > 0 {
+		// The fade out also follows an interruption.
+		return fadeOut(context.WithoutCancel(ctx), stream, f, *fade, period)
+	}
+	return nil
+}
+
+// parseInterspersed parses the flags of args, before and after the
+// positional arguments, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return pos, nil
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"io"
+	"net"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huecolor"
+	"github.com/rschio/huestream/huesim"
+	"github.com/rschio/huestream/wire"
+)
+
+// defaultMirrorAddr is the address watch listens on by default.
+const defaultMirrorAddr = "127.0.0.1:2101"
+
+func watch(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("watch", "[-listen addr]", stderr)
+	listen := fs.String("listen", defaultMirrorAddr, "UDP `address` receiving the frames mirrored with -mirror")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return errors.New("watch: unexpected arguments")
+	}
+
+	conn, err := net.ListenPacket("udp", *listen)
+	if err != nil {
+		return err
+	}
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+	defer conn.Close()
+
+	term := huesim.New(stdout)
+	defer term.Close()
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil // Stopped by the user.
+			}
+			return err
+		}
+		f, err := wire.Decode(buf[:n])
+		if err != nil {
+			continue // Not a message, ignored.
+		}
+		if err := term.Send(frameOf(f)); err != nil {
+			return err
+		}
+	}
+}
+
+// frameOf returns the colors of the channels of the message f.
+func frameOf(f wire.Frame) huestream.Frame {
+	frame := make(huestream.Frame, len(f.Channels))
+	for _, ch := range f.Channels {
+		v := ch.Values
+		if f.ColorSpace == wire.ColorSpaceXY {
+			xy := huecolor.XY{X: float64(v[0]) / 0xffff, Y: float64(v[1]) / 0xffff}
+			frame[int(ch.ID)] = huecolor.XYToRGB(xy, float64(v[2])/0xffff)
+			continue
+		}
+		frame[int(ch.ID)] = color.RGBA64{R: v[0], G: v[1], B: v[2], A: 0xffff}
+	}
+	return frame
+}
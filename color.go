@@ -0,0 +1,153 @@
+package huestream
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// namedColors are the colors ParseColor knows by name: the basic colors of
+// CSS, a few common extended ones and the whites of the lamps.
+var namedColors = map[string]color.RGBA{
+	// The basic colors of CSS.
+	"black":   {0, 0, 0, 255},
+	"silver":  {192, 192, 192, 255},
+	"gray":    {128, 128, 128, 255},
+	"white":   {255, 255, 255, 255},
+	"maroon":  {128, 0, 0, 255},
+	"red":     {255, 0, 0, 255},
+	"purple":  {128, 0, 128, 255},
+	"fuchsia": {255, 0, 255, 255},
+	"green":   {0, 128, 0, 255},
+	"lime":    {0, 255, 0, 255},
+	"olive":   {128, 128, 0, 255},
+	"yellow":  {255, 255, 0, 255},
+	"navy":    {0, 0, 128, 255},
+	"blue":    {0, 0, 255, 255},
+	"teal":    {0, 128, 128, 255},
+	"aqua":    {0, 255, 255, 255},
+
+	// Extended colors of CSS.
+	"grey":    {128, 128, 128, 255},
+	"cyan":    {0, 255, 255, 255},
+	"magenta": {255, 0, 255, 255},
+	"orange":  {255, 165, 0, 255},
+	"pink":    {255, 192, 203, 255},
+
+	// The whites of the lamps, from the warmest.
+	"candle":    {255, 147, 41, 255},  // ~1900K.
+	"warm":      {255, 147, 41, 255},  // As candle.
+	"warmwhite": {255, 180, 107, 255}, // ~2700K.
+	"coolwhite": {255, 209, 163, 255}, // ~4000K.
+	"daylight":  {255, 249, 253, 255}, // ~6500K.
+}
+
+// colorForms are the forms of the colors of ParseColor, for its errors.
+const colorForms = `#rrggbb, #rgb, #rrggbbaa, rgb(r, g, b), rgba(r, g, b, a) or a name as orange`
+
+// ParseColor parses a color, in any case and surrounded by spaces:
+//
+//	"#ff8800", "ff8800", "#f80"     hex, red, green and blue
+//	"#ff880080"                     hex with alpha
+//	"rgb(255, 136, 0)"              from 0 to 255, or in percent as 50%
+//	"rgba(255, 136, 0, 0.5)"        alpha from 0 to 1, or in percent
+//	"orange", "warmwhite"           a name
+//
+// The names are the basic colors of CSS, as red, lime and navy, the extended
+// grey, cyan, magenta, orange and pink, and the whites candle, warmwhite,
+// coolwhite and daylight. The colors without alpha are a color.RGBA, the
+// others a color.NRGBA.
+func ParseColor(s string) (color.Color, error) {
+	c, err := parseColor(strings.ToLower(strings.TrimSpace(s)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %q: %w", s, err)
+	}
+	return c, nil
+}
+
+func parseColor(s string) (color.Color, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty, want %s", colorForms)
+	}
+	if c, ok := namedColors[s]; ok {
+		return c, nil
+	}
+	if name, args, ok := strings.Cut(s, "("); ok {
+		return parseFunctional(name, args)
+	}
+
+	h := strings.TrimPrefix(s, "#")
+	for _, r := range h {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			if h == s {
+				return nil, fmt.Errorf("unknown name, want %s", colorForms)
+			}
+			return nil, fmt.Errorf("invalid hex digit %q", r)
+		}
+	}
+	switch len(h) {
+	case 3:
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	case 6, 8:
+	default:
+		if h == s && len(h) < 3 {
+			return nil, fmt.Errorf("unknown name, want %s", colorForms)
+		}
+		return nil, fmt.Errorf("%d hex digits, want 3, 6 or 8", len(h))
+	}
+	v, _ := strconv.ParseUint(h, 16, 32)
+	if len(h) == 8 {
+		return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+// parseFunctional parses the color of the function name, with the arguments
+// args up to the closing parenthesis.
+func parseFunctional(name, args string) (color.Color, error) {
+	n := 0
+	switch name = strings.TrimSpace(name); name {
+	case "rgb":
+		n = 3
+	case "rgba":
+		n = 4
+	default:
+		return nil, fmt.Errorf("unknown function %s(), want rgb() or rgba()", name)
+	}
+	args, ok := strings.CutSuffix(args, ")")
+	if !ok {
+		return nil, fmt.Errorf("missing ) after the %s() arguments", name)
+	}
+	parts := strings.Split(args, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("%s() takes %d components, got %d", name, n, len(parts))
+	}
+
+	var c [4]uint8
+	c[3] = 255
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		limit := 255.0
+		if i == 3 {
+			limit = 1 // Alpha.
+		}
+		v, pct := strings.CutSuffix(p, "%")
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(f) {
+			return nil, fmt.Errorf("invalid component %q", p)
+		}
+		if pct {
+			f = f / 100 * limit
+		}
+		if f < 0 || f > limit {
+			return nil, fmt.Errorf("component %q out of 0 to %v, or 0%% to 100%%", p, limit)
+		}
+		c[i] = uint8(math.Round(f / limit * 255))
+	}
+	if n == 4 {
+		return color.NRGBA{R: c[0], G: c[1], B: c[2], A: c[3]}, nil
+	}
+	return color.RGBA{R: c[0], G: c[1], B: c[2], A: 255}, nil
+}
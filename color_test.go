@@ -0,0 +1,88 @@
+package huestream
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	orange := color.RGBA{255, 136, 0, 255}
+	tests := []struct {
+		in   string
+		want color.Color
+	}{
+		{"#ff8800", orange},
+		{"ff8800", orange},
+		{"FF8800", orange},
+		{"#f80", orange},
+		{"F80", orange},
+		{" #ff8800 ", orange},
+		{"#ff880080", color.NRGBA{255, 136, 0, 128}},
+		{"#FF8800ff", color.NRGBA{255, 136, 0, 255}},
+		{"rgb(255, 136, 0)", orange},
+		{" RGB(255,136,0) ", orange},
+		{"rgb(100%, 53.3%, 0%)", orange},
+		{"rgb(255.0, 136, 0)", orange},
+		{"rgba(255, 136, 0, 0.5)", color.NRGBA{255, 136, 0, 128}},
+		{"rgba(255, 136, 0, 50%)", color.NRGBA{255, 136, 0, 128}},
+		{"rgba(0, 0, 0, 1)", color.NRGBA{0, 0, 0, 255}},
+		{"red", color.RGBA{255, 0, 0, 255}},
+		{"Navy", color.RGBA{0, 0, 128, 255}},
+		{"green", color.RGBA{0, 128, 0, 255}},
+		{"lime", color.RGBA{0, 255, 0, 255}},
+		{"grey", color.RGBA{128, 128, 128, 255}},
+		{"orange", color.RGBA{255, 165, 0, 255}},
+		{"WarmWhite", color.RGBA{255, 180, 107, 255}},
+		{"candle", color.RGBA{255, 147, 41, 255}},
+		{"abc", color.RGBA{0xaa, 0xbb, 0xcc, 255}}, // Hex, not a name.
+	}
+	for _, tt := range tests {
+		c, err := ParseColor(tt.in)
+		if err != nil || c != tt.want {
+			t.Errorf("ParseColor(%q) = %v, %v, want %v", tt.in, c, err, tt.want)
+		}
+	}
+}
+
+func TestParseColorNames(t *testing.T) {
+	for name, want := range namedColors {
+		if c, err := ParseColor(name); err != nil || c != want {
+			t.Errorf("ParseColor(%q) = %v, %v, want %v", name, c, err, want)
+		}
+	}
+}
+
+func TestParseColorErrors(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", `invalid color "": empty`},
+		{"  ", `invalid color "  ": empty`},
+		{"reddish", `invalid color "reddish": unknown name, want #rrggbb`},
+		{"ab", `invalid color "ab": unknown name`},
+		{"#ff88zz", `invalid color "#ff88zz": invalid hex digit 'z'`},
+		{"#ff88", `invalid color "#ff88": 4 hex digits, want 3, 6 or 8`},
+		{"#ff88001", `invalid color "#ff88001": 7 hex digits`},
+		{"#", `invalid color "#": 0 hex digits`},
+		{"ff880", `invalid color "ff880": 5 hex digits`},
+		{"hsl(30, 100%, 50%)", `invalid color "hsl(30, 100%, 50%)": unknown function hsl(), want rgb() or rgba()`},
+		{"rgb(255, 136, 0", `invalid color "rgb(255, 136, 0": missing ) after the rgb() arguments`},
+		{"rgb(255, 136)", `invalid color "rgb(255, 136)": rgb() takes 3 components, got 2`},
+		{"rgba(255, 136, 0)", `invalid color "rgba(255, 136, 0)": rgba() takes 4 components, got 3`},
+		{"rgb(255, 136, 0, 1)", `rgb() takes 3 components, got 4`},
+		{"rgb(256, 0, 0)", `invalid color "rgb(256, 0, 0)": component "256" out of 0 to 255, or 0% to 100%`},
+		{"rgb(-1, 0, 0)", `component "-1" out of 0 to 255`},
+		{"rgb(101%, 0, 0)", `component "101%" out of 0 to 255`},
+		{"rgb(x, 0, 0)", `invalid component "x"`},
+		{"rgb(, 0, 0)", `invalid component ""`},
+		{"rgb(nan, 0, 0)", `invalid component "nan"`},
+		{"rgba(0, 0, 0, 2)", `component "2" out of 0 to 1`},
+	}
+	for _, tt := range tests {
+		c, err := ParseColor(tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseColor(%q) = %v, %v, want an error with %q", tt.in, c, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package huestream
+
+import (
+	"errors"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+)
+
+// These tests are meant to run with -race.
+
+func TestConcurrentSends(t *testing.T) {
+	s, frames := pipeStream(t)
+
+	const senders, sends = 4, 50
+	var wg sync.WaitGroup
+	for i := range senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range sends {
+				if err := s.Send(Frame{i: color.White}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	for range senders * sends {
+		select {
+		case <-frames:
+		case <-time.After(5 * time.Second):
+			t.Fatal("frames missing")
+		}
+	}
+	wg.Wait()
+
+	if got := s.Stats().FramesSent; got != senders*sends {
+		t.Errorf("FramesSent = %d, want %d", got, senders*sends)
+	}
+}
+
+func TestSendDuringClose(t *testing.T) {
+	s := discardStream()
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				err := s.Send(Frame{i: color.White})
+				if errors.Is(err, ErrClosed) {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	s.Close()
+	wg.Wait()
+
+	if err := s.Send(nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send after Close: %v, want ErrClosed", err)
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	s := discardStream()
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStatsDuringSends(t *testing.T) {
+	s := discardStream()
+	defer s.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 500 {
+			s.Send(Frame{1: color.White})
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			if got := s.Stats().FramesSent; got != 500 {
+				t.Errorf("FramesSent = %d, want 500", got)
+			}
+			return
+		default:
+			_ = s.Stats()
+			_ = s.LocalAddr()
+		}
+	}
+}
+
+func TestKeepAliveDuringSends(t *testing.T) {
+	discard := &funcConn{write: func(b []byte) (int, error) { return len(b), nil }}
+	s := newStream(discard, nil, testAreaID, newConfig([]Option{WithKeepAlive(time.Millisecond)}))
+	defer s.Close()
+
+	for i := range 500 {
+		if err := s.Send(Frame{i % 20: color.White}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package huestream
+
+import (
+	"bytes"
+
+	"github.com/rschio/huestream/wire"
+)
+
+// offSequence is the offset of the sequence number in a message, after the
+// protocol name and the version.
+const offSequence = len(wire.ProtocolName) + 2
+
+// duplicate reports whether the message b, built by Send, is to be skipped
+// by WithDedup: it repeats the last message written, but for the sequence
+// number, less than the max hold ago.
+func (s *Stream) duplicate(b []byte) bool {
+	if s.cfg.dedup <= 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	last, held := s.last, s.clk.Now().Sub(s.lastSend)
+	s.mu.Unlock()
+
+	if held >= s.cfg.dedup || len(last) != len(b) ||
+		!bytes.Equal(last[:offSequence], b[:offSequence]) ||
+		!bytes.Equal(last[offSequence+1:], b[offSequence+1:]) {
+		return false
+	}
+	s.framesSuppressed.Add(1)
+	return true
+}
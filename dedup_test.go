@@ -0,0 +1,53 @@
+package huestream
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+func TestDedup(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	s, frames := pipeStream(t, withClock(clk), WithDedup(time.Second))
+
+	red := Frame{0: color.RGBA{R: 0xff, A: 0xff}}
+	blue := Frame{0: color.RGBA{B: 0xff, A: 0xff}}
+	sent := func(f Frame) bool {
+		t.Helper()
+		if err := s.Send(f); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-frames:
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}
+
+	if !sent(red) {
+		t.Fatal("first frame not sent")
+	}
+	clk.Advance(500 * time.Millisecond)
+	if sent(red) {
+		t.Error("repeated frame sent before the max hold")
+	}
+	if !sent(blue) {
+		t.Error("new frame not sent")
+	}
+	clk.Advance(999 * time.Millisecond)
+	if sent(blue) {
+		t.Error("repeated frame sent before the max hold")
+	}
+	clk.Advance(time.Millisecond)
+	if !sent(blue) {
+		t.Error("repeated frame not sent after the max hold")
+	}
+
+	st := s.Stats()
+	if st.FramesSuppressed != 2 || st.FramesSent != 3 {
+		t.Errorf("%d frames suppressed and %d sent, want 2 and 3", st.FramesSuppressed, st.FramesSent)
+	}
+}
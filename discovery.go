@@ -0,0 +1,103 @@
+package huestream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// discoveryURL is the Hue discovery endpoint, listing the bridges of the
+// network of the caller as seen from the internet.
+var discoveryURL = "https://discovery.meethue.com/"
+
+// BridgeInfo is a bridge found by Discover.
+type BridgeInfo struct {
+	ID   string `json:"id"`
+	Host string `json:"internalipaddress"`
+	Port int    `json:"port,omitempty"`
+}
+
+// Discover lists the bridges of the local network with the Hue discovery
+// endpoint. The endpoint is rate limited, it answers 429 to more than one
+// request every 15 minutes.
+func Discover(ctx context.Context) ([]BridgeInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("discover: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discover: %w", &StatusError{Code: resp.StatusCode})
+	}
+	var bridges []BridgeInfo
+	if err := json.NewDecoder(resp.Body).Decode(&bridges); err != nil {
+		return nil, fmt.Errorf("discover: %w", err)
+	}
+	return bridges, nil
+}
+
+// ErrLinkButton is returned by Register while the link button of the bridge
+// has not been pressed.
+var ErrLinkButton = errors.New("link button not pressed")
+
+// Credentials are the credentials of an application registered on a
+// bridge, the arguments of Start.
+type Credentials struct {
+	Host      string `json:"host"`
+	Username  string `json:"username"`
+	ClientKey string `json:"clientkey"`
+}
+
+// Register registers an application on the bridge at host and returns its
+// credentials. deviceType names the application, as "app#device".
+//
+// The bridge only accepts the registration within 30s after its link
+// button is pressed, Register fails with an error wrapping ErrLinkButton
+// before. Only WithBaseURL and WithProtocolVersion are used from opts.
+func Register(ctx context.Context, host, deviceType string, opts ...Option) (Credentials, error) {
+	c := newClient(host, "", "")
+	c.cfg = newConfig(opts)
+
+	body := fmt.Sprintf(`{"devicetype":%q,"generateclientkey":true}`, deviceType)
+	req, err := http.NewRequestWithContext(ctx, "POST", c.apiURL()+"/api", strings.NewReader(body))
+	if err != nil {
+		return Credentials{}, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("register: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("register: %w", &StatusError{Code: resp.StatusCode})
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("register: %w", err)
+	}
+	if err := v1Error(b); err != nil {
+		return Credentials{}, fmt.Errorf("register: %w", err)
+	}
+
+	var results []struct {
+		Success *struct {
+			Username  string `json:"username"`
+			ClientKey string `json:"clientkey"`
+		} `json:"success"`
+	}
+	if err := json.Unmarshal(b, &results); err != nil || len(results) == 0 || results[0].Success == nil {
+		return Credentials{}, fmt.Errorf("register: unexpected answer %q", b)
+	}
+	s := results[0].Success
+	return Credentials{Host: host, Username: s.Username, ClientKey: s.ClientKey}, nil
+}
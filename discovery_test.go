@@ -0,0 +1,39 @@
+package huestream
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`[{"id":"001788fffe100491","internalipaddress":"192.168.2.23","port":443}]`))
+	}))
+	defer srv.Close()
+
+	old := discoveryURL
+	discoveryURL = srv.URL
+	defer func() { discoveryURL = old }()
+
+	got, err := Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BridgeInfo{{ID: "001788fffe100491", Host: "192.168.2.23", Port: 443}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	status = http.StatusTooManyRequests
+	_, err = Discover(context.Background())
+	var se *StatusError
+	if !errors.As(err, &se) || se.Code != http.StatusTooManyRequests {
+		t.Errorf("got %v, want a 429 *StatusError", err)
+	}
+}
@@ -0,0 +1,129 @@
+package huestream
+
+import "sync/atomic"
+
+// errorQueueSize is the number of errors buffered before new ones are dropped.
+const errorQueueSize = 16
+
+// errorDispatcher delivers asynchronous errors to the user's handler and
+// channel from a single goroutine, so reporting an error never blocks the
+// caller. Every goroutine of the Stream reports its failures to it: the
+// keepalive, the watchdog, the recovery, the send loops, the sender of
+// SetTarget and the frame capture and dump.
+//
+// Its queue holds errorQueueSize errors. When the consumers fall behind and
+// the queue is full, the newest errors are dropped and counted, the ones
+// queued are delivered in order.
+type errorDispatcher struct {
+	handler func(error)
+	ch      chan<- error
+	queue   chan error
+	quit    chan struct{}
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	// delivering is set while the handler runs, so close called from it
+	// doesn't wait for itself.
+	delivering atomic.Bool
+}
+
+// newErrorDispatcher returns a running dispatcher, or nil if h and ch are
+// both nil. A nil dispatcher discards every error.
+func newErrorDispatcher(h func(error), ch chan<- error) *errorDispatcher {
+	if h == nil && ch == nil {
+		return nil
+	}
+
+	d := &errorDispatcher{
+		handler: h,
+		ch:      ch,
+		queue:   make(chan error, errorQueueSize),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go d.run()
+
+	return d
+}
+
+func (d *errorDispatcher) run() {
+	defer close(d.done)
+	for {
+		select {
+		case err := <-d.queue:
+			d.deliver(err, d.quit)
+		case <-d.quit:
+			// Deliver what was reported before close, without waiting
+			// for the channel.
+			for {
+				select {
+				case err := <-d.queue:
+					d.deliver(err, nil)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver passes err to the handler, then to the channel, waiting for the
+// channel until quit is closed. The error is dropped if the channel can't
+// take it.
+func (d *errorDispatcher) deliver(err error, quit <-chan struct{}) {
+	if d.handler != nil {
+		d.delivering.Store(true)
+		d.handler(err)
+		d.delivering.Store(false)
+	}
+	if d.ch == nil {
+		return
+	}
+	select {
+	case d.ch <- err:
+		return
+	default:
+	}
+	if quit != nil {
+		select {
+		case d.ch <- err:
+			return
+		case <-quit:
+		}
+	}
+	d.dropped.Add(1)
+}
+
+// report queues err for delivery, dropping it if the queue is full.
+func (d *errorDispatcher) report(err error) {
+	if d == nil || err == nil {
+		return
+	}
+	select {
+	case d.queue <- err:
+	default:
+		d.dropped.Add(1)
+	}
+}
+
+// droppedErrors returns the number of errors dropped by d.
+func (d *errorDispatcher) droppedErrors() uint64 {
+	if d == nil {
+		return 0
+	}
+	return d.dropped.Load()
+}
+
+// close delivers the queued errors and stops the dispatcher. If the handler
+// is running, as when close is called from it, close returns without waiting
+// and the queued errors are delivered once the handler returns.
+func (d *errorDispatcher) close() {
+	if d == nil {
+		return
+	}
+	close(d.quit)
+	if d.delivering.Load() {
+		return
+	}
+	<-d.done
+}
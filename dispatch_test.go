@@ -0,0 +1,95 @@
+package huestream
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestErrorDispatcherStress(t *testing.T) {
+	const reporters, reports = 8, 1000
+
+	var delivered atomic.Uint64
+	ch := make(chan error) // Never read: every delivery to it drops.
+	d := newErrorDispatcher(func(error) {
+		delivered.Add(1)
+		time.Sleep(10 * time.Microsecond)
+	}, nil)
+	dc := newErrorDispatcher(nil, ch)
+
+	var wg sync.WaitGroup
+	for range reporters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range reports {
+				d.report(errors.New("fail"))
+				dc.report(errors.New("fail"))
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		d.close()
+		dc.close()
+		<-d.done // close doesn't wait for a running handler.
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("reporters or close blocked")
+	}
+
+	const total = reporters * reports
+	if got := delivered.Load() + d.droppedErrors(); got != total {
+		t.Errorf("%d errors delivered and %d dropped, want %d in all", delivered.Load(), d.droppedErrors(), total)
+	}
+	if d.droppedErrors() == 0 {
+		t.Error("no error dropped by a slow handler")
+	}
+	if got := dc.droppedErrors(); got != total {
+		t.Errorf("%d errors dropped for a channel never read, want %d", got, total)
+	}
+}
+
+func TestErrorChannel(t *testing.T) {
+	errs := make(chan error, 1)
+	var handled error
+	d := newErrorDispatcher(func(err error) { handled = err }, errs)
+
+	want := errors.New("fail")
+	d.report(want)
+	select {
+	case err := <-errs:
+		if err != want {
+			t.Errorf("got %v, want %v", err, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("error not sent to the channel")
+	}
+	d.close()
+	if handled != want {
+		t.Errorf("handler got %v, want %v", handled, want)
+	}
+	if n := d.droppedErrors(); n != 0 {
+		t.Errorf("%d errors dropped, want 0", n)
+	}
+}
+
+func TestStatsErrorsDropped(t *testing.T) {
+	block := make(chan struct{})
+	s, _ := pipeStream(t, WithErrorHandler(func(error) { <-block }))
+	defer close(block)
+
+	// The first error blocks the handler, the next ones fill the queue.
+	for range errorQueueSize + 5 {
+		s.errs.report(errors.New("fail"))
+	}
+	if got := s.Stats().ErrorsDropped; got < 4 {
+		t.Errorf("Stats().ErrorsDropped = %d, want at least 4", got)
+	}
+}
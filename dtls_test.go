@@ -0,0 +1,244 @@
+package huestream
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/dtls/v3"
+)
+
+const testClientKey = "000102030405060708090a0b0c0d0e0f"
+
+// pskServer starts a local DTLS server offering suites and returns a client
+// whose handshakes reach it. The identities sent by the clients are sent to
+// the returned channel.
+func pskServer(t *testing.T, suites ...dtls.CipherSuiteID) (*client, <-chan string) {
+	t.Helper()
+
+	key, _ := hex.DecodeString(testClientKey)
+	identities := make(chan string, 8)
+	ln, err := dtls.Listen("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, &dtls.Config{
+		PSK: func(identity []byte) ([]byte, error) {
+			identities <- string(identity)
+			return key, nil
+		},
+		PSKIdentityHint: []byte("bridge"),
+		CipherSuites:    suites,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				conn.(*dtls.Conn).HandshakeContext(ctx)
+			}()
+		}
+	}()
+
+	c := newClient("127.0.0.1", "username", testClientKey)
+	c.streamPort = ln.Addr().(*net.UDPAddr).Port
+	return c, identities
+}
+
+func TestHandshakeCipherSuites(t *testing.T) {
+	c, _ := pskServer(t, dtls.TLS_PSK_WITH_AES_128_CCM_8)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := c.handshakeUDP(ctx)
+	if err == nil {
+		t.Fatal("handshake without a common suite should fail")
+	}
+	if !strings.Contains(err.Error(), "TLS_PSK_WITH_AES_128_GCM_SHA256") {
+		t.Errorf("error %q does not name the offered suite", err)
+	}
+
+	c.cfg = newConfig([]Option{WithCipherSuites(dtls.TLS_PSK_WITH_AES_128_CCM_8)})
+	conn, err := c.handshakeUDP(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestHandshakePSKIdentity(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		want string
+	}{
+		{opts: nil, want: "username"},
+		{opts: []Option{WithPSKIdentity("proxy-identity")}, want: "proxy-identity"},
+	}
+	for _, tt := range tests {
+		c, identities := pskServer(t, dtls.TLS_PSK_WITH_AES_128_GCM_SHA256)
+		c.cfg = newConfig(tt.opts)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		conn, err := c.handshakeUDP(ctx)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+
+		if got := <-identities; got != tt.want {
+			t.Errorf("server received identity %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestHandshakePSKProvider(t *testing.T) {
+	c, _ := pskServer(t, dtls.TLS_PSK_WITH_AES_128_GCM_SHA256)
+	c.clientKey = ""
+	key, _ := hex.DecodeString(testClientKey)
+
+	var provided [][]byte
+	c.cfg = newConfig([]Option{WithPSKProvider(func(ctx context.Context) ([]byte, error) {
+		psk := slices.Clone(key)
+		provided = append(provided, psk)
+		return psk, nil
+	})})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for range 2 {
+		conn, err := c.handshakeUDP(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	if len(provided) != 2 {
+		t.Errorf("provider called %d times for 2 handshakes", len(provided))
+	}
+	for _, psk := range provided {
+		if !bytes.Equal(psk, make([]byte, len(key))) {
+			t.Errorf("psk not zeroed after the handshake: %x", psk)
+		}
+	}
+
+	c.cfg = newConfig([]Option{WithPSKProvider(func(ctx context.Context) ([]byte, error) {
+		return nil, errors.New("keychain locked")
+	})})
+	_, err := c.handshakeUDP(ctx)
+	if err == nil || !strings.Contains(err.Error(), "keychain locked") {
+		t.Errorf("handshake error %v does not carry the provider error", err)
+	}
+}
+
+func TestHandshakeClientKeyBytes(t *testing.T) {
+	c, _ := pskServer(t, dtls.TLS_PSK_WITH_AES_128_GCM_SHA256)
+	key, _ := hex.DecodeString(testClientKey)
+	c.clientKey = ""
+	c.cfg = newConfig([]Option{WithClientKey(key)})
+	c.key = c.cfg.clientKey
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := c.handshakeUDP(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newStream(conn, nil, testAreaID, c.cfg)
+	s.client = c
+	s.Close() // The stop action fails, there is no bridge.
+
+	if !bytes.Equal(c.key, make([]byte, clientKeySize)) {
+		t.Errorf("key not zeroed on Close: %x", c.key)
+	}
+	if _, err := c.handshakeUDP(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("handshake after Close: %v, want ErrClosed", err)
+	}
+}
+
+func TestValidateClientKey(t *testing.T) {
+	cfg := newConfig([]Option{WithClientKey(make([]byte, 15))})
+	if err := cfg.validate(); err == nil {
+		t.Error("validate should reject a 15 bytes key")
+	}
+}
+
+func TestHandshakeMTU(t *testing.T) {
+	c, _ := pskServer(t, dtls.TLS_PSK_WITH_AES_128_GCM_SHA256)
+	c.cfg = newConfig([]Option{WithMTU(minMTU)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := c.handshakeUDP(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestValidateMTU(t *testing.T) {
+	for _, mtu := range []int{-1, minMTU - 1} {
+		cfg := newConfig([]Option{WithMTU(mtu)})
+		if err := cfg.validate(); err == nil {
+			t.Errorf("validate should reject MTU %d", mtu)
+		}
+	}
+	cfg := newConfig([]Option{WithMTU(1380)})
+	if err := cfg.validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHandshakeDialer(t *testing.T) {
+	c, _ := pskServer(t, dtls.TLS_PSK_WITH_AES_128_GCM_SHA256)
+
+	var addrs []string
+	c.cfg = newConfig([]Option{WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		addrs = append(addrs, network+" "+addr)
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	})})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := c.handshakeUDP(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	want := fmt.Sprintf("udp 127.0.0.1:%d", c.streamPort)
+	if len(addrs) != 1 || addrs[0] != want {
+		t.Errorf("dialer called with %q, want [%s]", addrs, want)
+	}
+}
+
+func TestValidateCipherSuites(t *testing.T) {
+	tests := map[string][]dtls.CipherSuiteID{
+		"empty":       {},
+		"certificate": {dtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		"unknown":     {0x1234},
+	}
+	for name, suites := range tests {
+		cfg := newConfig([]Option{WithCipherSuites(suites...)})
+		if err := cfg.validate(); err == nil {
+			t.Errorf("%s: validate should fail", name)
+		}
+	}
+
+	cfg := newConfig([]Option{WithCipherSuites(dtls.TLS_PSK_WITH_AES_128_CCM, dtls.TLS_PSK_WITH_AES_128_GCM_SHA256)})
+	if err := cfg.validate(); err != nil {
+		t.Error(err)
+	}
+}
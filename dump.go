@@ -0,0 +1,57 @@
+package huestream
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rschio/huestream/wire"
+)
+
+// frameDump writes the messages sent by a Stream, see WithFrameDump. Its
+// methods are called with the write lock of the Stream held.
+type frameDump struct {
+	w     io.Writer
+	every time.Duration
+	last  time.Time // When the last dump was written.
+	n     int       // Messages skipped since the last dump.
+}
+
+// write dumps b, sent at t, unless a dump was written less than every ago.
+func (d *frameDump) write(t time.Time, b []byte) error {
+	if !d.last.IsZero() && t.Sub(d.last) < d.every {
+		d.n++
+		return nil
+	}
+	skipped := d.n
+	d.last, d.n = t, 0
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %d bytes", t.Format("15:04:05.000000"), len(b))
+	if skipped > 0 {
+		fmt.Fprintf(&sb, ", %d skipped", skipped)
+	}
+	sb.WriteByte('\n')
+	sb.WriteString(hex.Dump(b))
+
+	// Decoding the bytes, rather than printing the frame, shows what the
+	// bridge will read.
+	f, err := wire.Decode(b)
+	if err != nil {
+		fmt.Fprintf(&sb, "  undecodable: %v\n", err)
+	} else {
+		fmt.Fprintf(&sb, "  version %d, sequence %d, color space %d", f.Version, f.Sequence, f.ColorSpace)
+		if f.AreaID != "" {
+			fmt.Fprintf(&sb, ", area %s", f.AreaID)
+		}
+		sb.WriteByte('\n')
+		for _, c := range f.Channels {
+			fmt.Fprintf(&sb, "  channel %d: %04x %04x %04x\n", c.ID, c.Values[0], c.Values[1], c.Values[2])
+		}
+	}
+
+	_, err = io.WriteString(d.w, sb.String())
+	return err
+}
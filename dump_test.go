@@ -0,0 +1,40 @@
+package huestream
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+func TestFrameDump(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	var buf bytes.Buffer
+	s, frames := pipeStream(t, withClock(clk), WithFrameDump(&buf, 100*time.Millisecond))
+
+	for _, d := range []time.Duration{0, 10 * time.Millisecond, 110 * time.Millisecond} {
+		clk.Advance(d)
+		if err := s.Send(Frame{1: color.RGBA{R: 255, A: 255}}); err != nil {
+			t.Fatal(err)
+		}
+		<-frames
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"12:00:00.000000 59 bytes\n00000000  48 75 65 53 74 72 65 61  6d 02 00 00 00 00 00 00  |HueStream.......|",
+		"  version 2, sequence 0, color space 0, area " + testAreaID + "\n  channel 1: ffff 0000 0000\n",
+		"12:00:00.120000 59 bytes, 1 skipped\n",
+		"version 2, sequence 2,",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dump does not contain %q:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "bytes"); n != 2 {
+		t.Errorf("got %d dumps, want 2:\n%s", n, out)
+	}
+}
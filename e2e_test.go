@@ -7,10 +7,13 @@ import (
 	"fmt"
 	"image/color"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huetest"
 )
 
 var (
@@ -24,7 +27,7 @@ func TestNilMessage(t *testing.T) {
 	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
 	defer cancel()
 
-	stream, err := huestream.Start(ctx, bridgeHost, username, clientKey, areaID)
+	stream, err := huestream.Start(ctx, bridgeHost, username, clientKey, areaID, record(t)...)
 	if err != nil {
 		t.Fatalf("hue.StartStream: %v", err)
 	}
@@ -39,7 +42,7 @@ func TestMoreThan20Channels(t *testing.T) {
 	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
 	defer cancel()
 
-	stream, err := huestream.Start(ctx, bridgeHost, username, clientKey, areaID)
+	stream, err := huestream.Start(ctx, bridgeHost, username, clientKey, areaID, record(t)...)
 	if err != nil {
 		t.Fatalf("hue.StartStream: %v", err)
 	}
@@ -59,7 +62,7 @@ func TestOnlyOneLamp(t *testing.T) {
 	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
 	defer cancel()
 
-	stream, err := huestream.Start(ctx, bridgeHost, username, clientKey, areaID)
+	stream, err := huestream.Start(ctx, bridgeHost, username, clientKey, areaID, record(t)...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -101,7 +104,7 @@ func testE2E(t *testing.T) {
 	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
 	defer cancel()
 
-	stream, err := huestream.Start(ctx, bridgeHost, username, clientKey, areaID)
+	stream, err := huestream.Start(ctx, bridgeHost, username, clientKey, areaID, record(t)...)
 	if err != nil {
 		t.Fatalf("hue.StartStream: %v", err)
 	}
@@ -150,6 +153,34 @@ var colors = [...]map[int]color.Color{
 	},
 }
 
+// recorders holds the Recorder of each test, TestE2E starts two streams.
+var recorders sync.Map
+
+// record returns the options recording the CLIP traffic of the test when
+// HUESTREAM_RECORD=1. The recording is saved for the fake bridge to replay
+// when the test ends.
+func record(t *testing.T) []huestream.Option {
+	if os.Getenv("HUESTREAM_RECORD") != "1" {
+		return nil
+	}
+	if rec, ok := recorders.Load(t.Name()); ok {
+		return rec.(*huetest.Recorder).Options()
+	}
+
+	rec := huetest.NewRecorder(t, "https://"+bridgeHost, username, clientKey, areaID)
+	recorders.Store(t.Name(), rec)
+	t.Cleanup(func() {
+		path := recordingPath(t)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Error(err)
+		}
+		if err := rec.Save(path); err != nil {
+			t.Error(err)
+		}
+	})
+	return rec.Options()
+}
+
 func mustEnv(key string) string {
 	v := os.Getenv(key)
 	if v == "" {
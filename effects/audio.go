@@ -0,0 +1,115 @@
+package effects
+
+import (
+	"image/color"
+	"iter"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huecolor"
+)
+
+// AudioFrame is the analysis of a slice of audio, made by the caller: this
+// package does not capture nor analyze audio.
+type AudioFrame struct {
+	RMS   float64   // Level, from 0 to 1.
+	Bands []float64 // Energies of the frequency bands, lowest first, from 0 to 1.
+	Beat  bool      // Whether a beat starts in the slice.
+}
+
+// centroid returns the spectral centroid of the bands, from 0, all the
+// energy in the lowest band, to 1, all in the highest.
+func (a AudioFrame) centroid() float64 {
+	if len(a.Bands) < 2 {
+		return 0
+	}
+	var sum, weighted float64
+	for i, e := range a.Bands {
+		sum += e
+		weighted += float64(i) * e
+	}
+	if sum == 0 {
+		return 0
+	}
+	return weighted / sum / float64(len(a.Bands)-1)
+}
+
+// AudioMapping sets how Audio modulates its base effect. The zero
+// AudioMapping leaves the base as is.
+type AudioMapping struct {
+	// Brightness is how much the brightness follows the RMS level: 0 keeps
+	// the base, 1 goes from black in silence to the base at full level.
+	Brightness float64
+	// Hue is the rotation of the hue, in turns, at the highest spectral
+	// centroid.
+	Hue float64
+	// Flash is the color flashed on beats, fading over a few ticks. Nil
+	// disables the flashes.
+	Flash color.Color
+	// Smoothing, from 0 to 1, is the weight of the past in the level and the
+	// centroid, smoothing them exponentially. 0 follows the audio at once.
+	Smoothing float64
+}
+
+// Audio modulates the frames of base with the audio frames received from in,
+// as set by m. On every tick it takes the latest frame received, without
+// waiting: a slow analysis keeps the last values, and the beats of the
+// frames skipped are not lost. The effect ends when in is closed.
+func Audio(base iter.Seq[huestream.Frame], in <-chan AudioFrame, m AudioMapping) iter.Seq[huestream.Frame] {
+	const fade = 0.6 // Flash level kept per tick.
+
+	return func(yield func(huestream.Frame) bool) {
+		var level, centroid, flash float64
+		first := true
+		for f := range base {
+			a, received, open := drain(in)
+			if !open {
+				return
+			}
+			switch {
+			case !received:
+			case first:
+				level, centroid, first = a.RMS, a.centroid(), false
+			default:
+				level = m.Smoothing*level + (1-m.Smoothing)*a.RMS
+				centroid = m.Smoothing*centroid + (1-m.Smoothing)*a.centroid()
+			}
+			flash *= fade
+			if a.Beat {
+				flash = 1
+			}
+
+			gain := 1 - m.Brightness + m.Brightness*min(max(level, 0), 1)
+			out := make(huestream.Frame, len(f))
+			for id, c := range f {
+				c = huecolor.RotateHue(c, m.Hue*centroid)
+				c = mix(color.Black, c, gain)
+				if m.Flash != nil {
+					c = mix(c, m.Flash, flash)
+				}
+				out[id] = c
+			}
+			if !yield(out) {
+				return
+			}
+		}
+	}
+}
+
+// drain returns the last frame received from in, with a beat if any of the
+// frames received had one, whether any was received and false if in is
+// closed.
+func drain(in <-chan AudioFrame) (last AudioFrame, received, open bool) {
+	beat := false
+	for {
+		select {
+		case a, ok := <-in:
+			if !ok {
+				return last, received, false
+			}
+			last, received, beat = a, true, beat || a.Beat
+			last.Beat = beat
+		default:
+			return last, received, true
+		}
+	}
+}
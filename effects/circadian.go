@@ -0,0 +1,160 @@
+package effects
+
+import (
+	"image/color"
+	"iter"
+	"math"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huecolor"
+)
+
+// CircadianParams sets the curve of Circadian. The zero CircadianParams
+// follows the sun at latitude and longitude 0, from 2700K to 6500K.
+type CircadianParams struct {
+	// The place of the sun times, in degrees, north and east positive.
+	Latitude, Longitude float64
+
+	// Sun returns the sunrise and the sunset of the day of t, replacing
+	// the ones computed at Latitude and Longitude, for a schedule of your
+	// own as 7:00 to 19:00 every day.
+	Sun func(t time.Time) (rise, set time.Time)
+
+	// The color temperatures of the night and of midday, in kelvin, 2700
+	// and 6500 by default.
+	WarmKelvin, CoolKelvin float64
+
+	// Now returns the time, time.Now if nil. The days are the ones of its
+	// location.
+	Now func() time.Time
+}
+
+func (p CircadianParams) withDefaults() CircadianParams {
+	if p.WarmKelvin == 0 {
+		p.WarmKelvin = 2700
+	}
+	if p.CoolKelvin == 0 {
+		p.CoolKelvin = 6500
+	}
+	if p.Now == nil {
+		p.Now = time.Now
+	}
+	if p.Sun == nil {
+		lat, lon := p.Latitude, p.Longitude
+		p.Sun = func(t time.Time) (time.Time, time.Time) { return SunTimes(t, lat, lon) }
+	}
+	return p
+}
+
+// Kelvin returns the color temperature of the curve at t, in kelvin: warm
+// through the night, cooling from sunrise to the coolest at the middle of
+// the day, then warming back until sunset. The temperature moves along a
+// sine of the time from sunrise to sunset, in mirek.
+func (p CircadianParams) Kelvin(t time.Time) float64 {
+	p = p.withDefaults()
+	warm, cool := 1e6/p.WarmKelvin, 1e6/p.CoolKelvin
+	rise, set := p.Sun(t)
+	var day float64 // The height of the day, from 0 at night to 1.
+	if !rise.IsZero() && !t.Before(rise) && t.Before(set) {
+		day = math.Sin(math.Pi * float64(t.Sub(rise)) / float64(set.Sub(rise)))
+	}
+	return 1e6 / (warm + (cool-warm)*day)
+}
+
+// circadianUpdate is the time between two white points of Circadian, in
+// which the curve moves by about a mirek: changes that small go unseen.
+const circadianUpdate = time.Minute
+
+// Circadian lights the channels with the white of the time of day as
+// p.Kelvin tells, following the sun over the day, as a base for all-day
+// ambient lighting. The white point is updated every minute, in steps too
+// small to be seen.
+//
+// The brightness of the channels is the one of the frames of brightness,
+// another effect modulating the white: the largest linear intensity of the
+// color of a channel, full for the channels it does not set. Circadian
+// ends with brightness. A nil brightness is full brightness, forever.
+func Circadian(ids []int, p CircadianParams, brightness iter.Seq[huestream.Frame]) iter.Seq[huestream.Frame] {
+	p = p.withDefaults()
+
+	return func(yield func(huestream.Frame) bool) {
+		var levels func() (huestream.Frame, bool)
+		if brightness != nil {
+			next, stop := iter.Pull(brightness)
+			defer stop()
+			levels = next
+		}
+		var k float64
+		var updated time.Time
+		for {
+			now := p.Now()
+			if updated.IsZero() || now.Sub(updated) >= circadianUpdate || now.Before(updated) {
+				k, updated = p.Kelvin(now), now
+			}
+			var lf huestream.Frame
+			if levels != nil {
+				var ok bool
+				if lf, ok = levels(); !ok {
+					return
+				}
+			}
+			f := make(huestream.Frame, len(ids))
+			for _, id := range ids {
+				level := 1.0
+				if c, ok := lf[id]; ok {
+					level = maxLinear(c)
+				}
+				f[id] = warmth(k, level)
+			}
+			if !yield(f) {
+				return
+			}
+		}
+	}
+}
+
+// SunTimes returns the sunrise and the sunset of the day of t, in its
+// location, at latitude lat and longitude lon in degrees, north and east
+// positive, with the sunrise equation of NOAA, good to a minute or two.
+//
+// On the days the sun does not set, rise and set are the start and the
+// end of the day, on the days it does not rise they are both zero.
+func SunTimes(t time.Time, lat, lon float64) (rise, set time.Time) {
+	const (
+		j2000 = 2451545.0 // The Julian date of 2000-01-01 12:00 UTC.
+		unix  = 2440587.5 // The Julian date of the Unix epoch.
+		rad   = math.Pi / 180
+	)
+	y, m, d := t.Date()
+	noon := time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+	n := math.Round(float64(noon.Unix())/86400 + unix - j2000)
+
+	jstar := n - lon/360
+	ma := math.Mod(357.5291+0.98560028*jstar, 360)
+	c := 1.9148*math.Sin(ma*rad) + 0.02*math.Sin(2*ma*rad) + 0.0003*math.Sin(3*ma*rad)
+	lambda := math.Mod(ma+c+180+102.9372, 360)
+	transit := j2000 + jstar + 0.0053*math.Sin(ma*rad) - 0.0069*math.Sin(2*lambda*rad)
+	sinDecl := math.Sin(lambda*rad) * math.Sin(23.4397*rad)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	cosH := (math.Sin(-0.833*rad) - math.Sin(lat*rad)*sinDecl) / (math.Cos(lat*rad) * cosDecl)
+
+	switch {
+	case cosH > 1: // Polar night.
+		return time.Time{}, time.Time{}
+	case cosH < -1: // Midnight sun.
+		start := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 0, 1)
+	}
+	h := math.Acos(cosH) / rad / 360
+	julian := func(j float64) time.Time {
+		return time.Unix(0, int64((j-unix)*86400*float64(time.Second))).In(t.Location())
+	}
+	return julian(transit - h), julian(transit + h)
+}
+
+// maxLinear returns the largest linear intensity of c, from 0 to 1.
+func maxLinear(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return huecolor.ToLinear(float64(max(r, g, b)) / 0xffff)
+}
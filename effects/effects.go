@@ -0,0 +1,316 @@
+// Package effects generates frame sequences to play with Stream.PlaySeq or
+// huestream.Play.
+//
+// An effect yields one frame per tick, so its speed follows the rate it is
+// played at. The random effects draw from a generator set with WithSeed or
+// WithRand: two runs with the same seed yield the same frames, which makes
+// them testable against golden frames or images.
+//
+// Audio makes an effect follow music: it modulates the frames of another
+// effect with the analyses of an audio source.
+//
+// Sunrise and Circadian are slow effects of white light, a wake-up ramp and
+// a white following the sun over the day, on which Circadian lays another
+// effect for the brightness.
+//
+// Registry lists the effects playable by name, with typed parameters read
+// from flags or JSON, for the huestream command, the preset packs and the
+// HTTP API.
+//
+// HueSweep and PaletteCycle are a few lines each, they are the reference
+// examples for writing an effect of your own.
+package effects
+
+import (
+	"image/color"
+	"iter"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huecolor"
+)
+
+// Option configures an effect.
+type Option func(*config)
+
+type config struct {
+	newRand     func() *rand.Rand // Called on every iteration of the effect.
+	phase       func(i, id int) float64 // In turns, nil is 0.
+	blend       bool
+	randomOrder bool
+}
+
+// offset returns the phase of the channel id, at index i of the ids of the
+// effect.
+func (c config) offset(i, id int) float64 {
+	if c.phase == nil {
+		return 0
+	}
+	return c.phase(i, id)
+}
+
+func newConfig(opts []Option) config {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.newRand == nil {
+		cfg.newRand = func() *rand.Rand {
+			seed := uint64(time.Now().UnixNano())
+			return rand.New(rand.NewPCG(seed, seed))
+		}
+	}
+	return cfg
+}
+
+// WithRand makes the effect draw from r. r is not safe for concurrent use,
+// so it must not be shared by effects played at the same time.
+//
+// The draws of an iteration advance r, so a second iteration of the effect
+// yields other frames: the sequence is meant to be played once. WithSeed
+// yields a sequence replaying the same frames.
+func WithRand(r *rand.Rand) Option {
+	return func(c *config) { c.newRand = func() *rand.Rand { return r } }
+}
+
+// WithSeed makes the effect draw from a generator seeded with seed, a new
+// one on every iteration, so each iteration yields the same frames. By
+// default the seed is the time the iteration starts.
+func WithSeed(seed uint64) Option {
+	return func(c *config) {
+		c.newRand = func() *rand.Rand { return rand.New(rand.NewPCG(seed, seed)) }
+	}
+}
+
+// WithIndexPhase puts every channel of a cyclic effect, as HueSweep,
+// spread turns of its cycle ahead of the one before it in ids. By default
+// the channels are in phase.
+func WithIndexPhase(spread float64) Option {
+	return func(c *config) { c.phase = func(i, _ int) float64 { return float64(i) * spread } }
+}
+
+// WithPositionPhase offsets every channel of a cyclic effect, as HueSweep,
+// by its position from left to right: the channels at the left edge of the
+// area are in phase with the cycle and the ones at the right edge spread
+// turns ahead. The channels not in channels are in phase.
+func WithPositionPhase(channels []huestream.Channel, spread float64) Option {
+	x := make(map[int]float64, len(channels))
+	for _, ch := range channels {
+		x[ch.ID] = (ch.Position.X + 1) / 2
+	}
+	return func(c *config) { c.phase = func(_, id int) float64 { return x[id] * spread } }
+}
+
+// WithBlend makes PaletteCycle fade from each color to the next one instead
+// of stepping.
+func WithBlend() Option {
+	return func(c *config) { c.blend = true }
+}
+
+// WithRandomOrder makes PaletteCycle pick the next color of every channel
+// at random, never the color it has, drawing from the generator of WithSeed
+// or WithRand. The phase options are ignored.
+func WithRandomOrder() Option {
+	return func(c *config) { c.randomOrder = true }
+}
+
+// Sparkle lights the channels with base and makes them flash white at
+// random, each flash fading back to base over a few ticks. density is the
+// probability that a channel starts a flash on a tick.
+func Sparkle(ids []int, base color.Color, density float64, opts ...Option) iter.Seq[huestream.Frame] {
+	cfg := newConfig(opts)
+	const fade = 5 // Ticks of a flash.
+
+	return func(yield func(huestream.Frame) bool) {
+		r := cfg.newRand()
+		left := make([]int, len(ids)) // Ticks left of the flash of each channel.
+		for {
+			f := make(huestream.Frame, len(ids))
+			for i, id := range ids {
+				if left[i] == 0 && r.Float64() < density {
+					left[i] = fade
+				}
+				f[id] = mix(base, color.White, float64(left[i])/fade)
+				left[i] = max(left[i]-1, 0)
+			}
+			if !yield(f) {
+				return
+			}
+		}
+	}
+}
+
+// Candle makes the channels flicker like candle flames, each on its own.
+func Candle(ids []int, opts ...Option) iter.Seq[huestream.Frame] {
+	cfg := newConfig(opts)
+	flame := color.RGBA{R: 255, G: 147, B: 41, A: 255}
+
+	return func(yield func(huestream.Frame) bool) {
+		r := cfg.newRand()
+		level := make([]float64, len(ids))
+		for i := range level {
+			level[i] = 0.8
+		}
+		for {
+			f := make(huestream.Frame, len(ids))
+			for i, id := range ids {
+				// A random walk pulled back to 0.8, with rare dips.
+				level[i] += (0.8-level[i])*0.3 + (r.Float64()-0.5)*0.2
+				if r.Float64() < 0.02 {
+					level[i] -= 0.3
+				}
+				level[i] = min(max(level[i], 0.3), 1)
+				f[id] = mix(color.Black, flame, level[i])
+			}
+			if !yield(f) {
+				return
+			}
+		}
+	}
+}
+
+// Lightning keeps the channels dark and strikes at random: every channel
+// flashes white, one to three times, then fades. A strike starts on a tick
+// with probability chance.
+func Lightning(ids []int, chance float64, opts ...Option) iter.Seq[huestream.Frame] {
+	cfg := newConfig(opts)
+
+	return func(yield func(huestream.Frame) bool) {
+		r := cfg.newRand()
+		var strike []float64 // Levels of the ticks left of the strike.
+		for {
+			if len(strike) == 0 && r.Float64() < chance {
+				strike = newStrike(r)
+			}
+			level := 0.0
+			if len(strike) > 0 {
+				level, strike = strike[0], strike[1:]
+			}
+			f := make(huestream.Frame, len(ids))
+			for _, id := range ids {
+				f[id] = mix(color.Black, color.White, level)
+			}
+			if !yield(f) {
+				return
+			}
+		}
+	}
+}
+
+// Rainbow cycles the channels through the hues, a turn every period ticks,
+// every channel spread turns ahead of the one before it in ids.
+func Rainbow(ids []int, period int, spread float64) iter.Seq[huestream.Frame] {
+	return HueSweep(ids, color.RGBA{R: 255, A: 255}, period, WithIndexPhase(spread))
+}
+
+// HueSweep rotates the hue of base smoothly, a turn every period ticks,
+// keeping its saturation and value. The channels are offset with
+// WithIndexPhase or WithPositionPhase.
+func HueSweep(ids []int, base color.Color, period int, opts ...Option) iter.Seq[huestream.Frame] {
+	cfg := newConfig(opts)
+	period = max(period, 1)
+
+	return func(yield func(huestream.Frame) bool) {
+		for tick := 0; ; tick = (tick + 1) % period {
+			f := make(huestream.Frame, len(ids))
+			for i, id := range ids {
+				f[id] = huecolor.RotateHue(base, float64(tick)/float64(period)+cfg.offset(i, id))
+			}
+			if !yield(f) {
+				return
+			}
+		}
+	}
+}
+
+// PaletteCycle steps the channels through the colors of palette, a color
+// every step ticks, or fades from color to color with WithBlend. The
+// channels are offset with WithIndexPhase or WithPositionPhase, in turns
+// of the whole palette, or take the colors in random order with
+// WithRandomOrder. An empty palette yields no frame.
+func PaletteCycle(ids []int, palette []color.Color, step int, opts ...Option) iter.Seq[huestream.Frame] {
+	cfg := newConfig(opts)
+	step = max(step, 1)
+	n := len(palette)
+	cycle := float64(n * step)
+
+	return func(yield func(huestream.Frame) bool) {
+		if n == 0 {
+			return
+		}
+		r := cfg.newRand()
+		// The color of every channel and the next one, in random order.
+		cur, next := make([]int, len(ids)), make([]int, len(ids))
+		draw := func(not int) int {
+			if n == 1 {
+				return 0
+			}
+			j := r.IntN(n - 1)
+			if j >= not {
+				j++
+			}
+			return j
+		}
+		if cfg.randomOrder {
+			for i := range ids {
+				cur[i] = r.IntN(n)
+				next[i] = draw(cur[i])
+			}
+		}
+
+		for tick := 0; ; tick++ {
+			f := make(huestream.Frame, len(ids))
+			for i, id := range ids {
+				var t float64 // The progress toward the next color.
+				if cfg.randomOrder {
+					if tick > 0 && tick%step == 0 {
+						cur[i], next[i] = next[i], draw(next[i])
+					}
+					t = float64(tick%step) / float64(step)
+				} else {
+					pos := math.Mod(float64(tick)+cfg.offset(i, id)*cycle, cycle)
+					if pos < 0 {
+						pos += cycle
+					}
+					cur[i] = min(int(pos)/step, n-1)
+					next[i] = (cur[i] + 1) % n
+					t = (pos - float64(cur[i]*step)) / float64(step)
+				}
+				c := palette[cur[i]]
+				if cfg.blend {
+					c = mix(c, palette[next[i]], t)
+				}
+				f[id] = c
+			}
+			if !yield(f) {
+				return
+			}
+		}
+	}
+}
+
+// newStrike returns the levels of a strike: flashes separated by short
+// darkness, the last one fading out.
+func newStrike(r *rand.Rand) []float64 {
+	var levels []float64
+	for range 1 + r.IntN(3) {
+		levels = append(levels, 0.6+0.4*r.Float64(), 0, 0)
+	}
+	levels = levels[:len(levels)-2]
+	for l := levels[len(levels)-1]; l > 0.05; l /= 2 {
+		levels = append(levels, l/2)
+	}
+	return levels
+}
+
+// mix returns the color at t of the way from a to b.
+func mix(a, b color.Color, t float64) color.Color {
+	ar, ag, ab, _ := a.RGBA()
+	br, bg, bb, _ := b.RGBA()
+	lerp := func(x, y uint32) uint16 {
+		return uint16(float64(x) + (float64(y)-float64(x))*t)
+	}
+	return color.RGBA64{R: lerp(ar, br), G: lerp(ag, bg), B: lerp(ab, bb), A: 0xffff}
+}
@@ -0,0 +1,468 @@
+package effects
+
+import (
+	"image/color"
+	"iter"
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huecolor"
+)
+
+func take(seq iter.Seq[huestream.Frame], n int) []huestream.Frame {
+	var frames []huestream.Frame
+	for f := range seq {
+		frames = append(frames, f)
+		if len(frames) == n {
+			break
+		}
+	}
+	return frames
+}
+
+func TestSameSeedSameFrames(t *testing.T) {
+	ids := []int{0, 1, 2}
+	tests := map[string]func(...Option) iter.Seq[huestream.Frame]{
+		"sparkle": func(opts ...Option) iter.Seq[huestream.Frame] {
+			return Sparkle(ids, color.Black, 0.1, opts...)
+		},
+		"candle": func(opts ...Option) iter.Seq[huestream.Frame] {
+			return Candle(ids, opts...)
+		},
+		"lightning": func(opts ...Option) iter.Seq[huestream.Frame] {
+			return Lightning(ids, 0.1, opts...)
+		},
+		"palette": func(opts ...Option) iter.Seq[huestream.Frame] {
+			palette := []color.Color{color.Black, color.White, color.Gray{0x80}, color.Gray{0x40}}
+			return PaletteCycle(ids, palette, 2, append(opts, WithRandomOrder())...)
+		},
+	}
+	for name, effect := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := take(effect(WithSeed(42)), 200)
+			b := take(effect(WithRand(rand.New(rand.NewPCG(42, 42)))), 200)
+			if !reflect.DeepEqual(a, b) {
+				t.Error("two runs with the same seed differ")
+			}
+			if c := take(effect(WithSeed(43)), 200); reflect.DeepEqual(a, c) {
+				t.Error("two runs with different seeds are equal")
+			}
+
+			seq := effect(WithSeed(42))
+			take(seq, 50)
+			if replay := take(seq, 200); !reflect.DeepEqual(a, replay) {
+				t.Error("a second iteration of a seeded sequence differs")
+			}
+		})
+	}
+}
+
+func TestSparkleFrames(t *testing.T) {
+	frames := take(Sparkle([]int{0, 1, 2}, color.Black, 0.1, WithSeed(1)), 12)
+
+	// With seed 1 the channels start flashing at ticks 4, 8 and 10.
+	white := color.RGBA64{R: 0xffff, G: 0xffff, B: 0xffff, A: 0xffff}
+	black := color.RGBA64{A: 0xffff}
+	for i, f := range frames {
+		for id, start := range []int{4, 8, 10} {
+			want := color.Color(black)
+			switch {
+			case i == start:
+				want = white
+			case i > start && i < start+5:
+				g := uint16(0xffff * (5 - (i - start)) / 5)
+				want = color.RGBA64{R: g, G: g, B: g, A: 0xffff}
+			}
+			if f[id] != want {
+				t.Errorf("tick %d, channel %d: got %v, want %v", i, id, f[id], want)
+			}
+		}
+	}
+}
+
+func TestLightningFrames(t *testing.T) {
+	frames := take(Lightning([]int{0}, 0.1, WithSeed(1)), 22)
+
+	// With seed 1 a strike of two flashes starts at tick 12.
+	want := []uint16{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		55979, 0, 0, 53711, 26855, 13427, 6713, 3356, 1678, 0,
+	}
+	for i, f := range frames {
+		c := color.RGBA64{R: want[i], G: want[i], B: want[i], A: 0xffff}
+		if f[0] != c {
+			t.Errorf("tick %d: got %v, want %v", i, f[0], c)
+		}
+	}
+}
+
+func TestCandleStaysWarm(t *testing.T) {
+	for i, f := range take(Candle([]int{0, 1}, WithSeed(7)), 500) {
+		for id, c := range f {
+			r, g, b, _ := c.RGBA()
+			if r < g || g < b || r < 0xffff*3/10-1 {
+				t.Fatalf("tick %d, channel %d: %v is not a flame color", i, id, c)
+			}
+		}
+	}
+}
+
+func constant(c color.Color) iter.Seq[huestream.Frame] {
+	return func(yield func(huestream.Frame) bool) {
+		for yield(huestream.Frame{0: c}) {
+		}
+	}
+}
+
+func TestAudio(t *testing.T) {
+	red := color.RGBA64{R: 0xffff, A: 0xffff}
+	in := make(chan AudioFrame, 3)
+	m := AudioMapping{Brightness: 1, Hue: 1.0 / 3, Flash: color.White}
+
+	// Two frames drained on the first tick: the level of the last one, the
+	// beat of the first.
+	in <- AudioFrame{RMS: 1, Beat: true}
+	in <- AudioFrame{RMS: 0.5, Bands: []float64{0, 1}}
+	next, stop := iter.Pull(Audio(constant(red), in, m))
+	defer stop()
+
+	f, _ := next()
+	if want := (color.RGBA64{R: 0xffff, G: 0xffff, B: 0xffff, A: 0xffff}); f[0] != want {
+		t.Errorf("beat tick = %v, want %v", f[0], want)
+	}
+	// No frame received: the level and the centroid stay, the flash fades.
+	f, _ = next()
+	r, g, b, _ := f[0].RGBA()
+	if r != b || g <= r || g > 0xffff/2+0xffff*4/10 {
+		t.Errorf("second tick = %v, want green at half brightness and a fading flash", f[0])
+	}
+
+	close(in)
+	if _, ok := next(); ok {
+		t.Error("frame yielded after in was closed")
+	}
+}
+
+func TestAudioZeroMapping(t *testing.T) {
+	c := color.RGBA64{R: 0x1234, G: 0x5678, B: 0x9abc, A: 0xffff}
+	in := make(chan AudioFrame, 1)
+	in <- AudioFrame{RMS: 0.1, Bands: []float64{0, 0, 1}, Beat: true}
+	for _, f := range take(Audio(constant(c), in, AudioMapping{}), 3) {
+		if f[0] != color.Color(c) {
+			t.Errorf("got %v, want the base %v", f[0], c)
+		}
+	}
+}
+
+func TestRainbow(t *testing.T) {
+	frames := take(Rainbow([]int{0, 1}, 3, 0.5), 4)
+	red := color.RGBA64{R: 0xffff, A: 0xffff}
+	want := []struct{ c0, c1 color.Color }{
+		{red, color.RGBA64{G: 0xffff, B: 0xffff, A: 0xffff}},
+		{color.RGBA64{G: 0xffff, A: 0xffff}, color.RGBA64{R: 0xffff, B: 0xffff, A: 0xffff}},
+		{color.RGBA64{B: 0xffff, A: 0xffff}, color.RGBA64{R: 0xffff, G: 0xffff, A: 0xffff}},
+		{red, color.RGBA64{G: 0xffff, B: 0xffff, A: 0xffff}},
+	}
+	for i, f := range frames {
+		if color.RGBA64Model.Convert(f[0]) != want[i].c0 || f[1] != want[i].c1 {
+			t.Errorf("tick %d: got %v and %v, want %v and %v", i, f[0], f[1], want[i].c0, want[i].c1)
+		}
+	}
+}
+
+func TestHueSweepPositionPhase(t *testing.T) {
+	channels := []huestream.Channel{
+		{ID: 4, Position: huestream.Position{X: -1}},
+		{ID: 7, Position: huestream.Position{X: 1}},
+	}
+	frames := take(HueSweep([]int{4, 7}, color.RGBA{R: 255, A: 255}, 4, WithPositionPhase(channels, 0.5)), 3)
+
+	red := color.RGBA64{R: 0xffff, A: 0xffff}
+	cyan := color.RGBA64{G: 0xffff, B: 0xffff, A: 0xffff}
+	if f := frames[0]; color.RGBA64Model.Convert(f[4]) != red || f[7] != cyan {
+		t.Errorf("tick 0: got %v and %v, want red at the left and cyan at the right", f[4], f[7])
+	}
+	// A quarter turn later, halfway between red and green.
+	chartreuse := color.RGBA64{R: 0x8000, G: 0xffff, A: 0xffff}
+	if got := frames[1][4]; got != chartreuse {
+		t.Errorf("tick 1: got %v, want %v", got, chartreuse)
+	}
+	if frames[2][4] != cyan {
+		t.Errorf("tick 2: got %v, want %v", frames[2][4], cyan)
+	}
+}
+
+func TestPaletteCycle(t *testing.T) {
+	red := color.RGBA64{R: 0xffff, A: 0xffff}
+	blue := color.RGBA64{B: 0xffff, A: 0xffff}
+	palette := []color.Color{red, blue}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want [][2]color.Color // The colors of the channels 0 and 1 by tick.
+	}{
+		{"step", nil, [][2]color.Color{{red, red}, {red, red}, {blue, blue}, {blue, blue}, {red, red}}},
+		{"index phase", []Option{WithIndexPhase(0.5)}, [][2]color.Color{{red, blue}, {red, blue}, {blue, red}, {blue, red}, {red, blue}}},
+		{"blend", []Option{WithBlend()}, [][2]color.Color{
+			{red, red},
+			{color.RGBA64{R: 0x7fff, B: 0x7fff, A: 0xffff}, color.RGBA64{R: 0x7fff, B: 0x7fff, A: 0xffff}},
+			{blue, blue},
+			{color.RGBA64{R: 0x7fff, B: 0x7fff, A: 0xffff}, color.RGBA64{R: 0x7fff, B: 0x7fff, A: 0xffff}},
+			{red, red},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, f := range take(PaletteCycle([]int{0, 1}, palette, 2, tt.opts...), len(tt.want)) {
+				c0, c1 := color.RGBA64Model.Convert(f[0]), color.RGBA64Model.Convert(f[1])
+				if c0 != tt.want[i][0] || c1 != tt.want[i][1] {
+					t.Errorf("tick %d: got %v and %v, want %v and %v", i, c0, c1, tt.want[i][0], tt.want[i][1])
+				}
+			}
+		})
+	}
+
+	if frames := take(PaletteCycle([]int{0}, nil, 2), 1); len(frames) != 0 {
+		t.Errorf("empty palette yielded %d frames", len(frames))
+	}
+}
+
+func TestPaletteCycleRandomOrder(t *testing.T) {
+	palette := []color.Color{color.Black, color.White, color.Gray{0x80}}
+	frames := take(PaletteCycle([]int{0}, palette, 3, WithRandomOrder(), WithSeed(7)), 30)
+	for i := 0; i < len(frames); i += 3 {
+		// A color lasts its step and never follows itself.
+		if frames[i+1][0] != frames[i][0] || frames[i+2][0] != frames[i][0] {
+			t.Fatalf("tick %d: the color changed within its step", i)
+		}
+		if i > 0 && frames[i][0] == frames[i-1][0] {
+			t.Fatalf("tick %d: the color repeated", i)
+		}
+	}
+}
+
+func TestSunrise(t *testing.T) {
+	const ticks = 100
+	frames := take(Sunrise([]int{0, 1}, ticks, SunriseParams{}), ticks+10)
+
+	// linear returns the linear intensities of the color of channel 0 of f.
+	linear := func(f huestream.Frame) (r, g, b float64) {
+		c := f[0].(color.RGBA64)
+		return huecolor.ToLinear(float64(c.R) / 0xffff), huecolor.ToLinear(float64(c.G) / 0xffff), huecolor.ToLinear(float64(c.B) / 0xffff)
+	}
+	if r, g, b := linear(frames[0]); r+g+b != 0 {
+		t.Errorf("tick 0: got %v, want off", frames[0][0])
+	}
+	// The first step is at the floor, deep red: visible at once.
+	if r, g, b := linear(frames[1]); math.Abs(r-0.01) > 0.001 || g > r/2 || b > g {
+		t.Errorf("tick 1: got linear %.4f %.4f %.4f, want red at 0.01", r, g, b)
+	}
+	// The end is cool white at full brightness, then held.
+	for _, f := range frames[ticks:] {
+		if r, g, b := linear(f); math.Abs(max(r, g, b)-1) > 0.001 || min(r, g, b) < 0.9 {
+			t.Fatalf("end: got linear %.4f %.4f %.4f, want white", r, g, b)
+		}
+	}
+	// It brightens and cools at every step.
+	for i := 2; i <= ticks; i++ {
+		r0, g0, b0 := linear(frames[i-1])
+		r, g, b := linear(frames[i])
+		if max(r, g, b) < max(r0, g0, b0) || b/r < b0/r0 {
+			t.Fatalf("tick %d: got linear %.4f %.4f %.4f after %.4f %.4f %.4f", i, r, g, b, r0, g0, b0)
+		}
+	}
+	if frames[ticks/2][0] != frames[ticks/2][1] {
+		t.Error("the channels differ")
+	}
+}
+
+func TestSunTimes(t *testing.T) {
+	for _, tc := range []struct {
+		place     string
+		lat, lon  float64
+		day       time.Time
+		rise, set string // In UTC, "" for none.
+	}{
+		{"London", 51.5074, -0.1278, time.Date(2026, 6, 21, 0, 0, 0, 0, time.UTC), "03:43", "20:21"},
+		{"London", 51.5074, -0.1278, time.Date(2026, 12, 21, 0, 0, 0, 0, time.UTC), "08:04", "15:53"},
+		{"Tromsø", 69.6492, 18.9553, time.Date(2026, 12, 21, 0, 0, 0, 0, time.UTC), "", ""},
+	} {
+		rise, set := SunTimes(tc.day, tc.lat, tc.lon)
+		if tc.rise == "" {
+			if !rise.IsZero() || !set.IsZero() {
+				t.Errorf("%s %s: got %v to %v, want no sunrise", tc.place, tc.day.Format(time.DateOnly), rise, set)
+			}
+			continue
+		}
+		for _, got := range []struct {
+			name string
+			t    time.Time
+			want string
+		}{{"sunrise", rise, tc.rise}, {"sunset", set, tc.set}} {
+			want, _ := time.Parse(time.DateOnly+" 15:04", tc.day.Format(time.DateOnly)+" "+got.want)
+			if d := got.t.Sub(want); d < -3*time.Minute || d > 3*time.Minute {
+				t.Errorf("%s %s: %s at %v, want %s", tc.place, tc.day.Format(time.DateOnly), got.name, got.t, got.want)
+			}
+		}
+	}
+
+	// The sun does not set in Tromsø in June.
+	day := time.Date(2026, 6, 21, 0, 0, 0, 0, time.UTC)
+	if rise, set := SunTimes(day, 69.6492, 18.9553); !rise.Equal(day) || !set.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("midnight sun: got %v to %v, want the whole day", rise, set)
+	}
+}
+
+// simulatedDay is a day of sun from 6:00 to 18:00 UTC.
+func simulatedDay(t time.Time) (rise, set time.Time) {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 6, 0, 0, 0, time.UTC), time.Date(y, m, d, 18, 0, 0, 0, time.UTC)
+}
+
+func TestCircadianKelvin(t *testing.T) {
+	p := CircadianParams{Sun: simulatedDay}
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	prev := p.Kelvin(day)
+	for m := 0; m < 24*60; m++ {
+		now := day.Add(time.Duration(m) * time.Minute)
+		k := p.Kelvin(now)
+		switch h := now.Hour(); {
+		case h < 6 || h >= 18:
+			if k != 2700 {
+				t.Fatalf("%s: got %.0fK, want 2700K at night", now.Format("15:04"), k)
+			}
+		case m <= 12*60:
+			if k < prev-1e-6 {
+				t.Fatalf("%s: got %.0fK after %.0fK, want cooling in the morning", now.Format("15:04"), k, prev)
+			}
+		default:
+			if k > prev+1e-6 {
+				t.Fatalf("%s: got %.0fK after %.0fK, want warming in the afternoon", now.Format("15:04"), k, prev)
+			}
+		}
+		// A minute moves the white point by less than 2 mirek.
+		if d := math.Abs(1e6/k - 1e6/prev); d > 2 {
+			t.Fatalf("%s: moved by %.1f mirek in a minute", now.Format("15:04"), d)
+		}
+		prev = k
+	}
+	if k := p.Kelvin(day.Add(12 * time.Hour)); math.Abs(k-6500) > 1 {
+		t.Errorf("midday: got %.0fK, want 6500K", k)
+	}
+}
+
+func TestCircadian(t *testing.T) {
+	// A frame a second, from 8:59:30.
+	now := time.Date(2026, 10, 17, 8, 59, 30, 0, time.UTC)
+	p := CircadianParams{Sun: simulatedDay, Now: func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}}
+	half := color.Gray{0xbc} // A linear intensity of about 0.5.
+	dim := func(yield func(huestream.Frame) bool) {
+		for yield(huestream.Frame{1: half}) {
+		}
+	}
+	frames := take(Circadian([]int{0, 1}, p, dim), 120)
+
+	for i, f := range frames {
+		if got := maxLinear(f[0]); math.Abs(got-1) > 0.001 {
+			t.Fatalf("frame %d: channel 0 at %.3f, want full", i, got)
+		}
+		if got, want := maxLinear(f[1]), maxLinear(half); math.Abs(got-want) > 0.01 {
+			t.Fatalf("frame %d: channel 1 at %.3f, want %.3f", i, got, want)
+		}
+		// The white point changes once a minute.
+		if changed := i > 0 && f[0] != frames[i-1][0]; changed != (i == 60) {
+			t.Errorf("frame %d: white point changed %v", i, changed)
+		}
+	}
+
+	if n := len(take(Circadian([]int{0}, p, take2(dim)), 10)); n != 2 {
+		t.Errorf("got %d frames over a brightness of 2, want 2", n)
+	}
+}
+
+// take2 returns the first 2 frames of seq.
+func take2(seq iter.Seq[huestream.Frame]) iter.Seq[huestream.Frame] {
+	return func(yield func(huestream.Frame) bool) {
+		for _, f := range take(seq, 2) {
+			if !yield(f) {
+				return
+			}
+		}
+	}
+}
+
+func TestParamParse(t *testing.T) {
+	red, teal := color.RGBA{R: 0xff, A: 0xff}, color.RGBA{G: 0x80, B: 0x80, A: 0xff}
+	for _, tc := range []struct {
+		typ  ParamType
+		flag string
+		json string
+		want any // An error message if a string.
+	}{
+		{NumberParam, "0.5", `0.5`, 0.5},
+		{NumberParam, "-1", `-1`, "number"},
+		{DurationParam, "1500ms", `1.5`, 1500 * time.Millisecond},
+		{DurationParam, "0s", `"0s"`, "duration"},
+		{ColorParam, "red", `"red"`, color.Color(red)},
+		{ColorParam, "reddish", `"reddish"`, "color"},
+		{PaletteParam, "red, rgb(0, 128, 128)", `["red", "rgb(0, 128, 128)"]`, []color.Color{red, teal}},
+		{PaletteParam, " , ", `[]`, "palette"},
+		{BoolParam, "true", `true`, true},
+		{BoolParam, "yes", `"yes"`, "bool"},
+	} {
+		p := Param{Name: "p", Type: tc.typ}
+		for _, in := range []struct {
+			s     string
+			parse func(string) (any, error)
+		}{
+			{tc.flag, p.Parse},
+			{tc.json, func(s string) (any, error) { return p.Decode([]byte(s)) }},
+		} {
+			got, err := in.parse(in.s)
+			if _, wantErr := tc.want.(string); wantErr {
+				if err == nil {
+					t.Errorf("%v %s: got %v, want an error", tc.typ, in.s, got)
+				}
+				continue
+			}
+			if err != nil || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%v %s: got %v, %v, want %v", tc.typ, in.s, got, err, tc.want)
+			}
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for _, e := range Registry() {
+		for _, p := range e.Params {
+			// The defaults print as the flags read them.
+			v, err := p.Parse(p.Format(p.Default))
+			if err != nil || !reflect.DeepEqual(v, p.Default) {
+				t.Errorf("%s -%s: default %v parses back as %v, %v", e.Name, p.Name, p.Default, v, err)
+			}
+		}
+		if got := take(e.Frames([]int{0, 1}, 25, nil, WithSeed(1)), 3); len(got) != 3 {
+			t.Errorf("%s: got %d frames with the defaults, want 3", e.Name, len(got))
+		}
+	}
+
+	rainbow, ok := Lookup("rainbow")
+	if !ok {
+		t.Fatal("no rainbow")
+	}
+	got := take(rainbow.Frames([]int{0, 1}, 25, Args{"period": 2 * time.Second}), 60)
+	if want := take(Rainbow([]int{0, 1}, 50, 0.1), 60); !reflect.DeepEqual(got, want) {
+		t.Error("rainbow: frames differ from Rainbow with a period of 50 ticks")
+	}
+	if _, ok := Lookup("strobe"); ok {
+		t.Error("found strobe, not in the registry")
+	}
+}
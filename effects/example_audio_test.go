@@ -0,0 +1,173 @@
+package effects_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"math"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/effects"
+)
+
+// Feed the Audio effect from a WAV file, here made in memory: four notes,
+// each a loud attack then a quieter tail, going from low to high.
+func ExampleAudio() {
+	rate, samples, err := decodeWAV(bytes.NewReader(makeWAV()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	frames := analyze(samples, rate, rate/20) // 20 analyses per second.
+
+	red := func(yield func(huestream.Frame) bool) {
+		for yield(huestream.Frame{0: color.RGBA{R: 255, A: 255}}) {
+		}
+	}
+	m := effects.AudioMapping{Brightness: 1, Hue: 0.5, Flash: color.White}
+
+	// Feed one analysis per tick, as an analysis running at the rate the
+	// effect is played at would.
+	in := make(chan effects.AudioFrame, 1)
+	in <- frames[0]
+	i := 1
+	for f := range effects.Audio(red, in, m) {
+		r, g, b, _ := f[0].RGBA()
+		fmt.Printf("%02d beat=%-5v #%02x%02x%02x\n", i-1, frames[i-1].Beat, r>>8, g>>8, b>>8)
+		if i == len(frames) {
+			close(in)
+			continue
+		}
+		in <- frames[i]
+		i++
+	}
+	// Output:
+	// 00 beat=true  #ffffff
+	// 01 beat=false #ae9999
+	// 02 beat=false #7c5c5c
+	// 03 beat=false #5f3737
+	// 04 beat=true  #ffffff
+	// 05 beat=false #aead99
+	// 06 beat=false #7c7b5c
+	// 07 beat=false #5f5e37
+	// 08 beat=true  #ffffff
+	// 09 beat=false #99ae99
+	// 10 beat=false #5c7c5c
+	// 11 beat=false #375f37
+	// 12 beat=true  #ffffff
+	// 13 beat=false #99aeae
+	// 14 beat=false #5c7c7c
+	// 15 beat=false #375f5f
+}
+
+var notes = []float64{110, 440, 1760, 3520} // Hz.
+
+// makeWAV returns a WAV file, 8 kHz mono 16-bit PCM, of the notes, each
+// lasting 200ms.
+func makeWAV() []byte {
+	const rate = 8000
+	var pcm []byte
+	for _, freq := range notes {
+		for i := range rate / 5 {
+			amp := 0.2
+			if i < rate/20 {
+				amp = 0.8
+			}
+			v := amp * math.Sin(2*math.Pi*freq*float64(i)/rate)
+			pcm = binary.LittleEndian.AppendUint16(pcm, uint16(int16(v*math.MaxInt16)))
+		}
+	}
+
+	var b []byte
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(36+len(pcm)))
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1) // PCM.
+	b = binary.LittleEndian.AppendUint16(b, 1) // Mono.
+	b = binary.LittleEndian.AppendUint32(b, rate)
+	b = binary.LittleEndian.AppendUint32(b, rate*2)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(pcm)))
+	return append(b, pcm...)
+}
+
+// decodeWAV decodes a mono 16-bit PCM WAV file, the samples going from -1
+// to 1.
+func decodeWAV(r io.Reader) (rate int, samples []float64, err error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:4]) != "RIFF" || string(header[8:]) != "WAVE" {
+		return 0, nil, errors.New("not a WAV file")
+	}
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return 0, nil, err
+		}
+		data := make([]byte, binary.LittleEndian.Uint32(chunk[4:]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return 0, nil, err
+		}
+		switch string(chunk[:4]) {
+		case "fmt ":
+			if len(data) < 16 ||
+				binary.LittleEndian.Uint16(data[0:]) != 1 ||
+				binary.LittleEndian.Uint16(data[2:]) != 1 ||
+				binary.LittleEndian.Uint16(data[14:]) != 16 {
+				return 0, nil, errors.New("not mono 16-bit PCM")
+			}
+			rate = int(binary.LittleEndian.Uint32(data[4:]))
+		case "data":
+			if rate == 0 {
+				return 0, nil, errors.New("data before format")
+			}
+			for i := 0; i+1 < len(data); i += 2 {
+				samples = append(samples, float64(int16(binary.LittleEndian.Uint16(data[i:])))/math.MaxInt16)
+			}
+			return rate, samples, nil
+		}
+	}
+}
+
+// analyze splits the samples in windows of n and returns their RMS level,
+// the energies at the notes and the beats: the windows more than twice as
+// loud as the one before.
+func analyze(samples []float64, rate, n int) []effects.AudioFrame {
+	var frames []effects.AudioFrame
+	prev := 0.0
+	for start := 0; start+n <= len(samples); start += n {
+		w := samples[start : start+n]
+		var sum float64
+		for _, v := range w {
+			sum += v * v
+		}
+		a := effects.AudioFrame{RMS: math.Sqrt(sum/float64(n)) * math.Sqrt2}
+		for _, freq := range notes {
+			a.Bands = append(a.Bands, goertzel(w, freq/float64(rate)))
+		}
+		a.Beat = a.RMS > 0.1 && a.RMS > 2*prev
+		prev = a.RMS
+		frames = append(frames, a)
+	}
+	return frames
+}
+
+// goertzel returns the amplitude of the frequency f, in cycles per sample,
+// in w.
+func goertzel(w []float64, f float64) float64 {
+	k := 2 * math.Cos(2*math.Pi*f)
+	var s1, s2 float64
+	for _, v := range w {
+		s1, s2 = v+k*s1-s2, s1
+	}
+	power := s1*s1 + s2*s2 - k*s1*s2
+	return 2 * math.Sqrt(max(power, 0)) / float64(len(w))
+}
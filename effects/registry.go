@@ -0,0 +1,332 @@
+package effects
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huecolor"
+)
+
+// ParamType is the type of the value of a parameter of an effect of the
+// registry.
+type ParamType int
+
+// The types of the parameters, with the Go types of their values.
+const (
+	NumberParam   ParamType = iota // A float64, not negative.
+	DurationParam                  // A time.Duration, positive.
+	ColorParam                     // A color.Color.
+	PaletteParam                   // A []color.Color of a color at least.
+	BoolParam                      // A bool.
+)
+
+func (t ParamType) String() string {
+	switch t {
+	case NumberParam:
+		return "number"
+	case DurationParam:
+		return "duration"
+	case ColorParam:
+		return "color"
+	case PaletteParam:
+		return "palette"
+	case BoolParam:
+		return "bool"
+	}
+	return fmt.Sprintf("ParamType(%d)", int(t))
+}
+
+// Param is a parameter of an effect of the registry.
+type Param struct {
+	Name    string
+	Type    ParamType
+	Help    string
+	Default any // A value of the Go type of Type.
+}
+
+// Parse parses s as a value of the parameter, in the form of a flag: a
+// number, a duration as 2s, a color of huestream.ParseColor, a palette of
+// colors separated by commas or true or false.
+func (p Param) Parse(s string) (any, error) {
+	switch p.Type {
+	case NumberParam:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("invalid number %q", s)
+		}
+		return f, nil
+	case DurationParam:
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid duration %q", s)
+		}
+		return d, nil
+	case ColorParam:
+		return huestream.ParseColor(s)
+	case PaletteParam:
+		var palette []color.Color
+		for _, s := range splitColors(s) {
+			c, err := huestream.ParseColor(s)
+			if err != nil {
+				return nil, err
+			}
+			palette = append(palette, c)
+		}
+		if len(palette) == 0 {
+			return nil, errors.New("empty palette")
+		}
+		return palette, nil
+	case BoolParam:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool %q", s)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unknown parameter type %v", p.Type)
+}
+
+// splitColors splits a list of colors at the commas out of parentheses, so
+// that rgb(255, 0, 0) stays whole.
+func splitColors(s string) []string {
+	var list []string
+	depth, start := 0, 0
+	for i, r := range s + "," {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			if c := strings.TrimSpace(s[start:min(i, len(s))]); c != "" {
+				list = append(list, c)
+			}
+			start = i + 1
+		}
+	}
+	return list
+}
+
+// Decode decodes data, the JSON value of the parameter: a number, a
+// duration as "2s" or in seconds, a color of huestream.ParseColor, an array
+// of colors for a palette, or true or false.
+func (p Param) Decode(data []byte) (any, error) {
+	var s string
+	isString := json.Unmarshal(data, &s) == nil
+	switch p.Type {
+	case NumberParam:
+		var f float64
+		if json.Unmarshal(data, &f) == nil && f >= 0 {
+			return f, nil
+		}
+		return nil, fmt.Errorf("want a number not negative, got %s", data)
+	case DurationParam:
+		var secs float64
+		if json.Unmarshal(data, &secs) == nil && secs > 0 {
+			return time.Duration(secs * float64(time.Second)), nil
+		}
+		if isString {
+			return p.Parse(s)
+		}
+		return nil, fmt.Errorf(`want a duration as "2s", got %s`, data)
+	case ColorParam:
+		if isString {
+			return p.Parse(s)
+		}
+		return nil, fmt.Errorf(`want a color as "#ff8800", got %s`, data)
+	case PaletteParam:
+		var list []string
+		if json.Unmarshal(data, &list) != nil || len(list) == 0 {
+			return nil, fmt.Errorf(`want an array of colors as ["#ff8800", "purple"], got %s`, data)
+		}
+		palette := make([]color.Color, len(list))
+		for i, s := range list {
+			c, err := huestream.ParseColor(s)
+			if err != nil {
+				return nil, err
+			}
+			palette[i] = c
+		}
+		return palette, nil
+	case BoolParam:
+		var b bool
+		if json.Unmarshal(data, &b) == nil {
+			return b, nil
+		}
+		return nil, fmt.Errorf("want true or false, got %s", data)
+	}
+	return nil, fmt.Errorf("unknown parameter type %v", p.Type)
+}
+
+// Format formats v, a value of the parameter, as Parse reads it.
+func (p Param) Format(v any) string {
+	switch v := v.(type) {
+	case color.Color:
+		return formatColor(v)
+	case []color.Color:
+		list := make([]string, len(v))
+		for i, c := range v {
+			list[i] = formatColor(c)
+		}
+		return strings.Join(list, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+func formatColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+// Args are the values of the parameters of an effect, by name, of the Go
+// types of their Param. The parameters missing take their default.
+type Args map[string]any
+
+// Effect is an effect of the registry, playable by name with parameters
+// given as flags or JSON.
+type Effect struct {
+	Name, Help string
+	Params     []Param
+
+	frames func(ids []int, rate float64, a Args, opts []Option) iter.Seq[huestream.Frame]
+}
+
+// Param returns the parameter of e named name.
+func (e Effect) Param(name string) (Param, bool) {
+	for _, p := range e.Params {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+// Frames returns the frames of e for the channels ids, played at rate Hz:
+// the durations of args are converted to ticks at rate. The random effects
+// take opts. Frames panics if a value of args is not of the Go type of its
+// parameter.
+func (e Effect) Frames(ids []int, rate float64, args Args, opts ...Option) iter.Seq[huestream.Frame] {
+	a := make(Args, len(e.Params))
+	for _, p := range e.Params {
+		a[p.Name] = p.Default
+	}
+	for name, v := range args {
+		a[name] = v
+	}
+	return e.frames(ids, rate, a, opts)
+}
+
+// ticks returns the duration of the parameter name in ticks at rate.
+func (a Args) ticks(name string, rate float64) int {
+	return max(int(a[name].(time.Duration).Seconds()*rate), 1)
+}
+
+func (a Args) number(name string) float64 { return a[name].(float64) }
+
+// registry are the effects playable by name, in the order listed.
+var registry = []Effect{
+	{
+		Name: "rainbow", Help: "cycle the channels through the hues",
+		Params: []Param{
+			{"period", DurationParam, "time of a turn of the hues", 10 * time.Second},
+			{"spread", NumberParam, "hue shift from a channel to the next, in turns", 0.1},
+		},
+		frames: func(ids []int, rate float64, a Args, opts []Option) iter.Seq[huestream.Frame] {
+			return Rainbow(ids, a.ticks("period", rate), a.number("spread"))
+		},
+	},
+	{
+		Name: "sparkle", Help: "flash the channels white at random over a base color",
+		Params: []Param{
+			{"color", ColorParam, "base color", color.Color(color.RGBA{A: 0xff})},
+			{"density", NumberParam, "probability that a channel flashes on a tick", 0.05},
+		},
+		frames: func(ids []int, rate float64, a Args, opts []Option) iter.Seq[huestream.Frame] {
+			return Sparkle(ids, a["color"].(color.Color), a.number("density"), opts...)
+		},
+	},
+	{
+		Name: "candle", Help: "flicker the channels like candle flames",
+		frames: func(ids []int, rate float64, a Args, opts []Option) iter.Seq[huestream.Frame] {
+			return Candle(ids, opts...)
+		},
+	},
+	{
+		Name: "lightning", Help: "strike lightning at random in the dark",
+		Params: []Param{
+			{"chance", NumberParam, "probability that a strike starts on a tick", 0.02},
+		},
+		frames: func(ids []int, rate float64, a Args, opts []Option) iter.Seq[huestream.Frame] {
+			return Lightning(ids, a.number("chance"), opts...)
+		},
+	},
+	{
+		Name: "palette", Help: "step the channels through the colors of a palette",
+		Params: []Param{
+			{"palette", PaletteParam, "colors, separated by commas", []color.Color{
+				color.RGBA{R: 0xff, A: 0xff}, color.RGBA{G: 0xff, A: 0xff}, color.RGBA{B: 0xff, A: 0xff},
+			}},
+			{"step", DurationParam, "time of a color", time.Second},
+			{"spread", NumberParam, "shift from a channel to the next, in turns of the palette", 0.0},
+			{"blend", BoolParam, "fade from color to color", false},
+		},
+		frames: func(ids []int, rate float64, a Args, opts []Option) iter.Seq[huestream.Frame] {
+			opts = append(opts, WithIndexPhase(a.number("spread")))
+			if a["blend"].(bool) {
+				opts = append(opts, WithBlend())
+			}
+			return PaletteCycle(ids, a["palette"].([]color.Color), a.ticks("step", rate), opts...)
+		},
+	},
+	{
+		Name: "sunrise", Help: "ramp from off through deep red and amber to cool white, then hold",
+		Params: []Param{
+			{"length", DurationParam, "time of the ramp", 30 * time.Minute},
+			{"start", NumberParam, "color temperature of the start, in kelvin", float64(huecolor.MinKelvin)},
+			{"end", NumberParam, "color temperature of the end, in kelvin", 6500.0},
+			{"brightness", NumberParam, "brightness of the end, from 0 to 1", 1.0},
+			{"floor", NumberParam, "lowest brightness the lamps show, where the ramp starts", 0.01},
+		},
+		frames: func(ids []int, rate float64, a Args, opts []Option) iter.Seq[huestream.Frame] {
+			return Sunrise(ids, a.ticks("length", rate), SunriseParams{
+				StartKelvin: a.number("start"),
+				EndKelvin:   a.number("end"),
+				Brightness:  a.number("brightness"),
+				Floor:       a.number("floor"),
+			})
+		},
+	},
+}
+
+// Registry returns the effects playable by name: rainbow, sparkle, candle,
+// lightning, palette and sunrise, with their parameters. The huestream
+// command, the preset packs and the HTTP API play them.
+func Registry() []Effect {
+	return append([]Effect(nil), registry...)
+}
+
+// Lookup returns the effect of the registry named name.
+func Lookup(name string) (Effect, bool) {
+	for _, e := range registry {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Effect{}, false
+}
+
+// Names returns the names of the effects of the registry.
+func Names() []string {
+	names := make([]string, len(registry))
+	for i, e := range registry {
+		names[i] = e.Name
+	}
+	return names
+}
@@ -0,0 +1,106 @@
+package effects
+
+import (
+	"image/color"
+	"iter"
+	"math"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huecolor"
+)
+
+// SunriseParams sets the ramp of Sunrise. The zero SunriseParams is a
+// sunrise from deep red to cool white at full brightness.
+type SunriseParams struct {
+	// The color temperatures of the start and the end, in kelvin,
+	// huecolor.MinKelvin (deep red) and 6500 (cool white) by default. The
+	// ramp goes through the temperatures in equal steps of mirek, the steps
+	// the eye sees alike.
+	StartKelvin, EndKelvin float64
+
+	// Brightness is the brightness of the end, from 0 to 1, 1 by default.
+	Brightness float64
+
+	// Floor is the lowest brightness the lamps show, from 0 to 1, 0.01 by
+	// default. The ramp starts there rather than at 0, so the lamps glow
+	// from its first minutes instead of staying dark under their floor for
+	// a long part of it. As huestream.WithMinBrightness does the same
+	// for every frame, set Floor or WithMinBrightness, not both.
+	Floor float64
+
+	// Easing shapes the progress of the ramp, huestream.Linear if nil. It
+	// applies to the temperature and to the lightness, the brightness as the
+	// eye sees it, the cube root of the light emitted.
+	Easing huestream.Easing
+}
+
+func (p SunriseParams) withDefaults() SunriseParams {
+	if p.StartKelvin == 0 {
+		p.StartKelvin = huecolor.MinKelvin
+	}
+	if p.EndKelvin == 0 {
+		p.EndKelvin = 6500
+	}
+	if p.Brightness == 0 {
+		p.Brightness = 1
+	}
+	if p.Floor == 0 {
+		p.Floor = 0.01
+	}
+	if p.Easing == nil {
+		p.Easing = huestream.Linear
+	}
+	return p
+}
+
+// Sunrise simulates a sunrise over ticks ticks, as a wake-up light: from
+// off, the channels glow deep red at the floor of the lamps, warm through
+// amber and brighten to cool white, as set by p, then hold the end color.
+// At 25 Hz a sunrise of 30 minutes is 45000 ticks.
+//
+// The effect never ends: played with Stream.PlaySeq it keeps sending the
+// end color, so the bridge does not close the stream for being idle
+// however slow the ramp.
+func Sunrise(ids []int, ticks int, p SunriseParams) iter.Seq[huestream.Frame] {
+	p = p.withDefaults()
+	ticks = max(ticks, 1)
+	startMirek, endMirek := 1e6/p.StartKelvin, 1e6/p.EndKelvin
+	startL, endL := math.Cbrt(p.Floor), math.Cbrt(p.Brightness)
+
+	return func(yield func(huestream.Frame) bool) {
+		var c color.Color = color.RGBA64{A: 0xffff} // Off at the first tick.
+		for tick := 0; ; tick++ {
+			if tick > 0 && tick <= ticks {
+				t := p.Easing(float64(tick-1) / float64(max(ticks-1, 1)))
+				mirek := startMirek + (endMirek-startMirek)*t
+				l := startL + (endL-startL)*t
+				c = warmth(1e6/mirek, l*l*l)
+			}
+			f := make(huestream.Frame, len(ids))
+			for _, id := range ids {
+				f[id] = c
+			}
+			if !yield(f) {
+				return
+			}
+		}
+	}
+}
+
+// warmth returns the color of the temperature k, in kelvin, whose largest
+// linear intensity is level, from 0 to 1: the level of the lamps, which
+// huestream.WithMinBrightness compares to their floor.
+func warmth(k, level float64) color.RGBA64 {
+	full := huecolor.XYToRGB(huecolor.CCTToXY(k), 1)
+	lin := [3]float64{
+		huecolor.ToLinear(float64(full.R) / 0xffff),
+		huecolor.ToLinear(float64(full.G) / 0xffff),
+		huecolor.ToLinear(float64(full.B) / 0xffff),
+	}
+	m := max(lin[0], lin[1], lin[2])
+	var v [3]uint16
+	for i, x := range lin {
+		v[i] = uint16(math.Round(huecolor.FromLinear(x/m*level) * 0xffff))
+	}
+	return color.RGBA64{R: v[0], G: v[1], B: v[2], A: 0xffff}
+}
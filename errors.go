@@ -0,0 +1,154 @@
+package huestream
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rschio/huestream/wire"
+)
+
+// The errors of the package. The errors returned by Start, the methods of a
+// Stream and the ones reported to the error handler wrap them, they can be
+// tested with errors.Is. The failures of the bridge API are also a
+// *StatusError or a *BridgeError, the timeouts a *TimeoutError and the
+// failures of a session recovery a *RecoveryError, see errors.As.
+var (
+	// ErrUnauthorized is returned by Start when the bridge rejects the
+	// username.
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrAreaNotFound is returned by Start when the bridge has no
+	// entertainment area with the given ID.
+	ErrAreaNotFound = errors.New("entertainment area not found")
+
+	// ErrStreamActive is returned by Start when the bridge refuses to start
+	// the stream because another application is streaming to the area.
+	ErrStreamActive = errors.New("stream already active")
+
+	// ErrInvalidClientKey is returned by Start when the clientKey or the key
+	// set by WithClientKey is not a 16 bytes key.
+	ErrInvalidClientKey = errors.New("invalid client key")
+
+	// ErrTooManyChannels is returned by Send when a frame has more channels
+	// than a message holds.
+	ErrTooManyChannels = wire.ErrTooManyChannels
+)
+
+// ErrNoChannels is returned by Start for an area without channels, whose
+// stream would light nothing.
+var ErrNoChannels = errors.New("entertainment area has no channels")
+
+// ErrClosed is returned by the methods of a Stream called after Close.
+var ErrClosed = errors.New("stream closed")
+
+// ErrConnLost is the cause of the end of a Stream whose connection was
+// closed by the bridge or the network without WithRecovery, see Stream.Err.
+var ErrConnLost = errors.New("connection lost")
+
+// ErrStopFailed is returned by Close when the bridge did not take the stop
+// action after its retries. The area stays streamed until the bridge ends
+// the idle session, ~10s later, starting a stream to it fails meanwhile.
+var ErrStopFailed = errors.New("stream not stopped on the bridge")
+
+// ErrPaused is returned by Send while the Stream is paused.
+var ErrPaused = errors.New("stream paused")
+
+// ErrStreamIdle is reported to the error handler when no frame was sent for
+// the idle threshold, see WithIdleThreshold. The bridge ends sessions that
+// stay idle for ~10s.
+var ErrStreamIdle = errors.New("stream idle")
+
+// ErrOverrun is reported to the error handler when a send loop of the Stream
+// (PlaySeq or keepalive) wakes up too late and skips slots of its schedule.
+var ErrOverrun = errors.New("send loop overrun")
+
+// TimeoutError is returned when an operation does not complete before the
+// deadline of its context.
+type TimeoutError struct {
+	Op  string // The operation that timed out, e.g. "send".
+	Err error  // The underlying error, usually context.DeadlineExceeded.
+}
+
+func (e *TimeoutError) Error() string { return e.Op + ": timeout: " + e.Err.Error() }
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// Timeout reports true, so TimeoutError satisfies the net.Error convention.
+func (e *TimeoutError) Timeout() bool { return true }
+
+// StatusError is the failure of a CLIP request answered with an HTTP status
+// code other than 200. It wraps ErrUnauthorized for 401 and 403,
+// ErrAreaNotFound for 404 and ErrStreamActive for 409.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string { return fmt.Sprintf("status code not OK, got %d", e.Code) }
+
+func (e *StatusError) Unwrap() error {
+	switch e.Code {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrAreaNotFound
+	case http.StatusConflict:
+		return ErrStreamActive
+	}
+	return nil
+}
+
+// BridgeError is an error reported in the body of a version 1 API response,
+// see WithProtocolVersion, or to Register. It wraps ErrUnauthorized for the
+// type 1, ErrAreaNotFound for the type 3, ErrLinkButton for the type 101 and
+// ErrStreamActive for the type 307.
+type BridgeError struct {
+	Type        int
+	Description string
+}
+
+func (e *BridgeError) Error() string {
+	return fmt.Sprintf("bridge error %d: %s", e.Type, e.Description)
+}
+
+func (e *BridgeError) Unwrap() error {
+	switch e.Type {
+	case 1:
+		return ErrUnauthorized
+	case 3:
+		return ErrAreaNotFound
+	case 101:
+		return ErrLinkButton
+	case 307:
+		return ErrStreamActive
+	}
+	return nil
+}
+
+// LightError is the failure of a request on one light of a request on the
+// lights of an area, as SetAreaPowerOnBehavior.
+type LightError struct {
+	Light string // ID of the light.
+	Err   error
+}
+
+func (e *LightError) Error() string { return "light " + e.Light + ": " + e.Err.Error() }
+
+func (e *LightError) Unwrap() error { return e.Err }
+
+// AreaError is the failure of the preflight of Start, the area can't be
+// streamed to. It wraps ErrAreaNotFound or ErrNoChannels.
+type AreaError struct {
+	AreaID string
+	Name   string // The name of the area, empty if not found.
+	Err    error
+}
+
+func (e *AreaError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("area %s (%s): %v", e.AreaID, e.Name, e.Err)
+	}
+	return fmt.Sprintf("area %s: %v", e.AreaID, e.Err)
+}
+
+func (e *AreaError) Unwrap() error { return e.Err }
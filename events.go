@@ -0,0 +1,298 @@
+package huestream
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The actions of a ButtonEvent. A press is reported as ButtonInitialPress
+// then ButtonShortRelease, or ButtonLongPress, ButtonRepeat every 800ms
+// while held, and ButtonLongRelease.
+const (
+	ButtonInitialPress = "initial_press"
+	ButtonRepeat       = "repeat"
+	ButtonShortRelease = "short_release"
+	ButtonLongPress    = "long_press"
+	ButtonLongRelease  = "long_release"
+)
+
+// Event is an event of the bridge decoded by Events, a ButtonEvent or a
+// RotaryEvent.
+type Event interface {
+	event()
+}
+
+// ButtonEvent is an action on a button of a switch, as a Hue dimmer switch.
+type ButtonEvent struct {
+	ID     string    // ID of the button resource.
+	Button int       // Number of the button on its switch, from 1.
+	Action string    // As ButtonShortRelease.
+	Time   time.Time // When the bridge registered it, zero if unknown.
+}
+
+// RotaryEvent is a turn of a rotary dial, as the one of a Hue tap dial
+// switch.
+type RotaryEvent struct {
+	ID       string        // ID of the relative_rotary resource.
+	Action   string        // "start" for a new turn, "repeat" while turning.
+	Steps    int           // Positive clockwise, negative counterclockwise.
+	Duration time.Duration // Of the turn reported.
+	Time     time.Time     // When the bridge registered it, zero if unknown.
+}
+
+func (ButtonEvent) event() {}
+func (RotaryEvent) event() {}
+
+// debounceWindow is the time during which a repeated report of an event
+// without its time is ignored.
+const debounceWindow = 100 * time.Millisecond
+
+// Events subscribes to the event stream of the bridge at host, using the
+// CLIP v2 API, and calls h with the button and rotary events until ctx is
+// done or the connection fails. h is called on the goroutine of Events, one
+// event at a time.
+//
+// The reports of the firmware with and without the time of the events are
+// both decoded, and an event reported twice is passed once.
+//
+// Events returns ctx.Err() when ctx is done, otherwise the error ending the
+// subscription, the caller may subscribe again.
+func Events(ctx context.Context, host, username string, h func(Event), opts ...Option) error {
+	c := newClient(host, username, "")
+	c.cfg = newConfig(opts)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.apiURL()+"/eventstream/clip/v2", nil)
+	if err != nil {
+		return err
+	}
+	c.setAuthHeader(req)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("events: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("events: %w", &StatusError{Code: resp.StatusCode})
+	}
+
+	d := newEventDecoder(c.cfg.clock.Now)
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(nil, 1<<20)
+	var data []byte // The data lines of the message being read.
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			for _, e := range d.decode(data) {
+				h(e)
+			}
+			data = data[:0]
+			continue
+		}
+		if v, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, bytes.TrimPrefix(v, []byte(" "))...)
+		}
+		// The id, event and comment lines are not used.
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("events: %w", err)
+	}
+	return errors.New("events: stream closed by the bridge")
+}
+
+// eventDecoder decodes the messages of the event stream, dropping the
+// events already reported.
+type eventDecoder struct {
+	now  func() time.Time
+	seen map[string]eventReport // The last report, by resource ID.
+}
+
+// eventReport identifies a report of an event.
+type eventReport struct {
+	event   string
+	updated time.Time // Zero if the firmware does not report it.
+	at      time.Time // When it was decoded.
+}
+
+func newEventDecoder(now func() time.Time) *eventDecoder {
+	return &eventDecoder{now: now, seen: make(map[string]eventReport)}
+}
+
+// rotation is the turn of a relative_rotary report.
+type rotation struct {
+	Direction string `json:"direction"` // "clock_wise" or "counter_clock_wise".
+	Steps     int    `json:"steps"`
+	Duration  int    `json:"duration"` // In milliseconds.
+}
+
+// decode returns the events of the data of a message, a list of updates
+// holding the changed resources.
+func (d *eventDecoder) decode(data []byte) []Event {
+	var msgs []struct {
+		Type string `json:"type"`
+		Data []struct {
+			ID       string `json:"id"`
+			Type     string `json:"type"`
+			Metadata struct {
+				ControlID int `json:"control_id"`
+			} `json:"metadata"`
+			Button *struct {
+				LastEvent string `json:"last_event"`
+				Report    *struct {
+					Updated time.Time `json:"updated"`
+					Event   string    `json:"event"`
+				} `json:"button_report"`
+			} `json:"button"`
+			Rotary *struct {
+				LastEvent *struct {
+					Action   string   `json:"action"`
+					Rotation rotation `json:"rotation"`
+				} `json:"last_event"`
+				Report *struct {
+					Updated  time.Time `json:"updated"`
+					Action   string    `json:"action"`
+					Rotation rotation  `json:"rotation"`
+				} `json:"rotary_report"`
+			} `json:"relative_rotary"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(data, &msgs) != nil {
+		return nil // A message of another kind.
+	}
+
+	var events []Event
+	for _, m := range msgs {
+		if m.Type != "update" {
+			continue
+		}
+		for _, r := range m.Data {
+			switch {
+			case r.Type == "button" && r.Button != nil:
+				e := ButtonEvent{ID: r.ID, Button: r.Metadata.ControlID, Action: r.Button.LastEvent}
+				if rep := r.Button.Report; rep != nil {
+					e.Action, e.Time = rep.Event, rep.Updated
+				}
+				if e.Action != "" && d.first(r.ID, e.Action, e.Time) {
+					events = append(events, e)
+				}
+			case r.Type == "relative_rotary" && r.Rotary != nil:
+				e := RotaryEvent{ID: r.ID}
+				var rot rotation
+				switch {
+				case r.Rotary.Report != nil:
+					e.Action, e.Time, rot = r.Rotary.Report.Action, r.Rotary.Report.Updated, r.Rotary.Report.Rotation
+				case r.Rotary.LastEvent != nil:
+					e.Action, rot = r.Rotary.LastEvent.Action, r.Rotary.LastEvent.Rotation
+				default:
+					continue
+				}
+				e.Steps = rot.Steps
+				if rot.Direction == "counter_clock_wise" {
+					e.Steps = -e.Steps
+				}
+				e.Duration = time.Duration(rot.Duration) * time.Millisecond
+				if d.first(r.ID, fmt.Sprint(e.Action, e.Steps), e.Time) {
+					events = append(events, e)
+				}
+			}
+		}
+	}
+	return events
+}
+
+// first reports whether the event of the resource id is not a repeated
+// report of the last one: the same time if the firmware reports it, else
+// the same event within debounceWindow.
+func (d *eventDecoder) first(id, event string, updated time.Time) bool {
+	now := d.now()
+	last, ok := d.seen[id]
+	d.seen[id] = eventReport{event: event, updated: updated, at: now}
+	if !ok || last.event != event {
+		return true
+	}
+	if !updated.IsZero() {
+		return !updated.Equal(last.updated)
+	}
+	return now.Sub(last.at) >= debounceWindow
+}
+
+// Router calls the handlers registered for the events it handles. Pass
+// its Handle method to Events:
+//
+//	var r huestream.Router
+//	r.OnButton(1, huestream.ButtonShortRelease, func(huestream.ButtonEvent) { stream.Close() })
+//	r.OnRotary(func(e huestream.RotaryEvent) { brightness += float64(e.Steps) / 1000 })
+//	err := huestream.Events(ctx, host, username, r.Handle)
+//
+// The zero Router is ready to use. Its methods are safe for concurrent use.
+type Router struct {
+	mu      sync.Mutex
+	buttons map[buttonRoute]func(ButtonEvent)
+	rotary  func(RotaryEvent)
+}
+
+type buttonRoute struct {
+	button int
+	action string
+}
+
+// OnButton registers h for the action on the button number button of the
+// switches, replacing the handler registered before. Zero is any button,
+// called when the button has no handler of its own.
+func (r *Router) OnButton(button int, action string, h func(ButtonEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.buttons == nil {
+		r.buttons = make(map[buttonRoute]func(ButtonEvent))
+	}
+	r.buttons[buttonRoute{button, action}] = h
+}
+
+// OnRotary registers h for the turns of the rotary dials, replacing the
+// handler registered before.
+func (r *Router) OnRotary(h func(RotaryEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rotary = h
+}
+
+// Handle calls the handler registered for e, if any.
+func (r *Router) Handle(e Event) {
+	r.mu.Lock()
+	var h func()
+	switch e := e.(type) {
+	case ButtonEvent:
+		f, ok := r.buttons[buttonRoute{e.Button, e.Action}]
+		if !ok {
+			f, ok = r.buttons[buttonRoute{0, e.Action}]
+		}
+		if ok {
+			h = func() { f(e) }
+		}
+	case RotaryEvent:
+		if f := r.rotary; f != nil {
+			h = func() { f(e) }
+		}
+	}
+	r.mu.Unlock()
+
+	if h != nil {
+		h() // Without mu, the handler may register others.
+	}
+}
@@ -0,0 +1,124 @@
+package huestream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// buttonUpdate returns the data of an update of the button id, in the
+// format of the firmware reporting the time of the events if updated is
+// set.
+func buttonUpdate(id string, control int, event, updated string) string {
+	button := fmt.Sprintf(`{"last_event":%q}`, event)
+	if updated != "" {
+		button = fmt.Sprintf(`{"last_event":%q,"button_report":{"updated":%q,"event":%q}}`, event, updated, event)
+	}
+	return fmt.Sprintf(`[{"creationtime":"2026-01-02T03:04:05Z","id":"e1","type":"update","data":[`+
+		`{"id":%q,"type":"button","metadata":{"control_id":%d},"button":%s}]}]`, id, control, button)
+}
+
+func TestEventDecoder(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	d := newEventDecoder(func() time.Time { return now })
+	updated := now.Add(-time.Second)
+
+	for _, tt := range []struct {
+		name    string
+		data    string
+		advance time.Duration
+		want    []Event
+	}{
+		{
+			name: "button report",
+			data: buttonUpdate("b1", 1, "initial_press", updated.Format(time.RFC3339Nano)),
+			want: []Event{ButtonEvent{ID: "b1", Button: 1, Action: ButtonInitialPress, Time: updated}},
+		},
+		{
+			name: "same report again",
+			data: buttonUpdate("b1", 1, "initial_press", updated.Format(time.RFC3339Nano)),
+		},
+		{
+			name: "new press reported",
+			data: buttonUpdate("b1", 1, "initial_press", updated.Add(time.Second).Format(time.RFC3339Nano)),
+			want: []Event{ButtonEvent{ID: "b1", Button: 1, Action: ButtonInitialPress, Time: updated.Add(time.Second)}},
+		},
+		{
+			name: "old firmware",
+			data: buttonUpdate("b2", 4, "long_press", ""),
+			want: []Event{ButtonEvent{ID: "b2", Button: 4, Action: ButtonLongPress}},
+		},
+		{
+			name:    "old firmware repeated",
+			data:    buttonUpdate("b2", 4, "long_press", ""),
+			advance: debounceWindow / 2,
+		},
+		{
+			name:    "old firmware held",
+			data:    buttonUpdate("b2", 4, "long_press", ""),
+			advance: 800 * time.Millisecond,
+			want:    []Event{ButtonEvent{ID: "b2", Button: 4, Action: ButtonLongPress}},
+		},
+		{
+			name: "rotary report",
+			data: `[{"type":"update","data":[{"id":"r1","type":"relative_rotary","relative_rotary":{` +
+				`"last_event":{"action":"start","rotation":{"direction":"counter_clock_wise","steps":30,"duration":400}},` +
+				`"rotary_report":{"updated":"2026-01-02T03:04:04Z","action":"start","rotation":{"direction":"counter_clock_wise","steps":30,"duration":400}}}}]}]`,
+			want: []Event{RotaryEvent{ID: "r1", Action: "start", Steps: -30, Duration: 400 * time.Millisecond, Time: updated}},
+		},
+		{
+			name: "rotary old firmware",
+			data: `[{"type":"update","data":[{"id":"r2","type":"relative_rotary","relative_rotary":{` +
+				`"last_event":{"action":"repeat","rotation":{"direction":"clock_wise","steps":75,"duration":400}}}}]}]`,
+			want: []Event{RotaryEvent{ID: "r2", Action: "repeat", Steps: 75, Duration: 400 * time.Millisecond}},
+		},
+		{
+			name: "other resources",
+			data: `[{"type":"update","data":[{"id":"l1","type":"light","on":{"on":true}}]},{"type":"delete","data":[]}]`,
+		},
+		{name: "not JSON", data: "hi"},
+	} {
+		now = now.Add(tt.advance)
+		if got := d.decode([]byte(tt.data)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/eventstream/clip/v2" || r.Header.Get("hue-application-key") != "user" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": hi\n\n")
+		fmt.Fprintf(w, "id: 1:0\ndata: %s\n\n", buttonUpdate("b1", 1, "short_release", "2026-01-02T03:04:05Z"))
+		fmt.Fprintf(w, "id: 2:0\ndata: %s\n\n", buttonUpdate("b1", 2, "short_release", "2026-01-02T03:04:06Z"))
+	}))
+	defer srv.Close()
+
+	var r Router
+	var got []string
+	r.OnButton(1, ButtonShortRelease, func(e ButtonEvent) { got = append(got, "stop") })
+	r.OnButton(0, ButtonShortRelease, func(e ButtonEvent) { got = append(got, fmt.Sprint("any ", e.Button)) })
+	r.OnRotary(func(RotaryEvent) { got = append(got, "rotary") })
+
+	err := Events(context.Background(), "127.0.0.1", "user", r.Handle, WithBaseURL(srv.URL))
+	if err == nil {
+		t.Fatal("no error at the end of the stream")
+	}
+	if want := []string{"stop", "any 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("handled %q, want %q", got, want)
+	}
+
+	err = Events(context.Background(), "127.0.0.1", "intruder", r.Handle, WithBaseURL(srv.URL))
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("got %v, want ErrUnauthorized", err)
+	}
+}
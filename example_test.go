@@ -25,14 +25,10 @@ func Example() {
 	// Philips Hue App:
 	// Settings > Entertainment areas > +.
 	//
-	// Use this command to get the ID of your first entertainment area,
-	// if you have more than one and want to choose, adapt the command:
+	// List the entertainment areas with huestream.Areas, or with the
+	// huestream command:
 	//
-	// curl -s -k \
-	//    -H 'hue-application-key: <username>' \
-	//    https://<host>/clip/v2/resource/entertainment_configuration | jq '.data.[0].id'
-	//
-	// Yes, I'm too lazy to write this function.
+	// go run github.com/rschio/huestream/cmd/huestream areas
 	areaID := ""
 
 	// Create a context with timeout so the stream will finish in 5s.
@@ -65,14 +61,16 @@ func Example() {
 	// 10 Hz = 1 message each 100 ms.
 	changeColorRate := time.Tick(time.Second / 10)
 
-	c0, c1 := randColor(), randColor()
+	// A fixed seed plays the same colors on every run.
+	rnd := rand.New(rand.NewPCG(1, 2))
+	c0, c1 := randColor(rnd), randColor(rnd)
 	for {
 		select {
 		case <-ctx.Done():
 			return
 
 		case <-changeColorRate:
-			c0, c1 = randColor(), randColor()
+			c0, c1 = randColor(rnd), randColor(rnd)
 
 		case <-sendRate:
 			// Here we are sending two colors because my Entertainment Area has 2 lights.
@@ -106,7 +104,7 @@ func genClientCreds() (host, username, clientKey string, err error) {
 	return host, user.Username, user.ClientKey, nil
 }
 
-func randColor() color.Color {
-	rnd := func() uint8 { return uint8(rand.IntN(256)) }
+func randColor(r *rand.Rand) color.Color {
+	rnd := func() uint8 { return uint8(r.IntN(256)) }
 	return color.RGBA{R: rnd(), G: rnd(), B: rnd()}
 }
@@ -0,0 +1,115 @@
+package huestream
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// expvars holds the variables published by PublishExpvar by name. expvar
+// can't remove a variable, so a name is published once and its slot points
+// to the Stream publishing it, nil after Close.
+var expvars = struct {
+	mu    sync.Mutex
+	slots map[string]*expvarSlot
+}{slots: make(map[string]*expvarSlot)}
+
+type expvarSlot struct {
+	stream atomic.Pointer[Stream]
+
+	mu     sync.Mutex // Guards the fields below, only used by readers.
+	frames uint64     // FramesSent at the previous read.
+	at     time.Time  // Time of the previous read.
+}
+
+// PublishExpvar publishes the Stats of the Stream as the expvar variable
+// name, served by expvar's /debug/vars handler. The variable is a JSON
+// object of the counters and frames_per_second, the rate of frames since
+// the previous read.
+//
+// The counters are read when the variable is, the send path is not slowed
+// down. Close resets the variable to zeros, so a Stream started later can
+// publish the same name. It fails if name is used by another variable or
+// another open Stream.
+func (s *Stream) PublishExpvar(name string) error {
+	expvars.mu.Lock()
+	defer expvars.mu.Unlock()
+
+	s.connMu.Lock()
+	closed := s.closed
+	s.connMu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	slot, ok := expvars.slots[name]
+	if !ok {
+		if expvar.Get(name) != nil {
+			return fmt.Errorf("expvar %q is already published", name)
+		}
+		slot = &expvarSlot{}
+		expvar.Publish(name, expvar.Func(slot.value))
+		expvars.slots[name] = slot
+	}
+	if cur := slot.stream.Load(); cur != nil && cur != s {
+		return fmt.Errorf("expvar %q is published by another Stream", name)
+	}
+
+	slot.mu.Lock()
+	slot.frames, slot.at = s.framesSent.Load(), s.clk.Now()
+	slot.mu.Unlock()
+	slot.stream.Store(s)
+	s.expvars = append(s.expvars, slot)
+	return nil
+}
+
+// unpublishExpvars resets the variables published by the Stream.
+func (s *Stream) unpublishExpvars() {
+	expvars.mu.Lock()
+	defer expvars.mu.Unlock()
+
+	for _, slot := range s.expvars {
+		slot.stream.CompareAndSwap(s, nil)
+	}
+	s.expvars = nil
+}
+
+// value is the expvar.Func of the slot.
+func (slot *expvarSlot) value() any {
+	s := slot.stream.Load()
+	if s == nil {
+		return expvarStats{}
+	}
+	st := s.Stats()
+
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	now := s.clk.Now()
+	var fps float64
+	if d := now.Sub(slot.at); d > 0 {
+		fps = float64(st.FramesSent-slot.frames) / d.Seconds()
+	}
+	slot.frames, slot.at = st.FramesSent, now
+
+	return expvarStats{
+		FramesSent:      st.FramesSent,
+		BytesSent:       st.BytesSent,
+		SendErrors:      st.SendErrors,
+		TransientErrors: st.TransientErrors,
+		SkippedSlots:    st.SkippedSlots,
+		FramesPerSecond: fps,
+		SendLatencyP99:  st.SendLatencyP99.Seconds(),
+	}
+}
+
+type expvarStats struct {
+	FramesSent      uint64  `json:"frames_sent"`
+	BytesSent       uint64  `json:"bytes_sent"`
+	SendErrors      uint64  `json:"send_errors"`
+	TransientErrors uint64  `json:"transient_errors"`
+	SkippedSlots    uint64  `json:"skipped_slots"`
+	FramesPerSecond float64 `json:"frames_per_second"`
+	SendLatencyP99  float64 `json:"send_latency_p99_seconds"`
+}
@@ -0,0 +1,72 @@
+package huestream
+
+import (
+	"encoding/json"
+	"expvar"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+func readExpvar(t *testing.T, name string) expvarStats {
+	t.Helper()
+
+	v := expvar.Get(name)
+	if v == nil {
+		t.Fatalf("expvar %q not published", name)
+	}
+	var st expvarStats
+	if err := json.Unmarshal([]byte(v.String()), &st); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestPublishExpvar(t *testing.T) {
+	const name = "huestream_test_stream"
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, frames := pipeStream(t, withClock(clk))
+
+	if err := s.PublishExpvar(name); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if err := s.Send(Frame{0: color.White}); err != nil {
+			t.Fatal(err)
+		}
+		<-frames
+	}
+	clk.Advance(time.Second)
+
+	st := readExpvar(t, name)
+	if st.FramesSent != 3 || st.FramesPerSecond != 3 || st.BytesSent == 0 {
+		t.Errorf("got %+v, want 3 frames at 3 fps", st)
+	}
+
+	other, _ := pipeStream(t)
+	if err := other.PublishExpvar(name); err == nil {
+		t.Error("a second open Stream should not publish the same name")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if st := readExpvar(t, name); st != (expvarStats{}) {
+		t.Errorf("got %+v after Close, want zeros", st)
+	}
+	if err := other.PublishExpvar(name); err != nil {
+		t.Errorf("publishing the name of a closed Stream: %v", err)
+	}
+}
+
+func TestPublishExpvarTaken(t *testing.T) {
+	if expvar.Get("huestream_test_taken") == nil {
+		expvar.NewInt("huestream_test_taken")
+	}
+	s, _ := pipeStream(t)
+	if err := s.PublishExpvar("huestream_test_taken"); err == nil {
+		t.Error("publishing the name of another variable should fail")
+	}
+}
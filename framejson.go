@@ -0,0 +1,109 @@
+package huestream
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/rschio/huestream/huecolor"
+)
+
+// MarshalJSON encodes f as an object of the hex colors by channel ID, in
+// increasing ID order:
+//
+//	{"0":"#ff8800","3":"#0000ff"}
+//
+// The components are rounded to 8 bits. It is a frame document of the
+// jsonframe package.
+func (f Frame) MarshalJSON() ([]byte, error) {
+	b := []byte{'{'}
+	for i, id := range slices.Sorted(maps.Keys(f)) {
+		c := f[id]
+		if c == nil {
+			return nil, fmt.Errorf("frame: channel %d: no color", id)
+		}
+		if i > 0 {
+			b = append(b, ',')
+		}
+		r, g, bl, _ := c.RGBA()
+		u8 := func(v uint32) uint32 { return (v*0xff + 0x7fff) / 0xffff }
+		b = fmt.Appendf(b, `"%d":"#%02x%02x%02x"`, id, u8(r), u8(g), u8(bl))
+	}
+	return append(b, '}'), nil
+}
+
+// frameXY is the xy form of a color of a Frame in JSON.
+type frameXY struct {
+	X          *float64 `json:"x"`
+	Y          *float64 `json:"y"`
+	Brightness *float64 `json:"brightness"` // From 0 to 1, 1 if unset.
+}
+
+// UnmarshalJSON decodes an object of colors by channel ID into f, replacing
+// its channels. A color is a string in a form of ParseColor, or the
+// chromaticity and brightness, from 0 to 1, of the CIE xy form:
+//
+//	{"0":"#ff8800","1":"rgb(0, 0, 255)","2":"orange","3":{"x":0.7,"y":0.3,"brightness":0.5}}
+//
+// The errors name the key at fault.
+func (f *Frame) UnmarshalJSON(data []byte) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("frame: %w", err)
+	}
+	if doc == nil {
+		return fmt.Errorf("frame: got null, want an object")
+	}
+	frame := make(Frame, len(doc))
+	for key, v := range doc {
+		id, err := strconv.ParseUint(key, 10, 8)
+		if err != nil {
+			return fmt.Errorf("frame: key %q: not a channel ID, want an integer from 0 to 255", key)
+		}
+		c, err := decodeFrameColor(v)
+		if err != nil {
+			return fmt.Errorf("frame: channel %q: %w", key, err)
+		}
+		frame[int(id)] = c
+	}
+	*f = frame
+	return nil
+}
+
+func decodeFrameColor(data []byte) (color.Color, error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		return ParseColor(s)
+	case len(data) > 0 && data[0] == '{':
+		var xy frameXY
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&xy); err != nil {
+			return nil, fmt.Errorf("xy color: %w", err)
+		}
+		if xy.X == nil || xy.Y == nil {
+			return nil, fmt.Errorf("xy color %s: want x and y", data)
+		}
+		if *xy.X < 0 || *xy.X > 1 || *xy.Y <= 0 || *xy.Y > 1 {
+			return nil, fmt.Errorf("xy color %s: x and y out of 0 to 1", data)
+		}
+		brightness := 1.0
+		if xy.Brightness != nil {
+			brightness = *xy.Brightness
+		}
+		if brightness < 0 || brightness > 1 {
+			return nil, fmt.Errorf("xy color %s: brightness out of [0, 1]", data)
+		}
+		return huecolor.XYToRGB(huecolor.XY{X: *xy.X, Y: *xy.Y}, brightness), nil
+	}
+	return nil, fmt.Errorf("invalid color %s, want a string as \"#ff8800\" or an object as {\"x\":0.7,\"y\":0.3}", data)
+}
@@ -0,0 +1,111 @@
+package huestream
+
+import (
+	"encoding/json"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFrameJSONRoundTrip(t *testing.T) {
+	f := Frame{
+		10: color.RGBA{0x12, 0x34, 0x56, 0xff},
+		2:  color.RGBA{0xff, 0x88, 0x00, 0xff},
+		0:  color.Black,
+		1:  color.White,
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"0":"#000000","1":"#ffffff","2":"#ff8800","10":"#123456"}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	var got Frame
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	for id, c := range f {
+		if color.RGBAModel.Convert(got[id]) != color.RGBAModel.Convert(c) {
+			t.Errorf("channel %d = %v, want %v", id, got[id], c)
+		}
+	}
+	if len(got) != len(f) {
+		t.Errorf("got %d channels, want %d", len(got), len(f))
+	}
+
+	// In a struct too, and the 16 bits colors are rounded.
+	b, err = json.Marshal(struct{ F Frame }{Frame{0: color.RGBA64{R: 0x8070, A: 0xffff}}})
+	if err != nil || string(b) != `{"F":{"0":"#800000"}}` {
+		t.Errorf("got %s, %v", b, err)
+	}
+}
+
+func TestFrameUnmarshalJSON(t *testing.T) {
+	var f Frame
+	in := `{"0":"#ff8800","1":"rgb(0, 0, 255)","2":"red","3":{"x":0.7006,"y":0.2993},"4":{"x":0.7006,"y":0.2993,"brightness":0}}`
+	if err := json.Unmarshal([]byte(in), &f); err != nil {
+		t.Fatal(err)
+	}
+	want := Frame{
+		0: color.RGBA{0xff, 0x88, 0, 0xff},
+		1: color.RGBA{0, 0, 0xff, 0xff},
+		2: color.RGBA{0xff, 0, 0, 0xff},
+		3: color.RGBA64{R: 0xffff, A: 0xffff},
+		4: color.RGBA64{A: 0xffff},
+	}
+	for id, c := range want {
+		r, g, b, _ := f[id].RGBA()
+		wr, wg, wb, _ := c.RGBA()
+		if max(absDiff(r, wr), absDiff(g, wg), absDiff(b, wb)) > 0x100 {
+			t.Errorf("channel %d = %v, want %v", id, f[id], c)
+		}
+	}
+
+	// The channels are replaced.
+	if err := json.Unmarshal([]byte(`{"5":"blue"}`), &f); err != nil || !reflect.DeepEqual(f, Frame{5: color.RGBA{0, 0, 0xff, 0xff}}) {
+		t.Errorf("got %v, %v, want only channel 5", f, err)
+	}
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestFrameUnmarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`[]`, "frame: json: cannot unmarshal array"},
+		{`null`, "frame: got null, want an object"},
+		{`{"all":"#ff0000"}`, `frame: key "all": not a channel ID`},
+		{`{"-1":"#ff0000"}`, `frame: key "-1": not a channel ID`},
+		{`{"256":"#ff0000"}`, `frame: key "256": not a channel ID`},
+		{`{"1.5":"#ff0000"}`, `frame: key "1.5": not a channel ID`},
+		{`{"3":"#ff00"}`, `frame: channel "3": invalid color "#ff00"`},
+		{`{"3":"reddish"}`, `frame: channel "3": invalid color "reddish": unknown name`},
+		{`{"3":null}`, `frame: channel "3": invalid color null`},
+		{`{"3":[255,0,0]}`, `frame: channel "3": invalid color [255,0,0]`},
+		{`{"3":{"x":0.3}}`, `frame: channel "3": xy color {"x":0.3}: want x and y`},
+		{`{"3":{"x":0.3,"y":0}}`, `x and y out of 0 to 1`},
+		{`{"3":{"x":0.3,"y":0.3,"brightness":2}}`, `brightness out of [0, 1]`},
+		{`{"3":{"x":0.3,"y":0.3,"bri":1}}`, `frame: channel "3": xy color: json: unknown field "bri"`},
+	}
+	for _, tt := range tests {
+		var f Frame
+		err := json.Unmarshal([]byte(tt.in), &f)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Unmarshal(%s) = %v, want an error with %q", tt.in, err, tt.want)
+		}
+	}
+
+	if _, err := json.Marshal(Frame{7: nil}); err == nil || !strings.Contains(err.Error(), "channel 7: no color") {
+		t.Errorf("Marshal of a nil color = %v, want an error", err)
+	}
+}
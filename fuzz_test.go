@@ -0,0 +1,39 @@
+package huestream
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/rschio/huestream/wire"
+)
+
+// rawColor is a color.Color returning arbitrary values, even ones out of
+// the range allowed by the color.Color contract.
+type rawColor struct{ r, g, b, a uint32 }
+
+func (c rawColor) RGBA() (r, g, b, a uint32) { return c.r, c.g, c.b, c.a }
+
+func FuzzMarshalColors(f *testing.F) {
+	f.Add(uint32(0), uint32(0), uint32(0), uint32(0), 0)
+	f.Add(uint32(0xffff), uint32(0xffff), uint32(0xffff), uint32(0xffff), 19)
+	f.Add(^uint32(0), ^uint32(0), ^uint32(0), ^uint32(0), -1)
+	f.Fuzz(func(t *testing.T, r, g, b, a uint32, id int) {
+		c := rawColor{r, g, b, a}
+		m := message{areaID: testAreaID, idColors: Frame{id: c, id + 1: color.White}}
+		data, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fr, err := wire.Decode(data)
+		if err != nil {
+			t.Fatalf("MarshalBinary produced an invalid message: %v", err)
+		}
+		// The alpha is discarded and the values truncated to 16 bits.
+		want := [3]uint16{uint16(r), uint16(g), uint16(b)}
+		for _, ch := range fr.Channels {
+			if ch.ID == uint16(byte(id)) && ch.Values != want {
+				t.Fatalf("channel %d is %v, want %v", ch.ID, ch.Values, want)
+			}
+		}
+	})
+}
@@ -5,10 +5,10 @@ go 1.23.2
 require (
 	github.com/amimof/huego v1.2.1
 	github.com/pion/dtls/v3 v3.0.4
+	github.com/pion/logging v0.2.2
 )
 
 require (
-	github.com/pion/logging v0.2.2 // indirect
 	github.com/pion/transport/v3 v3.0.7 // indirect
 	golang.org/x/crypto v0.28.0 // indirect
 )
@@ -0,0 +1,229 @@
+package huestream
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pion/dtls/v3"
+)
+
+// HandshakeDiagnostics describes a DTLS handshake with the bridge, to tell
+// a network problem from a rejected key without a packet capture.
+type HandshakeDiagnostics struct {
+	Elapsed time.Duration
+
+	// DatagramsSent and DatagramsReceived count the datagrams exchanged
+	// with the stream port of the bridge.
+	DatagramsSent     int
+	DatagramsReceived int
+
+	// Retransmissions counts the handshake messages sent again because the
+	// bridge did not answer in time.
+	Retransmissions int
+
+	// CipherSuite is the suite selected by the bridge, empty if the bridge
+	// did not answer the hello.
+	CipherSuite string
+
+	// Alert is the alert sent by the bridge to abort the handshake, if any.
+	// It is "encrypted" when the alert was sent after the key exchange.
+	Alert string
+
+	// Refused is set when the host answered that nothing listens on the
+	// stream port.
+	Refused bool
+}
+
+// Cause describes the likely cause of a failed handshake.
+func (d HandshakeDiagnostics) Cause() string {
+	switch {
+	case d.Refused:
+		return "port closed: nothing listens on the stream port"
+	case d.DatagramsReceived == 0:
+		return "no response: the bridge is unreachable or the port is filtered"
+	case d.Alert != "":
+		return fmt.Sprintf("rejected by the bridge with alert %s: check the clientKey and the cipher suites", d.Alert)
+	case d.CipherSuite != "":
+		// A bridge that can't verify the key drops the Finished message.
+		return "the key exchange did not complete: check the clientKey"
+	}
+	return "the bridge stopped answering during the handshake"
+}
+
+// HandshakeError is returned when the DTLS handshake with the bridge fails.
+type HandshakeError struct {
+	Suites      string // The cipher suites offered.
+	Diagnostics HandshakeDiagnostics
+	Err         error
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("handshake offering %s: %v (%s)", e.Suites, e.Err, e.Diagnostics.Cause())
+}
+
+func (e *HandshakeError) Unwrap() error { return e.Err }
+
+// Handshake returns the diagnostics of the handshake of the current
+// session.
+func (s *Stream) Handshake() HandshakeDiagnostics {
+	if s.client == nil {
+		return HandshakeDiagnostics{}
+	}
+	s.client.hsMu.Lock()
+	defer s.client.hsMu.Unlock()
+	return s.client.handshake
+}
+
+// handshakeAttrs returns the log attributes of d, followed by attrs.
+func handshakeAttrs(d HandshakeDiagnostics, attrs ...any) []any {
+	return append([]any{
+		"duration", d.Elapsed,
+		"sent", d.DatagramsSent,
+		"received", d.DatagramsReceived,
+		"retransmissions", d.Retransmissions,
+		"cipher_suite", d.CipherSuite,
+	}, attrs...)
+}
+
+// handshakeRecorder follows the DTLS records of a handshake through the
+// connection carrying it. It stops looking at the records once the
+// handshake is over.
+type handshakeRecorder struct {
+	start time.Time
+	done  atomic.Bool
+
+	mu   sync.Mutex // Guards the fields below.
+	d    HandshakeDiagnostics
+	sent map[uint64]bool // The sequence and offset of the sent messages.
+}
+
+func newHandshakeRecorder(start time.Time) *handshakeRecorder {
+	return &handshakeRecorder{start: start, sent: make(map[uint64]bool)}
+}
+
+// finish stops the recording at t and returns the diagnostics.
+func (r *handshakeRecorder) finish(t time.Time) HandshakeDiagnostics {
+	r.done.Store(true)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.d.Elapsed = t.Sub(r.start)
+	return r.d
+}
+
+// DTLS content and handshake types.
+const (
+	recordAlert     = 21
+	recordHandshake = 22
+	recordHeader    = 13
+
+	handshakeServerHello = 2
+	handshakeHeader      = 12
+)
+
+func (r *handshakeRecorder) wrote(b []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.d.DatagramsSent++
+	dtlsRecords(b, func(typ uint8, epoch uint16, frag []byte) {
+		if typ != recordHandshake || epoch != 0 || len(frag) < handshakeHeader {
+			return
+		}
+		seq := uint64(binary.BigEndian.Uint16(frag[4:6]))
+		offset := uint64(frag[6])<<16 | uint64(frag[7])<<8 | uint64(frag[8])
+		if key := seq<<24 | offset; r.sent[key] {
+			r.d.Retransmissions++
+		} else {
+			r.sent[key] = true
+		}
+	})
+}
+
+func (r *handshakeRecorder) read(b []byte, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		r.d.Refused = true
+	}
+	if len(b) == 0 {
+		return
+	}
+	r.d.DatagramsReceived++
+	dtlsRecords(b, func(typ uint8, epoch uint16, frag []byte) {
+		switch {
+		case typ == recordAlert && epoch > 0:
+			r.d.Alert = "encrypted"
+		case typ == recordAlert && len(frag) == 2:
+			r.d.Alert = alertName(frag[1])
+		case typ == recordHandshake && epoch == 0 && len(frag) > handshakeHeader && frag[0] == handshakeServerHello:
+			// Version, random and session ID precede the suite.
+			body := frag[handshakeHeader:]
+			if len(body) < 35 || len(body) < 35+int(body[34])+2 {
+				return
+			}
+			id := binary.BigEndian.Uint16(body[35+int(body[34]):])
+			r.d.CipherSuite = dtls.CipherSuiteName(dtls.CipherSuiteID(id))
+		}
+	})
+}
+
+// dtlsRecords calls f with the content type, epoch and fragment of each
+// record of the datagram b.
+func dtlsRecords(b []byte, f func(typ uint8, epoch uint16, frag []byte)) {
+	for len(b) >= recordHeader {
+		n := int(binary.BigEndian.Uint16(b[11:13]))
+		if len(b) < recordHeader+n {
+			return
+		}
+		f(b[0], binary.BigEndian.Uint16(b[3:5]), b[recordHeader:recordHeader+n])
+		b = b[recordHeader+n:]
+	}
+}
+
+func alertName(desc uint8) string {
+	switch desc {
+	case 20:
+		return "bad_record_mac"
+	case 40:
+		return "handshake_failure"
+	case 47:
+		return "illegal_parameter"
+	case 51:
+		return "decrypt_error"
+	case 70:
+		return "protocol_version"
+	case 80:
+		return "internal_error"
+	case 115:
+		return "unknown_psk_identity"
+	}
+	return fmt.Sprintf("%d", desc)
+}
+
+// recordedConn passes the datagrams of a handshake to a handshakeRecorder.
+type recordedConn struct {
+	net.Conn
+	r *handshakeRecorder
+}
+
+func (c *recordedConn) Write(b []byte) (int, error) {
+	if !c.r.done.Load() {
+		c.r.wrote(b)
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *recordedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if !c.r.done.Load() {
+		c.r.read(b[:n], err)
+	}
+	return n, err
+}
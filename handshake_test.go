@@ -0,0 +1,99 @@
+package huestream
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/dtls/v3"
+)
+
+func TestHandshakeDiagnostics(t *testing.T) {
+	c, _ := pskServer(t, dtls.TLS_PSK_WITH_AES_128_GCM_SHA256)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := c.handshakeUDP(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	d := (&Stream{client: c}).Handshake()
+	if d.CipherSuite != "TLS_PSK_WITH_AES_128_GCM_SHA256" {
+		t.Errorf("cipher suite %q, want the one of the server", d.CipherSuite)
+	}
+	if d.DatagramsSent == 0 || d.DatagramsReceived == 0 || d.Elapsed <= 0 {
+		t.Errorf("got %+v, want datagrams both ways and an elapsed time", d)
+	}
+}
+
+// handshakeError runs a handshake expected to fail with a *HandshakeError.
+func handshakeError(t *testing.T, c *client, timeout time.Duration) *HandshakeError {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := c.handshakeUDP(ctx)
+	var herr *HandshakeError
+	if !errors.As(err, &herr) {
+		t.Fatalf("got %v, want a *HandshakeError", err)
+	}
+	return herr
+}
+
+func TestHandshakeDiagnosticsRejected(t *testing.T) {
+	c, _ := pskServer(t, dtls.TLS_PSK_WITH_AES_128_GCM_SHA256)
+	c.clientKey = "ffffffffffffffffffffffffffffffff"
+
+	herr := handshakeError(t, c, 2*time.Second)
+	d := herr.Diagnostics
+	if d.DatagramsReceived == 0 || d.CipherSuite == "" {
+		t.Errorf("got %+v, want the answers of the bridge", d)
+	}
+	if !strings.Contains(herr.Error(), "check the clientKey") {
+		t.Errorf("error %q does not point to the clientKey", herr)
+	}
+}
+
+func TestHandshakeDiagnosticsNoResponse(t *testing.T) {
+	// A socket that never answers, as a filtered port.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	c := newClient("127.0.0.1", "username", testClientKey)
+	c.streamPort = pc.LocalAddr().(*net.UDPAddr).Port
+
+	herr := handshakeError(t, c, 2500*time.Millisecond)
+	d := herr.Diagnostics
+	if d.DatagramsReceived != 0 || d.Retransmissions == 0 || d.Refused {
+		t.Errorf("got %+v, want retransmissions without answer", d)
+	}
+	if !strings.Contains(herr.Error(), "no response") {
+		t.Errorf("error %q does not tell there was no response", herr)
+	}
+}
+
+func TestHandshakeDiagnosticsRefused(t *testing.T) {
+	// A port nothing listens on.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+	pc.Close()
+
+	c := newClient("127.0.0.1", "username", testClientKey)
+	c.streamPort = port
+
+	herr := handshakeError(t, c, 2500*time.Millisecond)
+	if !herr.Diagnostics.Refused || !strings.Contains(herr.Error(), "port closed") {
+		t.Errorf("got %v, %+v, want a refused port", herr, herr.Diagnostics)
+	}
+}
@@ -0,0 +1,47 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rschio/huestream"
+)
+
+var (
+	errBadRequest   = errors.New("invalid request")
+	errNotFound     = errors.New("not found")
+	errNotStreaming = errors.New("not streaming, start the stream first")
+	errServerClosed = errors.New("server closed")
+)
+
+// statusCode returns the status code answering err.
+func statusCode(err error) int {
+	var timeout *huestream.TimeoutError
+	switch {
+	case errors.Is(err, errBadRequest), errors.Is(err, huestream.ErrTooManyChannels):
+		return http.StatusBadRequest
+	case errors.Is(err, errNotFound), errors.Is(err, huestream.ErrAreaNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errNotStreaming), errors.Is(err, huestream.ErrStreamActive):
+		return http.StatusConflict
+	case errors.Is(err, errServerClosed), errors.Is(err, huestream.ErrClosed):
+		return http.StatusServiceUnavailable
+	case errors.As(err, &timeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	// The bridge refused, including ErrUnauthorized: the credentials are
+	// the ones of the Server, not of the client.
+	return http.StatusBadGateway
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, statusCode(err), Error{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,462 @@
+// Package httpapi exposes the entertainment areas of a bridge over HTTP, to
+// control the lights from a phone, a home automation system or curl without
+// writing a server.
+//
+// A Server is an http.Handler serving JSON:
+//
+//	GET    /areas                          list the areas
+//	POST   /areas/{id}/stream              start streaming to an area
+//	DELETE /areas/{id}/stream              stop streaming
+//	PUT    /areas/{id}/color               set the channels: {"color":"#ff8800"}
+//	PUT    /areas/{id}/channels/{channel}  set a channel: {"color":"#ff8800"}
+//	POST   /areas/{id}/effects/{name}      play an effect: {"color":"#ff8800","duration":"10s"}
+//	GET    /presets                        list the presets
+//	POST   /areas/{id}/presets/{name}      play a preset: {"duration":"10s"}
+//
+// The bodies are the frame documents and effect commands of the jsonframe
+// package: the color requests also take colors by channel ID, HS tuples and
+// a transition, as {"0":[30,100],"all":"#000000","transition":2}. A
+// channel request only sets its channel, "color" or "all" being its color.
+//
+// The effects are sparkle, candle and lightning of the effects package, they
+// play until the duration elapses, the colors are set or the stream stops.
+// The colors are held with keepalive until they are changed.
+//
+// The presets are the ones of the seasonal packs of the preset package and
+// of the packs added by AddPack, played as the effects. A preset request
+// only takes a duration.
+//
+// Failures are answered with {"error":"..."} and a status code: 400 for an
+// invalid request, 404 for an unknown area, channel, effect or preset, 409 for a
+// stream not started or already active, 502 when the bridge refuses and 504
+// when it does not answer.
+//
+// Close the Server to stop its streams, for instance on the shutdown of the
+// http.Server:
+//
+//	srv.RegisterOnShutdown(func() { h.Close() })
+package httpapi
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"iter"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/effects"
+	"github.com/rschio/huestream/jsonframe"
+	"github.com/rschio/huestream/preset"
+)
+
+// effectRate is the rate of the effects, in Hz.
+const effectRate = 25
+
+// Server is an http.Handler controlling the entertainment areas of a
+// bridge.
+type Server struct {
+	host      string
+	username  string
+	clientKey string
+	opts      []huestream.Option
+	mux       *http.ServeMux
+
+	mu      sync.Mutex // Guards the fields below.
+	streams map[string]*areaStream
+	closed  bool
+	presets []PresetStatus // In the order of the packs.
+	byName  map[string]preset.Preset
+}
+
+// areaStream is a stream started by the Server.
+type areaStream struct {
+	area   huestream.Area
+	stream *huestream.Stream
+
+	mu     sync.Mutex         // Guards the fields below.
+	frame  huestream.Frame    // The colors set, resent by keepalive.
+	cancel context.CancelFunc // Stops the effect playing, if any.
+	done   chan struct{}      // Closed when the effect returns.
+}
+
+// New returns a Server controlling the bridge at host. opts are passed to
+// huestream.Start and huestream.Areas.
+func New(host, username, clientKey string, opts ...huestream.Option) *Server {
+	s := &Server{
+		host:      host,
+		username:  username,
+		clientKey: clientKey,
+		opts:      opts,
+		mux:       http.NewServeMux(),
+		streams:   make(map[string]*areaStream),
+		byName:    make(map[string]preset.Preset),
+	}
+	for _, pk := range preset.Builtin() {
+		s.AddPack(pk)
+	}
+	s.mux.HandleFunc("GET /areas", s.listAreas)
+	s.mux.HandleFunc("POST /areas/{id}/stream", s.startStream)
+	s.mux.HandleFunc("DELETE /areas/{id}/stream", s.stopStream)
+	s.mux.HandleFunc("PUT /areas/{id}/color", s.setColor)
+	s.mux.HandleFunc("PUT /areas/{id}/channels/{channel}", s.setColor)
+	s.mux.HandleFunc("POST /areas/{id}/effects/{name}", s.playEffect)
+	s.mux.HandleFunc("GET /presets", s.listPresets)
+	s.mux.HandleFunc("POST /areas/{id}/presets/{name}", s.playPreset)
+	return s
+}
+
+// AddPack adds the presets of pk, replacing the presets of the same names.
+func (s *Server) AddPack(pk *preset.Pack) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range pk.Presets {
+		st := PresetStatus{Name: p.Name, Description: p.Description, Pack: pk.Name, Effect: p.Effect.Name}
+		if _, ok := s.byName[p.Name]; ok {
+			i := slices.IndexFunc(s.presets, func(st PresetStatus) bool { return st.Name == p.Name })
+			s.presets = slices.Delete(s.presets, i, i+1)
+		}
+		s.presets = append(s.presets, st)
+		s.byName[p.Name] = p
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close stops the streams, the later requests starting a stream fail.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	streams := s.streams
+	s.streams = make(map[string]*areaStream)
+	s.closed = true
+	s.mu.Unlock()
+
+	var errs []error
+	for _, as := range streams {
+		errs = append(errs, as.close())
+	}
+	return errors.Join(errs...)
+}
+
+// AreaStatus is an area in the answer of GET /areas.
+type AreaStatus struct {
+	huestream.Area
+	Streaming bool `json:"streaming"` // Whether the Server streams to it.
+}
+
+// PresetStatus is a preset in the answer of GET /presets.
+type PresetStatus struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Pack        string `json:"pack"`
+	Effect      string `json:"effect"`
+}
+
+// Error is the body of the failures.
+type Error struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) listAreas(w http.ResponseWriter, r *http.Request) {
+	areas, err := huestream.Areas(r.Context(), s.host, s.username, s.opts...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.mu.Lock()
+	list := make([]AreaStatus, len(areas))
+	for i, a := range areas {
+		_, ok := s.streams[a.ID]
+		list[i] = AreaStatus{Area: a, Streaming: ok}
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) startStream(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	as, err := s.start(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, AreaStatus{Area: as.area, Streaming: true})
+}
+
+func (s *Server) start(ctx context.Context, id string) (*areaStream, error) {
+	s.mu.Lock()
+	_, ok := s.streams[id]
+	closed := s.closed
+	s.mu.Unlock()
+	if ok {
+		return nil, fmt.Errorf("area %s: %w", id, huestream.ErrStreamActive)
+	}
+	if closed {
+		return nil, errServerClosed
+	}
+
+	areas, err := huestream.Areas(ctx, s.host, s.username, s.opts...)
+	if err != nil {
+		return nil, err
+	}
+	as := &areaStream{frame: make(huestream.Frame)}
+	for _, a := range areas {
+		if a.ID == id {
+			as.area = a
+		}
+	}
+	if as.area.ID == "" {
+		return nil, fmt.Errorf("area %s: %w", id, huestream.ErrAreaNotFound)
+	}
+
+	opts := append([]huestream.Option{huestream.WithKeepAlive(time.Second)}, s.opts...)
+	as.stream, err = huestream.Start(ctx, s.host, s.username, s.clientKey, id, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.streams[id]; ok || s.closed {
+		// Started concurrently, or the Server closed meanwhile.
+		as.stream.Close()
+		if s.closed {
+			return nil, errServerClosed
+		}
+		return nil, fmt.Errorf("area %s: %w", id, huestream.ErrStreamActive)
+	}
+	s.streams[id] = as
+	return as, nil
+}
+
+func (s *Server) stopStream(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	as, ok := s.streams[id]
+	delete(s.streams, id)
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, fmt.Errorf("area %s: %w", id, errNotStreaming))
+		return
+	}
+	if err := as.close(); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setColor(w http.ResponseWriter, r *http.Request) {
+	as, err := s.stream(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ids := channelIDs(as.area)
+	if ch := r.PathValue("channel"); ch != "" {
+		id, err := strconv.Atoi(ch)
+		if err != nil || !hasChannel(as.area, id) {
+			writeError(w, fmt.Errorf("channel %s: %w", ch, errNotFound))
+			return
+		}
+		ids = []int{id}
+	}
+	body, err := readBody(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	doc, err := jsonframe.DecodeFrame(body, ids)
+	if err != nil {
+		writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+
+	as.mu.Lock()
+	as.stopEffectLocked()
+	from := maps.Clone(as.frame)
+	maps.Copy(as.frame, doc.Colors)
+	f := maps.Clone(as.frame)
+	as.mu.Unlock()
+
+	if doc.Transition > 0 {
+		as.playEffect(jsonframe.Fade(from, f, doc.Transition, effectRate), 0)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := as.stream.SendContext(r.Context(), f); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) playEffect(w http.ResponseWriter, r *http.Request) {
+	as, err := s.stream(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	body, err := readBody(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	e, err := jsonframe.DecodeEffect(body)
+	if err != nil {
+		writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+	name := r.PathValue("name")
+	if e.Name != "" && e.Name != name {
+		writeError(w, fmt.Errorf("%w: effect %q in the body, %q in the path", errBadRequest, e.Name, name))
+		return
+	}
+	c := cmp.Or[color.Color](e.Color, color.White)
+
+	ids := channelIDs(as.area)
+	var frames iter.Seq[huestream.Frame]
+	switch name {
+	case "sparkle":
+		frames = effects.Sparkle(ids, c, 0.05)
+	case "candle":
+		frames = effects.Candle(ids)
+	case "lightning":
+		frames = effects.Lightning(ids, 0.02)
+	default:
+		writeError(w, fmt.Errorf("effect %s: %w", name, errNotFound))
+		return
+	}
+
+	as.playEffect(frames, e.Duration)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) listPresets(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	list := slices.Clone(s.presets)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) playPreset(w http.ResponseWriter, r *http.Request) {
+	as, err := s.stream(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	body, err := readBody(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	e, err := jsonframe.DecodeEffect(body)
+	if err != nil {
+		writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+	if e.Name != "" || e.Color != nil {
+		writeError(w, fmt.Errorf("%w: a preset takes only a duration", errBadRequest))
+		return
+	}
+	name := r.PathValue("name")
+	s.mu.Lock()
+	p, ok := s.byName[name]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, fmt.Errorf("preset %s: %w", name, errNotFound))
+		return
+	}
+
+	as.playEffect(p.Frames(channelIDs(as.area)), e.Duration)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// stream returns the stream of the area of r.
+func (s *Server) stream(r *http.Request) (*areaStream, error) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	as, ok := s.streams[id]
+	if !ok {
+		return nil, fmt.Errorf("area %s: %w", id, errNotStreaming)
+	}
+	return as, nil
+}
+
+// playEffect replaces the effect playing by frames, played for d if d is
+// not zero, on a goroutine.
+func (as *areaStream) playEffect(frames iter.Seq[huestream.Frame], d time.Duration) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if d > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), d)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	done := make(chan struct{})
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	as.stopEffectLocked()
+	as.cancel, as.done = cancel, done
+	go func() {
+		defer close(done)
+		as.stream.PlaySeq(ctx, frames, effectRate)
+	}()
+}
+
+// stopEffectLocked stops the effect playing, if any, and waits for it. It
+// must be called with mu held, the effect does not lock it.
+func (as *areaStream) stopEffectLocked() {
+	if as.cancel != nil {
+		as.cancel()
+		<-as.done
+		as.cancel, as.done = nil, nil
+	}
+}
+
+func (as *areaStream) close() error {
+	as.mu.Lock()
+	as.stopEffectLocked()
+	as.mu.Unlock()
+	return as.stream.Close()
+}
+
+func channelIDs(a huestream.Area) []int {
+	ids := make([]int, len(a.Channels))
+	for i, ch := range a.Channels {
+		ids[i] = ch.ID
+	}
+	return ids
+}
+
+func hasChannel(a huestream.Area, id int) bool {
+	for _, ch := range a.Channels {
+		if ch.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// maxBodySize is the size limit of the request bodies.
+const maxBodySize = 1 << 20
+
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,244 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/huetest"
+	"github.com/rschio/huestream/preset"
+	"github.com/rschio/huestream/wire"
+)
+
+func newServer(t *testing.T) (*huetest.Bridge, *httptest.Server) {
+	t.Helper()
+
+	b := huetest.NewBridge(t)
+	h := New(b.Host, b.Username, b.ClientKey, b.Options()...)
+	srv := httptest.NewServer(h)
+	t.Cleanup(func() {
+		srv.Close()
+		h.Close()
+	})
+	return b, srv
+}
+
+// do sends a request to srv and returns the status code and the body.
+func do(t *testing.T, srv *httptest.Server, method, path, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+func nextFrame(t *testing.T, b *huetest.Bridge) wire.Frame {
+	t.Helper()
+
+	select {
+	case f := <-b.Frames():
+		return f
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame received")
+		return wire.Frame{}
+	}
+}
+
+func TestStreamColors(t *testing.T) {
+	b, srv := newServer(t)
+	area := "/areas/" + b.AreaID
+
+	code, body := do(t, srv, "GET", "/areas", "")
+	var areas []AreaStatus
+	if err := json.Unmarshal([]byte(body), &areas); code != http.StatusOK || err != nil {
+		t.Fatalf("GET /areas: %d %s", code, body)
+	}
+	if len(areas) != 1 || areas[0].ID != b.AreaID || areas[0].Streaming {
+		t.Errorf("GET /areas: got %+v", areas)
+	}
+
+	if code, body := do(t, srv, "POST", area+"/stream", ""); code != http.StatusCreated {
+		t.Fatalf("start: %d %s", code, body)
+	}
+	if !b.Active() {
+		t.Error("stream not started on the bridge")
+	}
+
+	if code, body := do(t, srv, "PUT", area+"/color", `{"color":"#ff0000"}`); code != http.StatusNoContent {
+		t.Fatalf("set color: %d %s", code, body)
+	}
+	f := nextFrame(t, b)
+	if len(f.Channels) != huetest.Lights || f.Channels[0].Values != [3]uint16{0xffff, 0, 0} {
+		t.Errorf("got %+v, want every channel red", f.Channels)
+	}
+
+	if code, body := do(t, srv, "PUT", area+"/channels/1", `{"color":"#0000ff"}`); code != http.StatusNoContent {
+		t.Fatalf("set channel: %d %s", code, body)
+	}
+	for f = nextFrame(t, b); f.Channels[1].Values == [3]uint16{0xffff, 0, 0}; f = nextFrame(t, b) {
+		// Skip the keepalive resends of the previous frame.
+	}
+	if f.Channels[0].Values != [3]uint16{0xffff, 0, 0} || f.Channels[1].Values != [3]uint16{0, 0, 0xffff} {
+		t.Errorf("got %+v, want channel 1 blue and the others red", f.Channels)
+	}
+
+	if code, body := do(t, srv, "POST", area+"/effects/candle", `{"duration":"100ms"}`); code != http.StatusAccepted {
+		t.Fatalf("effect: %d %s", code, body)
+	}
+	if code, body := do(t, srv, "DELETE", area+"/stream", ""); code != http.StatusNoContent {
+		t.Fatalf("stop: %d %s", code, body)
+	}
+	if b.Active() {
+		t.Error("stream not stopped on the bridge")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	b, srv := newServer(t)
+	area := "/areas/" + b.AreaID
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/areas/missing/stream", "", http.StatusNotFound},
+		{"PUT", area + "/color", `{"color":"#ff0000"}`, http.StatusConflict},
+		{"DELETE", area + "/stream", "", http.StatusConflict},
+		{"POST", area + "/stream", "", http.StatusCreated},
+		{"POST", area + "/stream", "", http.StatusConflict},
+		{"PUT", area + "/color", `{"color":"reddish"}`, http.StatusBadRequest},
+		{"PUT", area + "/color", `not json`, http.StatusBadRequest},
+		{"PUT", area + "/channels/1", `{"0":"#ff0000"}`, http.StatusBadRequest},
+		{"POST", area + "/effects/candle", `{"name":"sparkle"}`, http.StatusBadRequest},
+		{"PUT", area + "/channels/7", `{"color":"#ff0000"}`, http.StatusNotFound},
+		{"POST", area + "/effects/fireworks", `{}`, http.StatusNotFound},
+		{"POST", area + "/effects/sparkle", `{"duration":"-1s"}`, http.StatusBadRequest},
+		{"POST", area + "/presets/fireworks", `{}`, http.StatusNotFound},
+		{"POST", area + "/presets/pumpkins", `{"color":"#ff0000"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		code, body := do(t, srv, tt.method, tt.path, tt.body)
+		if code != tt.want {
+			t.Errorf("%s %s: got %d %s, want %d", tt.method, tt.path, code, body, tt.want)
+		}
+		if code >= 400 {
+			var e Error
+			if err := json.Unmarshal([]byte(body), &e); err != nil || e.Error == "" {
+				t.Errorf("%s %s: invalid error body %q", tt.method, tt.path, body)
+			}
+		}
+	}
+}
+
+func TestCloseStopsStreams(t *testing.T) {
+	b := huetest.NewBridge(t)
+	h := New(b.Host, b.Username, b.ClientKey, b.Options()...)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	if code, body := do(t, srv, "POST", "/areas/"+b.AreaID+"/stream", ""); code != http.StatusCreated {
+		t.Fatalf("start: %d %s", code, body)
+	}
+	if code, body := do(t, srv, "POST", "/areas/"+b.AreaID+"/effects/sparkle", `{}`); code != http.StatusAccepted {
+		t.Fatalf("effect: %d %s", code, body)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Active() {
+		t.Error("stream not stopped by Close")
+	}
+	if code, _ := do(t, srv, "POST", "/areas/"+b.AreaID+"/stream", ""); code != http.StatusServiceUnavailable {
+		t.Errorf("start after Close: got %d, want 503", code)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	b, srv := newServer(t)
+	area := "/areas/" + b.AreaID
+
+	if code, body := do(t, srv, "POST", area+"/stream", ""); code != http.StatusCreated {
+		t.Fatalf("start: %d %s", code, body)
+	}
+	// Without colors set before the channels have no color to fade from.
+	if code, body := do(t, srv, "PUT", area+"/color", `{"color":"#000000"}`); code != http.StatusNoContent {
+		t.Fatalf("set black: %d %s", code, body)
+	}
+	// Blue as a Home Assistant hs_color, rendered by a template.
+	if code, body := do(t, srv, "PUT", area+"/color", `{"all":"(240.0, 100.0)","transition":"0.2"}`); code != http.StatusNoContent {
+		t.Fatalf("set color: %d %s", code, body)
+	}
+	var f wire.Frame
+	steps := 0
+	for f = nextFrame(t, b); f.Channels[0].Values != [3]uint16{0, 0, 0xffff}; f = nextFrame(t, b) {
+		if v := f.Channels[0].Values; v[0] != 0 || v[1] != 0 {
+			t.Fatalf("got %v, want a fade from black to blue", v)
+		}
+		steps++
+	}
+	if steps == 0 {
+		t.Error("blue set at once, want a fade")
+	}
+}
+
+func TestPresets(t *testing.T) {
+	b := huetest.NewBridge(t)
+	h := New(b.Host, b.Username, b.ClientKey, b.Options()...)
+	pk, err := preset.Load(strings.NewReader(`{"name": "test", "presets": [
+		{"name": "pumpkins", "description": "all red", "effect": "palette", "params": {"palette": ["red"]}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.AddPack(pk)
+	srv := httptest.NewServer(h)
+	t.Cleanup(func() {
+		srv.Close()
+		h.Close()
+	})
+	area := "/areas/" + b.AreaID
+
+	code, body := do(t, srv, "GET", "/presets", "")
+	var presets []PresetStatus
+	if err := json.Unmarshal([]byte(body), &presets); code != http.StatusOK || err != nil {
+		t.Fatalf("GET /presets: %d %s", code, body)
+	}
+	var builtin, replaced bool
+	for _, p := range presets {
+		switch {
+		case p.Name == "dawn" && p.Pack == "spring" && p.Effect == "sunrise":
+			builtin = true
+		case p.Name == "pumpkins":
+			replaced = p.Pack == "test" && p.Description == "all red"
+		}
+	}
+	if !builtin || !replaced {
+		t.Errorf("GET /presets: got %+v, want the builtin presets and pumpkins of the pack test", presets)
+	}
+
+	if code, body := do(t, srv, "POST", area+"/stream", ""); code != http.StatusCreated {
+		t.Fatalf("start: %d %s", code, body)
+	}
+	if code, body := do(t, srv, "POST", area+"/presets/pumpkins", `{"duration":"1s"}`); code != http.StatusAccepted {
+		t.Fatalf("preset: %d %s", code, body)
+	}
+	f := nextFrame(t, b)
+	for len(f.Channels) == 0 {
+		f = nextFrame(t, b)
+	}
+	if f.Channels[0].Values != [3]uint16{0xffff, 0, 0} {
+		t.Errorf("got %v, want red", f.Channels[0].Values)
+	}
+}
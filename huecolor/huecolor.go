@@ -0,0 +1,186 @@
+// Package huecolor implements the color math of the Hue lights: the sRGB
+// encoding, the conversions between RGB and the CIE 1931 xy chromaticities
+// the lights are driven with, their gamuts and the color temperatures.
+//
+// The Stream of huestream does its math with this package, an application
+// doing its own color pipeline with it gets the colors the Stream gets.
+//
+// The conversions follow the Hue documentation: the RGB colors are sRGB
+// encoded, with the wide gamut primaries of the Hue lights.
+package huecolor
+
+import (
+	"image/color"
+	"math"
+)
+
+// ToLinear returns the linear intensity of the sRGB encoded component v,
+// both from 0 to 1.
+func ToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// FromLinear is the inverse of ToLinear, it sRGB encodes the intensity v.
+func FromLinear(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// XY is a chromaticity of the CIE 1931 color space.
+type XY struct {
+	X, Y float64
+}
+
+// RGBToXY returns the chromaticity of c and its brightness, the luminance
+// from 0 to 1. Black has the chromaticity of white.
+func RGBToXY(c color.Color) (xy XY, brightness float64) {
+	r, g, b, _ := c.RGBA()
+	rl := ToLinear(float64(r) / 0xffff)
+	gl := ToLinear(float64(g) / 0xffff)
+	bl := ToLinear(float64(b) / 0xffff)
+	black := rl == 0 && gl == 0 && bl == 0
+	if black {
+		rl, gl, bl = 1, 1, 1
+	}
+	x := rl*0.664511 + gl*0.154324 + bl*0.162028
+	y := rl*0.283881 + gl*0.668433 + bl*0.047685
+	z := rl*0.000088 + gl*0.072310 + bl*0.986039
+	sum := x + y + z
+	xy = XY{X: x / sum, Y: y / sum}
+	if black {
+		return xy, 0
+	}
+	return xy, y
+}
+
+// XYToRGB returns the color of the chromaticity xy at brightness, the
+// luminance from 0 to 1. The components are scaled down together when one
+// overflows, a color out of the primaries is clipped to the nearest they
+// render.
+func XYToRGB(xy XY, brightness float64) color.RGBA64 {
+	if xy.Y <= 0 || brightness <= 0 {
+		return color.RGBA64{A: 0xffff}
+	}
+	y := brightness
+	x := y / xy.Y * xy.X
+	z := y / xy.Y * (1 - xy.X - xy.Y)
+	rgb := [3]float64{
+		x*1.656492 - y*0.354851 - z*0.255038,
+		-x*0.707196 + y*1.655397 + z*0.036152,
+		x*0.051713 - y*0.121364 + z*1.011530,
+	}
+	if m := max(rgb[0], rgb[1], rgb[2]); m > 1 {
+		for i := range rgb {
+			rgb[i] /= m
+		}
+	}
+	var v [3]uint16
+	for i, c := range rgb {
+		v[i] = uint16(math.Round(FromLinear(max(c, 0)) * 0xffff))
+	}
+	return color.RGBA64{R: v[0], G: v[1], B: v[2], A: 0xffff}
+}
+
+// Gamut is the triangle of the chromaticities a light renders, between the
+// chromaticities of its primaries.
+type Gamut struct {
+	Red, Green, Blue XY
+}
+
+// The gamuts of the Hue lights, see the Gamut of their light resources.
+var (
+	GamutA = Gamut{Red: XY{0.704, 0.296}, Green: XY{0.2151, 0.7106}, Blue: XY{0.138, 0.08}}
+	GamutB = Gamut{Red: XY{0.675, 0.322}, Green: XY{0.409, 0.518}, Blue: XY{0.167, 0.04}}
+	GamutC = Gamut{Red: XY{0.6915, 0.3083}, Green: XY{0.17, 0.7}, Blue: XY{0.1532, 0.0475}}
+)
+
+// Contains reports whether g contains xy, on its edges included.
+func (g Gamut) Contains(xy XY) bool {
+	d1 := cross(g.Red, g.Green, xy)
+	d2 := cross(g.Green, g.Blue, xy)
+	d3 := cross(g.Blue, g.Red, xy)
+	neg := d1 < 0 || d2 < 0 || d3 < 0
+	pos := d1 > 0 || d2 > 0 || d3 > 0
+	return !(neg && pos)
+}
+
+// cross is the z of the cross product of b-a and p-a, its sign tells the
+// side of the line ab p is on.
+func cross(a, b, p XY) float64 {
+	return (b.X-a.X)*(p.Y-a.Y) - (b.Y-a.Y)*(p.X-a.X)
+}
+
+// ClosestInGamut returns xy if g contains it, otherwise the chromaticity of
+// g closest to it, on one of its edges. The lights render the colors out
+// of their gamut this way.
+func ClosestInGamut(xy XY, g Gamut) XY {
+	if g.Contains(xy) {
+		return xy
+	}
+	best, dist := xy, math.Inf(1)
+	for _, edge := range [][2]XY{{g.Red, g.Green}, {g.Green, g.Blue}, {g.Blue, g.Red}} {
+		p := closestOnSegment(xy, edge[0], edge[1])
+		if d := math.Hypot(p.X-xy.X, p.Y-xy.Y); d < dist {
+			best, dist = p, d
+		}
+	}
+	return best
+}
+
+func closestOnSegment(p, a, b XY) XY {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / (dx*dx + dy*dy)
+	t = min(max(t, 0), 1)
+	return XY{X: a.X + t*dx, Y: a.Y + t*dy}
+}
+
+// The range of the color temperatures of CCTToXY, in kelvin.
+const (
+	MinKelvin = 1667
+	MaxKelvin = 25000
+)
+
+// MirekToKelvin returns the color temperature m, in mirek as the Hue API
+// sets it, in kelvin.
+func MirekToKelvin(m int) float64 { return 1e6 / float64(m) }
+
+// KelvinToMirek returns the color temperature k, in kelvin, in mirek.
+func KelvinToMirek(k float64) int { return int(math.Round(1e6 / k)) }
+
+// CCTToXY returns the chromaticity of the color temperature k, in kelvin,
+// on the Planckian locus, with the approximation of Kim et al. k is clamped
+// to [MinKelvin, MaxKelvin].
+func CCTToXY(k float64) XY {
+	t := min(max(k, MinKelvin), MaxKelvin)
+	t2, t3 := t*t, t*t*t
+	var x float64
+	if t <= 4000 {
+		x = -0.2661239e9/t3 - 0.2343589e6/t2 + 0.8776956e3/t + 0.179910
+	} else {
+		x = -3.0258469e9/t3 + 2.1070379e6/t2 + 0.2226347e3/t + 0.240390
+	}
+	x2, x3 := x*x, x*x*x
+	var y float64
+	switch {
+	case t <= 2222:
+		y = -1.1063814*x3 - 1.34811020*x2 + 2.18555832*x - 0.20219683
+	case t <= 4000:
+		y = -0.9549476*x3 - 1.37418593*x2 + 2.09137015*x - 0.16748867
+	default:
+		y = 3.0817580*x3 - 5.87338670*x2 + 3.75112997*x - 0.37001483
+	}
+	return XY{X: x, Y: y}
+}
+
+// XYToCCT returns the correlated color temperature of xy, in kelvin, with
+// the approximation of McCamy. It is accurate near the Planckian locus,
+// from 2000K to 12500K.
+func XYToCCT(xy XY) float64 {
+	n := (xy.X - 0.3320) / (0.1858 - xy.Y)
+	return 449*n*n*n + 3525*n*n + 6823.3*n + 5520.33
+}
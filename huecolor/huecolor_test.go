@@ -0,0 +1,133 @@
+package huecolor
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func near(a, b, tolerance float64) bool { return math.Abs(a-b) <= tolerance }
+
+func TestLinearRoundTrip(t *testing.T) {
+	for v := range 0x10000 {
+		f := float64(v) / 0xffff
+		if got := math.Round(FromLinear(ToLinear(f)) * 0xffff); got != float64(v) {
+			t.Fatalf("%d decoded and encoded back to %v", v, got)
+		}
+	}
+	// Reference values of the sRGB specification.
+	if got := ToLinear(0.5); !near(got, 0.214041, 1e-6) {
+		t.Errorf("ToLinear(0.5) = %v, want 0.214041", got)
+	}
+}
+
+func TestRGBToXY(t *testing.T) {
+	tests := []struct {
+		name       string
+		c          color.Color
+		xy         XY
+		brightness float64
+	}{
+		// The primaries of the wide gamut of the Hue documentation.
+		{"red", color.RGBA{R: 255, A: 255}, XY{0.7006, 0.2993}, 0.2839},
+		{"green", color.RGBA{G: 255, A: 255}, XY{0.1724, 0.7468}, 0.6684},
+		{"blue", color.RGBA{B: 255, A: 255}, XY{0.1355, 0.0399}, 0.0477},
+		{"white", color.White, XY{0.3227, 0.3290}, 1},
+		{"black", color.Black, XY{0.3227, 0.3290}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xy, b := RGBToXY(tt.c)
+			if !near(xy.X, tt.xy.X, 1e-4) || !near(xy.Y, tt.xy.Y, 1e-4) || !near(b, tt.brightness, 1e-4) {
+				t.Errorf("RGBToXY = %.4f, %.4f, want %v, %v", xy, b, tt.xy, tt.brightness)
+			}
+		})
+	}
+}
+
+func TestXYToRGBRoundTrip(t *testing.T) {
+	for _, c := range []color.RGBA64{
+		{R: 0xffff, A: 0xffff},
+		{R: 0xffff, G: 0x8000, A: 0xffff},
+		{R: 0x1000, G: 0x2000, B: 0x3000, A: 0xffff},
+		{R: 0xffff, G: 0xffff, B: 0xffff, A: 0xffff},
+	} {
+		got := XYToRGB(RGBToXY(c))
+		for i, pair := range [][2]uint16{{got.R, c.R}, {got.G, c.G}, {got.B, c.B}} {
+			if !near(float64(pair[0]), float64(pair[1]), 2) {
+				t.Errorf("%v converted back to %v, component %d differs", c, got, i)
+			}
+		}
+	}
+	if got := XYToRGB(XY{0.3227, 0.3290}, 0); got != (color.RGBA64{A: 0xffff}) {
+		t.Errorf("XYToRGB at brightness 0 = %v, want black", got)
+	}
+}
+
+func TestGamut(t *testing.T) {
+	for _, g := range []Gamut{GamutA, GamutB, GamutC} {
+		white := XY{0.3227, 0.3290}
+		if !g.Contains(white) || ClosestInGamut(white, g) != white {
+			t.Errorf("gamut %v does not contain white", g)
+		}
+		for _, p := range []XY{g.Red, g.Green, g.Blue} {
+			if !g.Contains(p) {
+				t.Errorf("gamut %v does not contain its primary %v", g, p)
+			}
+		}
+	}
+
+	// Out of the red-blue edge of gamut C, along its normal.
+	g := GamutC
+	mid := XY{(g.Red.X + g.Blue.X) / 2, (g.Red.Y + g.Blue.Y) / 2}
+	dx, dy := g.Blue.X-g.Red.X, g.Blue.Y-g.Red.Y
+	l := math.Hypot(dx, dy)
+	out := XY{mid.X - dy/l*0.05, mid.Y + dx/l*0.05}
+	if g.Contains(out) {
+		t.Fatalf("gamut C contains %v", out)
+	}
+	if got := ClosestInGamut(out, g); !near(got.X, mid.X, 1e-9) || !near(got.Y, mid.Y, 1e-9) {
+		t.Errorf("ClosestInGamut(%v) = %v, want %v", out, got, mid)
+	}
+
+	// Beyond a vertex.
+	if got := ClosestInGamut(XY{0.9, 0.2}, GamutB); got != GamutB.Red {
+		t.Errorf("ClosestInGamut beyond red = %v, want %v", got, GamutB.Red)
+	}
+
+	// A custom gamut, the sRGB one.
+	srgb := Gamut{Red: XY{0.64, 0.33}, Green: XY{0.30, 0.60}, Blue: XY{0.15, 0.06}}
+	if srgb.Contains(GamutC.Green) {
+		t.Errorf("sRGB gamut contains the green of gamut C")
+	}
+}
+
+func TestCCT(t *testing.T) {
+	tests := []struct {
+		kelvin float64
+		xy     XY
+	}{
+		{2000, XY{0.5267, 0.4133}},
+		{2700, XY{0.4599, 0.4106}},
+		{4000, XY{0.3805, 0.3768}},
+		{6500, XY{0.3135, 0.3237}},
+	}
+	for _, tt := range tests {
+		xy := CCTToXY(tt.kelvin)
+		if !near(xy.X, tt.xy.X, 2e-3) || !near(xy.Y, tt.xy.Y, 2e-3) {
+			t.Errorf("CCTToXY(%v) = %.4f, want %v", tt.kelvin, xy, tt.xy)
+		}
+		if k := XYToCCT(xy); !near(k, tt.kelvin, tt.kelvin*0.015) {
+			t.Errorf("XYToCCT(%v) = %.0f, want %v", xy, k, tt.kelvin)
+		}
+	}
+	if got, want := CCTToXY(100), CCTToXY(MinKelvin); got != want {
+		t.Errorf("CCTToXY(100) = %v, want the clamped %v", got, want)
+	}
+	if m := KelvinToMirek(6500); m != 154 {
+		t.Errorf("KelvinToMirek(6500) = %d, want 154", m)
+	}
+	if k := MirekToKelvin(500); k != 2000 {
+		t.Errorf("MirekToKelvin(500) = %v, want 2000", k)
+	}
+}
@@ -0,0 +1,224 @@
+package huecolor
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+)
+
+// RotateHue rotates the hue of c by turns, keeping its value and chroma in
+// the HSV model. Gray has no hue, it is returned as is.
+func RotateHue(c color.Color, turns float64) color.Color {
+	if turns == 0 {
+		return c
+	}
+	r, g, b, _ := c.RGBA()
+	rf, gf, bf := float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff
+	v := max(rf, gf, bf)
+	d := v - min(rf, gf, bf)
+	if d == 0 {
+		return c
+	}
+	var h float64
+	switch v {
+	case rf:
+		h = math.Mod((gf-bf)/d, 6)
+	case gf:
+		h = (bf-rf)/d + 2
+	default:
+		h = (rf-gf)/d + 4
+	}
+	h = math.Mod(h/6+turns, 1)
+	if h < 0 {
+		h++
+	}
+
+	// Back to RGB, with the same value and chroma.
+	h *= 6
+	x := d * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r1, g1, b1 float64
+	switch int(h) {
+	case 0:
+		r1, g1 = d, x
+	case 1:
+		r1, g1 = x, d
+	case 2:
+		g1, b1 = d, x
+	case 3:
+		g1, b1 = x, d
+	case 4:
+		r1, b1 = x, d
+	default:
+		r1, b1 = d, x
+	}
+	m := v - d
+	unit := func(v float64) uint16 { return uint16(math.Round(min(max(v, 0), 1) * 0xffff)) }
+	return color.RGBA64{R: unit(r1 + m), G: unit(g1 + m), B: unit(b1 + m), A: 0xffff}
+}
+
+// Complementary returns c and the color of the opposite hue.
+func Complementary(c color.Color) []color.Color {
+	return []color.Color{c, RotateHue(c, 0.5)}
+}
+
+// Triadic returns c and the two colors of the hues a third of a turn away.
+func Triadic(c color.Color) []color.Color {
+	return []color.Color{c, RotateHue(c, 1.0/3), RotateHue(c, 2.0/3)}
+}
+
+// Analogous returns c between the colors of the hues spread turns away on
+// either side, as 1.0/12 for the usual 30 degrees.
+func Analogous(c color.Color, spread float64) []color.Color {
+	return []color.Color{RotateHue(c, -spread), c, RotateHue(c, spread)}
+}
+
+// maxSamples bounds the pixels Dominant samples on each axis.
+const maxSamples = 64
+
+// Dominant returns the n dominant colors of img, the most common first: it
+// samples the pixels on a grid of at most 64x64 and splits them with the
+// median cut refined with k-means, the colors are the averages of the
+// groups. The transparent pixels are ignored. It returns fewer colors for
+// an image with fewer distinct ones, and the same colors for the same
+// image.
+func Dominant(img image.Image, n int) []color.Color {
+	bounds := img.Bounds()
+	stepX := max(bounds.Dx()/maxSamples, 1)
+	stepY := max(bounds.Dy()/maxSamples, 1)
+	var pixels [][3]uint32
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			// Undo the premultiplication by alpha.
+			pixels = append(pixels, [3]uint32{r * 0xffff / a, g * 0xffff / a, b * 0xffff / a})
+		}
+	}
+	if n <= 0 || len(pixels) == 0 {
+		return nil
+	}
+
+	boxes := [][][3]uint32{pixels}
+	for len(boxes) < n {
+		// Split the box with the widest range of a component.
+		best, comp, width := -1, 0, uint32(0)
+		for i, box := range boxes {
+			if c, w := widest(box); w > width {
+				best, comp, width = i, c, w
+			}
+		}
+		if best < 0 {
+			break // Every box holds a single color.
+		}
+		box := boxes[best]
+		slices.SortStableFunc(box, func(a, b [3]uint32) int { return int(a[comp]) - int(b[comp]) })
+		// The median, moved to the end of its run so that no color spans
+		// both halves.
+		mid := len(box) / 2
+		for mid < len(box) && box[mid][comp] == box[mid-1][comp] {
+			mid++
+		}
+		if mid == len(box) {
+			mid = len(box) / 2
+			for mid > 0 && box[mid][comp] == box[mid-1][comp] {
+				mid--
+			}
+		}
+		boxes[best] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	// Refine the averages of the boxes with k-means: the median cut splits
+	// at the median, not between the groups of colors.
+	centers := make([][3]float64, len(boxes))
+	for i, box := range boxes {
+		centers[i] = mean(box)
+	}
+	counts := make([]int, len(centers))
+	for range kmeansRounds {
+		sums := make([][3]float64, len(centers))
+		clear(counts)
+		for _, p := range pixels {
+			k := nearest(centers, p)
+			for j := range p {
+				sums[k][j] += float64(p[j])
+			}
+			counts[k]++
+		}
+		for k := range centers {
+			if counts[k] > 0 {
+				for j := range sums[k] {
+					centers[k][j] = sums[k][j] / float64(counts[k])
+				}
+			}
+		}
+	}
+
+	order := make([]int, len(centers))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return counts[b] - counts[a] })
+	colors := make([]color.Color, 0, len(centers))
+	for _, k := range order {
+		if counts[k] == 0 {
+			continue
+		}
+		u := func(v float64) uint16 { return uint16(math.Round(v)) }
+		c := centers[k]
+		colors = append(colors, color.RGBA64{R: u(c[0]), G: u(c[1]), B: u(c[2]), A: 0xffff})
+	}
+	return colors
+}
+
+// kmeansRounds is the number of rounds of the k-means of Dominant.
+const kmeansRounds = 8
+
+func mean(pixels [][3]uint32) [3]float64 {
+	var sum [3]float64
+	for _, p := range pixels {
+		for j := range p {
+			sum[j] += float64(p[j])
+		}
+	}
+	for j := range sum {
+		sum[j] /= float64(len(pixels))
+	}
+	return sum
+}
+
+// nearest returns the index of the center closest to p.
+func nearest(centers [][3]float64, p [3]uint32) int {
+	best, dist := 0, math.Inf(1)
+	for k, c := range centers {
+		var d float64
+		for j := range c {
+			d += (c[j] - float64(p[j])) * (c[j] - float64(p[j]))
+		}
+		if d < dist {
+			best, dist = k, d
+		}
+	}
+	return best
+}
+
+// widest returns the component of the pixels with the widest range, and
+// the range.
+func widest(pixels [][3]uint32) (comp int, width uint32) {
+	lo := [3]uint32{math.MaxUint32, math.MaxUint32, math.MaxUint32}
+	var hi [3]uint32
+	for _, p := range pixels {
+		for j := range p {
+			lo[j], hi[j] = min(lo[j], p[j]), max(hi[j], p[j])
+		}
+	}
+	for j := range lo {
+		if hi[j]-lo[j] > width {
+			comp, width = j, hi[j]-lo[j]
+		}
+	}
+	return comp, width
+}
@@ -0,0 +1,102 @@
+package huecolor
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestHarmonies(t *testing.T) {
+	red := color.RGBA64{R: 0xffff, A: 0xffff}
+	rgb := func(r, g, b uint16) color.Color { return color.RGBA64{R: r, G: g, B: b, A: 0xffff} }
+	tests := []struct {
+		name string
+		got  []color.Color
+		want []color.Color
+	}{
+		{"complementary", Complementary(red), []color.Color{red, rgb(0, 0xffff, 0xffff)}},
+		{"triadic", Triadic(red), []color.Color{red, rgb(0, 0xffff, 0), rgb(0, 0, 0xffff)}},
+		{"analogous", Analogous(red, 1.0/12), []color.Color{rgb(0xffff, 0, 0x8000), red, rgb(0xffff, 0x8000, 0)}},
+		{"gray", Complementary(color.Gray16{0x8000}), []color.Color{color.Gray16{0x8000}, color.Gray16{0x8000}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func readPNG(t *testing.T, name string) image.Image {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// near8 reports whether c is within 3 of the 8 bits color want.
+func near8(c color.Color, want [3]int) bool {
+	r, g, b, _ := c.RGBA()
+	for i, v := range []uint32{r >> 8, g >> 8, b >> 8} {
+		if d := int(v) - want[i]; d < -3 || d > 3 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDominant(t *testing.T) {
+	tests := []struct {
+		image string
+		n     int
+		want  [][3]int
+	}{
+		// Bands of orange, teal and purple over half, 30% and 20% of the
+		// image, with noise.
+		{"bands.png", 3, [][3]int{{240, 130, 20}, {20, 160, 150}, {120, 40, 160}}},
+		// A gradient from dark blue to orange, split in halves.
+		{"gradient.png", 2, [][3]int{{78, 58, 72}, {192, 112, 38}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			img := readPNG(t, tt.image)
+			got := Dominant(img, tt.n)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d colors, want %d", len(got), len(tt.want))
+			}
+			for i, c := range got {
+				if !near8(c, tt.want[i]) {
+					t.Errorf("color %d = %v, want near %v", i, c, tt.want[i])
+				}
+			}
+			if again := Dominant(img, tt.n); !reflect.DeepEqual(got, again) {
+				t.Errorf("second run got %v, want %v", again, got)
+			}
+		})
+	}
+}
+
+func TestDominantFewColors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for x := range 10 {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255}) // The rest is transparent.
+	}
+	got := Dominant(img, 4)
+	if want := []color.Color{color.RGBA64{R: 0xffff, A: 0xffff}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := Dominant(image.NewRGBA(image.Rect(0, 0, 4, 4)), 2); got != nil {
+		t.Errorf("transparent image got %v, want none", got)
+	}
+}
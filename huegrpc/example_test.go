@@ -0,0 +1,59 @@
+package huegrpc_test
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rschio/huestream/huegrpc/huestreampb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// A client streaming a red to blue gradient to the first area for 5s.
+func Example_client() {
+	conn, err := grpc.NewClient("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	client := huestreampb.NewHueStreamClient(conn)
+	ctx := context.Background()
+
+	areas, err := client.ListAreas(ctx, &huestreampb.ListAreasRequest{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(areas.Areas) == 0 {
+		log.Fatal("no entertainment area")
+	}
+	session, err := client.StartSession(ctx, &huestreampb.StartSessionRequest{
+		AreaId:    areas.Areas[0].Id,
+		KeepAlive: durationpb.New(500 * time.Millisecond),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.StopSession(ctx, &huestreampb.StopSessionRequest{SessionId: session.SessionId})
+
+	frames, err := client.StreamFrames(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i := range 250 { // 5s at 50 Hz.
+		v := uint32(i * 0xffff / 249)
+		f := &huestreampb.Frame{}
+		for _, ch := range session.Area.Channels {
+			f.Channels = append(f.Channels, &huestreampb.ChannelColor{Channel: ch.Id, R: 0xffff - v, B: v})
+		}
+		req := &huestreampb.StreamFramesRequest{SessionId: session.SessionId, Frame: f}
+		if err := frames.Send(req); err != nil {
+			log.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if _, err := frames.CloseAndRecv(); err != nil {
+		log.Fatal(err)
+	}
+}
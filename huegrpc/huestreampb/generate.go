@@ -0,0 +1,4 @@
+// Package huestreampb is the generated code of the HueStream gRPC service.
+package huestreampb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative huestream.proto
@@ -0,0 +1,244 @@
+// The HueStream service streams frames to the entertainment areas of a Hue
+// Bridge, see the huegrpc package for the server.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: huestream.proto
+
+package huestreampb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	HueStream_ListAreas_FullMethodName    = "/huestream.v1.HueStream/ListAreas"
+	HueStream_StartSession_FullMethodName = "/huestream.v1.HueStream/StartSession"
+	HueStream_StreamFrames_FullMethodName = "/huestream.v1.HueStream/StreamFrames"
+	HueStream_StopSession_FullMethodName  = "/huestream.v1.HueStream/StopSession"
+)
+
+// HueStreamClient is the client API for HueStream service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type HueStreamClient interface {
+	// ListAreas lists the entertainment areas of the bridge.
+	ListAreas(ctx context.Context, in *ListAreasRequest, opts ...grpc.CallOption) (*ListAreasResponse, error)
+	// StartSession starts streaming to an area. The stream lasts until
+	// StopSession, or until a StreamFrames call of the session breaks.
+	StartSession(ctx context.Context, in *StartSessionRequest, opts ...grpc.CallOption) (*StartSessionResponse, error)
+	// StreamFrames sends the frames of the client to a session. The first
+	// request names the session, the later ones may leave it empty.
+	StreamFrames(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[StreamFramesRequest, StreamFramesResponse], error)
+	// StopSession stops the stream of a session.
+	StopSession(ctx context.Context, in *StopSessionRequest, opts ...grpc.CallOption) (*StopSessionResponse, error)
+}
+
+type hueStreamClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHueStreamClient(cc grpc.ClientConnInterface) HueStreamClient {
+	return &hueStreamClient{cc}
+}
+
+func (c *hueStreamClient) ListAreas(ctx context.Context, in *ListAreasRequest, opts ...grpc.CallOption) (*ListAreasResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListAreasResponse)
+	err := c.cc.Invoke(ctx, HueStream_ListAreas_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hueStreamClient) StartSession(ctx context.Context, in *StartSessionRequest, opts ...grpc.CallOption) (*StartSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartSessionResponse)
+	err := c.cc.Invoke(ctx, HueStream_StartSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hueStreamClient) StreamFrames(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[StreamFramesRequest, StreamFramesResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &HueStream_ServiceDesc.Streams[0], HueStream_StreamFrames_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamFramesRequest, StreamFramesResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type HueStream_StreamFramesClient = grpc.ClientStreamingClient[StreamFramesRequest, StreamFramesResponse]
+
+func (c *hueStreamClient) StopSession(ctx context.Context, in *StopSessionRequest, opts ...grpc.CallOption) (*StopSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StopSessionResponse)
+	err := c.cc.Invoke(ctx, HueStream_StopSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HueStreamServer is the server API for HueStream service.
+// All implementations must embed UnimplementedHueStreamServer
+// for forward compatibility.
+type HueStreamServer interface {
+	// ListAreas lists the entertainment areas of the bridge.
+	ListAreas(context.Context, *ListAreasRequest) (*ListAreasResponse, error)
+	// StartSession starts streaming to an area. The stream lasts until
+	// StopSession, or until a StreamFrames call of the session breaks.
+	StartSession(context.Context, *StartSessionRequest) (*StartSessionResponse, error)
+	// StreamFrames sends the frames of the client to a session. The first
+	// request names the session, the later ones may leave it empty.
+	StreamFrames(grpc.ClientStreamingServer[StreamFramesRequest, StreamFramesResponse]) error
+	// StopSession stops the stream of a session.
+	StopSession(context.Context, *StopSessionRequest) (*StopSessionResponse, error)
+	mustEmbedUnimplementedHueStreamServer()
+}
+
+// UnimplementedHueStreamServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedHueStreamServer struct{}
+
+func (UnimplementedHueStreamServer) ListAreas(context.Context, *ListAreasRequest) (*ListAreasResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAreas not implemented")
+}
+func (UnimplementedHueStreamServer) StartSession(context.Context, *StartSessionRequest) (*StartSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartSession not implemented")
+}
+func (UnimplementedHueStreamServer) StreamFrames(grpc.ClientStreamingServer[StreamFramesRequest, StreamFramesResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamFrames not implemented")
+}
+func (UnimplementedHueStreamServer) StopSession(context.Context, *StopSessionRequest) (*StopSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopSession not implemented")
+}
+func (UnimplementedHueStreamServer) mustEmbedUnimplementedHueStreamServer() {}
+func (UnimplementedHueStreamServer) testEmbeddedByValue()                   {}
+
+// UnsafeHueStreamServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HueStreamServer will
+// result in compilation errors.
+type UnsafeHueStreamServer interface {
+	mustEmbedUnimplementedHueStreamServer()
+}
+
+func RegisterHueStreamServer(s grpc.ServiceRegistrar, srv HueStreamServer) {
+	// If the following call pancis, it indicates UnimplementedHueStreamServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&HueStream_ServiceDesc, srv)
+}
+
+func _HueStream_ListAreas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAreasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HueStreamServer).ListAreas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HueStream_ListAreas_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HueStreamServer).ListAreas(ctx, req.(*ListAreasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HueStream_StartSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HueStreamServer).StartSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HueStream_StartSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HueStreamServer).StartSession(ctx, req.(*StartSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HueStream_StreamFrames_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HueStreamServer).StreamFrames(&grpc.GenericServerStream[StreamFramesRequest, StreamFramesResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type HueStream_StreamFramesServer = grpc.ClientStreamingServer[StreamFramesRequest, StreamFramesResponse]
+
+func _HueStream_StopSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HueStreamServer).StopSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HueStream_StopSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HueStreamServer).StopSession(ctx, req.(*StopSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// HueStream_ServiceDesc is the grpc.ServiceDesc for HueStream service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var HueStream_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "huestream.v1.HueStream",
+	HandlerType: (*HueStreamServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListAreas",
+			Handler:    _HueStream_ListAreas_Handler,
+		},
+		{
+			MethodName: "StartSession",
+			Handler:    _HueStream_StartSession_Handler,
+		},
+		{
+			MethodName: "StopSession",
+			Handler:    _HueStream_StopSession_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamFrames",
+			Handler:       _HueStream_StreamFrames_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "huestream.proto",
+}
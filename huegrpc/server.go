@@ -0,0 +1,278 @@
+// Package huegrpc serves the HueStream gRPC service, defined in
+// huestreampb/huestream.proto, streaming the frames of the clients to the
+// entertainment areas of a bridge:
+//
+//	s := huegrpc.NewServer(host, username, clientKey)
+//	defer s.Close()
+//	g := grpc.NewServer()
+//	huestreampb.RegisterHueStreamServer(g, s)
+//	g.Serve(ln)
+//
+// A session is a Stream started by StartSession, resending its last frame
+// with keepalive, and stopped by StopSession or when a StreamFrames call of
+// the session breaks. A StreamFrames call closed by the client keeps the
+// session, so a client can send its frames in several calls.
+//
+// It is a separate module so that huestream does not depend on gRPC.
+package huegrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huegrpc/huestreampb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultKeepAlive is the keepalive interval of the sessions not setting
+// one.
+const defaultKeepAlive = time.Second
+
+// Server implements the HueStream service for the bridge at host.
+type Server struct {
+	huestreampb.UnimplementedHueStreamServer
+
+	host, username, clientKey string
+	opts                      []huestream.Option
+
+	mu       sync.Mutex // Guards the fields below.
+	sessions map[string]*session
+	closed   bool
+}
+
+type session struct {
+	area   huestream.Area
+	stream *huestream.Stream
+}
+
+// NewServer returns a Server streaming to the bridge at host. opts are
+// passed to huestream.Start and huestream.Areas.
+func NewServer(host, username, clientKey string, opts ...huestream.Option) *Server {
+	return &Server{
+		host:      host,
+		username:  username,
+		clientKey: clientKey,
+		opts:      opts,
+		sessions:  make(map[string]*session),
+	}
+}
+
+// Close stops the sessions, the later ones fail to start.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*session)
+	s.closed = true
+	s.mu.Unlock()
+
+	var errs []error
+	for _, sess := range sessions {
+		errs = append(errs, sess.stream.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) ListAreas(ctx context.Context, req *huestreampb.ListAreasRequest) (*huestreampb.ListAreasResponse, error) {
+	areas, err := huestream.Areas(ctx, s.host, s.username, s.opts...)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &huestreampb.ListAreasResponse{}
+	for _, a := range areas {
+		resp.Areas = append(resp.Areas, areaProto(a))
+	}
+	return resp, nil
+}
+
+func (s *Server) StartSession(ctx context.Context, req *huestreampb.StartSessionRequest) (*huestreampb.StartSessionResponse, error) {
+	keepAlive := defaultKeepAlive
+	if req.KeepAlive != nil {
+		if err := req.KeepAlive.CheckValid(); err != nil || req.KeepAlive.AsDuration() <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid keep_alive %v", req.KeepAlive)
+		}
+		keepAlive = req.KeepAlive.AsDuration()
+	}
+	if err := s.checkStart(req.AreaId); err != nil {
+		return nil, err
+	}
+
+	areas, err := huestream.Areas(ctx, s.host, s.username, s.opts...)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	sess := new(session)
+	for _, a := range areas {
+		if a.ID == req.AreaId {
+			sess.area = a
+		}
+	}
+	if sess.area.ID == "" {
+		return nil, status.Errorf(codes.NotFound, "area %q not found", req.AreaId)
+	}
+	opts := append([]huestream.Option{huestream.WithKeepAlive(keepAlive)}, s.opts...)
+	sess.stream, err = huestream.Start(ctx, s.host, s.username, s.clientKey, req.AreaId, opts...)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	id := newSessionID()
+	s.mu.Lock()
+	err = s.checkStartLocked(req.AreaId)
+	if err == nil {
+		s.sessions[id] = sess
+	}
+	s.mu.Unlock()
+	if err != nil {
+		// Closed, or the area started concurrently.
+		sess.stream.Close()
+		return nil, err
+	}
+	return &huestreampb.StartSessionResponse{SessionId: id, Area: areaProto(sess.area)}, nil
+}
+
+func (s *Server) StreamFrames(stream huestreampb.HueStream_StreamFramesServer) error {
+	var (
+		id   string
+		sess *session
+		sent uint64
+	)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&huestreampb.StreamFramesResponse{FramesSent: sent})
+		}
+		if err != nil {
+			// The call broke, the client won't stop the session.
+			if sess != nil {
+				s.stop(id)
+			}
+			return err
+		}
+		if sess == nil {
+			id = req.SessionId
+			if sess = s.session(id); sess == nil {
+				return status.Errorf(codes.NotFound, "session %q not found", id)
+			}
+		}
+		if req.Frame == nil {
+			continue
+		}
+		if err := sess.stream.SendContext(stream.Context(), frame(req.Frame)); err != nil {
+			if stream.Context().Err() != nil {
+				s.stop(id)
+			}
+			return toStatus(err)
+		}
+		sent++
+	}
+}
+
+func (s *Server) StopSession(ctx context.Context, req *huestreampb.StopSessionRequest) (*huestreampb.StopSessionResponse, error) {
+	found, err := s.stop(req.SessionId)
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "session %q not found", req.SessionId)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &huestreampb.StopSessionResponse{}, nil
+}
+
+func (s *Server) session(id string) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id]
+}
+
+// stop stops the session id, it reports whether it was found.
+func (s *Server) stop(id string) (found bool, err error) {
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, sess.stream.Close()
+}
+
+// checkStart returns an error if a session of the area can't start.
+func (s *Server) checkStart(areaID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkStartLocked(areaID)
+}
+
+func (s *Server) checkStartLocked(areaID string) error {
+	if s.closed {
+		return errClosed
+	}
+	for _, sess := range s.sessions {
+		if sess.area.ID == areaID {
+			return status.Errorf(codes.AlreadyExists, "area %q already has a session", areaID)
+		}
+	}
+	return nil
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func areaProto(a huestream.Area) *huestreampb.Area {
+	p := &huestreampb.Area{Id: a.ID, Name: a.Name, Type: a.Type, Status: a.Status}
+	for _, ch := range a.Channels {
+		p.Channels = append(p.Channels, &huestreampb.Channel{
+			Id: uint32(ch.ID), X: ch.Position.X, Y: ch.Position.Y, Z: ch.Position.Z, Lights: ch.Lights,
+		})
+	}
+	return p
+}
+
+// frame converts f, the components are clamped to 16 bits.
+func frame(f *huestreampb.Frame) huestream.Frame {
+	out := make(huestream.Frame, len(f.Channels))
+	for _, ch := range f.Channels {
+		out[int(ch.Channel)] = color.RGBA64{
+			R: uint16(min(ch.R, 0xffff)),
+			G: uint16(min(ch.G, 0xffff)),
+			B: uint16(min(ch.B, 0xffff)),
+			A: 0xffff,
+		}
+	}
+	return out
+}
+
+var errClosed = status.Error(codes.Unavailable, "server closed")
+
+// toStatus converts an error of huestream to a gRPC status.
+func toStatus(err error) error {
+	var timeout *huestream.TimeoutError
+	code := codes.Unavailable
+	switch {
+	case errors.Is(err, huestream.ErrAreaNotFound):
+		code = codes.NotFound
+	case errors.Is(err, huestream.ErrStreamActive):
+		code = codes.AlreadyExists
+	case errors.Is(err, huestream.ErrTooManyChannels):
+		code = codes.InvalidArgument
+	case errors.Is(err, huestream.ErrUnauthorized):
+		code = codes.PermissionDenied
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.As(err, &timeout), errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, fmt.Sprint(err))
+}
@@ -0,0 +1,163 @@
+package huegrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/huegrpc/huestreampb"
+	"github.com/rschio/huestream/huetest"
+	"github.com/rschio/huestream/wire"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newClient(t *testing.T) (*huetest.Bridge, huestreampb.HueStreamClient) {
+	t.Helper()
+
+	b := huetest.NewBridge(t)
+	s := NewServer(b.Host, b.Username, b.ClientKey, b.Options()...)
+	ln := bufconn.Listen(1 << 16)
+	g := grpc.NewServer()
+	huestreampb.RegisterHueStreamServer(g, s)
+	go g.Serve(ln)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		g.Stop()
+		s.Close()
+	})
+	return b, huestreampb.NewHueStreamClient(conn)
+}
+
+func nextFrame(t *testing.T, b *huetest.Bridge) wire.Frame {
+	t.Helper()
+
+	select {
+	case f := <-b.Frames():
+		return f
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame received")
+		return wire.Frame{}
+	}
+}
+
+func TestSession(t *testing.T) {
+	b, client := newClient(t)
+	ctx := context.Background()
+
+	areas, err := client.ListAreas(ctx, &huestreampb.ListAreasRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(areas.Areas) != 1 || areas.Areas[0].Id != b.AreaID || len(areas.Areas[0].Channels) != huetest.Lights {
+		t.Fatalf("got areas %v", areas.Areas)
+	}
+
+	start, err := client.StartSession(ctx, &huestreampb.StartSessionRequest{AreaId: b.AreaID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err := client.StreamFrames(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := &huestreampb.Frame{Channels: []*huestreampb.ChannelColor{{Channel: 0, R: 0xffff}}}
+	blue := &huestreampb.Frame{Channels: []*huestreampb.ChannelColor{{Channel: 1, B: 0xffff}}}
+	if err := frames.Send(&huestreampb.StreamFramesRequest{SessionId: start.SessionId, Frame: red}); err != nil {
+		t.Fatal(err)
+	}
+	if err := frames.Send(&huestreampb.StreamFramesRequest{Frame: blue}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := frames.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.FramesSent != 2 {
+		t.Errorf("FramesSent = %d, want 2", resp.FramesSent)
+	}
+	if f := nextFrame(t, b); f.Channels[0].ID != 0 || f.Channels[0].Values != [3]uint16{0xffff, 0, 0} {
+		t.Errorf("first frame: got %+v", f.Channels)
+	}
+	if !b.Active() {
+		t.Error("session stopped by the end of StreamFrames")
+	}
+
+	if _, err := client.StopSession(ctx, &huestreampb.StopSessionRequest{SessionId: start.SessionId}); err != nil {
+		t.Fatal(err)
+	}
+	if b.Active() {
+		t.Error("stream not stopped")
+	}
+	_, err = client.StopSession(ctx, &huestreampb.StopSessionRequest{SessionId: start.SessionId})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("second StopSession: got %v, want NotFound", err)
+	}
+}
+
+func TestBrokenStreamStopsSession(t *testing.T) {
+	b, client := newClient(t)
+
+	start, err := client.StartSession(context.Background(), &huestreampb.StartSessionRequest{AreaId: b.AreaID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	frames, err := client.StreamFrames(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := &huestreampb.Frame{Channels: []*huestreampb.ChannelColor{{Channel: 0, R: 0xffff}}}
+	if err := frames.Send(&huestreampb.StreamFramesRequest{SessionId: start.SessionId, Frame: red}); err != nil {
+		t.Fatal(err)
+	}
+	nextFrame(t, b)
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Active() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if b.Active() {
+		t.Error("session not stopped when StreamFrames broke")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	b, client := newClient(t)
+	ctx := context.Background()
+
+	_, err := client.StartSession(ctx, &huestreampb.StartSessionRequest{AreaId: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("unknown area: got %v, want NotFound", err)
+	}
+
+	frames, err := client.StreamFrames(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames.Send(&huestreampb.StreamFramesRequest{SessionId: "missing"})
+	if _, err := frames.CloseAndRecv(); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown session: got %v, want NotFound", err)
+	}
+
+	if _, err := client.StartSession(ctx, &huestreampb.StartSessionRequest{AreaId: b.AreaID}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.StartSession(ctx, &huestreampb.StartSessionRequest{AreaId: b.AreaID})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("second session of the area: got %v, want AlreadyExists", err)
+	}
+}
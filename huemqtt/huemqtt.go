@@ -0,0 +1,413 @@
+// Package huemqtt controls the entertainment areas of a bridge over MQTT:
+//
+//	a := huemqtt.New(host, username, clientKey, huemqtt.WithBroker("tcp://localhost:1883"))
+//	err := a.Run(ctx)
+//
+// The Adapter subscribes to the topics, under the huestream prefix by
+// default:
+//
+//	huestream/<area>/set     a frame: {"0":"#ff0000","all":"#000000","transition":2}
+//	huestream/<area>/effect  an effect: {"name":"candle","color":"#ff8800","duration":"10s"}
+//
+// and publishes, retained:
+//
+//	huestream/<area>/state   {"status":"streaming","frames_sent":42}
+//	huestream/status         "online", or "offline" when the Adapter stops
+//	                         or, as last will, loses the broker
+//
+// The payloads are the frame documents and effect commands of the jsonframe
+// package. A frame sets the channels of its IDs, and "all" the others of
+// the area; with a transition they fade from the colors set before.
+// The effects are sparkle, candle and lightning of the effects package,
+// "none" stops the effect playing; they play until the duration elapses,
+// the next message or the end of the stream.
+//
+// The stream of an area starts with its first message and stops after the
+// idle timeout without messages. A failed stream is closed, the next
+// message starts a new one; the Adapter reconnects to the broker on its
+// own.
+//
+// It is a separate module so that huestream does not depend on an MQTT
+// client.
+package huemqtt
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"iter"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/effects"
+	"github.com/rschio/huestream/jsonframe"
+)
+
+// effectRate is the rate of the effects, in Hz.
+const effectRate = 25
+
+// Option configures an Adapter.
+type Option func(*Adapter)
+
+// WithBroker sets the URL of the broker, by default tcp://localhost:1883.
+func WithBroker(url string) Option {
+	return func(a *Adapter) { a.broker = url }
+}
+
+// WithClientID sets the MQTT client ID, by default huestream.
+func WithClientID(id string) Option {
+	return func(a *Adapter) { a.clientID = id }
+}
+
+// WithCredentials sets the credentials of the broker.
+func WithCredentials(username, password string) Option {
+	return func(a *Adapter) { a.mqttUser, a.mqttPassword = username, password }
+}
+
+// WithPrefix sets the prefix of the topics, by default huestream.
+func WithPrefix(prefix string) Option {
+	return func(a *Adapter) { a.prefix = strings.TrimSuffix(prefix, "/") }
+}
+
+// WithIdleTimeout sets the time without messages after which the stream of
+// an area stops, by default 30s.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(a *Adapter) { a.idle = d }
+}
+
+// WithStateInterval sets the interval of the state publications of the
+// streaming areas, by default 10s. The state is also published when the
+// stream starts, stops or fails.
+func WithStateInterval(d time.Duration) Option {
+	return func(a *Adapter) { a.stateEvery = d }
+}
+
+// WithStreamOptions sets the options passed to huestream.Start and
+// huestream.Areas.
+func WithStreamOptions(opts ...huestream.Option) Option {
+	return func(a *Adapter) { a.streamOpts = opts }
+}
+
+// Adapter relays the MQTT messages to the areas of a bridge.
+type Adapter struct {
+	host, username, clientKey string
+
+	broker, clientID       string
+	mqttUser, mqttPassword string
+	prefix                 string
+	idle, stateEvery       time.Duration
+	streamOpts             []huestream.Option
+
+	client mqtt.Client
+
+	mu      sync.Mutex // Guards the fields below.
+	streams map[string]*areaStream
+	stopped bool
+}
+
+// areaStream is the stream of an area.
+type areaStream struct {
+	area   huestream.Area
+	stream *huestream.Stream
+	idle   *time.Timer
+
+	mu     sync.Mutex         // Guards the fields below.
+	frame  huestream.Frame    // The colors set, the start of the transitions.
+	cancel context.CancelFunc // Stops the effect playing, if any.
+	done   chan struct{}      // Closed when the effect returns.
+}
+
+// State is the payload of the state topics.
+type State struct {
+	Status     string `json:"status"` // "streaming", "idle" or "error".
+	FramesSent uint64 `json:"frames_sent,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// New returns an Adapter controlling the bridge at host.
+func New(host, username, clientKey string, opts ...Option) *Adapter {
+	a := &Adapter{
+		host:       host,
+		username:   username,
+		clientKey:  clientKey,
+		broker:     "tcp://localhost:1883",
+		clientID:   "huestream",
+		prefix:     "huestream",
+		idle:       30 * time.Second,
+		stateEvery: 10 * time.Second,
+		streams:    make(map[string]*areaStream),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Run connects to the broker and serves the messages until ctx is done,
+// then stops the streams and publishes the offline status.
+func (a *Adapter) Run(ctx context.Context) error {
+	status := a.prefix + "/status"
+	opts := mqtt.NewClientOptions().
+		AddBroker(a.broker).
+		SetClientID(a.clientID).
+		SetUsername(a.mqttUser).
+		SetPassword(a.mqttPassword).
+		SetWill(status, "offline", 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			// The subscriptions are lost with the session, renew them on
+			// every connection.
+			c.Subscribe(a.prefix+"/+/set", 1, a.handle)
+			c.Subscribe(a.prefix+"/+/effect", 1, a.handle)
+			c.Publish(status, 1, true, "online")
+		})
+	a.client = mqtt.NewClient(opts)
+
+	if err := wait(ctx, a.client.Connect()); err != nil {
+		a.client.Disconnect(0)
+		return fmt.Errorf("huemqtt: connect: %w", err)
+	}
+
+	tick := time.NewTicker(a.stateEvery)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			a.stop()
+			wait(context.Background(), a.client.Publish(status, 1, true, "offline"))
+			a.client.Disconnect(250)
+			return nil
+		case <-tick.C:
+			a.mu.Lock()
+			for _, as := range a.streams {
+				a.publishState(as.area.ID, State{Status: "streaming", FramesSent: as.stream.Stats().FramesSent})
+			}
+			a.mu.Unlock()
+		}
+	}
+}
+
+// wait waits for t, at most until ctx is done.
+func wait(ctx context.Context, t mqtt.Token) error {
+	select {
+	case <-t.Done():
+		return t.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop stops the streams.
+func (a *Adapter) stop() {
+	a.mu.Lock()
+	streams := a.streams
+	a.streams = make(map[string]*areaStream)
+	a.stopped = true
+	a.mu.Unlock()
+
+	for id, as := range streams {
+		as.close()
+		a.publishState(id, State{Status: "idle"})
+	}
+}
+
+func (a *Adapter) handle(_ mqtt.Client, msg mqtt.Message) {
+	parts := strings.Split(strings.TrimPrefix(msg.Topic(), a.prefix+"/"), "/")
+	if len(parts) != 2 {
+		return
+	}
+	id, kind := parts[0], parts[1]
+
+	as, err := a.areaStream(id)
+	if err != nil {
+		a.publishState(id, State{Status: "error", Error: err.Error()})
+		return
+	}
+	switch kind {
+	case "set":
+		err = a.set(as, msg.Payload())
+	case "effect":
+		err = a.effect(as, msg.Payload())
+	}
+	if err == nil {
+		return
+	}
+	a.publishState(id, State{Status: "error", Error: err.Error()})
+
+	// A failed stream is closed so that the next message starts a new one,
+	// the invalid messages are only reported.
+	if errors.Is(err, errInvalid) {
+		return
+	}
+	a.mu.Lock()
+	if a.streams[id] == as {
+		delete(a.streams, id)
+	}
+	a.mu.Unlock()
+	as.close()
+}
+
+// areaStream returns the stream of the area id, started if needed, and
+// postpones its idle timeout.
+func (a *Adapter) areaStream(id string) (*areaStream, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.stopped {
+		return nil, errors.New("adapter stopped")
+	}
+	if as, ok := a.streams[id]; ok {
+		as.idle.Reset(a.idle)
+		return as, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	areas, err := huestream.Areas(ctx, a.host, a.username, a.streamOpts...)
+	if err != nil {
+		return nil, err
+	}
+	as := &areaStream{frame: make(huestream.Frame)}
+	for _, area := range areas {
+		if area.ID == id {
+			as.area = area
+		}
+	}
+	if as.area.ID == "" {
+		return nil, fmt.Errorf("area %s: %w", id, huestream.ErrAreaNotFound)
+	}
+	opts := append([]huestream.Option{
+		huestream.WithKeepAlive(time.Second),
+		huestream.WithRecovery(10 * time.Second),
+	}, a.streamOpts...)
+	as.stream, err = huestream.Start(ctx, a.host, a.username, a.clientKey, id, opts...)
+	if err != nil {
+		return nil, err
+	}
+	as.idle = time.AfterFunc(a.idle, func() { a.expire(id, as) })
+	a.streams[id] = as
+	a.publishState(id, State{Status: "streaming"})
+	return as, nil
+}
+
+// expire stops the stream of the area id after the idle timeout.
+func (a *Adapter) expire(id string, as *areaStream) {
+	a.mu.Lock()
+	ok := a.streams[id] == as
+	if ok {
+		delete(a.streams, id)
+	}
+	a.mu.Unlock()
+
+	if ok {
+		as.close()
+		a.publishState(id, State{Status: "idle", FramesSent: as.stream.Stats().FramesSent})
+	}
+}
+
+func (a *Adapter) set(as *areaStream, payload []byte) error {
+	doc, err := jsonframe.DecodeFrame(payload, channelIDs(as.area))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalid, err)
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	as.stopEffectLocked()
+	from := maps.Clone(as.frame)
+	maps.Copy(as.frame, doc.Colors)
+	if doc.Transition > 0 {
+		as.playLocked(jsonframe.Fade(from, maps.Clone(as.frame), doc.Transition, effectRate), 0)
+		return nil
+	}
+	return as.stream.Send(doc.Colors)
+}
+
+func (a *Adapter) effect(as *areaStream, payload []byte) error {
+	e, err := jsonframe.DecodeEffect(payload)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalid, err)
+	}
+	c := cmp.Or[color.Color](e.Color, color.White)
+
+	ids := channelIDs(as.area)
+	var frames iter.Seq[huestream.Frame]
+	switch e.Name {
+	case "none":
+	case "sparkle":
+		frames = effects.Sparkle(ids, c, 0.05)
+	case "candle":
+		frames = effects.Candle(ids)
+	case "lightning":
+		frames = effects.Lightning(ids, 0.02)
+	default:
+		return fmt.Errorf("%w: effect %q", errInvalid, e.Name)
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	as.stopEffectLocked()
+	if frames != nil {
+		as.playLocked(frames, e.Duration)
+	}
+	return nil
+}
+
+// playLocked plays frames on a goroutine, for d if d is not zero. It must
+// be called with mu held and no effect playing.
+func (as *areaStream) playLocked(frames iter.Seq[huestream.Frame], d time.Duration) {
+	var ctx context.Context
+	if d > 0 {
+		ctx, as.cancel = context.WithTimeout(context.Background(), d)
+	} else {
+		ctx, as.cancel = context.WithCancel(context.Background())
+	}
+	done := make(chan struct{})
+	as.done = done
+	go func() {
+		defer close(done)
+		as.stream.PlaySeq(ctx, frames, effectRate)
+	}()
+}
+
+// stopEffectLocked stops the effect playing, if any, and waits for it. It
+// must be called with mu held, the effect does not lock it.
+func (as *areaStream) stopEffectLocked() {
+	if as.cancel != nil {
+		as.cancel()
+		<-as.done
+		as.cancel, as.done = nil, nil
+	}
+}
+
+func (as *areaStream) close() {
+	as.idle.Stop()
+	as.mu.Lock()
+	as.stopEffectLocked()
+	as.mu.Unlock()
+	as.stream.Close()
+}
+
+func (a *Adapter) publishState(id string, s State) {
+	b, _ := json.Marshal(s)
+	a.client.Publish(a.prefix+"/"+id+"/state", 1, true, b)
+}
+
+// errInvalid is wrapped by the errors of the invalid messages.
+var errInvalid = errors.New("invalid message")
+
+func channelIDs(a huestream.Area) []int {
+	ids := make([]int, len(a.Channels))
+	for i, ch := range a.Channels {
+		ids[i] = ch.ID
+	}
+	return ids
+}
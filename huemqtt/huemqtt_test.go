@@ -0,0 +1,160 @@
+package huemqtt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/rschio/huestream/huetest"
+	"github.com/rschio/huestream/wire"
+)
+
+// newBroker starts an MQTT broker and returns its URL.
+func newBroker(t *testing.T) string {
+	t.Helper()
+
+	srv := mochi.New(&mochi.Options{})
+	if err := srv.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	if err := srv.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return "tcp://" + tcp.Address()
+}
+
+// newTestClient connects a client subscribed to every topic, the messages
+// are received on the returned channel.
+func newTestClient(t *testing.T, broker string) (mqtt.Client, <-chan mqtt.Message) {
+	t.Helper()
+
+	msgs := make(chan mqtt.Message, 100)
+	c := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker).SetClientID("test"))
+	if tok := c.Connect(); tok.Wait() && tok.Error() != nil {
+		t.Fatal(tok.Error())
+	}
+	if tok := c.Subscribe("#", 1, func(_ mqtt.Client, m mqtt.Message) {
+		select {
+		case msgs <- m:
+		default: // The test does not read it.
+		}
+	}); tok.Wait() && tok.Error() != nil {
+		t.Fatal(tok.Error())
+	}
+	t.Cleanup(func() { c.Disconnect(0) })
+	return c, msgs
+}
+
+// waitMessage returns the first message of topic satisfying ok.
+func waitMessage(t *testing.T, msgs <-chan mqtt.Message, topic string, ok func(payload []byte) bool) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m := <-msgs:
+			if m.Topic() == topic && ok(m.Payload()) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no message on %s", topic)
+		}
+	}
+}
+
+func state(want string) func([]byte) bool {
+	return func(b []byte) bool {
+		var s State
+		return json.Unmarshal(b, &s) == nil && s.Status == want
+	}
+}
+
+func nextFrame(t *testing.T, b *huetest.Bridge) wire.Frame {
+	t.Helper()
+
+	select {
+	case f := <-b.Frames():
+		return f
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame received")
+		return wire.Frame{}
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	broker := newBroker(t)
+	b := huetest.NewBridge(t)
+	client, msgs := newTestClient(t, broker)
+
+	a := New(b.Host, b.Username, b.ClientKey,
+		WithBroker(broker),
+		WithIdleTimeout(300*time.Millisecond),
+		WithStreamOptions(b.Options()...),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- a.Run(ctx) }()
+
+	waitMessage(t, msgs, "huestream/status", func(p []byte) bool { return string(p) == "online" })
+
+	client.Publish("huestream/"+b.AreaID+"/set", 1, false, `{"all":"#ff0000"}`)
+	waitMessage(t, msgs, "huestream/"+b.AreaID+"/state", state("streaming"))
+	f := nextFrame(t, b)
+	if len(f.Channels) != huetest.Lights || f.Channels[0].Values != [3]uint16{0xffff, 0, 0} {
+		t.Errorf("got %+v, want every channel red", f.Channels)
+	}
+
+	// A Home Assistant hs_color fading from red to blue.
+	client.Publish("huestream/"+b.AreaID+"/set", 1, false, `{"all":[240,100],"transition":0.2}`)
+	for f = nextFrame(t, b); f.Channels[0].Values != [3]uint16{0, 0, 0xffff}; f = nextFrame(t, b) {
+		if v := f.Channels[0].Values; v[1] != 0 {
+			t.Fatalf("got %v, want a fade from red to blue", v)
+		}
+	}
+
+	client.Publish("huestream/"+b.AreaID+"/set", 1, false, `{"0":"reddish"}`)
+	waitMessage(t, msgs, "huestream/"+b.AreaID+"/state", state("error"))
+
+	// Without messages the stream stops after the idle timeout.
+	waitMessage(t, msgs, "huestream/"+b.AreaID+"/state", state("idle"))
+	if b.Active() {
+		t.Error("stream not stopped after the idle timeout")
+	}
+
+	client.Publish("huestream/"+b.AreaID+"/effect", 1, false, `{"name":"candle"}`)
+	waitMessage(t, msgs, "huestream/"+b.AreaID+"/state", state("streaming"))
+
+	cancel()
+	waitMessage(t, msgs, "huestream/status", func(p []byte) bool { return string(p) == "offline" })
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if b.Active() {
+		t.Error("stream not stopped by the end of Run")
+	}
+}
+
+func TestUnknownArea(t *testing.T) {
+	broker := newBroker(t)
+	b := huetest.NewBridge(t)
+	client, msgs := newTestClient(t, broker)
+
+	a := New(b.Host, b.Username, b.ClientKey, WithBroker(broker), WithStreamOptions(b.Options()...))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Run(ctx)
+
+	waitMessage(t, msgs, "huestream/status", func(p []byte) bool { return string(p) == "online" })
+	client.Publish("huestream/missing/set", 1, false, `{"all":"#ff0000"}`)
+	waitMessage(t, msgs, "huestream/missing/state", state("error"))
+}
@@ -0,0 +1,175 @@
+// Package huesim renders frames in a terminal, to develop effects without a
+// Hue Bridge.
+//
+// A Terminal is a huestream.Streamer, so it can replace a Stream in an
+// application or drive a preview command. Every frame is drawn
+// with 24-bit ANSI colors, over the previous one.
+package huesim
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image/color"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/wire"
+)
+
+// Position is the position of a channel in the entertainment area, as set
+// in the Hue app: x goes from left (-1) to right (1), y from back (-1) to
+// front (1).
+type Position struct {
+	X, Y float64
+}
+
+var _ huestream.Streamer = (*Terminal)(nil)
+
+// Option configures a Terminal.
+type Option func(*Terminal)
+
+// WithPositions lays the channels out by position instead of in a row
+// ordered by ID. Channels without a position are not drawn.
+func WithPositions(p map[int]Position) Option {
+	return func(t *Terminal) { t.positions = p }
+}
+
+// WithSize sets the size of the drawing in terminal cells, by default 40
+// columns and 10 rows. It is only used with WithPositions.
+func WithSize(cols, rows int) Option {
+	return func(t *Terminal) { t.cols, t.rows = max(cols, 2), max(rows, 1) }
+}
+
+// Terminal draws frames to a terminal.
+type Terminal struct {
+	positions  map[int]Position
+	cols, rows int
+
+	mu     sync.Mutex // Guards the fields below.
+	w      *bufio.Writer
+	drawn  int // Lines of the last drawing, overwritten by the next one.
+	closed bool
+}
+
+// New returns a Terminal drawing to w, os.Stdout if w is nil.
+func New(w io.Writer, opts ...Option) *Terminal {
+	if w == nil {
+		w = os.Stdout
+	}
+	t := &Terminal{w: bufio.NewWriter(w), cols: 40, rows: 10}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Send draws idColors, rejecting the frames a Stream would reject.
+func (t *Terminal) Send(idColors huestream.Frame) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return huestream.ErrClosed
+	}
+	if len(idColors) > wire.MaxChannels {
+		return fmt.Errorf("%w: maximum is %d, got %d", huestream.ErrTooManyChannels, wire.MaxChannels, len(idColors))
+	}
+
+	// Go back to the first line of the previous drawing.
+	if t.drawn > 0 {
+		fmt.Fprintf(t.w, "\x1b[%dA\r", t.drawn)
+	}
+	if t.positions != nil {
+		t.drawn = t.drawArea(idColors)
+	} else {
+		t.drawn = t.drawRow(idColors)
+	}
+
+	return t.w.Flush()
+}
+
+// SendContext is like Send, it fails if ctx is done.
+func (t *Terminal) SendContext(ctx context.Context, idColors huestream.Frame) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return t.Send(idColors)
+}
+
+// Close resets the terminal colors, later sends fail with ErrClosed.
+func (t *Terminal) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	t.w.WriteString(reset)
+	return t.w.Flush()
+}
+
+const reset = "\x1b[0m"
+
+// drawRow draws the channels in a row ordered by ID, with their IDs below,
+// and returns the number of lines written.
+func (t *Terminal) drawRow(idColors huestream.Frame) int {
+	ids := slices.Sorted(maps.Keys(idColors))
+	for _, id := range ids {
+		cell(t.w, idColors[id])
+		t.w.WriteString("  ")
+	}
+	t.w.WriteString("\x1b[K\n") // Erase what is left of a longer drawing.
+	for _, id := range ids {
+		fmt.Fprintf(t.w, "%-5d", id)
+	}
+	t.w.WriteString("\x1b[K\n")
+	return 2
+}
+
+// drawArea draws the channels at their positions, seen from above with the
+// front at the bottom, and returns the number of lines written.
+func (t *Terminal) drawArea(idColors huestream.Frame) int {
+	grid := make([][]color.Color, t.rows)
+	for i := range grid {
+		grid[i] = make([]color.Color, t.cols/2)
+	}
+	for id, c := range idColors {
+		p, ok := t.positions[id]
+		if !ok {
+			continue
+		}
+		col := scale(p.X, len(grid[0]))
+		row := scale(p.Y, t.rows)
+		grid[row][col] = c
+	}
+
+	for _, line := range grid {
+		for _, c := range line {
+			if c == nil {
+				t.w.WriteString("  ")
+				continue
+			}
+			cell(t.w, c)
+		}
+		t.w.WriteString("\x1b[K\n")
+	}
+	return t.rows
+}
+
+// scale maps v, from -1 to 1, to an index of a dimension of size n.
+func scale(v float64, n int) int {
+	i := int((v + 1) / 2 * float64(n-1))
+	return min(max(i, 0), n-1)
+}
+
+// cell writes a cell of two columns, about square, of color c.
+func cell(w *bufio.Writer, c color.Color) {
+	r, g, b, _ := c.RGBA()
+	fmt.Fprintf(w, "\x1b[48;2;%d;%d;%dm  %s", r>>8, g>>8, b>>8, reset)
+}
@@ -0,0 +1,74 @@
+package huesim
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/rschio/huestream"
+)
+
+func TestSendRow(t *testing.T) {
+	var buf bytes.Buffer
+	term := New(&buf)
+
+	if err := term.Send(huestream.Frame{2: color.RGBA{R: 255, A: 255}, 1: color.White}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	white := strings.Index(out, "\x1b[48;2;255;255;255m")
+	red := strings.Index(out, "\x1b[48;2;255;0;0m")
+	if white < 0 || red < 0 || white > red {
+		t.Errorf("channels not drawn in ID order: %q", out)
+	}
+
+	buf.Reset()
+	if err := term.Send(huestream.Frame{1: color.Black}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "\x1b[2A\r") {
+		t.Errorf("second frame does not overwrite the first: %q", buf.String())
+	}
+}
+
+func TestSendPositions(t *testing.T) {
+	var buf bytes.Buffer
+	term := New(&buf, WithSize(4, 2), WithPositions(map[int]Position{
+		0: {X: -1, Y: -1},
+		1: {X: 1, Y: 1},
+	}))
+
+	err := term.Send(huestream.Frame{0: color.White, 1: color.RGBA{B: 255, A: 255}, 7: color.White})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "\x1b[48;2;255;255;255m") {
+		t.Errorf("channel 0 not at the top left: %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "  \x1b[48;2;0;0;255m") {
+		t.Errorf("channel 1 not at the bottom right: %q", lines[1])
+	}
+}
+
+func TestSendLimits(t *testing.T) {
+	term := New(&bytes.Buffer{})
+
+	f := make(huestream.Frame)
+	for i := range 21 {
+		f[i] = color.White
+	}
+	if err := term.Send(f); err == nil {
+		t.Error("should reject more than 20 channels")
+	}
+
+	term.Close()
+	if err := term.Send(nil); !errors.Is(err, huestream.ErrClosed) {
+		t.Errorf("Send after Close: %v, want ErrClosed", err)
+	}
+}
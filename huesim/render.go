@@ -0,0 +1,126 @@
+package huesim
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+// Render draws frames as images, each channel as a disc at its position on
+// a black background, seen from above with the front at the bottom.
+//
+// The output only depends on the frames and the settings, so effects can be
+// checked against golden images.
+type Render struct {
+	Width, Height int // The size of the images, by default 160x120.
+	Radius        int // The radius of the discs, by default 8.
+
+	// Delay is the time between two frames of a GIF, by default 20ms, the
+	// period of a 50Hz stream. GIFs have a resolution of 10ms.
+	Delay time.Duration
+
+	// Positions of the channels, channels without a position are not drawn.
+	// If nil, the channels of each frame are laid out in a row ordered by
+	// ID.
+	Positions map[int]Position
+}
+
+func (r Render) withDefaults() Render {
+	if r.Width <= 0 || r.Height <= 0 {
+		r.Width, r.Height = 160, 120
+	}
+	if r.Radius <= 0 {
+		r.Radius = 8
+	}
+	if r.Delay <= 0 {
+		r.Delay = 20 * time.Millisecond
+	}
+	return r
+}
+
+// Frame draws f. The palette of the image holds the background and the
+// exact colors of the channels.
+func (r Render) Frame(f huestream.Frame) *image.Paletted {
+	r = r.withDefaults()
+
+	ids := slices.Sorted(maps.Keys(f))
+	positions := r.Positions
+	if positions == nil {
+		positions = rowPositions(ids)
+	}
+
+	pal := color.Palette{color.Black}
+	img := image.NewPaletted(image.Rect(0, 0, r.Width, r.Height), pal)
+	for _, id := range ids {
+		p, ok := positions[id]
+		if !ok {
+			continue
+		}
+		c := color.NRGBA64Model.Convert(f[id]).(color.NRGBA64)
+		c.A = 0xffff // The bridge ignores the alpha.
+		i := slices.Index(img.Palette, color.Color(c))
+		if i < 0 {
+			img.Palette = append(img.Palette, c)
+			i = len(img.Palette) - 1
+		}
+		cx := scale(p.X, r.Width)
+		cy := scale(p.Y, r.Height)
+		disc(img, cx, cy, r.Radius, uint8(i))
+	}
+	return img
+}
+
+// GIF writes frames as an animated GIF, looping forever.
+func (r Render) GIF(w io.Writer, frames []huestream.Frame) error {
+	r = r.withDefaults()
+
+	anim := &gif.GIF{}
+	delay := int(r.Delay / (10 * time.Millisecond))
+	for _, f := range frames {
+		anim.Image = append(anim.Image, r.Frame(f))
+		anim.Delay = append(anim.Delay, delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// PNGStrip writes frames side by side, from left to right, as a PNG.
+func (r Render) PNGStrip(w io.Writer, frames []huestream.Frame) error {
+	r = r.withDefaults()
+
+	strip := image.NewRGBA(image.Rect(0, 0, r.Width*len(frames), r.Height))
+	for i, f := range frames {
+		at := image.Rect(i*r.Width, 0, (i+1)*r.Width, r.Height)
+		draw.Draw(strip, at, r.Frame(f), image.Point{}, draw.Src)
+	}
+	return png.Encode(w, strip)
+}
+
+// rowPositions lays ids out in a row, evenly spaced.
+func rowPositions(ids []int) map[int]Position {
+	positions := make(map[int]Position, len(ids))
+	for i, id := range ids {
+		positions[id] = Position{X: 2*(float64(i)+0.5)/float64(len(ids)) - 1}
+	}
+	return positions
+}
+
+// disc fills the disc of center (cx, cy) and radius r with the color of
+// index i of the palette.
+func disc(img *image.Paletted, cx, cy, r int, i uint8) {
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			dx, dy := x-cx, y-cy
+			if dx*dx+dy*dy <= r*r && image.Pt(x, y).In(img.Rect) {
+				img.SetColorIndex(x, y, i)
+			}
+		}
+	}
+}
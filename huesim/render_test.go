@@ -0,0 +1,83 @@
+package huesim
+
+import (
+	"bytes"
+	"flag"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rschio/huestream"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+var renderFrames = []huestream.Frame{
+	{0: color.RGBA{R: 255, A: 255}, 1: color.RGBA{G: 255, A: 255}},
+	{0: color.RGBA{G: 255, A: 255}, 1: color.RGBA{B: 255, A: 255}},
+	{0: color.White},
+}
+
+func TestRenderFrame(t *testing.T) {
+	r := Render{Width: 40, Height: 20, Radius: 3, Positions: map[int]Position{
+		0: {X: -1, Y: -1},
+		1: {X: 1, Y: 1},
+	}}
+	img := r.Frame(renderFrames[0])
+
+	tests := []struct {
+		x, y int
+		want color.Color
+	}{
+		{0, 0, color.RGBA{R: 255, A: 255}},
+		{39, 19, color.RGBA{G: 255, A: 255}},
+		{20, 10, color.RGBA{A: 255}},
+	}
+	for _, tt := range tests {
+		if got := color.RGBAModel.Convert(img.At(tt.x, tt.y)); got != tt.want {
+			t.Errorf("pixel (%d, %d) is %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	r := Render{Width: 32, Height: 16, Radius: 4}
+
+	var strip, anim bytes.Buffer
+	if err := r.PNGStrip(&strip, renderFrames); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.GIF(&anim, renderFrames); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "strip.png", strip.Bytes())
+	golden(t, "anim.gif", anim.Bytes())
+
+	g, err := gif.DecodeAll(&anim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != len(renderFrames) || g.Delay[0] != 2 {
+		t.Errorf("GIF has %d frames with delay %d, want %d with delay 2", len(g.Image), g.Delay[0], len(renderFrames))
+	}
+}
+
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s changed, run go test -update if it is on purpose", name)
+	}
+}
@@ -0,0 +1,54 @@
+package huetest
+
+import (
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/internal/clock"
+	"github.com/rschio/huestream/internal/testhooks"
+	"github.com/rschio/huestream/schedule"
+)
+
+// Clock is a fake clock that only moves when told to. Passed to a Stream
+// with Option, it drives keepalive, PlaySeq, the watchdog, recovery and
+// Pause, so a test can play 30 seconds of frames in microseconds:
+//
+//	clk := huetest.NewClock(time.Unix(0, 0))
+//	s, err := huestream.Start(ctx, ..., append(b.Options(), clk.Option())...)
+//	...
+//	clk.BlockUntil(1)
+//	clk.Advance(20 * time.Millisecond)
+type Clock struct {
+	fake *clock.Fake
+}
+
+// NewClock returns a Clock set to now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{fake: clock.NewFake(now)}
+}
+
+// Option returns the option making a Stream use the Clock.
+func (c *Clock) Option() huestream.Option {
+	return testhooks.StreamClock(c.fake).(huestream.Option)
+}
+
+// ScheduleOption returns the option making a schedule.Scheduler use the
+// Clock, to run a schedule of days in a test.
+func (c *Clock) ScheduleOption() schedule.Option {
+	return testhooks.ScheduleClock(c.fake).(schedule.Option)
+}
+
+// Now returns the time of the Clock.
+func (c *Clock) Now() time.Time { return c.fake.Now() }
+
+// Advance moves the Clock forward by d, firing the timers that expire in
+// order.
+func (c *Clock) Advance(d time.Duration) { c.fake.Advance(d) }
+
+// Set moves the Clock to t, which may be in the past, without firing
+// timers. It simulates a step of the wall clock.
+func (c *Clock) Set(t time.Time) { c.fake.Set(t) }
+
+// BlockUntil blocks until at least n timers are waiting to fire, which is
+// how a test knows that the Stream is waiting for the Clock.
+func (c *Clock) BlockUntil(n int) { c.fake.BlockUntil(n) }
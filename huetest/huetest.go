@@ -0,0 +1,693 @@
+// Package huetest provides a fake Hue Bridge to test programs using
+// huestream without hardware.
+//
+// A Bridge serves the entertainment_configuration, entertainment, light,
+// scene and smart_scene endpoints of the CLIP v2 API over HTTPS, for an
+// area of Lights channels whose lights play the native Effects, with the
+// dynamic scene SceneID, the smart scene SmartSceneID and the color loop of
+// the group of the area in the version 1 API. It accepts the DTLS stream on a random local UDP port, decoding the
+// received messages:
+//
+//	b := huetest.NewBridge(t)
+//	s, err := huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, b.Options()...)
+//	...
+//	f := <-b.Frames()
+package huetest
+
+import (
+	"cmp"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/dtls/v3"
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/wire"
+)
+
+// The credentials and area accepted by a Bridge.
+const (
+	Username  = "huetest-user"
+	ClientKey = "00112233445566778899aabbccddeeff"
+	AreaID    = "1a8d99cc-967b-44f2-9202-43f976c0fa6e"
+	SceneID   = "7c5f2cf3-0f3e-4b16-a1e6-b4a4fbf3a4d2"
+
+	// SmartSceneID is the natural light scene of the lights, inactive
+	// until activated and stopped by the stream.
+	SmartSceneID = "c1b5a7a4-6f0e-4a43-9d86-3e3f1c8e0b7a"
+
+	// Lights is the number of lights, and channels, of the area.
+	Lights = 3
+
+	// BridgeID is the ID of the bridge, the common name of its certificate
+	// as for a real bridge.
+	BridgeID = "001788fffe7e5700"
+)
+
+// frameBuffer is the number of received frames buffered by a Bridge, the
+// later ones are dropped until the test reads them.
+const frameBuffer = 1024
+
+const (
+	configurationPath = "/clip/v2/resource/entertainment_configuration/"
+	lightPath         = "/clip/v2/resource/light/"
+	scenePath         = "/clip/v2/resource/scene/"
+	smartScenePath    = "/clip/v2/resource/smart_scene/"
+
+	// groupV1 is the ID of the group of the area in the version 1 API.
+	groupV1 = "200"
+)
+
+// Effects are the native effects of the lights of a Bridge.
+var Effects = []string{"candle", "fire", "sparkle", "prism"}
+
+// Request is a request received by the CLIP server.
+type Request struct {
+	Method string
+	Path   string
+	Body   string
+}
+
+// Bridge is a fake Hue Bridge.
+type Bridge struct {
+	Host      string // The host to pass to huestream.Start.
+	Username  string
+	ClientKey string
+	AreaID    string
+
+	srv    *httptest.Server
+	ln     net.Listener
+	frames chan wire.Frame
+	wg     sync.WaitGroup
+
+	mu         sync.Mutex // Guards the fields below.
+	requests   []Request
+	status     map[string]int // Forced status codes by method.
+	active     bool           // Whether the stream of the area is started.
+	identities []string
+	dropped    int
+	conns      []net.Conn
+	replay     []Exchange // Recorded exchanges not served yet.
+	replaying  bool
+	linked     bool // Whether the link button is pressed.
+	effects    [Lights]string
+	powerOn    [Lights]string // The powerup presets.
+	sceneSpeed float64
+	colorLoop  bool
+	smartScene bool   // Whether SmartSceneID is active.
+	areaName   string // Set by an update of the area, empty if none.
+	areaType   string
+}
+
+// NewBridge starts a Bridge, closed at the end of the test.
+func NewBridge(t testing.TB) *Bridge {
+	t.Helper()
+
+	b := &Bridge{
+		Host:       "127.0.0.1",
+		Username:   Username,
+		ClientKey:  ClientKey,
+		AreaID:     AreaID,
+		frames:     make(chan wire.Frame, frameBuffer),
+		status:     make(map[string]int),
+		sceneSpeed: 0.5,
+	}
+
+	key, _ := hex.DecodeString(ClientKey)
+	ln, err := dtls.Listen("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, &dtls.Config{
+		PSK: func(identity []byte) ([]byte, error) {
+			b.mu.Lock()
+			b.identities = append(b.identities, string(identity))
+			b.mu.Unlock()
+			return key, nil
+		},
+		PSKIdentityHint: []byte("huetest"),
+		CipherSuites:    []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+	})
+	if err != nil {
+		t.Fatalf("huetest: listen: %v", err)
+	}
+	b.ln = ln
+	b.srv = httptest.NewUnstartedServer(http.HandlerFunc(b.serveHTTP))
+	b.srv.TLS = &tls.Config{Certificates: []tls.Certificate{bridgeCert()}}
+	b.srv.StartTLS()
+
+	b.wg.Add(1)
+	go b.accept()
+	t.Cleanup(b.Close)
+
+	return b
+}
+
+// Options returns the options pointing a Stream to the Bridge.
+func (b *Bridge) Options() []huestream.Option {
+	return []huestream.Option{
+		huestream.WithBaseURL(b.srv.URL),
+		huestream.WithStreamPort(b.StreamPort()),
+	}
+}
+
+// StreamPort returns the UDP port of the stream.
+func (b *Bridge) StreamPort() int { return b.ln.Addr().(*net.UDPAddr).Port }
+
+// URL returns the base URL of the CLIP server.
+func (b *Bridge) URL() string { return b.srv.URL }
+
+// Frames returns the channel receiving the decoded messages. Messages that
+// can't be decoded are dropped.
+func (b *Bridge) Frames() <-chan wire.Frame { return b.frames }
+
+// Requests returns the requests received by the CLIP server so far.
+func (b *Bridge) Requests() []Request {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Request(nil), b.requests...)
+}
+
+// Identities returns the PSK identities of the DTLS handshakes so far.
+func (b *Bridge) Identities() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.identities...)
+}
+
+// Active reports whether the stream of the area is started.
+func (b *Bridge) Active() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.active
+}
+
+// Dropped returns the number of frames dropped because the Frames channel
+// was full.
+func (b *Bridge) Dropped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// SetStatus makes the CLIP server answer the requests with the given method
+// with code and an error body. Zero restores the normal answers.
+func (b *Bridge) SetStatus(method string, code int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if code == 0 {
+		delete(b.status, method)
+		return
+	}
+	b.status[method] = code
+}
+
+// DropConnections closes the stream connections, as a bridge reboot does.
+func (b *Bridge) DropConnections() {
+	b.mu.Lock()
+	conns := b.conns
+	b.conns = nil
+	b.mu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// LightEffect returns the native effect set on the light of the channel
+// id, empty if none was set.
+func (b *Bridge) LightEffect(id int) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.effects[id]
+}
+
+// PowerOn returns the power-on preset of the light of the channel id, as
+// "safety".
+func (b *Bridge) PowerOn(id int) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return cmp.Or(b.powerOn[id], "safety")
+}
+
+// ColorLoop reports whether the color loop of the group of the area is on.
+func (b *Bridge) ColorLoop() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.colorLoop
+}
+
+// SmartSceneActive reports whether the smart scene SmartSceneID is active.
+func (b *Bridge) SmartSceneActive() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.smartScene
+}
+
+// PressLinkButton makes the CLIP server accept the registration of
+// applications, answered with the Username and ClientKey of the Bridge.
+func (b *Bridge) PressLinkButton() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.linked = true
+}
+
+// Close stops the Bridge.
+func (b *Bridge) Close() {
+	b.srv.Close()
+	b.ln.Close()
+	b.DropConnections()
+	b.wg.Wait()
+}
+
+func (b *Bridge) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.requests = append(b.requests, Request{Method: r.Method, Path: r.URL.Path, Body: string(body)})
+
+	if code, ok := b.status[r.Method]; ok {
+		writeError(w, code, "forced by huetest")
+		return
+	}
+	if b.replaying {
+		b.serveReplay(w, r, string(body))
+		return
+	}
+	if r.Method == "POST" && r.URL.Path == "/api" {
+		b.serveRegister(w)
+		return
+	}
+	if r.Method == "GET" && r.URL.Path == "/api/0/config" {
+		// The public configuration, served without credentials.
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"name":       "huetest",
+			"bridgeid":   strings.ToUpper(BridgeID),
+			"apiversion": "1.65.0",
+			"swversion":  "1965111030",
+		})
+		return
+	}
+	if r.Method == "PUT" && r.URL.Path == "/api/"+b.Username+"/groups/"+groupV1+"/action" {
+		// The version 1 API takes the username in the path.
+		b.serveGroupAction(w, body)
+		return
+	}
+	if r.Header.Get("hue-application-key") != b.Username {
+		writeError(w, http.StatusForbidden, "unauthorized user")
+		return
+	}
+	if r.Method == "GET" && r.URL.Path == "/auth/v1" {
+		w.Header().Set("hue-application-id", "huetest-application")
+		return
+	}
+	if r.Method == "GET" {
+		if data, ok := b.resources()[r.URL.Path]; ok {
+			writeList(w, data)
+			return
+		}
+	}
+	if id, ok := strings.CutPrefix(r.URL.Path, lightPath); ok && r.Method == "PUT" {
+		b.serveLight(w, id, body)
+		return
+	}
+	if id, ok := strings.CutPrefix(r.URL.Path, scenePath); ok && r.Method == "PUT" {
+		b.serveScene(w, id, body)
+		return
+	}
+	if id, ok := strings.CutPrefix(r.URL.Path, smartScenePath); ok && r.Method == "PUT" {
+		b.serveSmartScene(w, id, body)
+		return
+	}
+	id, ok := strings.CutPrefix(r.URL.Path, configurationPath)
+	if !ok || id != b.AreaID {
+		writeError(w, http.StatusNotFound, "resource not found")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeData(w, b.configuration())
+	case "PUT":
+		var req struct {
+			Action   string `json:"action"`
+			Metadata *struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Type string `json:"configuration_type"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		switch req.Action {
+		case "start", "stop":
+			b.active = req.Action == "start"
+			if b.active {
+				b.smartScene = false // The stream takes the lights.
+			}
+		case "":
+			if req.Metadata != nil {
+				b.areaName = req.Metadata.Name
+			}
+			if req.Type != "" {
+				b.areaType = req.Type
+			}
+		default:
+			writeError(w, http.StatusBadRequest, "invalid action")
+			return
+		}
+		writeData(w, map[string]any{"rid": b.AreaID, "rtype": "entertainment_configuration"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// Replay makes the CLIP server answer with the exchanges of rec instead of
+// emulating a bridge. A request is answered with the first exchange not
+// served yet with the same method and path, verbatim, and with 404 if there
+// is none. The stream is still served by the Bridge.
+func (b *Bridge) Replay(rec *Recording) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.replay = append([]Exchange(nil), rec.Exchanges...)
+	b.replaying = true
+}
+
+func (b *Bridge) serveReplay(w http.ResponseWriter, r *http.Request, body string) {
+	expand := strings.NewReplacer(
+		RedactedUsername, b.Username,
+		RedactedClientKey, b.ClientKey,
+		RedactedHost, b.Host,
+		RedactedAreaID, b.AreaID,
+	)
+	for i, e := range b.replay {
+		if e.Method != r.Method || expand.Replace(e.Path) != r.URL.Path {
+			continue
+		}
+		b.replay = append(b.replay[:i], b.replay[i+1:]...)
+		if e.Status == http.StatusOK && r.Method == "PUT" {
+			b.active = strings.Contains(body, `"start"`) || strings.Contains(body, `"active":true`)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(e.Status)
+		io.WriteString(w, expand.Replace(e.Response))
+		return
+	}
+	writeError(w, http.StatusNotFound, "huetest: no recorded exchange for "+r.Method+" "+r.URL.Path)
+}
+
+// bridgeCert returns the certificate of the CLIP server, self-signed with
+// the bridge ID as common name like the one of a real bridge.
+var bridgeCert = sync.OnceValue(func() tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: BridgeID},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+})
+
+// serveLight answers the update of the effect or of the power-on behavior
+// of the light id.
+func (b *Bridge) serveLight(w http.ResponseWriter, id string, body []byte) {
+	var i int
+	if _, err := fmt.Sscanf(id, "light-%d", &i); err != nil || i < 0 || i >= Lights {
+		writeError(w, http.StatusNotFound, "resource not found")
+		return
+	}
+	var req struct {
+		Effects *struct {
+			Effect string `json:"effect"`
+		} `json:"effects"`
+		Powerup *struct {
+			Preset string `json:"preset"`
+		} `json:"powerup"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || (req.Effects == nil && req.Powerup == nil) {
+		writeError(w, http.StatusBadRequest, "invalid light update")
+		return
+	}
+	if e := req.Effects; e != nil && e.Effect != huestream.NoEffect && !slices.Contains(Effects, e.Effect) {
+		writeError(w, http.StatusBadRequest, "invalid effect")
+		return
+	}
+	if p := req.Powerup; p != nil && !slices.Contains([]string{"safety", "powerfail", "last_on_state", "custom"}, p.Preset) {
+		writeError(w, http.StatusBadRequest, "invalid powerup preset")
+		return
+	}
+	if req.Effects != nil {
+		b.effects[i] = req.Effects.Effect
+	}
+	if req.Powerup != nil {
+		b.powerOn[i] = req.Powerup.Preset
+	}
+	writeData(w, map[string]any{"rid": id, "rtype": "light"})
+}
+
+// serveScene answers the recall of the scene id, refused while the area,
+// made of the lights of the scene, is streamed.
+func (b *Bridge) serveScene(w http.ResponseWriter, id string, body []byte) {
+	if id != SceneID {
+		writeError(w, http.StatusNotFound, "resource not found")
+		return
+	}
+	var req struct {
+		Recall struct {
+			Action string `json:"action"`
+		} `json:"recall"`
+		Speed *float64 `json:"speed"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || (req.Speed != nil && (*req.Speed < 0 || *req.Speed > 1)) {
+		writeError(w, http.StatusBadRequest, "invalid scene update")
+		return
+	}
+	if b.active && req.Recall.Action != "" {
+		writeError(w, http.StatusConflict, "lights in use by entertainment streaming")
+		return
+	}
+	if req.Speed != nil {
+		b.sceneSpeed = *req.Speed
+	}
+	writeData(w, map[string]any{"rid": id, "rtype": "scene"})
+}
+
+// serveSmartScene answers the activation of the smart scene id.
+func (b *Bridge) serveSmartScene(w http.ResponseWriter, id string, body []byte) {
+	if id != SmartSceneID {
+		writeError(w, http.StatusNotFound, "resource not found")
+		return
+	}
+	var req struct {
+		Recall struct {
+			Action string `json:"action"`
+		} `json:"recall"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || (req.Recall.Action != "activate" && req.Recall.Action != "deactivate") {
+		writeError(w, http.StatusBadRequest, "invalid recall")
+		return
+	}
+	b.smartScene = req.Recall.Action == "activate"
+	writeData(w, map[string]any{"rid": id, "rtype": "smart_scene"})
+}
+
+// serveGroupAction answers the update of the effect of the group of the
+// area in the version 1 format.
+func (b *Bridge) serveGroupAction(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	var req struct {
+		Effect string `json:"effect"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || (req.Effect != "colorloop" && req.Effect != "none") {
+		io.WriteString(w, `[{"error":{"type":7,"address":"/groups/`+groupV1+`/action/effect","description":"invalid value"}}]`)
+		return
+	}
+	if b.active {
+		io.WriteString(w, `[{"error":{"type":307,"address":"/groups/`+groupV1+`/action","description":"cannot claim stream ownership"}}]`)
+		return
+	}
+	b.colorLoop = req.Effect == "colorloop"
+	json.NewEncoder(w).Encode([]any{map[string]any{
+		"success": map[string]string{"/groups/" + groupV1 + "/action/effect": req.Effect},
+	}})
+}
+
+// serveRegister answers a registration in the version 1 format, the only
+// one of the endpoint.
+func (b *Bridge) serveRegister(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	if !b.linked {
+		io.WriteString(w, `[{"error":{"type":101,"address":"","description":"link button not pressed"}}]`)
+		return
+	}
+	json.NewEncoder(w).Encode([]any{map[string]any{
+		"success": map[string]string{"username": b.Username, "clientkey": b.ClientKey},
+	}})
+}
+
+// configuration returns the entertainment configuration of the area, with
+// one channel per light, in a row from left to right.
+func (b *Bridge) configuration() map[string]any {
+	channels := make([]any, Lights)
+	for i := range channels {
+		channels[i] = map[string]any{
+			"channel_id": i,
+			"position":   map[string]float64{"x": float64(2*i)/(Lights-1) - 1, "y": 1, "z": 0},
+			"members": []any{map[string]any{
+				"service": map[string]string{"rid": fmt.Sprintf("entertainment-%d", i), "rtype": "entertainment"},
+				"index":   0,
+			}},
+		}
+	}
+	return map[string]any{
+		"id":                 b.AreaID,
+		"id_v1":              "/groups/" + groupV1,
+		"type":               "entertainment_configuration",
+		"metadata":           map[string]string{"name": cmp.Or(b.areaName, "huetest")},
+		"configuration_type": cmp.Or(b.areaType, "screen"),
+		"status":             map[bool]string{true: "active", false: "inactive"}[b.active],
+		"stream_proxy": map[string]any{
+			"mode": "auto",
+			"node": map[string]string{"rid": "entertainment-0", "rtype": "entertainment"},
+		},
+		"channels": channels,
+	}
+}
+
+// resources returns the resource lists served by the CLIP server, by path.
+func (b *Bridge) resources() map[string][]any {
+	var services, lights []any
+	for i := range Lights {
+		owner := map[string]string{"rid": fmt.Sprintf("device-%d", i), "rtype": "device"}
+		services = append(services, map[string]any{
+			"id": fmt.Sprintf("entertainment-%d", i), "type": "entertainment", "owner": owner,
+		})
+		lights = append(lights, map[string]any{
+			"id": fmt.Sprintf("light-%d", i), "type": "light", "owner": owner,
+			"metadata": map[string]string{"name": LightName(i)},
+			"effects": map[string]any{
+				"status":        cmp.Or(b.effects[i], huestream.NoEffect),
+				"status_values": append([]string{huestream.NoEffect}, Effects...),
+				"effect_values": append([]string{huestream.NoEffect}, Effects...),
+			},
+			"powerup": map[string]any{"preset": cmp.Or(b.powerOn[i], "safety")},
+			"dimming": map[string]any{"brightness": 100, "min_dim_level": 0.2},
+			"color_temperature": map[string]any{
+				"mirek_schema": map[string]int{"mirek_minimum": 153, "mirek_maximum": 500},
+			},
+			"color": map[string]any{"gamut_type": "C"},
+		})
+	}
+	return map[string][]any{
+		strings.TrimSuffix(configurationPath, "/"): {b.configuration()},
+		"/clip/v2/resource/entertainment":          services,
+		"/clip/v2/resource/light":                  lights,
+		strings.TrimSuffix(scenePath, "/"): {map[string]any{
+			"id": SceneID, "type": "scene", "speed": b.sceneSpeed,
+			"metadata": map[string]string{"name": "huetest scene"},
+		}},
+		strings.TrimSuffix(smartScenePath, "/"): {map[string]any{
+			"id": SmartSceneID, "type": "smart_scene",
+			"metadata": map[string]string{"name": "huetest natural light"},
+			"group":    map[string]string{"rid": "room-0", "rtype": "room"},
+			"state":    map[bool]string{true: "active", false: "inactive"}[b.smartScene],
+		}},
+	}
+}
+
+// LightName returns the name of the light of the channel id.
+func LightName(id int) string {
+	return fmt.Sprintf("huetest light %d", id)
+}
+
+func writeData(w http.ResponseWriter, data any) {
+	writeList(w, []any{data})
+}
+
+func writeList(w http.ResponseWriter, data []any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"errors": []any{}, "data": data})
+}
+
+func writeError(w http.ResponseWriter, code int, desc string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"errors": []any{map[string]string{"description": desc}},
+		"data":   []any{},
+	})
+}
+
+func (b *Bridge) accept() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.conns = append(b.conns, conn)
+		b.mu.Unlock()
+
+		b.wg.Add(1)
+		go b.read(conn)
+	}
+}
+
+// read decodes the messages of conn until it is closed.
+func (b *Bridge) read(conn net.Conn) {
+	defer b.wg.Done()
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	err := conn.(*dtls.Conn).HandshakeContext(ctx)
+	cancel()
+	if err != nil {
+		return
+	}
+
+	buf := make([]byte, 2048)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		f, err := wire.Decode(buf[:n])
+		if err != nil {
+			continue
+		}
+		select {
+		case b.frames <- f:
+		default:
+			b.mu.Lock()
+			b.dropped++
+			b.mu.Unlock()
+		}
+	}
+}
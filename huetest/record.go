@@ -0,0 +1,212 @@
+package huetest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rschio/huestream"
+)
+
+// Placeholders replacing the credentials in a Recording.
+const (
+	RedactedUsername  = "{username}"
+	RedactedClientKey = "{clientkey}"
+	RedactedHost      = "{host}"
+	RedactedAreaID    = "{area}"
+)
+
+// Recording is the traffic between a Stream and a bridge, made by a
+// Recorder and served by a Bridge with Replay. It holds no credentials.
+type Recording struct {
+	Exchanges []Exchange `json:"exchanges"` // CLIP requests, in order.
+	Events    []Event    `json:"events"`    // Stream events, in order.
+}
+
+// Exchange is a CLIP request and the response of the bridge.
+type Exchange struct {
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Body     string `json:"body,omitempty"`
+	Status   int    `json:"status"`
+	Response string `json:"response"`
+}
+
+// Event is an event of the stream connection: "dial", "write" of Size
+// bytes, "read" of Size bytes or "close".
+type Event struct {
+	Kind string `json:"kind"`
+	Size int    `json:"size,omitempty"`
+}
+
+// LoadRecording reads a Recording saved by Recorder.Save.
+func LoadRecording(path string) (*Recording, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Recording
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("huetest: %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// Recorder records the traffic of a Stream with a real bridge, to replay it
+// later without hardware. The CLIP requests go through a proxy and the
+// stream connection through a dialer, both set by Options:
+//
+//	rec := huetest.NewRecorder(t, "https://"+host, username, clientKey, areaID)
+//	s, err := huestream.Start(ctx, host, username, clientKey, areaID, rec.Options()...)
+//	...
+//	err = rec.Save("testdata/e2e/start.json")
+type Recorder struct {
+	target  string
+	secrets *strings.Replacer
+	srv     *httptest.Server
+	client  *http.Client
+
+	mu  sync.Mutex // Guards rec.
+	rec Recording
+}
+
+// NewRecorder starts a Recorder forwarding the CLIP requests to baseURL,
+// closed at the end of the test. username and clientKey are redacted from
+// the Recording, as are the host of baseURL and areaID so that a Bridge can
+// replay it with its own.
+func NewRecorder(t testing.TB, baseURL, username, clientKey, areaID string) *Recorder {
+	t.Helper()
+
+	host := strings.TrimPrefix(strings.TrimPrefix(baseURL, "https://"), "http://")
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Bridges use a self-signed certificate.
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	r := &Recorder{
+		target: strings.TrimSuffix(baseURL, "/"),
+		secrets: strings.NewReplacer(
+			username, RedactedUsername,
+			clientKey, RedactedClientKey,
+			host, RedactedHost,
+			areaID, RedactedAreaID,
+		),
+		client: &http.Client{Transport: transport},
+	}
+	r.srv = httptest.NewTLSServer(http.HandlerFunc(r.proxy))
+	t.Cleanup(r.srv.Close)
+
+	return r
+}
+
+// Options returns the options routing a Stream through the Recorder.
+func (r *Recorder) Options() []huestream.Option {
+	return []huestream.Option{
+		huestream.WithBaseURL(r.srv.URL),
+		huestream.WithDialer(r.dial),
+	}
+}
+
+// Recording returns the traffic recorded so far.
+func (r *Recorder) Recording() *Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Recording{
+		Exchanges: append([]Exchange(nil), r.rec.Exchanges...),
+		Events:    append([]Event(nil), r.rec.Events...),
+	}
+}
+
+// Save writes the Recording to path, as indented JSON.
+func (r *Recorder) Save(path string) error {
+	b, err := json.MarshalIndent(r.Recording(), "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func (r *Recorder) proxy(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	out, err := http.NewRequestWithContext(req.Context(), req.Method, r.target+req.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	out.Header = req.Header.Clone()
+	resp, err := r.client.Do(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+
+	r.mu.Lock()
+	r.rec.Exchanges = append(r.rec.Exchanges, Exchange{
+		Method:   req.Method,
+		Path:     r.secrets.Replace(req.URL.Path),
+		Body:     r.secrets.Replace(string(body)),
+		Status:   resp.StatusCode,
+		Response: r.secrets.Replace(string(respBody)),
+	})
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	w.Write(respBody)
+}
+
+func (r *Recorder) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	r.event(Event{Kind: "dial"})
+	return &recordConn{Conn: conn, r: r}, nil
+}
+
+func (r *Recorder) event(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rec.Events = append(r.rec.Events, e)
+}
+
+// recordConn records the events of a stream connection. The records are
+// DTLS records, their sizes are recorded but not their encrypted content.
+type recordConn struct {
+	net.Conn
+	r *Recorder
+}
+
+func (c *recordConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.r.event(Event{Kind: "read", Size: n})
+	}
+	return n, err
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.r.event(Event{Kind: "write", Size: n})
+	}
+	return n, err
+}
+
+func (c *recordConn) Close() error {
+	c.r.event(Event{Kind: "close"})
+	return c.Conn.Close()
+}
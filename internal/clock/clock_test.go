@@ -0,0 +1,68 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeFiresTimersInOrder(t *testing.T) {
+	start := time.Unix(0, 0)
+	f := NewFake(start)
+
+	late := f.NewTimer(2 * time.Second)
+	early := f.NewTimer(time.Second)
+
+	f.Advance(1500 * time.Millisecond)
+	select {
+	case got := <-early.C():
+		if want := start.Add(time.Second); !got.Equal(want) {
+			t.Errorf("early timer fired at %v, want %v", got, want)
+		}
+	default:
+		t.Fatal("early timer did not fire")
+	}
+	select {
+	case <-late.C():
+		t.Fatal("late timer fired too soon")
+	default:
+	}
+
+	if late.Stop() != true {
+		t.Error("Stop on a pending timer should report true")
+	}
+	f.Advance(time.Second)
+	select {
+	case <-late.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
+
+func TestFakeTickerDropsUnreadTicks(t *testing.T) {
+	start := time.Unix(0, 0)
+	f := NewFake(start)
+	tk := f.NewTicker(time.Second)
+
+	f.Advance(3500 * time.Millisecond)
+	if got, want := <-tk.C(), start.Add(time.Second); !got.Equal(want) {
+		t.Errorf("first tick at %v, want %v", got, want)
+	}
+	select {
+	case got := <-tk.C():
+		t.Fatalf("unread tick at %v was kept", got)
+	default:
+	}
+
+	f.Advance(time.Second)
+	if got, want := <-tk.C(), start.Add(4*time.Second); !got.Equal(want) {
+		t.Errorf("tick at %v, want %v", got, want)
+	}
+
+	tk.Stop()
+	f.Advance(time.Second)
+	select {
+	case <-tk.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
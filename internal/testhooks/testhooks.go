@@ -0,0 +1,14 @@
+// Package testhooks gives huetest access to the test options of the packages
+// of the module, so their signatures do not expose the internal packages.
+//
+// Each hook is set by the package it belongs to when it is initialized and
+// returns an option of that package.
+package testhooks
+
+import "github.com/rschio/huestream/internal/clock"
+
+// StreamClock returns a huestream.Option making a Stream use c.
+var StreamClock func(c clock.Clock) any
+
+// ScheduleClock returns a schedule.Option making a Scheduler use c.
+var ScheduleClock func(c clock.Clock) any
@@ -0,0 +1,471 @@
+// Package jsonframe decodes the JSON documents of frames and effect
+// commands. The HTTP facade, the MQTT adapter and the pipe mode of the
+// command all speak them, and they are lenient enough to be written by
+// Home Assistant templates.
+//
+// A frame document sets the colors of the channels:
+//
+//	{"0": "#ff8800", "1": [30, 100], "all": "#000000", "transition": 2}
+//
+// Its keys are:
+//
+//	"<id>"              the color of the channel of the ID
+//	"all" or "color"    the color of the channels without one of their own
+//	"transition"        the time to fade from the current colors
+//	"brightness"        from 0 to 255, scales the colors of the document
+//	"brightness_pct"    from 0 to 100, the same in percent
+//
+// A color is one of:
+//
+//	"#ff8800", "ff8800", "#f80"       hex, in any case
+//	"rgb(255, 136, 0)", "orange"      any form of huestream.ParseColor
+//	[255, 136, 0]                     red, green and blue from 0 to 255
+//	[32, 100]                         hue from 0 to 360 and saturation from
+//	                                  0 to 100, as hs_color
+//	{"rgb_color": [255, 136, 0]}      the same as an object, with an
+//	{"hs_color": [32, 100]}           optional "brightness" or
+//	{"hex": "#ff8800"}                "brightness_pct"
+//
+// The numbers may be strings, and the lists strings too, as "[32, 100]" or
+// "(32.0, 100.0)": templates often render them so. A null or empty color is
+// no color, the channel keeps its own. A time is in seconds, a number or a
+// string, or a Go duration as "1.5s" or "500ms".
+//
+// An effect command plays an effect:
+//
+//	{"name": "candle", "color": "#ff8800", "duration": "10m"}
+//
+// "effect" is an alias of "name", the color and the duration are
+// optional: without duration the effect plays until the next command.
+//
+// The errors name the key and the value at fault, and the accepted forms.
+package jsonframe
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"iter"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+// Frame is a decoded frame document.
+type Frame struct {
+	Colors     huestream.Frame
+	Transition time.Duration // Zero sets the colors at once.
+}
+
+// Effect is a decoded effect command.
+type Effect struct {
+	Name     string        // Lowercase, empty if the command has none.
+	Color    color.Color   // Nil if the command has none.
+	Duration time.Duration // Zero plays until the next command.
+}
+
+// DecodeFrame decodes a frame document for the channels of the IDs ids.
+func DecodeFrame(data []byte, ids []int) (Frame, error) {
+	var doc map[string]json.RawMessage
+	if err := unmarshalObject(data, &doc); err != nil {
+		return Frame{}, fmt.Errorf("jsonframe: frame: %w", err)
+	}
+
+	var f Frame
+	var all color.Color
+	k := 1.0
+	colors := make(huestream.Frame, len(doc))
+	for key, v := range doc {
+		var err error
+		switch key {
+		case "all", "color":
+			if all != nil {
+				return Frame{}, errors.New(`jsonframe: both "all" and "color" set`)
+			}
+			all, err = decodeColor(v)
+		case "transition":
+			f.Transition, err = decodeDuration(v)
+		case "brightness":
+			k, err = decodeBrightness(v, 255)
+		case "brightness_pct":
+			k, err = decodeBrightness(v, 100)
+		default:
+			id, convErr := strconv.Atoi(key)
+			if convErr != nil {
+				return Frame{}, fmt.Errorf(`jsonframe: unknown key %q, want a channel ID, "all", "color", "transition", "brightness" or "brightness_pct"`, key)
+			}
+			if !slices.Contains(ids, id) {
+				return Frame{}, fmt.Errorf("jsonframe: unknown channel %d, the channels are %v", id, ids)
+			}
+			var c color.Color
+			if c, err = decodeColor(v); c != nil {
+				colors[id] = c
+			}
+		}
+		if err != nil {
+			return Frame{}, fmt.Errorf("jsonframe: %q: %w", key, err)
+		}
+	}
+	if all != nil {
+		for _, id := range ids {
+			if _, ok := colors[id]; !ok {
+				colors[id] = all
+			}
+		}
+	}
+	if k != 1 {
+		for id, c := range colors {
+			colors[id] = scale(c, k)
+		}
+	}
+	f.Colors = colors
+	return f, nil
+}
+
+// DecodeEffect decodes an effect command. An empty document is the command
+// without name, color nor duration.
+func DecodeEffect(data []byte) (Effect, error) {
+	var doc map[string]json.RawMessage
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := unmarshalObject(data, &doc); err != nil {
+			return Effect{}, fmt.Errorf("jsonframe: effect: %w", err)
+		}
+	}
+
+	var e Effect
+	for key, v := range doc {
+		var err error
+		switch key {
+		case "name", "effect":
+			if e.Name != "" {
+				return Effect{}, errors.New(`jsonframe: both "name" and "effect" set`)
+			}
+			var s string
+			if err = json.Unmarshal(v, &s); err == nil {
+				e.Name = strings.ToLower(strings.TrimSpace(s))
+			} else {
+				err = fmt.Errorf("invalid name %s, want a string", v)
+			}
+		case "color":
+			e.Color, err = decodeColor(v)
+		case "duration":
+			e.Duration, err = decodeDuration(v)
+		default:
+			return Effect{}, fmt.Errorf(`jsonframe: unknown key %q, want "name", "effect", "color" or "duration"`, key)
+		}
+		if err != nil {
+			return Effect{}, fmt.Errorf("jsonframe: %q: %w", key, err)
+		}
+	}
+	return e, nil
+}
+
+// DecodeColor decodes a color, nil for a null or empty one.
+func DecodeColor(data []byte) (color.Color, error) {
+	c, err := decodeColor(data)
+	if err != nil {
+		return nil, fmt.Errorf("jsonframe: %w", err)
+	}
+	return c, nil
+}
+
+func unmarshalObject(data []byte, v *map[string]json.RawMessage) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("got a JSON %s, want an object", typeErr.Value)
+		}
+		return err
+	}
+	if *v == nil {
+		return errors.New("got null, want an object")
+	}
+	return nil
+}
+
+const colorForms = `a string as "#ff8800", "rgb(255, 136, 0)" or "orange", [r, g, b] from 0 to 255, [hue, saturation] from 0 to 360 and 100, or an object with "rgb_color", "hs_color" or "hex"`
+
+func decodeColor(data []byte) (color.Color, error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case len(data) == 0, string(data) == "null":
+		return nil, nil
+	case data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return nil, nil
+		}
+		if s[0] == '[' || s[0] == '(' {
+			return decodeTuple(s)
+		}
+		return huestream.ParseColor(s)
+	case data[0] == '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		nums := make([]float64, len(items))
+		for i, item := range items {
+			var err error
+			if nums[i], err = decodeNumber(item); err != nil {
+				return nil, err
+			}
+		}
+		return fromNumbers(nums)
+	case data[0] == '{':
+		return decodeColorObject(data)
+	}
+	return nil, fmt.Errorf("invalid color %s, want %s", data, colorForms)
+}
+
+// decodeTuple decodes a list rendered as a string, "[32, 100]" or
+// "(32.0, 100.0)".
+func decodeTuple(s string) (color.Color, error) {
+	open, end := s[0], s[len(s)-1]
+	if (open == '[' && end != ']') || (open == '(' && end != ')') {
+		return nil, fmt.Errorf("invalid color %q, unbalanced %c", s, open)
+	}
+	var nums []float64
+	for _, f := range strings.Split(s[1:len(s)-1], ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue // A trailing comma, as in "(32.0, 100.0,)".
+		}
+		n, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q in color %q", f, s)
+		}
+		nums = append(nums, n)
+	}
+	return fromNumbers(nums)
+}
+
+func fromNumbers(nums []float64) (color.Color, error) {
+	switch len(nums) {
+	case 2:
+		return fromHS(nums[0], nums[1])
+	case 3:
+		return fromRGB(nums)
+	}
+	return nil, fmt.Errorf("got %d numbers, want [r, g, b] or [hue, saturation]", len(nums))
+}
+
+func decodeColorObject(data []byte) (color.Color, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	var c color.Color
+	k := 1.0
+	for key, v := range obj {
+		var err error
+		var kc color.Color
+		switch key {
+		case "rgb_color", "rgb":
+			var nums []float64
+			if nums, err = decodeNumbers(v, 3); err == nil {
+				kc, err = fromRGB(nums)
+			}
+		case "hs_color", "hs":
+			var nums []float64
+			if nums, err = decodeNumbers(v, 2); err == nil {
+				kc, err = fromHS(nums[0], nums[1])
+			}
+		case "hex":
+			var s string
+			if err = json.Unmarshal(v, &s); err == nil {
+				kc, err = huestream.ParseColor(s)
+			}
+		case "brightness":
+			k, err = decodeBrightness(v, 255)
+		case "brightness_pct":
+			k, err = decodeBrightness(v, 100)
+		default:
+			return nil, fmt.Errorf(`unknown color key %q, want "rgb_color", "hs_color", "hex", "brightness" or "brightness_pct"`, key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", key, err)
+		}
+		if kc != nil {
+			if c != nil {
+				return nil, errors.New("several colors in one object")
+			}
+			c = kc
+		}
+	}
+	if c == nil {
+		return nil, fmt.Errorf("no color in %s, want %s", data, colorForms)
+	}
+	return scale(c, k), nil
+}
+
+// decodeNumbers decodes a list of n numbers, or a string of it.
+func decodeNumbers(data []byte, n int) ([]float64, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		var s string
+		if json.Unmarshal(data, &s) != nil {
+			return nil, fmt.Errorf("invalid list %s", data)
+		}
+		s = strings.Trim(strings.TrimSpace(s), "[]()")
+		for _, f := range strings.Split(s, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				items = append(items, json.RawMessage(strconv.Quote(f)))
+			}
+		}
+	}
+	if len(items) != n {
+		return nil, fmt.Errorf("got %d numbers, want %d", len(items), n)
+	}
+	nums := make([]float64, n)
+	for i, item := range items {
+		var err error
+		if nums[i], err = decodeNumber(item); err != nil {
+			return nil, err
+		}
+	}
+	return nums, nil
+}
+
+// decodeNumber decodes a number, or a string of it.
+func decodeNumber(data []byte) (float64, error) {
+	var n float64
+	if err := json.Unmarshal(data, &n); err == nil {
+		return n, nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if n, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid number %s", data)
+}
+
+func decodeBrightness(data []byte, full float64) (float64, error) {
+	n, err := decodeNumber(data)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > full || math.IsNaN(n) {
+		return 0, fmt.Errorf("brightness %v out of 0 to %v", n, full)
+	}
+	return n / full, nil
+}
+
+// decodeDuration decodes seconds, a number or a string, or a Go duration.
+func decodeDuration(data []byte) (time.Duration, error) {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return 0, nil
+	}
+	secs, err := decodeNumber(data)
+	if err != nil {
+		var s string
+		if json.Unmarshal(data, &s) != nil {
+			return 0, fmt.Errorf("invalid time %s, want seconds or a duration as \"1.5s\"", data)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %q, want seconds or a duration as \"1.5s\"", s)
+		}
+		secs = d.Seconds()
+	}
+	if secs < 0 || math.IsNaN(secs) || secs > math.MaxInt64/float64(time.Second) {
+		return 0, fmt.Errorf("time %v out of range", secs)
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+func fromRGB(nums []float64) (color.Color, error) {
+	var c [3]uint8
+	for i, n := range nums {
+		if n < 0 || n > 255 || math.IsNaN(n) {
+			return nil, fmt.Errorf("component %v out of 0 to 255", n)
+		}
+		c[i] = uint8(math.Round(n))
+	}
+	return color.RGBA{R: c[0], G: c[1], B: c[2], A: 255}, nil
+}
+
+// fromHS returns the color of the hue h, in degrees, and the saturation s,
+// in percent, at full brightness.
+func fromHS(h, s float64) (color.Color, error) {
+	if h < 0 || h > 360 || math.IsNaN(h) {
+		return nil, fmt.Errorf("hue %v out of 0 to 360", h)
+	}
+	if s < 0 || s > 100 || math.IsNaN(s) {
+		return nil, fmt.Errorf("saturation %v out of 0 to 100", s)
+	}
+	h, s = math.Mod(h, 360)/60, s/100
+	x := s * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g = s, x
+	case 1:
+		r, g = x, s
+	case 2:
+		g, b = s, x
+	case 3:
+		g, b = x, s
+	case 4:
+		r, b = x, s
+	default:
+		r, b = s, x
+	}
+	m := 1 - s
+	unit := func(v float64) uint16 { return uint16(math.Round(min(max(v, 0), 1) * 0xffff)) }
+	return color.RGBA64{R: unit(r + m), G: unit(g + m), B: unit(b + m), A: 0xffff}, nil
+}
+
+// scale returns c with its channels multiplied by k, in [0, 1].
+func scale(c color.Color, k float64) color.Color {
+	if k == 1 {
+		return c
+	}
+	r, g, b, _ := c.RGBA()
+	return color.RGBA64{R: uint16(float64(r) * k), G: uint16(float64(g) * k), B: uint16(float64(b) * k), A: 0xffff}
+}
+
+// Fade returns the frames fading from the colors from to the colors to in
+// d, at rate frames per second: the last one is to. The channels without a
+// color in from start at their color in to.
+func Fade(from, to huestream.Frame, d time.Duration, rate float64) iter.Seq[huestream.Frame] {
+	n := max(int(math.Ceil(d.Seconds()*rate)), 1)
+	return func(yield func(huestream.Frame) bool) {
+		for i := 1; i <= n; i++ {
+			t := float64(i) / float64(n)
+			f := make(huestream.Frame, len(to))
+			for id, c := range to {
+				if prev, ok := from[id]; ok && i < n {
+					f[id] = mix(prev, c, t)
+				} else {
+					f[id] = c
+				}
+			}
+			if !yield(f) {
+				return
+			}
+		}
+	}
+}
+
+// mix returns the color at t of the way from a to b.
+func mix(a, b color.Color, t float64) color.Color {
+	ar, ag, ab, _ := a.RGBA()
+	br, bg, bb, _ := b.RGBA()
+	lerp := func(x, y uint32) uint16 {
+		return uint16(float64(x) + (float64(y)-float64(x))*t)
+	}
+	return color.RGBA64{R: lerp(ar, br), G: lerp(ag, bg), B: lerp(ab, bb), A: 0xffff}
+}
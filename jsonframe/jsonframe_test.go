@@ -0,0 +1,268 @@
+package jsonframe
+
+import (
+	"encoding/json"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+var ids = []int{0, 1, 2}
+
+func rgb8(c color.Color) [3]uint32 {
+	r, g, b, _ := c.RGBA()
+	return [3]uint32{r >> 8, g >> 8, b >> 8}
+}
+
+var (
+	orange = [3]uint32{255, 136, 0}
+	red    = [3]uint32{255, 0, 0}
+	black  = [3]uint32{0, 0, 0}
+)
+
+func TestDecodeFrame(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc        string
+		want       map[int][3]uint32
+		transition time.Duration
+	}{
+		{"hex", `{"0":"#ff8800"}`, map[int][3]uint32{0: orange}, 0},
+		{"hex without hash", `{"0":"ff8800"}`, map[int][3]uint32{0: orange}, 0},
+		{"short hex", `{"0":"#f80"}`, map[int][3]uint32{0: {255, 136, 0}}, 0},
+		{"upper case and spaces", `{"0":"  #FF8800 "}`, map[int][3]uint32{0: orange}, 0},
+		{"rgb list", `{"0":[255,136,0]}`, map[int][3]uint32{0: orange}, 0},
+		{"rgb floats", `{"0":[254.6,136.2,0.0]}`, map[int][3]uint32{0: orange}, 0},
+		{"rgb strings", `{"0":["255","136","0"]}`, map[int][3]uint32{0: orange}, 0},
+		{"hs list", `{"0":[0,100]}`, map[int][3]uint32{0: red}, 0},
+		{"hs 360", `{"0":[360,100]}`, map[int][3]uint32{0: red}, 0},
+		{"hs unsaturated", `{"0":[200,0]}`, map[int][3]uint32{0: {255, 255, 255}}, 0},
+		{"hs green", `{"0":[120,100]}`, map[int][3]uint32{0: {0, 255, 0}}, 0},
+		{"hs rendered list", `{"0":"[120, 100]"}`, map[int][3]uint32{0: {0, 255, 0}}, 0},
+		{"hs rendered tuple", `{"0":"(120.0, 100.0)"}`, map[int][3]uint32{0: {0, 255, 0}}, 0},
+		{"rgb rendered tuple", `{"0":"(255, 136, 0)"}`, map[int][3]uint32{0: orange}, 0},
+		{"rgb_color object", `{"0":{"rgb_color":[255,136,0]}}`, map[int][3]uint32{0: orange}, 0},
+		{"hs_color object", `{"0":{"hs_color":"(0.0, 100.0)"}}`, map[int][3]uint32{0: red}, 0},
+		{"hex object", `{"0":{"hex":"#ff8800"}}`, map[int][3]uint32{0: orange}, 0},
+		{"object brightness", `{"0":{"rgb_color":[255,0,0],"brightness":"127.5"}}`, map[int][3]uint32{0: {127, 0, 0}}, 0},
+		{"object brightness_pct", `{"0":{"rgb":[255,0,0],"brightness_pct":0}}`, map[int][3]uint32{0: black}, 0},
+		{"all", `{"all":"#ff0000","1":"#ff8800"}`, map[int][3]uint32{0: red, 1: orange, 2: red}, 0},
+		{"color alias", `{"color":"#ff0000"}`, map[int][3]uint32{0: red, 1: red, 2: red}, 0},
+		{"null channel", `{"0":null,"1":"","2":"#ff0000"}`, map[int][3]uint32{2: red}, 0},
+		{"null all", `{"all":null,"0":"#ff0000"}`, map[int][3]uint32{0: red}, 0},
+		{"brightness", `{"0":"#ff0000","brightness":0}`, map[int][3]uint32{0: black}, 0},
+		{"brightness_pct", `{"0":"#ff0000","brightness_pct":"50"}`, map[int][3]uint32{0: {127, 0, 0}}, 0},
+		{"transition seconds", `{"0":"#ff0000","transition":2}`, map[int][3]uint32{0: red}, 2 * time.Second},
+		{"transition float string", `{"0":"#ff0000","transition":"0.5"}`, map[int][3]uint32{0: red}, 500 * time.Millisecond},
+		{"transition duration", `{"0":"#ff0000","transition":"1m30s"}`, map[int][3]uint32{0: red}, 90 * time.Second},
+		{"transition null", `{"0":"#ff0000","transition":null}`, map[int][3]uint32{0: red}, 0},
+		{"empty", `{}`, map[int][3]uint32{}, 0},
+		{"spaces", " \n{ \"0\" : \"#ff0000\" }\n", map[int][3]uint32{0: red}, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := DecodeFrame([]byte(tc.doc), ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(f.Colors) != len(tc.want) {
+				t.Errorf("got %d colors, want %d: %v", len(f.Colors), len(tc.want), f.Colors)
+			}
+			for id, want := range tc.want {
+				c, ok := f.Colors[id]
+				if !ok {
+					t.Errorf("channel %d: no color", id)
+					continue
+				}
+				if got := rgb8(c); got != want {
+					t.Errorf("channel %d = %v, want %v", id, got, want)
+				}
+			}
+			if f.Transition != tc.transition {
+				t.Errorf("transition = %v, want %v", f.Transition, tc.transition)
+			}
+		})
+	}
+}
+
+// TestFrameJSON checks that the JSON of a huestream.Frame is a frame
+// document, and that the documents of hex colors by channel decode as
+// frames.
+func TestFrameJSON(t *testing.T) {
+	frame := huestream.Frame{0: color.RGBA{255, 136, 0, 255}, 2: color.RGBA{0, 0, 255, 255}}
+	b, err := json.Marshal(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := DecodeFrame(b, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Colors) != len(frame) {
+		t.Fatalf("decoded %v, want %v", f.Colors, frame)
+	}
+	for id, c := range frame {
+		if rgb8(f.Colors[id]) != rgb8(c) {
+			t.Errorf("channel %d = %v, want %v", id, rgb8(f.Colors[id]), rgb8(c))
+		}
+	}
+
+	doc := `{"0":"#ff8800","1":"f80","2":"#0000FF"}`
+	var got huestream.Frame
+	if err := json.Unmarshal([]byte(doc), &got); err != nil {
+		t.Fatal(err)
+	}
+	want, err := DecodeFrame([]byte(doc), ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, c := range want.Colors {
+		if rgb8(got[id]) != rgb8(c) {
+			t.Errorf("channel %d = %v, want %v", id, rgb8(got[id]), rgb8(c))
+		}
+	}
+}
+
+func TestDecodeFrameErrors(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want string // In the error.
+	}{
+		{``, "unexpected end"},
+		{`null`, "want an object"},
+		{`[1,2]`, "got a JSON array, want an object"},
+		{`"#ff0000"`, "got a JSON string, want an object"},
+		{`{"0":"#ff00"}`, `invalid color "#ff00"`},
+		{`{"0":"#gg0000"}`, `invalid color "#gg0000"`},
+		{`{"0":"reddish"}`, `"0": invalid color "reddish"`},
+		{`{"0":[1]}`, "got 1 numbers"},
+		{`{"0":[1,2,3,4]}`, "got 4 numbers"},
+		{`{"0":[256,0,0]}`, "component 256 out of 0 to 255"},
+		{`{"0":[-1,0,0]}`, "component -1 out of"},
+		{`{"0":[400,50]}`, "hue 400 out of 0 to 360"},
+		{`{"0":[40,150]}`, "saturation 150 out of 0 to 100"},
+		{`{"0":["a",1]}`, `invalid number "a"`},
+		{`{"0":"(1, 2"}`, "unbalanced ("},
+		{`{"0":"(1, x)"}`, `invalid number "x"`},
+		{`{"0":true}`, "invalid color true"},
+		{`{"0":{}}`, "no color"},
+		{`{"0":{"xy_color":[0.3,0.3]}}`, `unknown color key "xy_color"`},
+		{`{"0":{"hex":"#ff0000","rgb":[1,2,3]}}`, "several colors"},
+		{`{"0":{"hs_color":[1,2,3]}}`, "got 3 numbers, want 2"},
+		{`{"7":"#ff0000"}`, "unknown channel 7, the channels are [0 1 2]"},
+		{`{"-1":"#ff0000"}`, "unknown channel -1"},
+		{`{"lights":"#ff0000"}`, `unknown key "lights"`},
+		{`{"all":"#ff0000","color":"#00ff00"}`, `both "all" and "color"`},
+		{`{"transition":-1}`, "time -1 out of range"},
+		{`{"transition":"soon"}`, `invalid time "soon"`},
+		{`{"transition":[1]}`, "invalid time [1]"},
+		{`{"brightness":300}`, "brightness 300 out of 0 to 255"},
+		{`{"brightness_pct":101}`, "brightness 101 out of 0 to 100"},
+	}
+	for _, tc := range tests {
+		_, err := DecodeFrame([]byte(tc.doc), ids)
+		if err == nil {
+			t.Errorf("%s: decoded", tc.doc)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.want) || !strings.HasPrefix(err.Error(), "jsonframe: ") {
+			t.Errorf("%s: error %q, want it to contain %q", tc.doc, err, tc.want)
+		}
+	}
+}
+
+func TestDecodeEffect(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want Effect
+	}{
+		{``, Effect{}},
+		{"  \n", Effect{}},
+		{`{}`, Effect{}},
+		{`{"name":"candle"}`, Effect{Name: "candle"}},
+		{`{"effect":" Sparkle "}`, Effect{Name: "sparkle"}},
+		{`{"name":"candle","duration":"10m"}`, Effect{Name: "candle", Duration: 10 * time.Minute}},
+		{`{"name":"candle","duration":90}`, Effect{Name: "candle", Duration: 90 * time.Second}},
+		{`{"name":"candle","duration":"2.5"}`, Effect{Name: "candle", Duration: 2500 * time.Millisecond}},
+		{`{"name":"candle","color":null}`, Effect{Name: "candle"}},
+	}
+	for _, tc := range tests {
+		e, err := DecodeEffect([]byte(tc.doc))
+		if err != nil {
+			t.Errorf("%q: %v", tc.doc, err)
+			continue
+		}
+		if e != tc.want {
+			t.Errorf("%q: got %+v, want %+v", tc.doc, e, tc.want)
+		}
+	}
+
+	e, err := DecodeEffect([]byte(`{"name":"sparkle","color":[30,100]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rgb8(e.Color), [3]uint32{255, 128, 0}; got != want {
+		t.Errorf("color = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeEffectErrors(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{`[]`, "want an object"},
+		{`{"name":3}`, "invalid name 3"},
+		{`{"name":"a","effect":"b"}`, `both "name" and "effect"`},
+		{`{"color":"nope"}`, `"color": invalid color "nope"`},
+		{`{"duration":"forever"}`, `invalid time "forever"`},
+		{`{"speed":2}`, `unknown key "speed"`},
+	}
+	for _, tc := range tests {
+		_, err := DecodeEffect([]byte(tc.doc))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error %v, want it to contain %q", tc.doc, err, tc.want)
+		}
+	}
+}
+
+func TestDecodeColor(t *testing.T) {
+	c, err := DecodeColor([]byte(`"#ff8800"`))
+	if err != nil || rgb8(c) != orange {
+		t.Errorf("got %v, %v, want orange", c, err)
+	}
+	if c, err := DecodeColor([]byte(`null`)); c != nil || err != nil {
+		t.Errorf("null: got %v, %v, want no color", c, err)
+	}
+}
+
+func TestFade(t *testing.T) {
+	from := huestream.Frame{0: color.Black}
+	to := huestream.Frame{0: color.White, 1: color.White}
+	var frames []huestream.Frame
+	for f := range Fade(from, to, time.Second, 4) {
+		frames = append(frames, f)
+	}
+	if len(frames) != 4 {
+		t.Fatalf("got %d frames, want 4", len(frames))
+	}
+	for i, want := range []uint32{63, 127, 191, 255} {
+		if got := rgb8(frames[i][0])[0]; got != want {
+			t.Errorf("frame %d: channel 0 = %d, want %d", i, got, want)
+		}
+		if got := rgb8(frames[i][1])[0]; got != 255 {
+			t.Errorf("frame %d: channel 1 = %d, want 255 without a color to fade from", i, got)
+		}
+	}
+
+	n := 0
+	for range Fade(from, to, 0, 25) {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("no transition: got %d frames, want 1", n)
+	}
+}
@@ -0,0 +1,236 @@
+package huestream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// NoEffect is the effect of a light playing none, set to stop an effect.
+const NoEffect = "no_effect"
+
+// Light is a light of the bridge, with the effects it plays natively. A
+// native effect runs on the light itself, without a stream or any network
+// traffic.
+type Light struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Effect  string   `json:"effect"`   // The effect playing, NoEffect if none.
+	Effects []string `json:"effects"`  // NoEffect, and as "candle" or "fire".
+	PowerOn string   `json:"power_on"` // The mode of its PowerOn.
+
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// Capabilities are the ranges of brightness and color temperature a light
+// renders.
+type Capabilities struct {
+	Color      bool    `json:"color"`       // Whether it renders colors.
+	Dimmable   bool    `json:"dimmable"`    // Whether its brightness can be set.
+	MinDimming float64 `json:"min_dimming"` // Its lowest brightness, from 0 to 1.
+
+	// The range of its color temperatures, in mirek, zero if it has none.
+	MirekMin int `json:"mirek_min"`
+	MirekMax int `json:"mirek_max"`
+}
+
+// ClampMirek returns the color temperature m in the range of the light, m
+// if it has none.
+func (c Capabilities) ClampMirek(m int) int {
+	if c.MirekMax == 0 {
+		return m
+	}
+	return min(max(m, c.MirekMin), c.MirekMax)
+}
+
+// ClampBrightness returns the brightness b, from 0 to 1, raised to the
+// lowest brightness of the light so that it does not turn off, 0 staying
+// 0. A light not dimmable is on at 1 for any b above 0.
+func (c Capabilities) ClampBrightness(b float64) float64 {
+	switch {
+	case b <= 0:
+		return 0
+	case !c.Dimmable:
+		return 1
+	}
+	return min(max(b, c.MinDimming), 1)
+}
+
+// light is a light resource of the CLIP v2 API.
+type light struct {
+	ID    string `json:"id"`
+	Owner struct {
+		RID string `json:"rid"`
+	} `json:"owner"`
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Effects struct {
+		Status string   `json:"status"`
+		Values []string `json:"effect_values"`
+	} `json:"effects"`
+	Powerup struct {
+		Preset string `json:"preset"`
+	} `json:"powerup"`
+	Dimming *struct {
+		MinDimLevel float64 `json:"min_dim_level"` // In percent.
+	} `json:"dimming"`
+	ColorTemperature *struct {
+		Schema struct {
+			Min int `json:"mirek_minimum"`
+			Max int `json:"mirek_maximum"`
+		} `json:"mirek_schema"`
+	} `json:"color_temperature"`
+	Color *struct{} `json:"color"`
+}
+
+func (l light) export() Light {
+	e := Light{ID: l.ID, Name: l.Metadata.Name, Effect: l.Effects.Status, Effects: l.Effects.Values, PowerOn: l.Powerup.Preset}
+	e.Capabilities.Color = l.Color != nil
+	if l.Dimming != nil {
+		e.Capabilities.Dimmable = true
+		e.Capabilities.MinDimming = l.Dimming.MinDimLevel / 100
+	}
+	if ct := l.ColorTemperature; ct != nil {
+		e.Capabilities.MirekMin, e.Capabilities.MirekMax = ct.Schema.Min, ct.Schema.Max
+	}
+	return e
+}
+
+// Lights lists the lights of the bridge at host with their effects and
+// capabilities, using the CLIP v2 API.
+func Lights(ctx context.Context, host, username string, opts ...Option) ([]Light, error) {
+	c := newClient(host, username, "")
+	c.cfg = newConfig(opts)
+
+	var lights []light
+	err := c.traced(ctx, "lights", "", func(ctx context.Context) error {
+		return c.getResource(ctx, "light", &lights)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("lights: %w", err)
+	}
+	list := make([]Light, len(lights))
+	for i, l := range lights {
+		list[i] = l.export()
+	}
+	return list, nil
+}
+
+// SetLightEffect starts the native effect of the light lightID, one of the
+// Effects of its Light, or stops it with NoEffect.
+func SetLightEffect(ctx context.Context, host, username, lightID, effect string, opts ...Option) error {
+	c := newClient(host, username, "")
+	c.cfg = newConfig(opts)
+
+	err := c.traced(ctx, "light effect", "", func(ctx context.Context) error {
+		return c.setEffect(ctx, lightID, effect)
+	})
+	if err != nil {
+		return fmt.Errorf("light %s: effect %s: %w", lightID, effect, err)
+	}
+	return nil
+}
+
+// SetAreaEffect starts the native effect on the lights of the area areaID
+// supporting it, or stops their effects with NoEffect. The lights not
+// supporting the effect are left as they are, the failures are reported as
+// a *LightError each, joined.
+//
+// The lights render the stream while the area is streamed, SetAreaEffect
+// then fails with ErrStreamActive: close the Stream first to hand the
+// lights over to their effect, as for the night once the show is over.
+func SetAreaEffect(ctx context.Context, host, username, areaID, effect string, opts ...Option) error {
+	c := newClient(host, username, "")
+	c.cfg = newConfig(opts)
+
+	err := c.traced(ctx, "area effect", areaID, func(ctx context.Context) error {
+		status, lights, err := c.areaLights(ctx, areaID)
+		if err != nil {
+			return err
+		}
+		if status == "active" {
+			return ErrStreamActive
+		}
+		var errs []error
+		set := 0
+		for _, l := range lights {
+			if effect != NoEffect && !slices.Contains(l.Effects.Values, effect) {
+				continue
+			}
+			if err := c.setEffect(ctx, l.ID, effect); err != nil {
+				errs = append(errs, &LightError{Light: l.ID, Err: err})
+			}
+			set++
+		}
+		if set == 0 {
+			return errors.New("no light supports the effect")
+		}
+		return errors.Join(errs...)
+	})
+	if err != nil {
+		return fmt.Errorf("area %s: effect %s: %w", areaID, effect, err)
+	}
+	return nil
+}
+
+func (c *client) setEffect(ctx context.Context, lightID, effect string) error {
+	var body struct {
+		Effects struct {
+			Effect string `json:"effect"`
+		} `json:"effects"`
+	}
+	body.Effects.Effect = effect
+	return c.putResource(ctx, "light", lightID, body)
+}
+
+// areaLights returns the status of the area areaID and its lights, the
+// lights of the entertainment services of its channels.
+func (c *client) areaLights(ctx context.Context, areaID string) (status string, lights []light, err error) {
+	type ref struct {
+		RID string `json:"rid"`
+	}
+	var configs []struct {
+		Status   string `json:"status"`
+		Channels []struct {
+			Members []struct {
+				Service ref `json:"service"`
+			} `json:"members"`
+		} `json:"channels"`
+	}
+	if err := c.getResource(ctx, "entertainment_configuration/"+areaID, &configs); err != nil {
+		return "", nil, err
+	}
+	if len(configs) == 0 {
+		return "", nil, ErrAreaNotFound
+	}
+	var services []struct {
+		ID    string `json:"id"`
+		Owner ref    `json:"owner"`
+	}
+	if err := c.getResource(ctx, "entertainment", &services); err != nil {
+		return "", nil, err
+	}
+	var all []light
+	if err := c.getResource(ctx, "light", &all); err != nil {
+		return "", nil, err
+	}
+
+	devices := make(map[string]bool) // Owning the services of the area.
+	for _, ch := range configs[0].Channels {
+		for _, m := range ch.Members {
+			for _, s := range services {
+				if s.ID == m.Service.RID {
+					devices[s.Owner.RID] = true
+				}
+			}
+		}
+	}
+	for _, l := range all {
+		if devices[l.Owner.RID] {
+			lights = append(lights, l)
+		}
+	}
+	return configs[0].Status, lights, nil
+}
@@ -0,0 +1,155 @@
+package huestream_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huetest"
+)
+
+func TestLights(t *testing.T) {
+	b := huetest.NewBridge(t)
+	ctx := context.Background()
+
+	if err := huestream.SetLightEffect(ctx, b.Host, b.Username, "light-1", "candle", b.Options()...); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.LightEffect(1); got != "candle" {
+		t.Errorf("got effect %q, want candle", got)
+	}
+
+	lights, err := huestream.Lights(ctx, b.Host, b.Username, b.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lights) != huetest.Lights {
+		t.Fatalf("got %d lights, want %d", len(lights), huetest.Lights)
+	}
+	for i, l := range lights {
+		want := huestream.NoEffect
+		if i == 1 {
+			want = "candle"
+		}
+		if l.Name != huetest.LightName(i) || l.Effect != want || !slices.Contains(l.Effects, "fire") || !l.Capabilities.Color {
+			t.Errorf("light %d: got %+v, want effect %s", i, l, want)
+		}
+	}
+
+	if err := huestream.SetLightEffect(ctx, b.Host, b.Username, "light-1", "disco", b.Options()...); err == nil {
+		t.Error("unsupported effect accepted")
+	}
+}
+
+func TestSetAreaEffect(t *testing.T) {
+	b := huetest.NewBridge(t)
+	ctx := context.Background()
+
+	if err := huestream.SetAreaEffect(ctx, b.Host, b.Username, b.AreaID, "fire", b.Options()...); err != nil {
+		t.Fatal(err)
+	}
+	for i := range huetest.Lights {
+		if got := b.LightEffect(i); got != "fire" {
+			t.Errorf("light %d: got effect %q, want fire", i, got)
+		}
+	}
+	if err := huestream.SetAreaEffect(ctx, b.Host, b.Username, b.AreaID, "disco", b.Options()...); err == nil {
+		t.Error("effect supported by no light accepted")
+	}
+	if err := huestream.SetAreaEffect(ctx, b.Host, b.Username, "missing", "fire", b.Options()...); !errors.Is(err, huestream.ErrAreaNotFound) {
+		t.Errorf("unknown area: got %v, want ErrAreaNotFound", err)
+	}
+
+	// The stream has the lights until it is closed.
+	s, err := huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, b.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := huestream.SetAreaEffect(ctx, b.Host, b.Username, b.AreaID, "candle", b.Options()...); !errors.Is(err, huestream.ErrStreamActive) {
+		t.Errorf("streamed area: got %v, want ErrStreamActive", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := huestream.SetAreaEffect(ctx, b.Host, b.Username, b.AreaID, huestream.NoEffect, b.Options()...); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.LightEffect(0); got != huestream.NoEffect {
+		t.Errorf("got effect %q, want %s", got, huestream.NoEffect)
+	}
+}
+
+func TestLightCapabilities(t *testing.T) {
+	var resources []byte
+	for _, name := range []string{"ct_bulb", "color_bulb", "plug"} {
+		b, err := os.ReadFile(filepath.Join("testdata", "lights", name+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resources) > 0 {
+			resources = append(resources, ',')
+		}
+		resources = append(resources, b...)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"errors":[],"data":[%s]}`, resources)
+	}))
+	defer srv.Close()
+
+	lights, err := huestream.Lights(context.Background(), "127.0.0.1", "user", huestream.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []huestream.Capabilities{
+		{Dimmable: true, MinDimming: 0.02, MirekMin: 153, MirekMax: 454},
+		{Color: true, Dimmable: true, MinDimming: 0.002, MirekMin: 153, MirekMax: 500},
+		{},
+	}
+	if len(lights) != len(want) {
+		t.Fatalf("got %d lights, want %d", len(lights), len(want))
+	}
+	for i, l := range lights {
+		if l.Capabilities != want[i] {
+			t.Errorf("%s: got %+v, want %+v", l.Name, l.Capabilities, want[i])
+		}
+	}
+
+	ct, plug := lights[0].Capabilities, lights[2].Capabilities
+	for _, tt := range []struct {
+		c          huestream.Capabilities
+		brightness float64
+		want       float64
+	}{
+		{ct, 0.01, 0.02},
+		{ct, 0.5, 0.5},
+		{ct, 0, 0},
+		{ct, 1.2, 1},
+		{plug, 0.01, 1},
+		{plug, 0, 0},
+	} {
+		if got := tt.c.ClampBrightness(tt.brightness); got != tt.want {
+			t.Errorf("%+v: ClampBrightness(%v) = %v, want %v", tt.c, tt.brightness, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		c     huestream.Capabilities
+		mirek int
+		want  int
+	}{
+		{ct, 500, 454},
+		{ct, 100, 153},
+		{ct, 300, 300},
+		{plug, 500, 500},
+	} {
+		if got := tt.c.ClampMirek(tt.mirek); got != tt.want {
+			t.Errorf("%+v: ClampMirek(%d) = %d, want %d", tt.c, tt.mirek, got, tt.want)
+		}
+	}
+}
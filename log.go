@@ -0,0 +1,59 @@
+package huestream
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/pion/logging"
+)
+
+// discardHandler is the slog.Handler of the default logger, it drops every
+// record.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discardLogger = slog.New(discardHandler{})
+
+// logger returns the logger set by WithLogger, or one discarding
+// everything.
+func (c config) logger() *slog.Logger {
+	if c.log == nil {
+		return discardLogger
+	}
+	return c.log
+}
+
+// logger returns the logger of the Stream, the zero Stream logs nothing.
+func (s *Stream) logger() *slog.Logger {
+	if s.log == nil {
+		return discardLogger
+	}
+	return s.log
+}
+
+// pionLoggers makes pion/dtls log to a slog.Logger, with the scope of each
+// logger as the "component" attribute. Trace messages are logged at debug
+// level.
+type pionLoggers struct{ l *slog.Logger }
+
+func (f pionLoggers) NewLogger(scope string) logging.LeveledLogger {
+	return pionLogger{f.l.With("component", "dtls", "scope", scope)}
+}
+
+type pionLogger struct{ l *slog.Logger }
+
+func (p pionLogger) Trace(msg string)               { p.l.Debug(msg) }
+func (p pionLogger) Tracef(format string, a ...any) { p.l.Debug(fmt.Sprintf(format, a...)) }
+func (p pionLogger) Debug(msg string)               { p.l.Debug(msg) }
+func (p pionLogger) Debugf(format string, a ...any) { p.l.Debug(fmt.Sprintf(format, a...)) }
+func (p pionLogger) Info(msg string)                { p.l.Info(msg) }
+func (p pionLogger) Infof(format string, a ...any)  { p.l.Info(fmt.Sprintf(format, a...)) }
+func (p pionLogger) Warn(msg string)                { p.l.Warn(msg) }
+func (p pionLogger) Warnf(format string, a ...any)  { p.l.Warn(fmt.Sprintf(format, a...)) }
+func (p pionLogger) Error(msg string)               { p.l.Error(msg) }
+func (p pionLogger) Errorf(format string, a ...any) { p.l.Error(fmt.Sprintf(format, a...)) }
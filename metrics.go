@@ -0,0 +1,100 @@
+package huestream
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// MetricsHook receives measurements of the send path.
+//
+// The methods are called synchronously by the goroutine writing the frame,
+// they must be fast and safe for concurrent use.
+type MetricsHook interface {
+	// SendDuration reports how long a successful write to the connection took.
+	SendDuration(d time.Duration)
+	// FrameSize reports the size in bytes of a successfully written message.
+	FrameSize(n int)
+	// Error reports a failed write.
+	Error(err error)
+}
+
+// BridgeMetricsHook is a MetricsHook also measuring the calls to the bridge.
+// WithMetrics checks whether its hook implements it.
+type BridgeMetricsHook interface {
+	MetricsHook
+	// RequestDuration reports how long a CLIP request took, with its name,
+	// "start", "stop" or "configuration", and its error if it failed.
+	RequestDuration(name string, d time.Duration, err error)
+	// Throttled reports a CLIP request rejected with 429 Too Many Requests.
+	Throttled()
+	// Reconnect reports a session recovered after a failure, see
+	// WithRecovery.
+	Reconnect()
+}
+
+// histogramBuckets is the number of buckets of histogram. Bucket i holds the
+// durations in [2^(i-1), 2^i) microseconds, the last one holds everything
+// above ~1s.
+const histogramBuckets = 22
+
+// histogram is a lock-free histogram of send durations with power of two
+// buckets, backing the latencies reported by Stats.
+type histogram struct {
+	buckets [histogramBuckets]atomic.Uint64
+}
+
+// observe records the duration of a send.
+func (h *histogram) observe(d time.Duration) {
+	us := uint64(max(d.Microseconds(), 0))
+	i := min(bits.Len64(us), histogramBuckets-1)
+	h.buckets[i].Add(1)
+}
+
+// quantile returns an upper bound of the q-quantile of the observed
+// durations, or 0 if nothing was observed.
+func (h *histogram) quantile(q float64) time.Duration {
+	return h.snapshot().quantile(q)
+}
+
+// histogramCounts are the counts of the buckets of a histogram.
+type histogramCounts [histogramBuckets]uint64
+
+// snapshot returns the current counts.
+func (h *histogram) snapshot() histogramCounts {
+	var counts histogramCounts
+	for i := range h.buckets {
+		counts[i] = h.buckets[i].Load()
+	}
+	return counts
+}
+
+// sub returns the counts observed since prev.
+func (counts histogramCounts) sub(prev histogramCounts) histogramCounts {
+	for i := range counts {
+		counts[i] -= prev[i]
+	}
+	return counts
+}
+
+// quantile returns an upper bound of the q-quantile of the counts, or 0 if
+// they are all zero.
+func (counts histogramCounts) quantile(q float64) time.Duration {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(q * float64(total))
+	var seen uint64
+	for i, c := range counts {
+		seen += c
+		if seen > rank {
+			return time.Duration(1<<i) * time.Microsecond
+		}
+	}
+	return time.Duration(1<<(histogramBuckets-1)) * time.Microsecond
+}
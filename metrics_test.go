@@ -0,0 +1,81 @@
+package huestream
+
+import (
+	"image/color"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	var h histogram
+	if got := h.quantile(0.5); got != 0 {
+		t.Errorf("empty histogram p50 = %v, want 0", got)
+	}
+
+	for range 98 {
+		h.observe(100 * time.Microsecond)
+	}
+	h.observe(5 * time.Millisecond)
+	h.observe(5 * time.Millisecond)
+
+	if got, want := h.quantile(0.5), 128*time.Microsecond; got != want {
+		t.Errorf("p50 = %v, want %v", got, want)
+	}
+	if got, want := h.quantile(0.99), 8192*time.Microsecond; got != want {
+		t.Errorf("p99 = %v, want %v", got, want)
+	}
+}
+
+type countingHook struct {
+	sends, bytes, errs atomic.Int64
+}
+
+func (h *countingHook) SendDuration(time.Duration) { h.sends.Add(1) }
+func (h *countingHook) FrameSize(n int)            { h.bytes.Add(int64(n)) }
+func (h *countingHook) Error(error)                { h.errs.Add(1) }
+
+func TestWithMetrics(t *testing.T) {
+	hook := &countingHook{}
+	s, frames := pipeStream(t, WithMetrics(hook))
+
+	for range 3 {
+		if err := s.Send(Frame{0: color.White}); err != nil {
+			t.Fatal(err)
+		}
+		<-frames
+	}
+	s.currentConn().Close()
+	if err := s.Send(Frame{0: color.White}); err == nil {
+		t.Fatal("Send on a closed conn should fail")
+	}
+
+	if got := hook.sends.Load(); got != 3 {
+		t.Errorf("SendDuration called %d times, want 3", got)
+	}
+	if got := hook.bytes.Load(); got != 3*59 {
+		t.Errorf("FrameSize total %d, want %d", got, 3*59)
+	}
+	if got := hook.errs.Load(); got != 1 {
+		t.Errorf("Error called %d times, want 1", got)
+	}
+}
+
+func TestWriteAllocs(t *testing.T) {
+	discard := &funcConn{write: func(b []byte) (int, error) { return len(b), nil }}
+	s := newStream(discard, nil, testAreaID, newConfig([]Option{WithMetrics(&countingHook{})}))
+
+	b, err := message{areaID: testAreaID, idColors: Frame{0: color.White}}.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := s.write(b); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("write allocates %v times per message", allocs)
+	}
+}
@@ -0,0 +1,60 @@
+package huestream
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMirror(t *testing.T) {
+	ln, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	mirror, err := net.Dial("udp", ln.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, frames := pipeStream(t)
+	s.mirror = mirror
+
+	if err := s.Send(Frame{0: color.White}); err != nil {
+		t.Fatal(err)
+	}
+	sent := <-frames
+
+	ln.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := ln.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf[:n], sent) {
+		t.Errorf("mirrored % x, want the message sent % x", buf[:n], sent)
+	}
+}
+
+func TestMirrorFailure(t *testing.T) {
+	var handled error
+	broken := &funcConn{write: func([]byte) (int, error) { return 0, errors.New("unreachable") }}
+	s, frames := pipeStream(t, WithErrorHandler(func(err error) { handled = err }))
+	s.mirror = broken
+
+	for range 2 {
+		if err := s.Send(Frame{0: color.White}); err != nil {
+			t.Fatalf("Send with a broken mirror: %v", err)
+		}
+		<-frames
+	}
+	if n := s.Stats().MirrorErrors; n != 2 {
+		t.Errorf("Stats().MirrorErrors = %d, want 2", n)
+	}
+	s.Close()
+	if handled != nil {
+		t.Errorf("mirror failure %v reported to the error handler", handled)
+	}
+}
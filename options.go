@@ -0,0 +1,530 @@
+package huestream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pion/dtls/v3"
+
+	"github.com/rschio/huestream/internal/clock"
+	"github.com/rschio/huestream/internal/testhooks"
+	"github.com/rschio/huestream/wire"
+)
+
+// Option configures a Stream.
+type Option func(*config)
+
+type config struct {
+	errorHandler func(error)
+	errorChan    chan<- error
+	keepAlive    time.Duration
+	clock        clock.Clock
+	epoch        time.Time
+	metrics      MetricsHook
+	log          *slog.Logger
+	trace        Tracer
+
+	idleThreshold        time.Duration
+	recovery             time.Duration
+	pauseBlack           bool
+	smoothing            time.Duration
+	minBrightness        float64
+	channelMinBrightness map[int]float64
+	ditherLevels         int
+	sendBuffer           int
+	dropPolicy           DropPolicy
+	linearInput          bool
+	dedup                time.Duration
+	staleAfter           time.Duration
+	stalePolicy          StalePolicy
+	version              int
+	cipherSuites         []dtls.CipherSuiteID
+	pskIdentity          string
+	pskProvider          func(context.Context) ([]byte, error)
+	clientKey            []byte
+	mtu                  int
+	dialer               func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	baseURL    string
+	streamPort int
+
+	restoreSmartScenes bool
+	noPreflight        bool
+	autoClose          bool
+	externalLifecycle  bool
+	dscp               int
+	udpSendBuffer      int
+
+	dump          *frameDump
+	reportEvery   time.Duration
+	reportFunc    func(Report)
+	capture       *Capture
+	captureWriter *captureWriter // Opened by Start from capture.
+	mirror        string
+	mirrorConn    net.Conn // Opened by Start from mirror.
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{
+		clock:         clock.Real,
+		idleThreshold: defaultIdleThreshold,
+		smoothing:     defaultSmoothing,
+		version:       wire.VersionMajor,
+		cipherSuites:  []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// validate reports the options that can't be honored.
+func (c config) validate() error {
+	if c.version != wire.Version1 && c.version != wire.VersionMajor {
+		return fmt.Errorf("unsupported protocol version %d", c.version)
+	}
+	if len(c.cipherSuites) == 0 {
+		return errors.New("no cipher suite")
+	}
+	for _, id := range c.cipherSuites {
+		if !pskCipherSuites[id] {
+			return fmt.Errorf("cipher suite %s is not a supported PSK suite", dtls.CipherSuiteName(id))
+		}
+	}
+	if c.clientKey != nil && len(c.clientKey) != clientKeySize {
+		return fmt.Errorf("%w: must have %d bytes, got %d", ErrInvalidClientKey, clientKeySize, len(c.clientKey))
+	}
+	if c.baseURL != "" {
+		u, err := url.Parse(c.baseURL)
+		if err != nil {
+			return fmt.Errorf("base URL: %w", err)
+		}
+		if u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+			return fmt.Errorf("base URL %q is not an absolute HTTP URL", c.baseURL)
+		}
+	}
+	if c.streamPort < 0 || c.streamPort > 0xffff {
+		return fmt.Errorf("invalid stream port %d", c.streamPort)
+	}
+	if c.minBrightness < 0 || c.minBrightness >= 1 {
+		return fmt.Errorf("minimum brightness %v out of [0, 1)", c.minBrightness)
+	}
+	for id, level := range c.channelMinBrightness {
+		if level < 0 || level >= 1 {
+			return fmt.Errorf("minimum brightness %v of channel %d out of [0, 1)", level, id)
+		}
+	}
+	if c.dscp < 0 || c.dscp > 63 {
+		return fmt.Errorf("DSCP %d out of [0, 63]", c.dscp)
+	}
+	if c.udpSendBuffer < 0 {
+		return fmt.Errorf("UDP send buffer size %d is negative", c.udpSendBuffer)
+	}
+	if c.sendBuffer < 0 {
+		return fmt.Errorf("send buffer size %d is negative", c.sendBuffer)
+	}
+	if c.dropPolicy < BlockWhenFull || c.dropPolicy > DropNewest {
+		return fmt.Errorf("unknown drop policy %v", c.dropPolicy)
+	}
+	if c.ditherLevels != 0 && (c.ditherLevels < 2 || c.ditherLevels > 0x10000) {
+		return fmt.Errorf("dithering levels %d out of [2, 65536]", c.ditherLevels)
+	}
+	if c.smoothing < 0 {
+		return fmt.Errorf("negative smoothing %v", c.smoothing)
+	}
+	if c.mtu != 0 && c.mtu < minMTU {
+		return fmt.Errorf("MTU %d is too small for a %d bytes frame, minimum is %d", c.mtu, wire.MaxMessageSize, minMTU)
+	}
+	return nil
+}
+
+// minMTU is the smallest MTU fitting the largest message in one record. The
+// worst record overhead of the PSK suites is the one of CBC: header, IV, MAC
+// and padding.
+const minMTU = wire.MaxMessageSize + 13 + 16 + 32 + 16
+
+// clientKeySize is the size of a decoded clientKey.
+const clientKeySize = 16
+
+// pskCipherSuites are the PSK cipher suites implemented by pion/dtls.
+var pskCipherSuites = map[dtls.CipherSuiteID]bool{
+	dtls.TLS_PSK_WITH_AES_128_CCM:              true,
+	dtls.TLS_PSK_WITH_AES_128_CCM_8:            true,
+	dtls.TLS_PSK_WITH_AES_256_CCM_8:            true,
+	dtls.TLS_PSK_WITH_AES_128_GCM_SHA256:       true,
+	dtls.TLS_PSK_WITH_AES_128_CBC_SHA256:       true,
+	dtls.TLS_ECDHE_PSK_WITH_AES_128_CBC_SHA256: true,
+}
+
+func suiteNames(ids []dtls.CipherSuiteID) string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = dtls.CipherSuiteName(id)
+	}
+	return strings.Join(names, ", ")
+}
+
+// WithErrorHandler sets a function called for every failure that happens on
+// a goroutine owned by the Stream, such as a keepalive write error.
+//
+// The handler is never called concurrently with itself and it runs on a
+// dedicated goroutine, so a slow handler does not delay the sending of
+// frames. If the handler falls too far behind, errors are dropped: 16 are
+// queued, the newer ones are dropped and counted in Stats.ErrorsDropped.
+// The handler may close the Stream: Close doesn't wait for a running handler,
+// the errors still queued are delivered after it returns.
+func WithErrorHandler(h func(error)) Option {
+	return func(c *config) { c.errorHandler = h }
+}
+
+// WithErrorChannel makes the Stream send the failures of WithErrorHandler
+// to ch too, after the handler if both are set. The errors wait for ch in
+// the queue of the handler and are dropped the same way if it is not read.
+// The errors still queued when the Stream is closed are sent to ch only if
+// it is ready, the Stream never closes ch.
+func WithErrorChannel(ch chan<- error) Option {
+	return func(c *config) { c.errorChan = ch }
+}
+
+// WithLogger makes the Stream log its lifecycle to l: the start and stop
+// actions, the handshakes, the recoveries and the watchdog, with attributes
+// such as area_id, bridge_host, attempt and duration. The DTLS library logs
+// to l too, with a "component" attribute of "dtls".
+//
+// The start and close of the Stream and the recoveries are logged at info
+// level, the details at debug level and the failures as warnings. By
+// default nothing is logged.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *config) { c.log = l }
+}
+
+// WithTracer makes the Stream trace its calls to the bridge with t, see
+// Tracer. The spans of Start are children of the span of its context.
+func WithTracer(t Tracer) Option {
+	return func(c *config) { c.trace = t }
+}
+
+// WithFrameDump writes every message sent by the Stream to w, for
+// debugging: its time, a hex dump of its bytes and the decoding of the
+// bytes, its sequence number and the values of each channel.
+//
+// At most one message per every is dumped, the dump tells how many were
+// skipped. Zero dumps every message. The dumps are written while sending,
+// w should be fast.
+func WithFrameDump(w io.Writer, every time.Duration) Option {
+	return func(c *config) { c.dump = &frameDump{w: w, every: every} }
+}
+
+// WithReport makes the Stream summarize its activity every period: the
+// frames sent and the rate achieved, the write latencies, the drops and the
+// reconnections. The Report is passed to f, or logged at info level to the
+// logger of WithLogger if f is nil.
+//
+// f is called on a goroutine of its own, a slow f never delays the frames.
+// f may close the Stream: Close doesn't wait for a running f.
+func WithReport(every time.Duration, f func(Report)) Option {
+	return func(c *config) { c.reportEvery, c.reportFunc = every, f }
+}
+
+// WithKeepAlive makes the Stream resend the last frame so that no more than
+// the given interval passes without sending a frame.
+//
+// The bridge closes the stream after ~10s without messages, keepalive is
+// useful when the application only sends frames when the colors change.
+func WithKeepAlive(interval time.Duration) Option {
+	return func(c *config) { c.keepAlive = interval }
+}
+
+// withClock makes the Stream read the time and arm its timers with c
+// instead of the system clock. Keepalive, PlaySeq, SetTarget, the watchdog,
+// recovery and Pause all follow c, so tests can drive them with a fake clock
+// without sleeping. Other packages reach it with huetest.Clock.Option.
+func withClock(c clock.Clock) Option {
+	return func(cfg *config) { cfg.clock = c }
+}
+
+func init() {
+	testhooks.StreamClock = func(c clock.Clock) any { return withClock(c) }
+}
+
+// WithEpoch aligns the send loops of the Stream (PlaySeq and keepalive) to
+// the wall clock: with a period p, frames are sent at epoch + N*p.
+//
+// Processes sharing an NTP-synced clock and the same epoch stay in phase even
+// if they are started at different times. Steps of the wall clock are
+// followed, but a send loop that repeatedly falls more than a second behind
+// its schedule reverts to free-running, see Stats.AlignmentLosses.
+func WithEpoch(epoch time.Time) Option {
+	return func(c *config) { c.epoch = epoch }
+}
+
+// WithMetrics sets a hook receiving the duration, size and errors of every
+// write to the bridge. If m is a BridgeMetricsHook it also receives the
+// measurements of the CLIP requests and the reconnections.
+func WithMetrics(m MetricsHook) Option {
+	return func(c *config) { c.metrics = m }
+}
+
+// WithIdleThreshold sets how long the Stream may go without sending a frame
+// before its watchdog fires, the default is 8s. Zero disables the watchdog.
+//
+// The watchdog runs when an error handler or keepalive is set. With
+// keepalive it sends the last frame again, otherwise it reports an error
+// wrapping ErrStreamIdle to the error handler, which usually means the
+// application's render loop is stuck.
+func WithIdleThreshold(d time.Duration) Option {
+	return func(c *config) { c.idleThreshold = d }
+}
+
+// WithRecovery makes the Stream recover its session after a failure that
+// kills it, such as a bridge reboot, trying for at most deadline.
+//
+// When a write fails with a non-transient error, the Stream polls the bridge
+// until it answers, starts the stream again, redoes the handshake and resumes
+// sending. Every failed step is reported to the error handler as a
+// *RecoveryError, the outcome as ErrRecovered or ErrRecoveryFailed.
+func WithRecovery(deadline time.Duration) Option {
+	return func(c *config) { c.recovery = deadline }
+}
+
+// WithPauseBlack makes Pause turn the lights of the last frame black instead
+// of holding their colors.
+func WithPauseBlack() Option {
+	return func(c *config) { c.pauseBlack = true }
+}
+
+// WithSmoothing sets the time constant of the sender of SetTarget, the
+// default is 100ms. A channel covers 63% of the way to its target in tau,
+// 95% in 3*tau. Zero makes the channels jump to their targets.
+func WithSmoothing(tau time.Duration) Option {
+	return func(c *config) { c.smoothing = tau }
+}
+
+// WithStaleTarget sets what the sender of SetTarget does once no target was
+// set for the given time, StaleHold by default. With StaleDecay the lights
+// fade to black, as when the source of the targets is gone, until the next
+// target.
+func WithStaleTarget(after time.Duration, p StalePolicy) Option {
+	return func(c *config) { c.staleAfter, c.stalePolicy = after, p }
+}
+
+// WithMinBrightness sets the lowest brightness of the lamps, from 0 to 1,
+// for the lamps that turn off abruptly below a level, ending slow fades to
+// black with a pop. The brightness of the colors sent, their largest
+// component, is mapped from (0, 1] onto (level, 1]: the fades stay smooth
+// and reach the floor as they reach 0. Black still turns the lamps off.
+func WithMinBrightness(level float64) Option {
+	return func(c *config) { c.minBrightness = level }
+}
+
+// WithChannelMinBrightness is like WithMinBrightness for each channel of
+// levels, overriding the level of WithMinBrightness. The lowest brightness
+// of a light is the MinDimming of its Capabilities.
+func WithChannelMinBrightness(levels map[int]float64) Option {
+	return func(c *config) { c.channelMinBrightness = maps.Clone(levels) }
+}
+
+// WithSendBuffer makes Stream.Enqueue queue the frames in a buffer of n
+// frames, sent in order by a goroutine of the Stream, the producer not
+// waiting for the network. What Enqueue does when the buffer is full is set
+// by p, see DropPolicy; the frames dropped are counted in Stats. A frame
+// holds the channels it changes only: dropping one with the others sent may
+// leave a channel behind until its next change, prefer frames of every
+// channel with the policies dropping frames.
+func WithSendBuffer(n int, p DropPolicy) Option {
+	return func(c *config) { c.sendBuffer, c.dropPolicy = n, p }
+}
+
+// WithDithering makes the Stream dither the colors in time for lamps
+// rendering only levels levels per component, such as 256, whose slow dark
+// fades step visibly. A value between two levels is sent as the two levels
+// in turn, in the ratio making their average the value: at 50 Hz the eye
+// sees the average. The dithering is disabled by default.
+func WithDithering(levels int) Option {
+	return func(c *config) { c.ditherLevels = levels }
+}
+
+// WithLinearInput makes the Stream take the colors passed to it as linear
+// intensities, proportional to the light emitted, instead of sRGB colors.
+// The colors are sRGB encoded for the bridge.
+func WithLinearInput() Option {
+	return func(c *config) { c.linearInput = true }
+}
+
+// WithDedup makes Send and SendContext skip the frames whose message is
+// the last one written, but for the sequence number, as when an effect and
+// the keepalive send the same colors. A repeated frame is still written
+// once maxHold passed since the last write, so that the session is kept
+// alive. The frames skipped are counted in Stats.FramesSuppressed.
+func WithDedup(maxHold time.Duration) Option {
+	return func(c *config) { c.dedup = maxHold }
+}
+
+// WithoutPreflight makes Start start the stream without fetching the
+// configuration of the area first. The preflight checks that the area
+// exists and has channels, failing with an *AreaError otherwise, and keeps
+// its channels for the Stream. It is skipped in version 1, see
+// WithProtocolVersion.
+func WithoutPreflight() Option {
+	return func(c *config) { c.noPreflight = true }
+}
+
+// WithAutoClose makes the Stream close itself when the ctx passed to Start
+// is done, stop action included, as on the signal.NotifyContext of a
+// program interrupted. Without it the ctx only bounds the start, the Stream
+// runs until Close. Close may still be called, before or after.
+func WithAutoClose() Option {
+	return func(c *config) { c.autoClose = true }
+}
+
+// WithExternalLifecycle makes the Stream leave the start and stop actions,
+// which activate the streaming mode of the area on the bridge and end it,
+// to someone else: Start connects to an area already active and Close only
+// closes the connection. It takes over the session of a Stream detached by
+// another process, see Stream.Detach, or of a stream activated with the
+// CLIP API. The session recovery of WithRecovery still sends the start
+// action.
+func WithExternalLifecycle() Option {
+	return func(c *config) { c.externalLifecycle = true }
+}
+
+// WithSmartSceneRestore makes Start note the smart scenes active, as a
+// natural light scene, and Close activate them again once the stream is
+// stopped, instead of leaving the lights in the state the bridge restores.
+// The smart scenes are a feature of the CLIP v2 API, the option is ignored
+// in version 1, see WithProtocolVersion.
+func WithSmartSceneRestore() Option {
+	return func(c *config) { c.restoreSmartScenes = true }
+}
+
+// WithProtocolVersion selects the major version of the streaming protocol,
+// 2 (the default) or 1 for bridges with older firmware.
+//
+// In version 1 the area ID passed to Start is the ID of an entertainment
+// group of the v1 API, the stream is activated through that API and the keys
+// of a Frame are light IDs.
+func WithProtocolVersion(v int) Option {
+	return func(c *config) { c.version = v }
+}
+
+// WithCipherSuites sets the DTLS cipher suites offered to the bridge, in
+// order of preference. They must be PSK suites, the default is
+// TLS_PSK_WITH_AES_128_GCM_SHA256, the one of the Hue bridges.
+func WithCipherSuites(suites ...dtls.CipherSuiteID) Option {
+	return func(c *config) { c.cipherSuites = suites }
+}
+
+// WithPSKIdentity sets the PSK identity sent in the DTLS handshake, by
+// default the username. The username is still used to authenticate the
+// HTTP requests.
+func WithPSKIdentity(identity string) Option {
+	return func(c *config) { c.pskIdentity = identity }
+}
+
+// WithPSKProvider sets a function returning the pre-shared key of the DTLS
+// handshake, used instead of decoding the clientKey passed to Start, which
+// may then be empty.
+//
+// The provider is called for every handshake, including the ones of a
+// session recovery or renewal, so the key does not have to stay in memory
+// between them. Its errors fail the handshake. The returned slice is zeroed
+// after the handshake, the provider must return a new one on every call.
+func WithPSKProvider(p func(ctx context.Context) ([]byte, error)) Option {
+	return func(c *config) { c.pskProvider = p }
+}
+
+// WithClientKey sets the clientKey as the 16 decoded bytes instead of the
+// hex string passed to Start, which may then be empty.
+//
+// The Stream keeps a copy of key and zeroes it on Close.
+func WithClientKey(key []byte) Option {
+	key = bytes.Clone(key)
+	return func(c *config) { c.clientKey = key }
+}
+
+// WithMTU sets the MTU used by the DTLS connection, by default the one of
+// pion/dtls (1200 bytes). It counts the DTLS records only, without the IP
+// and UDP headers.
+//
+// Only the handshake is affected: its flights are fragmented to fit the MTU,
+// which helps when the path to the bridge, e.g. a VPN tunnel, drops larger
+// datagrams. Frames are at most 192 bytes and always fit. Start fails if the
+// MTU can't hold a frame.
+func WithMTU(mtu int) Option {
+	return func(c *config) { c.mtu = mtu }
+}
+
+// WithDSCP marks the datagrams of the stream with the DSCP value, as
+// DSCPExpedited, for the networks prioritizing real-time traffic: the WMM
+// queues of a Wi-Fi access point map EF and AF41 to their voice and video
+// queues. It sets IP_TOS, or IPV6_TCLASS, on the UDP socket before the
+// handshake. Start fails if the platform or its permissions don't allow it,
+// or if the dialer of WithDialer does not return a socket. Zero, the
+// default, leaves the datagrams unmarked.
+func WithDSCP(value int) Option {
+	return func(c *config) { c.dscp = value }
+}
+
+// WithUDPSendBuffer sets the size of the send buffer of the UDP socket, in
+// bytes, for the hosts whose writes fail with ENOBUFS when the buffer fills
+// up during a scheduling hiccup. The kernel may clamp the size, or double it
+// for its bookkeeping as Linux does: the effective size is reported in
+// Stats.UDPSendBuffer. Start fails if the dialer of WithDialer does not
+// return a socket. Zero, the default, keeps the size of the system.
+func WithUDPSendBuffer(bytes int) Option {
+	return func(c *config) { c.udpSendBuffer = bytes }
+}
+
+// WithDialer sets the function opening the UDP connection to the bridge, the
+// DTLS session is established over the returned connection. By default a
+// UDP socket is used.
+//
+// It allows userspace network stacks, capture shims or in-process
+// simulators to carry the stream. The dialer is called for every handshake,
+// with network "udp" and the address of the bridge stream port.
+func WithDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(c *config) { c.dialer = dial }
+}
+
+// WithMirror sends a copy of every message written to the bridge to the
+// UDP address addr, as "127.0.0.1:2101", in plaintext, to watch the stream
+// live with another tool such as huestream watch. The copies are best
+// effort: their failures are only counted in Stats.MirrorErrors, they never
+// fail a send nor reach the error handler.
+func WithMirror(addr string) Option {
+	return func(c *config) { c.mirror = addr }
+}
+
+// WithCapture writes every message sent to the bridge to a pcapng file that
+// can be opened with Wireshark.
+//
+// The DTLS encryption can't be captured, the plaintext messages are written
+// as UDP datagrams between the addresses of the connection, timestamped when
+// they are written. The file is written on the send path, which makes each
+// send a bit slower. A failure of the capture is reported to the error
+// handler and stops the capture, not the Stream.
+func WithCapture(c Capture) Option {
+	return func(cfg *config) { cfg.capture = &c }
+}
+
+// WithBaseURL sets the URL of the bridge API, by default https://<host>. It
+// allows to reach the API through a proxy or a fake bridge, see the huetest
+// package.
+func WithBaseURL(u string) Option {
+	return func(c *config) { c.baseURL = u }
+}
+
+// WithStreamPort sets the UDP port of the stream, by default 2100, the one
+// of the bridge.
+func WithStreamPort(port int) Option {
+	return func(c *config) { c.streamPort = port }
+}
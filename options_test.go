@@ -0,0 +1,175 @@
+package huestream
+
+import (
+	"errors"
+	"image/color"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+func TestKeepAliveResendsLastFrame(t *testing.T) {
+	s, frames := pipeStream(t, WithKeepAlive(10*time.Millisecond))
+
+	if err := s.Send(Frame{3: color.White}); err != nil {
+		t.Fatal(err)
+	}
+	sent := <-frames
+
+	for range 3 {
+		select {
+		case b := <-frames:
+			if string(b) != string(sent) {
+				t.Fatalf("keepalive sent %x, want %x", b, sent)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("keepalive frame not received")
+		}
+	}
+}
+
+func TestKeepAliveWithoutFrames(t *testing.T) {
+	_, frames := pipeStream(t, WithKeepAlive(10*time.Millisecond))
+
+	select {
+	case b := <-frames:
+		if len(b) != 52 {
+			t.Errorf("got a %d bytes message, want only the header", len(b))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("keepalive frame not received")
+	}
+}
+
+func TestErrorHandlerReceivesKeepAliveErrors(t *testing.T) {
+	errs := make(chan error, errorQueueSize)
+	s, _ := pipeStream(t,
+		WithKeepAlive(5*time.Millisecond),
+		WithErrorHandler(func(err error) { errs <- err }),
+	)
+	s.currentConn().Close()
+
+	for {
+		select {
+		case err := <-errs:
+			// Overruns of the real clock may be reported first.
+			if errors.Is(err, ErrOverrun) {
+				continue
+			}
+			if !errors.Is(err, io.ErrClosedPipe) {
+				t.Errorf("got %v, want %v", err, io.ErrClosedPipe)
+			}
+			return
+		case <-time.After(time.Second):
+			t.Fatal("error handler not called")
+		}
+	}
+}
+
+func TestCloseFromErrorHandler(t *testing.T) {
+	var stream atomic.Pointer[Stream]
+	closed := make(chan error, 1)
+	s, _ := pipeStream(t,
+		WithIdleThreshold(20*time.Millisecond),
+		WithErrorHandler(func(err error) {
+			if errors.Is(err, ErrStreamIdle) {
+				closed <- stream.Load().Close()
+			}
+		}),
+	)
+	stream.Store(s)
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close called from the error handler deadlocked")
+	}
+}
+
+func TestErrorDispatcherIsSequential(t *testing.T) {
+	var running, calls int
+	d := newErrorDispatcher(func(error) {
+		running++
+		if running > 1 {
+			t.Error("handler called concurrently")
+		}
+		calls++
+		time.Sleep(time.Millisecond)
+		running--
+	}, nil)
+
+	for range errorQueueSize {
+		d.report(errors.New("fail"))
+	}
+	d.close()
+
+	if calls != errorQueueSize {
+		t.Errorf("handler called %d times, want %d", calls, errorQueueSize)
+	}
+}
+
+func TestKeepAliveGapNeverExceedsInterval(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, frames := pipeStream(t,
+		withClock(clk),
+		WithKeepAlive(100*time.Millisecond),
+		WithIdleThreshold(0),
+	)
+
+	// Send a frame just after the first keepalive check.
+	clk.BlockUntil(1)
+	clk.Advance(26 * time.Millisecond)
+	if err := s.Send(Frame{0: color.White}); err != nil {
+		t.Fatal(err)
+	}
+	<-frames
+	sent := clk.Now()
+
+	for {
+		clk.BlockUntil(1)
+		clk.Advance(25 * time.Millisecond)
+		select {
+		case <-frames:
+			if gap := clk.Now().Sub(sent); gap > 100*time.Millisecond {
+				t.Errorf("keepalive sent after %v, want at most 100ms", gap)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+		if clk.Now().Sub(sent) > time.Second {
+			t.Fatal("keepalive never sent")
+		}
+	}
+}
+
+func TestOverrunReported(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	errs := make(chan error, errorQueueSize)
+	_, frames := pipeStream(t,
+		withClock(clk),
+		WithKeepAlive(100*time.Millisecond),
+		WithIdleThreshold(0),
+		WithErrorHandler(func(err error) { errs <- err }),
+	)
+	go func() {
+		for range frames {
+		}
+	}()
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrOverrun) {
+			t.Fatalf("got %v, want %v", err, ErrOverrun)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("overrun not reported")
+	}
+}
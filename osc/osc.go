@@ -0,0 +1,465 @@
+// Package osc receives Open Sound Control messages, as sent by TouchDesigner,
+// Max/MSP or Resolume, and streams the colors they set to an entertainment
+// area:
+//
+//	r, err := osc.Listen(":9000", []int{0, 1, 2})
+//	...
+//	err = r.Run(ctx, stream)
+//
+// By default the Receiver understands the addresses of DefaultMap:
+//
+//	/hue/channel/<id>/rgb  r g b
+//	/hue/channel/<id>/hsv  h s v
+//	/hue/all/rgb           r g b
+//	/hue/all/hsv           h s v
+//
+// The arguments are three floats, type tags ",fff" (or doubles), from 0 to
+// 1, the hue being a fraction of a turn. WithAddressMap sets other
+// addresses. The messages of bundles are applied at once, their time tags
+// are ignored.
+//
+// The frames are sent at most at the rate of the Receiver, the latest colors
+// win. The default rate, 12.5 Hz, is the fastest effect rate advised by the
+// Hue documentation: half the rate the bridge sends over ZigBee. The
+// messages to unknown addresses or with wrong type tags are dropped and
+// logged, once per address, to the logger of WithLogger.
+package osc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/wire"
+)
+
+// All is the channel of a Target setting every channel.
+const All = -1
+
+// Model is the color model of the arguments of a message.
+type Model int
+
+const (
+	RGB Model = iota // Red, green and blue.
+	HSV              // Hue, saturation and value.
+)
+
+func (m Model) String() string {
+	switch m {
+	case RGB:
+		return "rgb"
+	case HSV:
+		return "hsv"
+	}
+	return "Model(" + strconv.Itoa(int(m)) + ")"
+}
+
+// Target is what the messages to an address set.
+type Target struct {
+	Channel int // A channel ID, or All.
+	Model   Model
+}
+
+// AddressMap maps the OSC addresses to their targets.
+type AddressMap map[string]Target
+
+// DefaultMap returns the addresses <prefix>/channel/<id>/rgb and
+// <prefix>/channel/<id>/hsv of the channels, and <prefix>/all/rgb and
+// <prefix>/all/hsv.
+func DefaultMap(prefix string, channels []int) AddressMap {
+	m := AddressMap{
+		prefix + "/all/rgb": {Channel: All, Model: RGB},
+		prefix + "/all/hsv": {Channel: All, Model: HSV},
+	}
+	for _, id := range channels {
+		m[fmt.Sprintf("%s/channel/%d/rgb", prefix, id)] = Target{Channel: id, Model: RGB}
+		m[fmt.Sprintf("%s/channel/%d/hsv", prefix, id)] = Target{Channel: id, Model: HSV}
+	}
+	return m
+}
+
+// Stats holds counters about a Receiver.
+type Stats struct {
+	Messages   uint64 // Messages applied.
+	Unmatched  uint64 // Messages to addresses out of the map, dropped.
+	BadTypes   uint64 // Messages with wrong type tags, dropped.
+	Invalid    uint64 // Datagrams that are not valid OSC packets.
+	FramesSent uint64
+}
+
+// Option configures a Receiver.
+type Option func(*Receiver)
+
+// WithRate sets the maximum rate of the frames, by default 12.5 Hz.
+func WithRate(hz float64) Option {
+	return func(r *Receiver) { r.period = time.Duration(float64(time.Second) / hz) }
+}
+
+// WithAddressMap sets the addresses the Receiver understands, by default
+// DefaultMap("/hue", channels).
+func WithAddressMap(m AddressMap) Option {
+	return func(r *Receiver) { r.addrs = m }
+}
+
+// WithLogger makes the Receiver log the messages it drops to l.
+func WithLogger(l *slog.Logger) Option {
+	return func(r *Receiver) { r.log = l }
+}
+
+// Receiver receives OSC messages.
+type Receiver struct {
+	conn     net.PacketConn
+	channels []int
+	addrs    AddressMap
+	period   time.Duration
+	log      *slog.Logger
+
+	messages, unmatched, badTypes, invalid, framesSent atomic.Uint64
+
+	mu     sync.Mutex // Guards logged.
+	logged map[string]bool
+}
+
+// Listen listens for the OSC messages setting the channels on the UDP
+// address addr.
+func Listen(addr string, channels []int, opts ...Option) (*Receiver, error) {
+	if len(channels) > wire.MaxChannels {
+		return nil, fmt.Errorf("osc: %w: maximum is %d, got %d", huestream.ErrTooManyChannels, wire.MaxChannels, len(channels))
+	}
+	r := &Receiver{
+		channels: channels,
+		period:   time.Second * 2 / 25,
+		logged:   make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.addrs == nil {
+		r.addrs = DefaultMap("/hue", channels)
+	}
+	for a, t := range r.addrs {
+		if t.Channel != All && !slices.Contains(channels, t.Channel) {
+			return nil, fmt.Errorf("osc: address %s: unknown channel %d", a, t.Channel)
+		}
+		if t.Model != RGB && t.Model != HSV {
+			return nil, fmt.Errorf("osc: address %s: unknown model %v", a, t.Model)
+		}
+	}
+
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("osc: %w", err)
+	}
+	r.conn = conn
+	return r, nil
+}
+
+// Addr returns the address the Receiver listens on.
+func (r *Receiver) Addr() net.Addr { return r.conn.LocalAddr() }
+
+// Stats returns the counters of the Receiver.
+func (r *Receiver) Stats() Stats {
+	return Stats{
+		Messages:   r.messages.Load(),
+		Unmatched:  r.unmatched.Load(),
+		BadTypes:   r.badTypes.Load(),
+		Invalid:    r.invalid.Load(),
+		FramesSent: r.framesSent.Load(),
+	}
+}
+
+// Run streams the colors set by the messages to st until ctx is done or a
+// send fails. The channels start black. The Receiver stops listening when
+// Run returns, it returns nil when ctx is done.
+func (r *Receiver) Run(ctx context.Context, st huestream.Streamer) error {
+	// pending holds the last frame not sent yet.
+	pending := make(chan huestream.Frame, 1)
+	readErr := make(chan error, 1)
+	go func() { readErr <- r.read(pending) }()
+	reading := true
+	defer func() {
+		r.conn.Close()
+		if reading {
+			<-readErr
+		}
+	}()
+
+	var last time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			reading = false
+			return fmt.Errorf("osc: %w", err)
+		case f := <-pending:
+			if wait := r.period - time.Since(last); wait > 0 {
+				t := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					t.Stop()
+					return nil
+				case <-t.C:
+				}
+				// Newer messages may have arrived while waiting.
+				select {
+				case f = <-pending:
+				default:
+				}
+			}
+			last = time.Now()
+			if err := st.SendContext(ctx, f); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("osc: %w", err)
+			}
+			r.framesSent.Add(1)
+		}
+	}
+}
+
+// read reads the packets until the connection is closed, and puts the
+// frame they leave in pending.
+func (r *Receiver) read(pending chan huestream.Frame) error {
+	state := make(huestream.Frame, len(r.channels))
+	for _, id := range r.channels {
+		state[id] = color.Black
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := r.conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msgs, err := parsePacket(buf[:n])
+		if err != nil {
+			r.invalid.Add(1)
+			r.logOnce("invalid:"+err.Error(), "osc: invalid packet", "error", err)
+			continue
+		}
+		changed := false
+		for _, m := range msgs {
+			if r.apply(state, m) {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		f := make(huestream.Frame, len(state))
+		for id, c := range state {
+			f[id] = c
+		}
+		// Replace the pending frame, if any.
+		select {
+		case <-pending:
+		default:
+		}
+		pending <- f
+	}
+}
+
+// apply applies m to state, it reports whether m was valid.
+func (r *Receiver) apply(state huestream.Frame, m message) bool {
+	t, ok := r.addrs[m.address]
+	if !ok {
+		r.unmatched.Add(1)
+		r.logOnce(m.address, "osc: unmatched address", "address", m.address, "types", m.types)
+		return false
+	}
+	if len(m.args) != 3 {
+		r.badTypes.Add(1)
+		r.logOnce(m.address, "osc: wrong type tags, want three floats (,fff)",
+			"address", m.address, "types", m.types)
+		return false
+	}
+	r.messages.Add(1)
+
+	var c color.Color
+	switch t.Model {
+	case RGB:
+		c = rgb(m.args[0], m.args[1], m.args[2])
+	case HSV:
+		c = hsv(m.args[0], m.args[1], m.args[2])
+	}
+	if t.Channel == All {
+		for id := range state {
+			state[id] = c
+		}
+	} else {
+		state[t.Channel] = c
+	}
+	return true
+}
+
+// logOnce logs msg the first time of key.
+func (r *Receiver) logOnce(key, msg string, args ...any) {
+	if r.log == nil {
+		return
+	}
+	r.mu.Lock()
+	logged := r.logged[key]
+	r.logged[key] = true
+	r.mu.Unlock()
+	if !logged {
+		r.log.Warn(msg, args...)
+	}
+}
+
+func rgb(r, g, b float64) color.RGBA64 {
+	return color.RGBA64{R: unit(r), G: unit(g), B: unit(b), A: 0xffff}
+}
+
+// hsv converts h, s and v, from 0 to 1, to RGB.
+func hsv(h, s, v float64) color.RGBA64 {
+	h = (h - math.Floor(h)) * 6
+	s, v = clamp(s), clamp(v)
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g = c, x
+	case 1:
+		r, g = x, c
+	case 2:
+		g, b = c, x
+	case 3:
+		g, b = x, c
+	case 4:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := v - c
+	return rgb(r+m, g+m, b+m)
+}
+
+func clamp(v float64) float64 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return min(max(v, 0), 1)
+}
+
+func unit(v float64) uint16 { return uint16(math.Round(clamp(v) * 0xffff)) }
+
+// message is an OSC message. The args are only parsed when they are three
+// numbers.
+type message struct {
+	address string
+	types   string // The type tag string.
+	args    []float64
+}
+
+var bundleTag = []byte("#bundle\x00")
+
+// parsePacket parses an OSC packet, a message or a bundle, and returns its
+// messages.
+func parsePacket(b []byte) ([]message, error) {
+	if !bytes.HasPrefix(b, bundleTag) {
+		m, err := parseMessage(b)
+		if err != nil {
+			return nil, err
+		}
+		return []message{m}, nil
+	}
+	b = b[len(bundleTag):]
+	if len(b) < 8 {
+		return nil, errors.New("bundle: short time tag")
+	}
+	b = b[8:]
+	var msgs []message
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, errors.New("bundle: short element size")
+		}
+		n := binary.BigEndian.Uint32(b)
+		b = b[4:]
+		if n%4 != 0 || uint32(len(b)) < n {
+			return nil, fmt.Errorf("bundle: invalid element size %d", n)
+		}
+		m, err := parsePacket(b[:n])
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m...)
+		b = b[n:]
+	}
+	return msgs, nil
+}
+
+func parseMessage(b []byte) (message, error) {
+	address, b, err := parseString(b)
+	if err != nil {
+		return message{}, fmt.Errorf("address: %w", err)
+	}
+	if len(address) == 0 || address[0] != '/' {
+		return message{}, fmt.Errorf("invalid address %q", address)
+	}
+	m := message{address: address}
+	if len(b) == 0 { // Old senders omit the type tags of messages without arguments.
+		return m, nil
+	}
+	m.types, b, err = parseString(b)
+	if err != nil {
+		return message{}, fmt.Errorf("type tags: %w", err)
+	}
+	if len(m.types) == 0 || m.types[0] != ',' {
+		return message{}, fmt.Errorf("invalid type tags %q", m.types)
+	}
+	if len(m.types) != 4 {
+		return m, nil
+	}
+	args := make([]float64, 0, 3)
+	for _, tag := range m.types[1:] {
+		switch tag {
+		case 'f':
+			if len(b) < 4 {
+				return message{}, errors.New("short float argument")
+			}
+			args = append(args, float64(math.Float32frombits(binary.BigEndian.Uint32(b))))
+			b = b[4:]
+		case 'd':
+			if len(b) < 8 {
+				return message{}, errors.New("short double argument")
+			}
+			args = append(args, math.Float64frombits(binary.BigEndian.Uint64(b)))
+			b = b[8:]
+		default:
+			return m, nil
+		}
+	}
+	m.args = args
+	return m, nil
+}
+
+// parseString parses an OSC string, null terminated and padded to a
+// multiple of 4 bytes, and returns it and the bytes after it.
+func parseString(b []byte) (string, []byte, error) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return "", nil, errors.New("unterminated string")
+	}
+	n := (i + 4) &^ 3
+	if n > len(b) {
+		return "", nil, errors.New("short string padding")
+	}
+	return string(b[:i]), b[n:], nil
+}
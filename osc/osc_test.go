@@ -0,0 +1,239 @@
+package osc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image/color"
+	"log/slog"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+// chanStreamer is a fake Streamer passing the frames sent to a channel.
+type chanStreamer chan huestream.Frame
+
+func (c chanStreamer) Send(f huestream.Frame) error { return c.SendContext(context.Background(), f) }
+
+func (c chanStreamer) SendContext(ctx context.Context, f huestream.Frame) error {
+	select {
+	case c <- f:
+	default: // The test does not read it.
+	}
+	return nil
+}
+
+func (c chanStreamer) Close() error { return nil }
+
+func oscString(s string) []byte {
+	b := append([]byte(s), 0)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// messageBytes encodes a message, the args being float32 or float64.
+func messageBytes(address string, args ...any) []byte {
+	types := ","
+	var data []byte
+	for _, a := range args {
+		switch a := a.(type) {
+		case float32:
+			types += "f"
+			data = binary.BigEndian.AppendUint32(data, math.Float32bits(a))
+		case float64:
+			types += "d"
+			data = binary.BigEndian.AppendUint64(data, math.Float64bits(a))
+		case int32:
+			types += "i"
+			data = binary.BigEndian.AppendUint32(data, uint32(a))
+		}
+	}
+	b := append(oscString(address), oscString(types)...)
+	return append(b, data...)
+}
+
+func bundleBytes(msgs ...[]byte) []byte {
+	b := append([]byte("#bundle\x00"), 0, 0, 0, 0, 0, 0, 0, 1)
+	for _, m := range msgs {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(m)))
+		b = append(b, m...)
+	}
+	return b
+}
+
+// start runs a Receiver on a local port and returns a connection to it.
+func start(t *testing.T, channels []int, opts ...Option) (*Receiver, net.Conn, chanStreamer) {
+	t.Helper()
+
+	r, err := Listen("127.0.0.1:0", channels, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := make(chanStreamer, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx, st) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	conn, err := net.Dial("udp", r.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return r, conn, st
+}
+
+func write(t *testing.T, conn net.Conn, b []byte) {
+	t.Helper()
+	if _, err := conn.Write(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func next(t *testing.T, st chanStreamer) huestream.Frame {
+	t.Helper()
+
+	select {
+	case f := <-st:
+		return f
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame sent")
+		return nil
+	}
+}
+
+func rgb8(c color.Color) [3]uint32 {
+	r, g, b, _ := c.RGBA()
+	return [3]uint32{r >> 8, g >> 8, b >> 8}
+}
+
+func TestReceive(t *testing.T) {
+	_, conn, st := start(t, []int{0, 1, 2}, WithRate(1000))
+
+	write(t, conn, messageBytes("/hue/all/rgb", float32(0), float32(0), float32(1)))
+	f := next(t, st)
+	for id := range 3 {
+		if got, want := rgb8(f[id]), [3]uint32{0, 0, 255}; got != want {
+			t.Errorf("channel %d = %v, want %v", id, got, want)
+		}
+	}
+
+	write(t, conn, messageBytes("/hue/channel/1/hsv", float64(1.0/3), float64(1), float64(1)))
+	f = next(t, st)
+	if got, want := rgb8(f[1]), [3]uint32{0, 255, 0}; got != want {
+		t.Errorf("channel 1 = %v, want %v", got, want)
+	}
+	if got, want := rgb8(f[0]), [3]uint32{0, 0, 255}; got != want {
+		t.Errorf("channel 0 = %v, want %v", got, want)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	r, conn, st := start(t, []int{0, 1}, WithRate(1000))
+
+	write(t, conn, bundleBytes(
+		messageBytes("/hue/channel/0/rgb", float32(1), float32(0), float32(0)),
+		bundleBytes(messageBytes("/hue/channel/1/rgb", float32(0), float32(1), float32(0))),
+	))
+	f := next(t, st)
+	if rgb8(f[0]) != [3]uint32{255, 0, 0} || rgb8(f[1]) != [3]uint32{0, 255, 0} {
+		t.Errorf("frame = %v, want red and green", f)
+	}
+	if got := r.Stats().Messages; got != 2 {
+		t.Errorf("messages = %d, want 2", got)
+	}
+}
+
+func TestAddressMap(t *testing.T) {
+	m := AddressMap{"/layer1/color": {Channel: 2, Model: RGB}}
+	_, conn, st := start(t, []int{2}, WithAddressMap(m), WithRate(1000))
+
+	write(t, conn, messageBytes("/hue/channel/2/rgb", float32(1), float32(1), float32(1)))
+	write(t, conn, messageBytes("/layer1/color", float32(1), float32(0.5), float32(2)))
+	f := next(t, st)
+	if got, want := rgb8(f[2]), [3]uint32{255, 128, 255}; got != want {
+		t.Errorf("channel 2 = %v, want %v", got, want)
+	}
+}
+
+func TestDropAndLog(t *testing.T) {
+	var logs bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&logs, nil))
+	r, conn, st := start(t, []int{0}, WithLogger(l), WithRate(1000))
+
+	write(t, conn, messageBytes("/hue/channel/9/rgb", float32(1), float32(1), float32(1)))
+	write(t, conn, messageBytes("/hue/channel/9/rgb", float32(1), float32(1), float32(1)))
+	write(t, conn, messageBytes("/hue/all/rgb", int32(1), int32(1), int32(1)))
+	write(t, conn, messageBytes("/hue/all/rgb", float32(1)))
+	write(t, conn, []byte("garbage"))
+	write(t, conn, messageBytes("/hue/all/rgb", float32(1), float32(1), float32(1)))
+	next(t, st)
+
+	want := Stats{Messages: 1, Unmatched: 2, BadTypes: 2, Invalid: 1}
+	got := r.Stats()
+	got.FramesSent = 0 // Counted after the send returns.
+	if got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+	out := logs.String()
+	if n := strings.Count(out, "unmatched address"); n != 1 {
+		t.Errorf("unmatched address logged %d times, want once:\n%s", n, out)
+	}
+	if !strings.Contains(out, "types=,iii") {
+		t.Errorf("wrong type tags not logged:\n%s", out)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	_, conn, st := start(t, []int{0}, WithRate(10))
+
+	for i := range 20 {
+		write(t, conn, messageBytes("/hue/all/rgb", float32(i)/255, float32(0), float32(0)))
+	}
+	first := next(t, st)
+	start := time.Now()
+	last := next(t, st)
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("second frame after %v, want about 100ms", d)
+	}
+	if got := rgb8(last[0]); got != [3]uint32{19, 0, 0} {
+		t.Errorf("frames %v then %v, want the last one to be sent", rgb8(first[0]), got)
+	}
+}
+
+func TestHSV(t *testing.T) {
+	for _, tc := range []struct {
+		h, s, v float64
+		want    [3]uint32
+	}{
+		{0, 1, 1, [3]uint32{255, 0, 0}},
+		{1, 1, 1, [3]uint32{255, 0, 0}},
+		{2.0 / 3, 1, 1, [3]uint32{0, 0, 255}},
+		{5.0 / 6, 1, 1, [3]uint32{255, 0, 255}},
+		{0.5, 0, 1, [3]uint32{255, 255, 255}},
+		{0.5, 1, 0, [3]uint32{0, 0, 0}},
+	} {
+		if got := rgb8(hsv(tc.h, tc.s, tc.v)); got != tc.want {
+			t.Errorf("hsv(%v, %v, %v) = %v, want %v", tc.h, tc.s, tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestListenInvalidMap(t *testing.T) {
+	m := AddressMap{"/x": {Channel: 5, Model: RGB}}
+	if _, err := Listen("127.0.0.1:0", []int{0}, WithAddressMap(m)); err == nil {
+		t.Error("Listen with an unknown channel succeeded")
+	}
+}
@@ -0,0 +1,89 @@
+// Package otel traces the calls of a huestream.Stream to the bridge with
+// OpenTelemetry:
+//
+//	s, err := huestream.Start(ctx, host, username, clientKey, areaID,
+//		huestream.WithTracer(otel.NewTracer(nil)))
+//
+// It is a separate module so that huestream does not depend on
+// OpenTelemetry.
+package otel
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rschio/huestream"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scope = "github.com/rschio/huestream/otel"
+
+// Tracer is a huestream.Tracer creating OpenTelemetry spans.
+type Tracer struct {
+	t trace.Tracer
+}
+
+var _ huestream.Tracer = (*Tracer)(nil)
+
+// NewTracer returns a Tracer creating the spans with tp, the global
+// TracerProvider if tp is nil.
+func NewTracer(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{t: tp.Tracer(scope)}
+}
+
+// Start implements huestream.Tracer. The spans of the CLIP requests are
+// client spans, the one of the Stream is internal.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, huestream.Span) {
+	kind := trace.SpanKindClient
+	if name == "huestream.stream" {
+		kind = trace.SpanKindInternal
+	}
+	ctx, span := t.t.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attributes(attrs)...))
+	return ctx, otelSpan{span}
+}
+
+type otelSpan struct {
+	s trace.Span
+}
+
+func (s otelSpan) AddEvent(name string, attrs ...slog.Attr) {
+	s.s.AddEvent(name, trace.WithAttributes(attributes(attrs)...))
+}
+
+func (s otelSpan) End(err error) {
+	if err != nil {
+		s.s.RecordError(err)
+		s.s.SetStatus(codes.Error, err.Error())
+	} else {
+		s.s.SetStatus(codes.Ok, "")
+	}
+	s.s.End()
+}
+
+// attributes converts slog attributes to OpenTelemetry ones. Values of other
+// kinds than string, int, float and bool are converted to strings.
+func attributes(attrs []slog.Attr) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		v := a.Value.Resolve()
+		switch v.Kind() {
+		case slog.KindString:
+			kvs = append(kvs, attribute.String(a.Key, v.String()))
+		case slog.KindInt64:
+			kvs = append(kvs, attribute.Int64(a.Key, v.Int64()))
+		case slog.KindFloat64:
+			kvs = append(kvs, attribute.Float64(a.Key, v.Float64()))
+		case slog.KindBool:
+			kvs = append(kvs, attribute.Bool(a.Key, v.Bool()))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, v.String()))
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,96 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huetest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpans(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	b := huetest.NewBridge(t)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	opts := append(b.Options(), huestream.WithTracer(NewTracer(tp)))
+	stream, err := huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range rec.Ended() {
+		spans[s.Name()] = s
+	}
+	streamSpan, ok := spans["huestream.stream"]
+	if !ok {
+		t.Fatalf("no stream span in %v", rec.Ended())
+	}
+	if streamSpan.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("the stream span is not a child of the span of the caller")
+	}
+	var events []string
+	for _, e := range streamSpan.Events() {
+		events = append(events, e.Name)
+	}
+	if len(events) != 2 || events[0] != "handshake" || events[1] != "close" {
+		t.Errorf("stream events %v, want [handshake close]", events)
+	}
+
+	for _, name := range []string{"huestream.start", "huestream.stop"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Errorf("no %s span", name)
+			continue
+		}
+		if s.Parent().SpanID() != streamSpan.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the stream span", name)
+		}
+		if s.Status().Code != codes.Ok {
+			t.Errorf("%s status %v, want Ok", name, s.Status())
+		}
+		want := attribute.String("huestream.area_id", b.AreaID)
+		if !hasAttr(s.Attributes(), want) {
+			t.Errorf("%s attributes %v, want %v", name, s.Attributes(), want)
+		}
+	}
+}
+
+func TestFailedSpan(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	b := huetest.NewBridge(t)
+	b.SetStatus("PUT", 503)
+
+	opts := append(b.Options(), huestream.WithTracer(NewTracer(tp)))
+	if _, err := huestream.Start(context.Background(), b.Host, b.Username, b.ClientKey, b.AreaID, opts...); err == nil {
+		t.Fatal("Start should fail")
+	}
+	for _, s := range rec.Ended() {
+		if s.Name() == "huestream.preflight" {
+			continue // The GET of the area succeeds.
+		}
+		if s.Status().Code != codes.Error {
+			t.Errorf("%s status %v, want Error", s.Name(), s.Status())
+		}
+	}
+}
+
+func hasAttr(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, a := range attrs {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package huestream
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+// An epoch-aligned pacer that wakes up more than maxAlignedLag behind its
+// schedule maxLagStreak times in a row gives up the alignment and runs free.
+// A single late wake up is taken as a forward step of the wall clock.
+const (
+	maxAlignedLag = time.Second
+	maxLagStreak  = 3
+)
+
+// pacer paces a send loop on a drift-free schedule.
+//
+// Slot n is due at start + n*period, so the error of one tick never carries
+// over to the next ones. When the loop wakes up after one or more slots have
+// passed (e.g. after a GC pause) the missed slots are skipped instead of
+// being sent in a burst.
+//
+// An aligned pacer uses the wall clock and a caller-provided epoch as start,
+// so pacers in different processes fire at the same instants. A step of the
+// wall clock, in either direction, realigns the pacer with the new time, but
+// if it keeps falling more than maxAlignedLag behind it reverts to a
+// free-running schedule.
+type pacer struct {
+	clk     clock.Clock
+	period  time.Duration
+	start   time.Time
+	n       int64 // The index of the next slot.
+	aligned bool
+	lagging int // Consecutive wake ups more than maxAlignedLag late.
+	timing  *timing
+
+	// overrun, if set, is called with the number of slots skipped by a
+	// late wake up.
+	overrun func(skipped int64)
+}
+
+func newPacer(clk clock.Clock, period time.Duration, t *timing) *pacer {
+	mustPositive(period)
+	return &pacer{
+		clk:    clk,
+		period: period,
+		start:  clk.Now(),
+		n:      1,
+		timing: t,
+	}
+}
+
+// newAlignedPacer returns a pacer whose slots are at epoch + n*period.
+func newAlignedPacer(clk clock.Clock, period time.Duration, t *timing, epoch time.Time) *pacer {
+	mustPositive(period)
+	p := &pacer{
+		clk:     clk,
+		period:  period,
+		start:   epoch.Round(0), // Strip the monotonic reading, use wall time.
+		aligned: true,
+		timing:  t,
+	}
+	p.n = p.slotAfter(p.now())
+	return p
+}
+
+// mustPositive panics if period is not positive, a pacer would divide by it.
+func mustPositive(period time.Duration) {
+	if period <= 0 {
+		panic(fmt.Sprintf("huestream: pacer period must be positive, got %v", period))
+	}
+}
+
+// newPacer returns a pacer for a send loop of s with the given period.
+// Skipped slots are reported to the error handler as ErrOverrun.
+func (s *Stream) newPacer(period time.Duration) *pacer {
+	var p *pacer
+	if !s.cfg.epoch.IsZero() {
+		p = newAlignedPacer(s.clk, period, &s.timing, s.cfg.epoch)
+	} else {
+		p = newPacer(s.clk, period, &s.timing)
+	}
+	p.overrun = func(skipped int64) {
+		s.errs.report(fmt.Errorf("%w: skipped %d slots of %v", ErrOverrun, skipped, period))
+	}
+	return p
+}
+
+// now returns the current time, as wall time for aligned pacers.
+func (p *pacer) now() time.Time {
+	if p.aligned {
+		return p.clk.Now().Round(0)
+	}
+	return p.clk.Now()
+}
+
+// slotAfter returns the index of the first slot after t.
+func (p *pacer) slotAfter(t time.Time) int64 {
+	elapsed := t.Sub(p.start)
+	n := int64(elapsed / p.period)
+	if elapsed < 0 {
+		return n
+	}
+	return n + 1
+}
+
+// wait blocks until the next slot is due. It returns false if done is closed
+// first.
+func (p *pacer) wait(done <-chan struct{}) bool {
+	due := p.start.Add(time.Duration(p.n) * p.period)
+
+	if p.aligned && due.Sub(p.now()) > p.period {
+		// The wall clock stepped back, realign with the new time.
+		p.n = p.slotAfter(p.now())
+		due = p.start.Add(time.Duration(p.n) * p.period)
+	}
+
+	if d := due.Sub(p.now()); d > 0 {
+		t := p.clk.NewTimer(d)
+		select {
+		case <-t.C():
+		case <-done:
+			t.Stop()
+			return false
+		}
+	}
+
+	now := p.now()
+	late := max(now.Sub(due), 0)
+	missed := int64(late / p.period)
+	p.n += 1 + missed
+	p.timing.observe(late-time.Duration(missed)*p.period, missed)
+	if missed > 0 && p.overrun != nil {
+		p.overrun(missed)
+	}
+
+	// Skipping the missed slots already realigned the pacer with the wall
+	// clock, give up only if it happens repeatedly.
+	if p.aligned {
+		if late > maxAlignedLag {
+			p.lagging++
+		} else {
+			p.lagging = 0
+		}
+		if p.lagging >= maxLagStreak {
+			p.aligned = false
+			p.start = p.clk.Now()
+			p.n = 1
+			p.timing.lostAlignment()
+		}
+	}
+
+	return true
+}
+
+// timing aggregates the lateness of the pacers of a Stream.
+type timing struct {
+	skipped   atomic.Uint64
+	unaligned atomic.Uint64
+	wakeups   atomic.Uint64
+	jitterSum atomic.Int64
+	jitterMax atomic.Int64
+}
+
+func (t *timing) observe(jitter time.Duration, skipped int64) {
+	if t == nil {
+		return
+	}
+
+	t.skipped.Add(uint64(skipped))
+	t.wakeups.Add(1)
+	t.jitterSum.Add(int64(jitter))
+	for {
+		m := t.jitterMax.Load()
+		if int64(jitter) <= m || t.jitterMax.CompareAndSwap(m, int64(jitter)) {
+			return
+		}
+	}
+}
+
+func (t *timing) lostAlignment() {
+	if t != nil {
+		t.unaligned.Add(1)
+	}
+}
@@ -0,0 +1,194 @@
+package huestream
+
+import (
+	"context"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+func TestPacerOnSchedule(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	var tm timing
+	p := newPacer(clk, 20*time.Millisecond, &tm)
+
+	for i := range 5 {
+		woke := make(chan bool)
+		go func() { woke <- p.wait(nil) }()
+
+		clk.BlockUntil(1)
+		clk.Advance(20 * time.Millisecond)
+		if !<-woke {
+			t.Fatalf("wait %d returned false", i)
+		}
+	}
+
+	if got := tm.skipped.Load(); got != 0 {
+		t.Errorf("skipped %d slots, want 0", got)
+	}
+	if got := tm.jitterMax.Load(); got != 0 {
+		t.Errorf("max jitter %v, want 0", time.Duration(got))
+	}
+}
+
+func TestPacerSkipsMissedSlots(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	var tm timing
+	p := newPacer(clk, 20*time.Millisecond, &tm)
+
+	// The loop sleeps through slots 1, 2 and 3 and wakes up 5ms after slot 3.
+	clk.Advance(65 * time.Millisecond)
+	if !p.wait(nil) {
+		t.Fatal("wait returned false")
+	}
+	if got := tm.skipped.Load(); got != 2 {
+		t.Errorf("skipped %d slots, want 2", got)
+	}
+	if got := time.Duration(tm.jitterMax.Load()); got != 5*time.Millisecond {
+		t.Errorf("max jitter %v, want 5ms", got)
+	}
+
+	// The next slot is still aligned to the start, not to the late wakeup.
+	woke := make(chan bool)
+	go func() { woke <- p.wait(nil) }()
+	clk.BlockUntil(1)
+	clk.Advance(14 * time.Millisecond)
+	select {
+	case <-woke:
+		t.Fatal("woke up before slot 4")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clk.Advance(time.Millisecond)
+	<-woke
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 80*time.Millisecond {
+		t.Errorf("woke up at %v, want 80ms", got)
+	}
+}
+
+func TestPacerStops(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	p := newPacer(clk, time.Second, nil)
+
+	done := make(chan struct{})
+	close(done)
+	if p.wait(done) {
+		t.Error("wait should return false once done is closed")
+	}
+}
+
+func TestPlaySeqFakeClock(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, frames := pipeStream(t, withClock(clk))
+
+	seq := func(yield func(Frame) bool) {
+		for i := range 4 {
+			if !yield(Frame{i: color.White}) {
+				return
+			}
+		}
+	}
+
+	errc := make(chan error)
+	go func() { errc <- s.PlaySeq(context.Background(), seq, 50) }()
+
+	for range 4 {
+		clk.BlockUntil(1)
+		clk.Advance(20 * time.Millisecond)
+		<-frames
+	}
+	clk.BlockUntil(1)
+	clk.Advance(20 * time.Millisecond)
+
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Stats().FramesSent; got != 4 {
+		t.Errorf("sent %d frames, want 4", got)
+	}
+}
+
+func TestAlignedPacerFiresOnEpochBoundaries(t *testing.T) {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(epoch.Add(time.Hour + 7*time.Millisecond))
+	p := newAlignedPacer(clk, 20*time.Millisecond, nil, epoch)
+
+	woke := make(chan bool)
+	go func() { woke <- p.wait(nil) }()
+	clk.BlockUntil(1)
+	clk.Advance(12 * time.Millisecond)
+	select {
+	case <-woke:
+		t.Fatal("woke up before the boundary")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clk.Advance(time.Millisecond)
+	<-woke
+}
+
+func TestAlignedPacerClockStepBack(t *testing.T) {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(epoch.Add(time.Hour))
+	p := newAlignedPacer(clk, 20*time.Millisecond, nil, epoch)
+
+	// NTP steps the clock back a minute, the pacer must not sleep a minute.
+	clk.Set(epoch.Add(time.Hour - time.Minute + 5*time.Millisecond))
+
+	woke := make(chan bool)
+	go func() { woke <- p.wait(nil) }()
+	clk.BlockUntil(1)
+	clk.Advance(15 * time.Millisecond)
+	<-woke
+}
+
+func TestAlignedPacerClockStepForward(t *testing.T) {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(epoch.Add(time.Hour))
+	var tm timing
+	p := newAlignedPacer(clk, 20*time.Millisecond, &tm, epoch)
+
+	// NTP steps the clock forward a minute.
+	clk.Set(epoch.Add(time.Hour + time.Minute + 5*time.Millisecond))
+	p.wait(nil)
+
+	if !p.aligned {
+		t.Fatal("pacer lost alignment after a single clock step")
+	}
+
+	// The next slot is on the epoch grid of the new time.
+	woke := make(chan bool)
+	go func() { woke <- p.wait(nil) }()
+	clk.BlockUntil(1)
+	clk.Advance(14 * time.Millisecond)
+	select {
+	case <-woke:
+		t.Fatal("woke up before the boundary")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clk.Advance(time.Millisecond)
+	<-woke
+
+	if got := tm.unaligned.Load(); got != 0 {
+		t.Errorf("lost alignment %d times, want 0", got)
+	}
+}
+
+func TestAlignedPacerFallsBackToFreeRunning(t *testing.T) {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(epoch)
+	var tm timing
+	p := newAlignedPacer(clk, 20*time.Millisecond, &tm, epoch)
+
+	for range maxLagStreak {
+		clk.Advance(2 * time.Second)
+		p.wait(nil)
+	}
+
+	if p.aligned {
+		t.Error("pacer still aligned after falling 2s behind repeatedly")
+	}
+	if got := tm.unaligned.Load(); got != 1 {
+		t.Errorf("lost alignment %d times, want 1", got)
+	}
+}
@@ -0,0 +1,151 @@
+package huestream
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rschio/huestream/wire"
+)
+
+// pauseKeepAlive is the rate of the frames holding a paused session open,
+// well under the ~10s after which the bridge ends an idle session.
+const pauseKeepAlive = time.Second
+
+// renewTimeout bounds the renewal of a session lost during a pause.
+const renewTimeout = 10 * time.Second
+
+// pauseState is the state of a paused Stream.
+type pauseState struct {
+	once   sync.Once
+	done   chan struct{} // Closed to end the pause.
+	exited chan struct{} // Closed when the hold loop returned.
+	lost   atomic.Bool   // Set when a hold frame could not be written.
+}
+
+func (p *pauseState) end() { p.once.Do(func() { close(p.done) }) }
+
+// Pause halts the output of the Stream while keeping the session with the
+// bridge open, so Resume is instant compared to a new Start.
+//
+// Pause sends a hold frame, the last frame sent or a black version of it
+// with WithPauseBlack, and then repeats it once per second. While paused,
+// Send returns ErrPaused and the keepalive is halted. Pausing a paused
+// Stream does nothing. Pause returns ErrClosed after Close.
+func (s *Stream) Pause() error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+
+	p := &pauseState{
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	if !s.pause.CompareAndSwap(nil, p) {
+		return nil
+	}
+
+	b, err := s.holdMessage()
+	if err != nil {
+		close(p.exited)
+		s.pause.CompareAndSwap(p, nil)
+		return err
+	}
+	err = s.write(b)
+
+	if !s.goBackground(func() { s.hold(p, b) }) {
+		close(p.exited)
+		return ErrClosed
+	}
+
+	return err
+}
+
+// Resume continues the output of a paused Stream. Resuming a Stream that is
+// not paused does nothing.
+//
+// If the session was lost during the pause, e.g. the bridge timed it out,
+// Resume starts it again before returning. Resume returns ErrClosed after
+// Close.
+func (s *Stream) Resume() error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+
+	p := s.pause.Load()
+	if p == nil {
+		return nil
+	}
+	p.end()
+	<-p.exited
+	s.pause.CompareAndSwap(p, nil)
+
+	if !p.lost.Load() || s.client == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), renewTimeout)
+	defer cancel()
+
+	conn, err := s.reconnect(ctx, 1)
+	if err != nil {
+		return fmt.Errorf("renew session: %w", err)
+	}
+	if !s.replaceConn(conn) {
+		return ErrClosed
+	}
+
+	return nil
+}
+
+// Paused reports whether the Stream is paused.
+func (s *Stream) Paused() bool { return s.pause.Load() != nil }
+
+// holdMessage returns the message repeated while paused.
+func (s *Stream) holdMessage() ([]byte, error) {
+	s.mu.Lock()
+	last := s.last
+	s.mu.Unlock()
+
+	if last == nil {
+		return s.message(nil).MarshalBinary()
+	}
+	if !s.cfg.pauseBlack {
+		return last, nil
+	}
+
+	// Keep the channels, zero their colors.
+	headerSize, channelSize := wire.HeaderSize, wire.ChannelSize
+	if s.cfg.version == wire.Version1 {
+		headerSize, channelSize = wire.HeaderSizeV1, wire.ChannelSizeV1
+	}
+	valuesSize := 3 * 2
+	b := append([]byte(nil), last...)
+	for i := headerSize; i+channelSize <= len(b); i += channelSize {
+		clear(b[i+channelSize-valuesSize : i+channelSize])
+	}
+	return b, nil
+}
+
+// hold repeats b until the pause ends or the Stream is closed.
+func (s *Stream) hold(p *pauseState, b []byte) {
+	defer close(p.exited)
+
+	// Close ends the pause too, p.done is enough to stop.
+	pc := newPacer(s.clk, pauseKeepAlive, &s.timing)
+	for pc.wait(p.done) {
+		if err := s.write(b); err != nil {
+			p.lost.Store(true)
+			s.errs.report(fmt.Errorf("pause keepalive: %w", err))
+		}
+	}
+}
+
+// isClosed reports whether Close was called.
+func (s *Stream) isClosed() bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	return s.closed
+}
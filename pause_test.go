@@ -0,0 +1,198 @@
+package huestream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+	"github.com/rschio/huestream/wire"
+)
+
+func TestPauseHoldsAndResumes(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, frames := pipeStream(t, withClock(clk))
+
+	if err := s.Send(Frame{2: color.RGBA{R: 255, A: 255}}); err != nil {
+		t.Fatal(err)
+	}
+	sent := <-frames
+
+	if err := s.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-frames; !bytes.Equal(got, sent) {
+		t.Errorf("hold frame %x, want the last frame %x", got, sent)
+	}
+	if err := s.Send(Frame{0: color.White}); !errors.Is(err, ErrPaused) {
+		t.Errorf("Send while paused = %v, want %v", err, ErrPaused)
+	}
+
+	clk.BlockUntil(1)
+	clk.Advance(pauseKeepAlive)
+	if got := <-frames; !bytes.Equal(got, sent) {
+		t.Errorf("keepalive while paused %x, want %x", got, sent)
+	}
+
+	if err := s.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Paused() {
+		t.Error("Paused() after Resume")
+	}
+	if err := s.Send(Frame{0: color.White}); err != nil {
+		t.Errorf("Send after Resume: %v", err)
+	}
+	<-frames
+}
+
+func TestPauseResumePause(t *testing.T) {
+	s, frames := pipeStream(t)
+	go func() {
+		for range frames {
+		}
+	}()
+
+	for range 3 {
+		if err := s.Pause(); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Pause(); err != nil {
+			t.Fatalf("second Pause: %v", err)
+		}
+		if !s.Paused() {
+			t.Fatal("not paused after Pause")
+		}
+		if err := s.Resume(); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Resume(); err != nil {
+			t.Fatalf("second Resume: %v", err)
+		}
+	}
+}
+
+func TestPauseThenClose(t *testing.T) {
+	s, frames := pipeStream(t)
+	go func() {
+		for range frames {
+		}
+	}()
+
+	if err := s.Pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- s.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Close while paused: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked by the pause")
+	}
+
+	if err := s.Resume(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Resume after Close = %v, want %v", err, ErrClosed)
+	}
+	if err := s.Pause(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Pause after Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestPauseHoldMessageError(t *testing.T) {
+	s, _ := pipeStream(t)
+	s.areaID = "invalid" // The hold message can't be encoded.
+
+	for range 2 {
+		if err := s.Pause(); err == nil {
+			t.Fatal("Pause should fail")
+		}
+		if s.Paused() {
+			t.Fatal("Paused() after a failed Pause")
+		}
+	}
+
+	done := make(chan error)
+	go func() { done <- s.Resume() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Resume: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Resume blocked after a failed Pause")
+	}
+}
+
+func TestPauseBlack(t *testing.T) {
+	s, frames := pipeStream(t, WithPauseBlack())
+
+	if err := s.Send(Frame{2: color.White, 5: color.White}); err != nil {
+		t.Fatal(err)
+	}
+	<-frames
+	if err := s.Pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-frames
+	want, _ := message{areaID: testAreaID, idColors: Frame{2: color.Black, 5: color.Black}}.MarshalBinary()
+	if len(got) != len(want) {
+		t.Fatalf("hold frame has %d bytes, want %d", len(got), len(want))
+	}
+	for i := wire.HeaderSize; i < len(got); i += wire.ChannelSize {
+		if !bytes.Equal(got[i+1:i+wire.ChannelSize], make([]byte, wire.ChannelSize-1)) {
+			t.Errorf("channel %d not black: %x", got[i], got[i:i+wire.ChannelSize])
+		}
+	}
+}
+
+func TestResumeRenewsLostSession(t *testing.T) {
+	var starts atomic.Int32
+	c := fakeBridge(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			starts.Add(1)
+		}
+	}))
+	c.dial = func(ctx context.Context) (net.Conn, error) {
+		return &funcConn{write: func(b []byte) (int, error) { return len(b), nil }}, nil
+	}
+
+	var dead atomic.Bool
+	conn := &funcConn{write: func(b []byte) (int, error) {
+		if dead.Load() {
+			return 0, net.ErrClosed
+		}
+		return len(b), nil
+	}}
+	clk := clock.NewFake(time.Unix(0, 0))
+	s := newStream(conn, c, testAreaID, newConfig([]Option{withClock(clk)}))
+	defer s.Close()
+
+	if err := s.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	dead.Store(true) // The bridge times the session out.
+	clk.BlockUntil(1)
+	clk.Advance(pauseKeepAlive)
+	clk.BlockUntil(1) // The failed hold frame was written.
+
+	if err := s.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if got := starts.Load(); got != 1 {
+		t.Errorf("start action sent %d times, want 1", got)
+	}
+	if err := s.Send(Frame{0: color.White}); err != nil {
+		t.Errorf("Send after renewal: %v", err)
+	}
+}
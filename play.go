@@ -0,0 +1,71 @@
+package huestream
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"math"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+// PlaySeq sends the frames produced by frames at the given rate (in Hz).
+//
+// Frames are pulled lazily, one per tick, so frames may be an infinite
+// sequence. Ticks follow a fixed schedule from the call to PlaySeq, ticks
+// missed because of a late wake up are skipped, see Stats.
+//
+// PlaySeq returns nil when the sequence ends, ctx.Err() when ctx is done, or
+// the first error returned by Send.
+func (s *Stream) PlaySeq(ctx context.Context, frames iter.Seq[Frame], rate float64) error {
+	period, err := ratePeriod(rate)
+	if err != nil {
+		return err
+	}
+	return play(ctx, s, s.newPacer(period), frames)
+}
+
+// Play is like Stream.PlaySeq for any Streamer. A *Stream is played with
+// PlaySeq, other Streamers follow a free-running schedule.
+func Play(ctx context.Context, st Streamer, frames iter.Seq[Frame], rate float64) error {
+	if s, ok := st.(*Stream); ok {
+		return s.PlaySeq(ctx, frames, rate)
+	}
+
+	period, err := ratePeriod(rate)
+	if err != nil {
+		return err
+	}
+	return play(ctx, st, newPacer(clock.Real, period, &timing{}), frames)
+}
+
+func play(ctx context.Context, st Streamer, p *pacer, frames iter.Seq[Frame]) error {
+	next, stop := iter.Pull(frames)
+	defer stop()
+
+	for p.wait(ctx.Done()) {
+		frame, ok := next()
+		if !ok {
+			return nil
+		}
+		if err := st.Send(frame); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// ratePeriod returns the period of a loop running at rate Hz.
+func ratePeriod(rate float64) (time.Duration, error) {
+	if math.IsNaN(rate) || math.IsInf(rate, 0) || rate <= 0 {
+		return 0, fmt.Errorf("rate must be positive and finite, got %v", rate)
+	}
+
+	period := time.Duration(float64(time.Second) / rate)
+	if period <= 0 {
+		return 0, fmt.Errorf("rate %v Hz is too high", rate)
+	}
+	return period, nil
+}
@@ -0,0 +1,108 @@
+package huestream
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"iter"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPlaySeqStopsWhenSequenceEnds(t *testing.T) {
+	s, frames := pipeStream(t)
+
+	seq := func(yield func(Frame) bool) {
+		for i := range 3 {
+			if !yield(Frame{i: color.White}) {
+				return
+			}
+		}
+	}
+
+	if err := s.PlaySeq(context.Background(), seq, 500); err != nil {
+		t.Fatalf("PlaySeq: %v", err)
+	}
+
+	for i := range 3 {
+		select {
+		case b := <-frames:
+			if got := b[len(b)-7]; got != byte(i) {
+				t.Errorf("frame %d: got channel %d", i, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("frame %d not received", i)
+		}
+	}
+}
+
+func TestPlaySeqInfiniteSequence(t *testing.T) {
+	s, frames := pipeStream(t)
+	go func() {
+		for range frames {
+		}
+	}()
+
+	var pulled int
+	forever := iter.Seq[Frame](func(yield func(Frame) bool) {
+		for {
+			pulled++
+			if !yield(Frame{0: color.Black}) {
+				return
+			}
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := s.PlaySeq(ctx, forever, 100)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("PlaySeq: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if pulled == 0 || pulled > 10 {
+		t.Errorf("pulled %d frames in 50ms at 100 Hz", pulled)
+	}
+}
+
+func TestPlaySeqInvalidRate(t *testing.T) {
+	s, _ := pipeStream(t)
+	for _, rate := range []float64{0, -50, math.NaN(), math.Inf(1), math.Inf(-1), 2e9} {
+		if err := s.PlaySeq(context.Background(), nil, rate); err == nil {
+			t.Errorf("should reject a rate of %v", rate)
+		}
+	}
+}
+
+// recordingStreamer is a fake Streamer recording the frames sent.
+type recordingStreamer struct {
+	frames []Frame
+}
+
+func (r *recordingStreamer) Send(f Frame) error { r.frames = append(r.frames, f); return nil }
+
+func (r *recordingStreamer) SendContext(ctx context.Context, f Frame) error { return r.Send(f) }
+
+func (r *recordingStreamer) Close() error { return nil }
+
+func TestPlayFakeStreamer(t *testing.T) {
+	var r recordingStreamer
+	seq := func(yield func(Frame) bool) {
+		for i := range 3 {
+			if !yield(Frame{i: color.White}) {
+				return
+			}
+		}
+	}
+
+	if err := Play(context.Background(), &r, seq, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.frames) != 3 {
+		t.Errorf("got %d frames, want 3", len(r.frames))
+	}
+	if err := Play(context.Background(), &r, seq, 0); err == nil {
+		t.Error("Play should reject a zero rate")
+	}
+}
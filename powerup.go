@@ -0,0 +1,196 @@
+package huestream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/rschio/huestream/huecolor"
+)
+
+// The modes of a PowerOn, what a light does when powered on.
+const (
+	PowerOnSafety    = "safety"        // Bright warm white, the default.
+	PowerOnPowerFail = "powerfail"     // The state before the power cut, off if it was off.
+	PowerOnLastState = "last_on_state" // The last state the light was on in.
+	PowerOnCustom    = "custom"        // On, with the color and brightness of the PowerOn.
+)
+
+// PowerOn is the power-on behavior of a light, after a power cut or when
+// switched on at the wall.
+type PowerOn struct {
+	Mode string // As PowerOnLastState.
+
+	// The fields of PowerOnCustom, the zero values keep the color or the
+	// brightness of the light before the power cut. Color is used for
+	// its chromaticity only, and can't be set with Mirek.
+	Color      color.Color
+	Mirek      int     // Color temperature, from 153 (cold) to 500 (warm).
+	Brightness float64 // From 0 to 1.
+}
+
+// The color temperatures supported by the power-on behavior, in mirek.
+const (
+	minMirek = 153
+	maxMirek = 500
+)
+
+func (p PowerOn) validate() error {
+	switch p.Mode {
+	case PowerOnSafety, PowerOnPowerFail, PowerOnLastState:
+		if p.Color != nil || p.Mirek != 0 || p.Brightness != 0 {
+			return fmt.Errorf("power-on mode %s takes no color or brightness", p.Mode)
+		}
+		return nil
+	case PowerOnCustom:
+	default:
+		return fmt.Errorf("unknown power-on mode %q", p.Mode)
+	}
+	if p.Color != nil && p.Mirek != 0 {
+		return errors.New("power-on color and color temperature both set")
+	}
+	if p.Mirek != 0 && (p.Mirek < minMirek || p.Mirek > maxMirek) {
+		return fmt.Errorf("power-on color temperature %d out of [%d, %d] mirek", p.Mirek, minMirek, maxMirek)
+	}
+	if p.Color != nil {
+		if r, g, b, _ := p.Color.RGBA(); r == 0 && g == 0 && b == 0 {
+			return errors.New("power-on color is black, it has no chromaticity")
+		}
+	}
+	if p.Brightness < 0 || p.Brightness > 1 {
+		return fmt.Errorf("power-on brightness %v out of [0, 1]", p.Brightness)
+	}
+	return nil
+}
+
+// powerup is the powerup of a light resource of the CLIP v2 API.
+type powerup struct {
+	Preset  string          `json:"preset"`
+	On      *powerupOn      `json:"on,omitempty"`
+	Dimming *powerupDimming `json:"dimming,omitempty"`
+	Color   *powerupColor   `json:"color,omitempty"`
+}
+
+type powerupOn struct {
+	Mode string `json:"mode"`
+	On   struct {
+		On bool `json:"on"`
+	} `json:"on"`
+}
+
+type powerupDimming struct {
+	Mode    string `json:"mode"` // "dimming" or "previous".
+	Dimming *struct {
+		Brightness float64 `json:"brightness"` // In percent.
+	} `json:"dimming,omitempty"`
+}
+
+type powerupColor struct {
+	Mode  string `json:"mode"` // "color", "color_temperature" or "previous".
+	Color *struct {
+		XY xy `json:"xy"`
+	} `json:"color,omitempty"`
+	ColorTemperature *struct {
+		Mirek int `json:"mirek"`
+	} `json:"color_temperature,omitempty"`
+}
+
+// xy is a chromaticity of the CIE 1931 color space.
+type xy struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+func (p PowerOn) powerup() powerup {
+	up := powerup{Preset: p.Mode}
+	if p.Mode != PowerOnCustom {
+		return up
+	}
+	up.On = &powerupOn{Mode: "on"}
+	up.On.On.On = true
+
+	up.Dimming = &powerupDimming{Mode: "previous"}
+	if p.Brightness > 0 {
+		up.Dimming.Mode = "dimming"
+		up.Dimming.Dimming = &struct {
+			Brightness float64 `json:"brightness"`
+		}{Brightness: math.Round(p.Brightness*10000) / 100}
+	}
+
+	up.Color = &powerupColor{Mode: "previous"}
+	switch {
+	case p.Color != nil:
+		up.Color.Mode = "color"
+		up.Color.Color = &struct {
+			XY xy `json:"xy"`
+		}{XY: chromaticity(p.Color)}
+	case p.Mirek != 0:
+		up.Color.Mode = "color_temperature"
+		up.Color.ColorTemperature = &struct {
+			Mirek int `json:"mirek"`
+		}{Mirek: p.Mirek}
+	}
+	return up
+}
+
+// chromaticity returns the chromaticity of c, an sRGB color, rounded to 4
+// decimals.
+func chromaticity(c color.Color) xy {
+	p, _ := huecolor.RGBToXY(c)
+	round := func(v float64) float64 { return math.Round(v*10000) / 10000 }
+	return xy{X: round(p.X), Y: round(p.Y)}
+}
+
+// SetPowerOnBehavior sets the power-on behavior of the light lightID.
+func SetPowerOnBehavior(ctx context.Context, host, username, lightID string, p PowerOn, opts ...Option) error {
+	if err := p.validate(); err != nil {
+		return fmt.Errorf("light %s: %w", lightID, err)
+	}
+	c := newClient(host, username, "")
+	c.cfg = newConfig(opts)
+
+	err := c.traced(ctx, "power on", "", func(ctx context.Context) error {
+		return c.setPowerOn(ctx, lightID, p)
+	})
+	if err != nil {
+		return fmt.Errorf("light %s: power on: %w", lightID, err)
+	}
+	return nil
+}
+
+// SetAreaPowerOnBehavior sets the power-on behavior of the lights of the
+// area areaID. It tries every light, the failures are reported as a
+// *LightError each, joined.
+func SetAreaPowerOnBehavior(ctx context.Context, host, username, areaID string, p PowerOn, opts ...Option) error {
+	if err := p.validate(); err != nil {
+		return fmt.Errorf("area %s: %w", areaID, err)
+	}
+	c := newClient(host, username, "")
+	c.cfg = newConfig(opts)
+
+	err := c.traced(ctx, "area power on", areaID, func(ctx context.Context) error {
+		_, lights, err := c.areaLights(ctx, areaID)
+		if err != nil {
+			return err
+		}
+		var errs []error
+		for _, l := range lights {
+			if err := c.setPowerOn(ctx, l.ID, p); err != nil {
+				errs = append(errs, &LightError{Light: l.ID, Err: err})
+			}
+		}
+		return errors.Join(errs...)
+	})
+	if err != nil {
+		return fmt.Errorf("area %s: power on: %w", areaID, err)
+	}
+	return nil
+}
+
+func (c *client) setPowerOn(ctx context.Context, lightID string, p PowerOn) error {
+	return c.putResource(ctx, "light", lightID, struct {
+		Powerup powerup `json:"powerup"`
+	}{p.powerup()})
+}
@@ -0,0 +1,98 @@
+package huestream_test
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"net/http"
+	"testing"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huetest"
+)
+
+func TestSetPowerOnBehavior(t *testing.T) {
+	b := huetest.NewBridge(t)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		p    huestream.PowerOn
+		body string
+	}{
+		{
+			p:    huestream.PowerOn{Mode: huestream.PowerOnLastState},
+			body: `{"powerup":{"preset":"last_on_state"}}`,
+		},
+		{
+			p: huestream.PowerOn{Mode: huestream.PowerOnCustom, Color: color.RGBA{R: 255, A: 255}, Brightness: 0.6},
+			body: `{"powerup":{"preset":"custom","on":{"mode":"on","on":{"on":true}},` +
+				`"dimming":{"mode":"dimming","dimming":{"brightness":60}},"color":{"mode":"color","color":{"xy":{"x":0.7006,"y":0.2993}}}}}`,
+		},
+		{
+			p: huestream.PowerOn{Mode: huestream.PowerOnCustom, Mirek: 366},
+			body: `{"powerup":{"preset":"custom","on":{"mode":"on","on":{"on":true}},` +
+				`"dimming":{"mode":"previous"},"color":{"mode":"color_temperature","color_temperature":{"mirek":366}}}}`,
+		},
+	} {
+		if err := huestream.SetPowerOnBehavior(ctx, b.Host, b.Username, "light-2", tt.p, b.Options()...); err != nil {
+			t.Fatal(err)
+		}
+		want := huetest.Request{Method: "PUT", Path: "/clip/v2/resource/light/light-2", Body: tt.body}
+		if got := lastRequest(t, b); got != want {
+			t.Errorf("got request %+v, want %+v", got, want)
+		}
+		if got := b.PowerOn(2); got != tt.p.Mode {
+			t.Errorf("got preset %q, want %q", got, tt.p.Mode)
+		}
+	}
+
+	for _, p := range []huestream.PowerOn{
+		{},
+		{Mode: "blackout"},
+		{Mode: huestream.PowerOnSafety, Brightness: 0.5},
+		{Mode: huestream.PowerOnCustom, Color: color.White, Mirek: 300},
+		{Mode: huestream.PowerOnCustom, Mirek: 100},
+		{Mode: huestream.PowerOnCustom, Color: color.Black},
+		{Mode: huestream.PowerOnCustom, Brightness: 1.5},
+	} {
+		if err := huestream.SetPowerOnBehavior(ctx, b.Host, b.Username, "light-2", p, b.Options()...); err == nil {
+			t.Errorf("%+v accepted", p)
+		}
+	}
+}
+
+func TestSetAreaPowerOnBehavior(t *testing.T) {
+	b := huetest.NewBridge(t)
+	ctx := context.Background()
+
+	p := huestream.PowerOn{Mode: huestream.PowerOnPowerFail}
+	if err := huestream.SetAreaPowerOnBehavior(ctx, b.Host, b.Username, b.AreaID, p, b.Options()...); err != nil {
+		t.Fatal(err)
+	}
+	lights, err := huestream.Lights(ctx, b.Host, b.Username, b.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range lights {
+		if l.PowerOn != huestream.PowerOnPowerFail {
+			t.Errorf("light %s: got power on %q, want %q", l.ID, l.PowerOn, huestream.PowerOnPowerFail)
+		}
+	}
+
+	b.SetStatus("PUT", http.StatusServiceUnavailable)
+	err = huestream.SetAreaPowerOnBehavior(ctx, b.Host, b.Username, b.AreaID, p, b.Options()...)
+	joined, ok := errors.Unwrap(err).(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("got %v, want the joined failures of the lights", err)
+	}
+	var failed []string
+	for _, err := range joined.Unwrap() {
+		var lerr *huestream.LightError
+		if errors.As(err, &lerr) {
+			failed = append(failed, lerr.Light)
+		}
+	}
+	if len(failed) != huetest.Lights {
+		t.Errorf("got failures of %v, want one by light: %v", failed, err)
+	}
+}
@@ -0,0 +1,264 @@
+// Package preset loads preset packs, named presets of the effects of the
+// effects package with their parameters, written as JSON:
+//
+//	{
+//	  "name": "halloween",
+//	  "description": "Pumpkins, candles and a storm",
+//	  "palettes": {"pumpkin": ["#ff5a00", "orange", "#5a00a0"]},
+//	  "presets": [
+//	    {"name": "pumpkins", "effect": "palette",
+//	     "params": {"palette": "pumpkin", "step": "2s", "blend": true}},
+//	    {"name": "storm", "effect": "lightning", "params": {"chance": 0.05}}
+//	  ]
+//	}
+//
+// The effects and their parameters are the ones of effects.Registry: a
+// number, a duration as "2s" or in seconds, a color of huestream.ParseColor,
+// true or false, and for a palette an array of colors or the name of a
+// palette of the pack. The parameters left out take their default.
+//
+// Load checks a pack against the registry, its errors naming the preset and
+// the parameter at fault and listing the valid choices. Builtin returns the
+// seasonal packs shipped with the package.
+package preset
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/effects"
+)
+
+// Rate is the rate of the frames of the presets, in Hz, the rate of the
+// effects of the huestream command.
+const Rate = 25
+
+// Pack is a pack of presets.
+type Pack struct {
+	Name, Description string
+	Presets           []Preset
+}
+
+// Preset is an effect of the registry with its parameters.
+type Preset struct {
+	Name, Description string
+	Effect            effects.Effect
+	Args              effects.Args
+}
+
+// Frames returns the frames of p at Rate for the channels ids. The random
+// effects take opts, as effects.WithSeed to play the same show every time.
+func (p Preset) Frames(ids []int, opts ...effects.Option) iter.Seq[huestream.Frame] {
+	return p.Effect.Frames(ids, Rate, p.Args, opts...)
+}
+
+// Lookup returns the preset of the pack named name.
+func (pk *Pack) Lookup(name string) (Preset, bool) {
+	for _, p := range pk.Presets {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Preset{}, false
+}
+
+// Play plays the preset named name on the channels ids of st until ctx is
+// done or the effect ends, as huestream.Play.
+func (pk *Pack) Play(ctx context.Context, st huestream.Streamer, ids []int, name string, opts ...effects.Option) error {
+	p, ok := pk.Lookup(name)
+	if !ok {
+		return fmt.Errorf("preset: pack %s has no preset %q", pk.Name, name)
+	}
+	return huestream.Play(ctx, st, p.Frames(ids, opts...), Rate)
+}
+
+// packDoc is the JSON document of a pack.
+type packDoc struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Palettes    map[string][]string `json:"palettes"`
+	Presets     []presetDoc         `json:"presets"`
+}
+
+type presetDoc struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Effect      string                     `json:"effect"`
+	Params      map[string]json.RawMessage `json:"params"`
+}
+
+// Load reads a pack from r and checks it against the registry of effects.
+func Load(r io.Reader) (*Pack, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("preset: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var doc packDoc
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("preset: %w", jsonError(data, err))
+	}
+	if dec.More() {
+		rest := bytes.TrimLeft(data[dec.InputOffset():], " \t\r\n")
+		return nil, fmt.Errorf("preset: %s: data after the pack", position(data, int64(len(data)-len(rest))))
+	}
+	pk, err := doc.pack()
+	if err != nil {
+		if doc.Name != "" {
+			return nil, fmt.Errorf("preset: pack %s: %w", doc.Name, err)
+		}
+		return nil, fmt.Errorf("preset: %w", err)
+	}
+	return pk, nil
+}
+
+// jsonError locates err, an error decoding data, by line and column.
+func jsonError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// The offset is the one of the byte after the fault.
+		return fmt.Errorf("%s: %w", position(data, syntaxErr.Offset-1), err)
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("%s: %s is a JSON %s, want a %v", position(data, typeErr.Offset), typeErr.Field, typeErr.Value, typeErr.Type)
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return errors.New("unexpected end of the pack")
+	}
+	// The unknown fields, whose error does not tell where they are.
+	return errors.New(strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// position returns the line and the column of the byte at offset of data,
+// from 1.
+func position(data []byte, offset int64) string {
+	offset = min(offset, int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("line %d:%d", line, col)
+}
+
+func (doc *packDoc) pack() (*Pack, error) {
+	if doc.Name == "" {
+		return nil, errors.New(`missing "name" of the pack`)
+	}
+	if len(doc.Presets) == 0 {
+		return nil, errors.New("no presets")
+	}
+	pk := &Pack{Name: doc.Name, Description: doc.Description}
+	for i, pd := range doc.Presets {
+		p, err := doc.preset(pd)
+		if err != nil {
+			if pd.Name == "" {
+				return nil, fmt.Errorf("preset %d: %w", i+1, err)
+			}
+			return nil, fmt.Errorf("preset %s: %w", pd.Name, err)
+		}
+		if _, ok := pk.Lookup(p.Name); ok {
+			return nil, fmt.Errorf("preset %s: name already used by a preset of the pack", p.Name)
+		}
+		pk.Presets = append(pk.Presets, p)
+	}
+	return pk, nil
+}
+
+func (doc *packDoc) preset(pd presetDoc) (Preset, error) {
+	if pd.Name == "" {
+		return Preset{}, errors.New(`missing "name"`)
+	}
+	e, ok := effects.Lookup(pd.Effect)
+	if !ok {
+		if pd.Effect == "" {
+			return Preset{}, fmt.Errorf(`missing "effect", want one of %s`, strings.Join(effects.Names(), ", "))
+		}
+		return Preset{}, fmt.Errorf("unknown effect %q, want one of %s", pd.Effect, strings.Join(effects.Names(), ", "))
+	}
+	p := Preset{Name: pd.Name, Description: pd.Description, Effect: e, Args: make(effects.Args)}
+	for _, name := range slices.Sorted(maps.Keys(pd.Params)) {
+		param, ok := e.Param(name)
+		if !ok {
+			return Preset{}, fmt.Errorf("effect %s has no parameter %q, %s", e.Name, name, paramNames(e))
+		}
+		v, err := doc.decode(param, pd.Params[name])
+		if err != nil {
+			return Preset{}, fmt.Errorf("parameter %s: %w", name, err)
+		}
+		p.Args[name] = v
+	}
+	return p, nil
+}
+
+// decode decodes the value data of the parameter p, resolving the names of
+// the palettes of the pack.
+func (doc *packDoc) decode(p effects.Param, data json.RawMessage) (any, error) {
+	var name string
+	if p.Type != effects.PaletteParam || json.Unmarshal(data, &name) != nil {
+		return p.Decode(data)
+	}
+	colors, ok := doc.Palettes[name]
+	if !ok {
+		if len(doc.Palettes) == 0 {
+			return nil, fmt.Errorf("unknown palette %q, the pack has none", name)
+		}
+		return nil, fmt.Errorf("unknown palette %q, want one of %s", name, strings.Join(slices.Sorted(maps.Keys(doc.Palettes)), ", "))
+	}
+	data, err := json.Marshal(colors)
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("palette %s: %w", name, err)
+	}
+	return v, nil
+}
+
+// paramNames describes the parameters of e in an error.
+func paramNames(e effects.Effect) string {
+	if len(e.Params) == 0 {
+		return "it has none"
+	}
+	names := make([]string, len(e.Params))
+	for i, p := range e.Params {
+		names[i] = p.Name
+	}
+	return "want one of " + strings.Join(names, ", ")
+}
+
+//go:embed packs/*.json
+var builtin embed.FS
+
+// Builtin returns the seasonal packs shipped with the package, sorted by
+// name: halloween, holidays and spring.
+func Builtin() []*Pack {
+	names, err := fs.Glob(builtin, "packs/*.json")
+	if err != nil {
+		panic(err)
+	}
+	var packs []*Pack
+	for _, name := range names {
+		data, err := builtin.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+		pk, err := Load(bytes.NewReader(data))
+		if err != nil {
+			panic(fmt.Sprintf("%s: %v", name, err))
+		}
+		packs = append(packs, pk)
+	}
+	return packs
+}
@@ -0,0 +1,134 @@
+package preset
+
+import (
+	"image/color"
+	"iter"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/effects"
+)
+
+// take returns the first n frames of seq.
+func take(seq iter.Seq[huestream.Frame], n int) []huestream.Frame {
+	var frames []huestream.Frame
+	for f := range seq {
+		if len(frames) == n {
+			break
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+func TestBuiltin(t *testing.T) {
+	packs := Builtin()
+	var names []string
+	seen := make(map[string]bool)
+	for _, pk := range packs {
+		names = append(names, pk.Name)
+		for _, p := range pk.Presets {
+			if seen[p.Name] {
+				t.Errorf("preset %s in two packs", p.Name)
+			}
+			seen[p.Name] = true
+			if p.Description == "" {
+				t.Errorf("preset %s: no description", p.Name)
+			}
+			if len(take(p.Frames([]int{0, 1, 2}), 2)) != 2 {
+				t.Errorf("preset %s: no frames", p.Name)
+			}
+		}
+	}
+	if got := strings.Join(names, ","); got != "halloween,holidays,spring" {
+		t.Errorf("got packs %s", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	pk, err := Load(strings.NewReader(`{
+		"name": "test",
+		"palettes": {"fire": ["red", "#ff8800"]},
+		"presets": [
+			{"name": "fire", "effect": "palette", "params": {"palette": "fire", "step": 0.4}},
+			{"name": "sea", "effect": "palette", "params": {"palette": ["blue", "rgb(0, 128, 128)"], "blend": true}},
+			{"name": "dusk", "effect": "sparkle", "params": {"color": "purple"}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{0, 1}
+
+	fire, _ := pk.Lookup("fire")
+	red, orange := color.RGBA{R: 0xff, A: 0xff}, color.RGBA{R: 0xff, G: 0x88, A: 0xff}
+	want := take(effects.PaletteCycle(ids, []color.Color{red, orange}, 10), 25)
+	if got := take(fire.Frames(ids), 25); !reflect.DeepEqual(got, want) {
+		t.Errorf("fire: got %v, want %v", got, want)
+	}
+
+	sea, _ := pk.Lookup("sea")
+	teal := color.RGBA{G: 0x80, B: 0x80, A: 0xff}
+	want = take(effects.PaletteCycle(ids, []color.Color{color.RGBA{B: 0xff, A: 0xff}, teal}, Rate, effects.WithBlend()), 40)
+	if got := take(sea.Frames(ids), 40); !reflect.DeepEqual(got, want) {
+		t.Errorf("sea: got %v, want %v", got, want)
+	}
+
+	dusk, _ := pk.Lookup("dusk")
+	want = take(effects.Sparkle(ids, color.RGBA{R: 0x80, B: 0x80, A: 0xff}, 0.05, effects.WithSeed(1)), 50)
+	if got := take(dusk.Frames(ids, effects.WithSeed(1)), 50); !reflect.DeepEqual(got, want) {
+		t.Errorf("dusk: got %v, want %v", got, want)
+	}
+
+	if _, ok := pk.Lookup("storm"); ok {
+		t.Error("found the preset storm, not in the pack")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		pack, want string
+	}{
+		{`{"name": "x", "presets": [}`, "preset: line 1:27: invalid character '}'"},
+		{"{\n  \"name\": \"x\",\n  \"colors\": []\n}", `preset: unknown field "colors"`},
+		{`{"name": 3}`, "preset: line 1:11: name is a JSON number, want a string"},
+		{`{"name": "x"`, "preset: unexpected end of the pack"},
+		{`{"name": "x", "presets": []} {}`, "preset: line 1:30: data after the pack"},
+		{`{"presets": [{"name": "a", "effect": "candle"}]}`, `preset: missing "name" of the pack`},
+		{`{"name": "x", "presets": []}`, "preset: pack x: no presets"},
+		{`{"name": "x", "presets": [{"effect": "candle"}]}`, `preset: pack x: preset 1: missing "name"`},
+		{`{"name": "x", "presets": [{"name": "a"}]}`,
+			`preset: pack x: preset a: missing "effect", want one of rainbow, sparkle, candle, lightning, palette, sunrise`},
+		{`{"name": "x", "presets": [{"name": "a", "effect": "strobe"}]}`,
+			`preset: pack x: preset a: unknown effect "strobe", want one of rainbow`},
+		{`{"name": "x", "presets": [{"name": "a", "effect": "rainbow", "params": {"speed": 2}}]}`,
+			`preset: pack x: preset a: effect rainbow has no parameter "speed", want one of period, spread`},
+		{`{"name": "x", "presets": [{"name": "a", "effect": "candle", "params": {"speed": 2}}]}`,
+			`effect candle has no parameter "speed", it has none`},
+		{`{"name": "x", "presets": [{"name": "a", "effect": "rainbow", "params": {"period": "soon"}}]}`,
+			`preset a: parameter period: invalid duration "soon"`},
+		{`{"name": "x", "presets": [{"name": "a", "effect": "rainbow", "params": {"period": true}}]}`,
+			`parameter period: want a duration as "2s", got true`},
+		{`{"name": "x", "presets": [{"name": "a", "effect": "lightning", "params": {"chance": -1}}]}`,
+			"parameter chance: want a number not negative, got -1"},
+		{`{"name": "x", "presets": [{"name": "a", "effect": "sparkle", "params": {"color": "reddish"}}]}`,
+			`parameter color: invalid color "reddish"`},
+		{`{"name": "x", "presets": [{"name": "a", "effect": "palette", "params": {"palette": "fire"}}]}`,
+			`parameter palette: unknown palette "fire", the pack has none`},
+		{`{"name": "x", "palettes": {"sea": ["blue"], "sky": ["cyan"]}, "presets": [{"name": "a", "effect": "palette", "params": {"palette": "fire"}}]}`,
+			`parameter palette: unknown palette "fire", want one of sea, sky`},
+		{`{"name": "x", "palettes": {"fire": []}, "presets": [{"name": "a", "effect": "palette", "params": {"palette": "fire"}}]}`,
+			`parameter palette: palette fire: want an array of colors`},
+		{`{"name": "x", "presets": [{"name": "a", "effect": "palette", "params": {"blend": "yes"}}]}`,
+			`parameter blend: want true or false, got "yes"`},
+		{`{"name": "x", "presets": [{"name": "a", "effect": "candle"}, {"name": "a", "effect": "candle"}]}`,
+			"preset a: name already used by a preset of the pack"},
+	} {
+		_, err := Load(strings.NewReader(tc.pack))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Load(%s): got %v, want an error with %q", tc.pack, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package huestream
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"maps"
+	"sync"
+	"time"
+)
+
+// priorityLane arbitrates between the ambient frames of Send and the
+// priority frames of SendPriority.
+type priorityLane struct {
+	mu      sync.Mutex
+	ambient Frame     // The last ambient color of every channel.
+	frame   Frame     // The priority frame held, nil if none.
+	until   time.Time // The end of the hold.
+	held    map[int]bool
+}
+
+// overlay records the ambient frame f and returns the frame to send: f
+// with the colors of the priority frame held, if any.
+func (l *priorityLane) overlay(f Frame) Frame {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ambient == nil {
+		l.ambient = make(Frame, len(f))
+	}
+	maps.Copy(l.ambient, f)
+	if l.frame == nil {
+		return f
+	}
+	out := maps.Clone(f)
+	if out == nil {
+		out = make(Frame, len(l.frame))
+	}
+	maps.Copy(out, l.frame)
+	return out
+}
+
+// hold makes f the priority frame until at least now+d, and reports
+// whether no frame was held before.
+func (l *priorityLane) hold(f Frame, now time.Time, d time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	first := l.frame == nil
+	if first {
+		l.held = make(map[int]bool)
+	}
+	l.frame = f
+	for id := range f {
+		l.held[id] = true
+	}
+	if end := now.Add(d); end.After(l.until) {
+		l.until = end
+	}
+	return first
+}
+
+// end returns the end of the hold.
+func (l *priorityLane) end() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.until
+}
+
+// release ends the hold if it is over at now, and returns the frame handing
+// the channels held back to the ambient colors, black for the channels
+// without one.
+func (l *priorityLane) release(now time.Time) (Frame, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Before(l.until) {
+		return nil, false // Extended meanwhile.
+	}
+	resume := maps.Clone(l.ambient)
+	if resume == nil {
+		resume = make(Frame, len(l.held))
+	}
+	for id := range l.held {
+		if _, ok := resume[id]; !ok {
+			resume[id] = color.Black
+		}
+	}
+	l.frame, l.held = nil, nil
+	return resume, true
+}
+
+// SendPriority sends frame at once and holds it over the frames of Send
+// for hold, as for an alert interrupting an ambient effect. During the hold
+// the channels of frame keep its colors, the frames of Send still light the
+// other channels. At the end of the hold the channels of frame get back the
+// last colors Send gave them, black if none, and Send has the output again.
+//
+// A priority frame sent during the hold of another one replaces it, the
+// latest wins, and the hold lasts until the later of their ends. The
+// channels of both are handed back at the end. SendPriority returns
+// ErrPaused while paused and ErrClosed after Close.
+func (s *Stream) SendPriority(frame Frame, hold time.Duration) error {
+	if s.Paused() {
+		return ErrPaused
+	}
+	if s.isClosed() {
+		return ErrClosed
+	}
+	if s.prio.hold(frame, s.clk.Now(), hold) && !s.goBackground(s.releasePriority) {
+		return ErrClosed
+	}
+	return s.send(frame)
+}
+
+// releasePriority waits for the end of the hold of the priority frame and
+// hands the output back to Send.
+func (s *Stream) releasePriority() {
+	for {
+		t := s.clk.NewTimer(s.prio.end().Sub(s.clk.Now()))
+		select {
+		case <-s.quit:
+			t.Stop()
+			return
+		case <-t.C():
+		}
+
+		resume, ok := s.prio.release(s.clk.Now())
+		if !ok {
+			continue
+		}
+		err := s.send(resume)
+		if err != nil && !errors.Is(err, ErrPaused) {
+			s.errs.report(fmt.Errorf("priority: %w", err))
+		}
+		return
+	}
+}
@@ -0,0 +1,149 @@
+package huestream
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+var (
+	prioRed   = color.RGBA{R: 0xff, A: 0xff}
+	prioGreen = color.RGBA{G: 0xff, A: 0xff}
+	prioBlue  = color.RGBA{B: 0xff, A: 0xff}
+)
+
+// The values sent for prioRed, prioGreen and prioBlue.
+var (
+	sentRed   = [3]uint16{0xffff, 0, 0}
+	sentGreen = [3]uint16{0, 0xffff, 0}
+	sentBlue  = [3]uint16{0, 0, 0xffff}
+)
+
+// nextColors returns the colors of the next frame written, or fails.
+func nextColors(t *testing.T, frames <-chan []byte) map[uint16][3]uint16 {
+	t.Helper()
+	select {
+	case b := <-frames:
+		return decodeValues(t, b)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame written")
+		return nil
+	}
+}
+
+// noFrame fails if a frame is written.
+func noFrame(t *testing.T, frames <-chan []byte) {
+	t.Helper()
+	select {
+	case b := <-frames:
+		t.Errorf("frame %v written, want none", decodeValues(t, b))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSendPriority(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, frames := pipeStream(t, withClock(clk))
+
+	if err := s.Send(Frame{0: prioRed, 1: prioRed}); err != nil {
+		t.Fatal(err)
+	}
+	nextColors(t, frames)
+
+	if err := s.SendPriority(Frame{0: prioBlue}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := nextColors(t, frames); got[0] != sentBlue || len(got) != 1 {
+		t.Errorf("priority frame sent as %v, want channel 0 blue only", got)
+	}
+
+	// The ambient frames light the other channels only.
+	if err := s.Send(Frame{0: prioGreen, 1: prioGreen}); err != nil {
+		t.Fatal(err)
+	}
+	if got := nextColors(t, frames); got[0] != sentBlue || got[1] != sentGreen {
+		t.Errorf("ambient frame sent as %v during the hold, want 0 blue and 1 green", got)
+	}
+
+	clk.BlockUntil(1)
+	clk.Advance(999 * time.Millisecond)
+	noFrame(t, frames)
+	clk.Advance(time.Millisecond)
+	if got := nextColors(t, frames); got[0] != sentGreen || got[1] != sentGreen {
+		t.Errorf("ambient resumed as %v, want 0 and 1 green", got)
+	}
+
+	if err := s.Send(Frame{0: prioRed}); err != nil {
+		t.Fatal(err)
+	}
+	if got := nextColors(t, frames); got[0] != sentRed {
+		t.Errorf("ambient frame sent as %v after the hold, want 0 red", got)
+	}
+}
+
+func TestSendPriorityOverlap(t *testing.T) {
+	tests := []struct {
+		name   string
+		second time.Duration // The hold of the second priority frame.
+		end    time.Duration // When the output is handed back.
+	}{
+		{"shorter", 200 * time.Millisecond, time.Second},
+		{"longer", time.Second, 1500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := clock.NewFake(time.Unix(0, 0))
+			s, frames := pipeStream(t, withClock(clk))
+
+			if err := s.SendPriority(Frame{0: prioRed}, time.Second); err != nil {
+				t.Fatal(err)
+			}
+			nextColors(t, frames)
+			clk.BlockUntil(1)
+			clk.Advance(500 * time.Millisecond)
+
+			if err := s.SendPriority(Frame{1: prioBlue}, tt.second); err != nil {
+				t.Fatal(err)
+			}
+			nextColors(t, frames)
+
+			// The latest wins: only its channel is held.
+			if err := s.Send(Frame{0: prioGreen, 1: prioGreen}); err != nil {
+				t.Fatal(err)
+			}
+			got := nextColors(t, frames)
+			if got[0] != sentGreen || got[1] != sentBlue {
+				t.Errorf("ambient frame sent as %v, want 0 green and 1 blue", got)
+			}
+
+			for clk.Now().Sub(time.Unix(0, 0)) < tt.end-100*time.Millisecond {
+				clk.BlockUntil(1)
+				clk.Advance(100 * time.Millisecond)
+				noFrame(t, frames)
+			}
+			clk.BlockUntil(1)
+			clk.Advance(100 * time.Millisecond)
+			got = nextColors(t, frames)
+			if got[0] != sentGreen || got[1] != sentGreen {
+				t.Errorf("ambient resumed as %v at %v, want 0 and 1 green", got, tt.end)
+			}
+		})
+	}
+}
+
+func TestSendPriorityBlackWithoutAmbient(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, frames := pipeStream(t, withClock(clk))
+
+	if err := s.SendPriority(Frame{2: prioRed}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	nextColors(t, frames)
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	if got := nextColors(t, frames); got[2] != [3]uint16{} {
+		t.Errorf("channel handed back as %v, want black", got[2])
+	}
+}
@@ -0,0 +1,118 @@
+// Package prometheus exposes the metrics of a huestream.Stream to
+// Prometheus:
+//
+//	m := prometheus.New(areaID, host)
+//	registry.MustRegister(m)
+//	s, err := huestream.Start(ctx, host, username, clientKey, areaID, huestream.WithMetrics(m))
+//
+// It is a separate module so that huestream does not depend on the
+// Prometheus client.
+package prometheus
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rschio/huestream"
+)
+
+// Metrics is a huestream.BridgeMetricsHook and a prometheus.Collector. Its
+// metrics have the constant labels area_id and bridge, so the Metrics of
+// several Streams can be registered together.
+type Metrics struct {
+	frames     prometheus.Counter
+	bytes      prometheus.Counter
+	errors     prometheus.Counter
+	lastSend   prometheus.Gauge
+	send       prometheus.Histogram
+	requests   *prometheus.HistogramVec
+	throttled  prometheus.Counter
+	reconnects prometheus.Counter
+}
+
+var (
+	_ huestream.BridgeMetricsHook = (*Metrics)(nil)
+	_ prometheus.Collector        = (*Metrics)(nil)
+)
+
+// New returns the Metrics of the Stream of the area areaID of the bridge.
+func New(areaID, bridge string) *Metrics {
+	labels := prometheus.Labels{"area_id": areaID, "bridge": bridge}
+	counter := func(name, help string) prometheus.Counter {
+		return prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "huestream", Name: name, Help: help, ConstLabels: labels,
+		})
+	}
+
+	return &Metrics{
+		frames: counter("frames_sent_total", "Messages written to the bridge, keepalives included."),
+		bytes:  counter("bytes_sent_total", "Bytes of the messages written to the bridge."),
+		errors: counter("send_errors_total", "Failed writes to the bridge."),
+		lastSend: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "huestream", Name: "last_send_timestamp_seconds",
+			Help:        "Time of the last message written to the bridge.",
+			ConstLabels: labels,
+		}),
+		send: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "huestream", Name: "send_duration_seconds",
+			Help:        "Time spent writing a message to the bridge.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(0.00001, 4, 8), // 10µs to ~160ms.
+		}),
+		requests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "huestream", Name: "clip_request_duration_seconds",
+			Help:        "Duration of the CLIP requests by request and result.",
+			ConstLabels: labels,
+		}, []string{"request", "result"}),
+		throttled:  counter("clip_throttled_total", "CLIP requests rejected with 429 Too Many Requests."),
+		reconnects: counter("reconnects_total", "Sessions recovered after a failure."),
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.frames, m.bytes, m.errors, m.lastSend, m.send, m.requests, m.throttled, m.reconnects,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// SendDuration implements huestream.MetricsHook.
+func (m *Metrics) SendDuration(d time.Duration) {
+	m.frames.Inc()
+	m.send.Observe(d.Seconds())
+	m.lastSend.SetToCurrentTime()
+}
+
+// FrameSize implements huestream.MetricsHook.
+func (m *Metrics) FrameSize(n int) { m.bytes.Add(float64(n)) }
+
+// Error implements huestream.MetricsHook.
+func (m *Metrics) Error(error) { m.errors.Inc() }
+
+// RequestDuration implements huestream.BridgeMetricsHook.
+func (m *Metrics) RequestDuration(name string, d time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.requests.WithLabelValues(name, result).Observe(d.Seconds())
+}
+
+// Throttled implements huestream.BridgeMetricsHook.
+func (m *Metrics) Throttled() { m.throttled.Inc() }
+
+// Reconnect implements huestream.BridgeMetricsHook.
+func (m *Metrics) Reconnect() { m.reconnects.Inc() }
@@ -0,0 +1,64 @@
+package prometheus
+
+import (
+	"context"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huetest"
+)
+
+func TestMetrics(t *testing.T) {
+	b := huetest.NewBridge(t)
+	m := New(b.AreaID, b.Host)
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(m)
+
+	opts := append(b.Options(), huestream.WithMetrics(m))
+	stream, err := huestream.Start(context.Background(), b.Host, b.Username, b.ClientKey, b.AreaID, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if err := stream.Send(huestream.Frame{0: color.White}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `
+# HELP huestream_frames_sent_total Messages written to the bridge, keepalives included.
+# TYPE huestream_frames_sent_total counter
+huestream_frames_sent_total{area_id="` + b.AreaID + `",bridge="127.0.0.1"} 3
+# HELP huestream_bytes_sent_total Bytes of the messages written to the bridge.
+# TYPE huestream_bytes_sent_total counter
+huestream_bytes_sent_total{area_id="` + b.AreaID + `",bridge="127.0.0.1"} 177
+`
+	err = testutil.GatherAndCompare(reg, strings.NewReader(want), "huestream_frames_sent_total", "huestream_bytes_sent_total")
+	if err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(m, "huestream_clip_request_duration_seconds"); n != 3 {
+		t.Errorf("got %d request series, want preflight, start and stop", n)
+	}
+}
+
+func TestThrottled(t *testing.T) {
+	b := huetest.NewBridge(t)
+	b.SetStatus("PUT", 429)
+	m := New(b.AreaID, b.Host)
+
+	opts := append(b.Options(), huestream.WithMetrics(m))
+	if _, err := huestream.Start(context.Background(), b.Host, b.Username, b.ClientKey, b.AreaID, opts...); err == nil {
+		t.Fatal("Start should fail")
+	}
+	if got := testutil.ToFloat64(m.throttled); got != 1 {
+		t.Errorf("throttled = %v, want 1", got)
+	}
+}
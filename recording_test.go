@@ -0,0 +1,13 @@
+package huestream_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordingPath is the file of the CLIP answers recorded by an e2e test and
+// replayed by the fake bridge.
+func recordingPath(t *testing.T) string {
+	return filepath.Join("testdata", "e2e", strings.ReplaceAll(t.Name(), "/", "_")+".json")
+}
@@ -0,0 +1,145 @@
+package huestream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// recoveryPollInterval is the time between two attempts to reach the bridge
+// while recovering a session. A rebooting bridge takes ~30s to answer again.
+const recoveryPollInterval = 2 * time.Second
+
+// ErrRecovered is reported to the error handler when a session has been
+// recovered after a failure, see WithRecovery.
+var ErrRecovered = errors.New("session recovered")
+
+// ErrRecoveryFailed is reported to the error handler when the session could
+// not be recovered before the recovery deadline. The Stream is unusable and
+// should be closed.
+var ErrRecoveryFailed = errors.New("session recovery failed")
+
+// RecoveryError is reported to the error handler for every failed step of a
+// session recovery, so the progress of the recovery can be followed.
+type RecoveryError struct {
+	Attempt int    // The attempt number, starting at 1.
+	Step    string // "poll", "start" or "handshake".
+	Err     error
+}
+
+func (e *RecoveryError) Error() string {
+	return fmt.Sprintf("recovery attempt %d: %s: %v", e.Attempt, e.Step, e.Err)
+}
+
+func (e *RecoveryError) Unwrap() error { return e.Err }
+
+// recoverOnFailure starts a session recovery in the background if recovery
+// is enabled and err means the session is lost.
+func (s *Stream) recoverOnFailure(err error) {
+	s.endOnFailure(err)
+	if s.cfg.recovery <= 0 || s.client == nil || IsTransient(err) {
+		return
+	}
+	if !s.recovering.CompareAndSwap(false, true) {
+		return
+	}
+	s.logger().Warn("session lost, recovering", "error", err)
+	if !s.goBackground(s.recoverSession) {
+		s.recovering.Store(false)
+	}
+}
+
+// recoverSession waits for the bridge to answer, starts the stream again and
+// replaces the connection, giving up after the recovery deadline.
+func (s *Stream) recoverSession() {
+	defer s.recovering.Store(false)
+
+	ctx, cancel := context.WithTimeout(s.traceContext(), s.cfg.recovery)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	start := s.clk.Now()
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		conn, err := s.reconnect(ctx, attempt)
+		if err == nil {
+			if s.replaceConn(conn) {
+				s.logger().Info("session recovered", "attempt", attempt, "duration", s.clk.Now().Sub(start))
+				s.traceSpan().AddEvent("recovered", slog.Int("attempt", attempt))
+				s.reconnects.Add(1)
+				if m, ok := s.cfg.metrics.(BridgeMetricsHook); ok {
+					m.Reconnect()
+				}
+				s.errs.report(ErrRecovered)
+				if err := s.resend(0); err != nil {
+					s.errs.report(fmt.Errorf("recovery: %w", err))
+				}
+			}
+			return
+		}
+		lastErr = err
+		var rerr *RecoveryError
+		if errors.As(err, &rerr) {
+			s.logger().Warn("recovery attempt failed", "attempt", rerr.Attempt, "step", rerr.Step, "error", rerr.Err)
+		}
+		s.errs.report(err)
+
+		t := s.clk.NewTimer(recoveryPollInterval)
+		select {
+		case <-t.C():
+		case <-ctx.Done():
+			t.Stop()
+			select {
+			case <-s.quit:
+			default:
+				s.logger().Warn("session recovery failed", "duration", s.clk.Now().Sub(start), "error", lastErr)
+				err := fmt.Errorf("%w after %v: %w", ErrRecoveryFailed, s.cfg.recovery, lastErr)
+				s.term.enter(err)
+				s.errs.report(err)
+			}
+			return
+		}
+	}
+}
+
+// reconnect runs one attempt to bring the session back.
+func (s *Stream) reconnect(ctx context.Context, attempt int) (net.Conn, error) {
+	c := s.client
+	if err := c.getConfiguration(ctx, s.areaID); err != nil {
+		return nil, &RecoveryError{Attempt: attempt, Step: "poll", Err: err}
+	}
+	if err := c.startStream(ctx, s.areaID); err != nil {
+		return nil, &RecoveryError{Attempt: attempt, Step: "start", Err: err}
+	}
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return nil, &RecoveryError{Attempt: attempt, Step: "handshake", Err: err}
+	}
+	return conn, nil
+}
+
+// replaceConn makes conn the connection of the Stream and closes the old
+// one. It reports false, closing conn, if the Stream was closed meanwhile.
+func (s *Stream) replaceConn(conn net.Conn) bool {
+	s.connMu.Lock()
+	if s.closed {
+		s.connMu.Unlock()
+		conn.Close()
+		return false
+	}
+	old := s.conn
+	s.conn = conn
+	s.connMu.Unlock()
+
+	old.Close()
+	return true
+}
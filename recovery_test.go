@@ -0,0 +1,120 @@
+package huestream
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+func TestRecoveryAfterBridgeReboot(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+		polls    int
+	)
+	c := fakeBridge(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method)
+		if r.Method == "GET" {
+			polls++
+			if polls == 1 { // Still booting.
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}
+	}))
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	c.dial = func(ctx context.Context) (net.Conn, error) { return local, nil }
+
+	dead := &funcConn{write: func(b []byte) (int, error) { return 0, net.ErrClosed }}
+	clk := clock.NewFake(time.Unix(0, 0))
+	errs := make(chan error, errorQueueSize)
+	s := newStream(dead, c, testAreaID, newConfig([]Option{
+		withClock(clk),
+		WithIdleThreshold(0),
+		WithRecovery(time.Minute),
+		WithErrorHandler(func(err error) { errs <- err }),
+	}))
+	defer s.Close()
+
+	if err := s.Send(Frame{0: color.White}); err == nil {
+		t.Fatal("Send on a dead conn should fail")
+	}
+
+	var recErr *RecoveryError
+	if err := <-errs; !errors.As(err, &recErr) || recErr.Step != "poll" {
+		t.Fatalf("got %v, want a poll *RecoveryError", err)
+	}
+	clk.BlockUntil(1)
+	clk.Advance(recoveryPollInterval)
+
+	if err := <-errs; !errors.Is(err, ErrRecovered) {
+		t.Fatalf("got %v, want %v", err, ErrRecovered)
+	}
+
+	mu.Lock()
+	got := append([]string(nil), requests...)
+	mu.Unlock()
+	want := []string{"GET", "GET", "PUT"}
+	if len(got) != len(want) {
+		t.Fatalf("bridge got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("bridge got %v, want %v", got, want)
+		}
+	}
+
+	sent := make(chan error)
+	go func() { sent <- s.Send(Frame{1: color.Black}) }()
+
+	// The recovery resends the last message, then comes the new frame.
+	buf := make([]byte, 1024)
+	remote.SetReadDeadline(time.Now().Add(time.Second))
+	for range 2 {
+		if _, err := remote.Read(buf); err != nil {
+			t.Fatalf("nothing sent on the new conn: %v", err)
+		}
+	}
+	if err := <-sent; err != nil {
+		t.Errorf("Send after recovery: %v", err)
+	}
+}
+
+func TestRecoveryGivesUp(t *testing.T) {
+	c := fakeBridge(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	dead := &funcConn{write: func(b []byte) (int, error) { return 0, net.ErrClosed }}
+	errs := make(chan error, errorQueueSize)
+	s := newStream(dead, c, testAreaID, newConfig([]Option{
+		WithIdleThreshold(0),
+		WithRecovery(100 * time.Millisecond),
+		WithErrorHandler(func(err error) { errs <- err }),
+	}))
+	defer s.Close()
+
+	s.Send(Frame{0: color.White})
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case err := <-errs:
+			if errors.Is(err, ErrRecoveryFailed) {
+				return
+			}
+		case <-timeout:
+			t.Fatal("recovery failure not reported")
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package huestream
+
+import (
+	"image/color"
+	"math"
+	"sync"
+
+	"github.com/rschio/huestream/huecolor"
+)
+
+// The colors passed to a Stream are sRGB colors, as the colors of the
+// image/color package, unless WithLinearInput is used. The sRGB encoding is
+// not proportional to the light emitted: the Stream does its math, the
+// brightness floor, the dithering and the moves of SetTarget and
+// Transition, on linear intensities, and sends the colors sRGB encoded to
+// the bridge. A frame sent as is, without these stages, reaches the bridge
+// unchanged. The conversions are the ones of the huecolor package.
+
+// toLinear returns the linear intensities of c, decoded from sRGB unless
+// linear is set.
+func toLinear(c color.Color, linear bool) rgb {
+	r, g, b, _ := c.RGBA()
+	v := rgb{float64(r), float64(g), float64(b)}
+	if !linear {
+		for i := range v {
+			v[i] = huecolor.ToLinear(v[i]/0xffff) * 0xffff
+		}
+	}
+	return v
+}
+
+// fromLinear returns the color of the linear intensities c, encoded in sRGB
+// unless linear is set.
+func fromLinear(c rgb, linear bool) color.RGBA64 {
+	u := func(v float64) uint16 {
+		v = min(max(v, 0), 0xffff)
+		if !linear {
+			v = huecolor.FromLinear(v/0xffff) * 0xffff
+		}
+		return uint16(math.Round(v))
+	}
+	return color.RGBA64{R: u(c[0]), G: u(c[1]), B: u(c[2]), A: 0xffff}
+}
+
+// render returns idColors as sent to the lamps: in linear intensities, with
+// the brightness floors of WithMinBrightness and WithChannelMinBrightness
+// applied and dithered with WithDithering, then sRGB encoded. idColors is
+// not modified.
+func (s *Stream) render(idColors Frame) Frame {
+	if s.cfg.minBrightness == 0 && s.cfg.channelMinBrightness == nil && s.cfg.ditherLevels == 0 && !s.cfg.linearInput {
+		return idColors // Decoded and encoded back unchanged.
+	}
+	lin := make(map[int]rgb, len(idColors))
+	for id, c := range idColors {
+		floor, ok := s.cfg.channelMinBrightness[id]
+		if !ok {
+			floor = s.cfg.minBrightness
+		}
+		lin[id] = raiseFloor(toLinear(c, s.cfg.linearInput), floor)
+	}
+	if s.cfg.ditherLevels > 0 {
+		return s.dither.apply(lin, s.cfg.ditherLevels)
+	}
+	out := make(Frame, len(lin))
+	for id, c := range lin {
+		out[id] = fromLinear(c, false)
+	}
+	return out
+}
+
+// raiseFloor maps the brightness of c, its largest intensity, from (0, 1]
+// onto (floor, 1], keeping its hue. Black stays black, the lamp is off.
+func raiseFloor(c rgb, floor float64) rgb {
+	m := max(c[0], c[1], c[2])
+	if m <= 0 || floor <= 0 {
+		return c
+	}
+	v := m / 0xffff
+	k := (floor + v*(1-floor)) / v
+	for i := range c {
+		c[i] = min(c[i]*k, 0xffff)
+	}
+	return c
+}
+
+// ditherer spreads the quantization error of the lamps over time: a value
+// between two levels the lamps render is sent as the nearest levels in
+// turn, so that the average of their intensities over the frames is the
+// value.
+type ditherer struct {
+	mu  sync.Mutex
+	err map[int][3]float64 // The intensity carried over, by channel.
+}
+
+// apply returns the sRGB encoded colors of the linear intensities of f,
+// quantized to levels levels per encoded component.
+func (d *ditherer) apply(f map[int]rgb, levels int) Frame {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err == nil {
+		d.err = make(map[int][3]float64)
+	}
+
+	steps := float64(levels - 1)
+	intensity := func(level float64) float64 { return huecolor.ToLinear(level / steps) }
+	out := make(Frame, len(f))
+	for id, c := range f {
+		carry := d.err[id]
+		var v [3]uint16
+		for i := range c {
+			want := c[i]/0xffff + carry[i]
+			lo := math.Floor(huecolor.FromLinear(min(max(want, 0), 1)) * steps)
+			hi := min(lo+1, steps)
+			level := lo
+			if want-intensity(lo) > intensity(hi)-want {
+				level = hi
+			}
+			carry[i] = want - intensity(level)
+			v[i] = uint16(math.Round(level / steps * 0xffff))
+		}
+		d.err[id] = carry
+		out[id] = color.RGBA64{R: v[0], G: v[1], B: v[2], A: 0xffff}
+	}
+	return out
+}
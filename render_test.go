@@ -0,0 +1,134 @@
+package huestream
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/rschio/huestream/huecolor"
+	"github.com/rschio/huestream/wire"
+)
+
+// srgb16 returns the sRGB encoded 16 bits value of the linear intensity v,
+// from 0 to 1.
+func srgb16(v float64) uint16 { return uint16(math.Round(huecolor.FromLinear(v) * 0xffff)) }
+
+func TestSRGBRoundTrip(t *testing.T) {
+	// The Stream encodes back what it decodes, the frames sent as is reach
+	// the bridge as before the linear stage.
+	for v := range 0x10000 {
+		c := color.RGBA64{R: uint16(v), A: 0xffff}
+		if got := fromLinear(toLinear(c, false), false); got != c {
+			t.Fatalf("%d decoded and encoded back to %d", v, got.R)
+		}
+	}
+}
+
+func TestLinearInput(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want uint16 // The red sent for a red of 0x8000.
+	}{
+		{"srgb", nil, 0x8000},
+		{"srgb stage", []Option{WithDithering(0x10000)}, 0x8000},
+		{"linear", []Option{WithLinearInput()}, srgb16(float64(0x8000) / 0xffff)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, frames := pipeStream(t, tt.opts...)
+			if err := s.Send(Frame{0: color.RGBA64{R: 0x8000, A: 0xffff}}); err != nil {
+				t.Fatal(err)
+			}
+			f, err := wire.Decode(<-frames)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Channels[0].Values[0]; got != tt.want {
+				t.Errorf("red sent %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRaiseFloorRamp(t *testing.T) {
+	const floor = 0.1
+	var last float64
+	for v := 0; v <= 0xffff; v += 0x40 {
+		c := raiseFloor(rgb{float64(v), float64(v) / 2}, floor)
+		switch {
+		case v == 0:
+			if c[0] != 0 {
+				t.Fatalf("black raised to %v", c[0])
+			}
+			continue
+		case c[0] < floor*0xffff:
+			t.Fatalf("red %d raised to %v, under the floor", v, c[0])
+		case c[0] <= last:
+			t.Fatalf("red %d raised to %v, not above the previous %v", v, c[0], last)
+		case math.Abs(c[1]-c[0]/2) > 1e-6:
+			t.Fatalf("red %d raised to %v with green %v, hue not kept", v, c[0], c[1])
+		}
+		last = c[0]
+	}
+	if c := raiseFloor(rgb{0xffff, 0xffff, 0xffff}, floor); c[0] != 0xffff {
+		t.Errorf("white raised to %v, want full brightness", c[0])
+	}
+}
+
+func TestMinBrightness(t *testing.T) {
+	s, frames := pipeStream(t,
+		WithMinBrightness(0.2),
+		WithChannelMinBrightness(map[int]float64{1: 0.5}),
+	)
+
+	dim := color.RGBA64{R: 0, G: 0, B: 1, A: 0xffff}
+	if err := s.Send(Frame{0: dim, 1: dim, 2: color.Black}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := wire.Decode(<-frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The floors are intensities, sRGB encoded.
+	want := []wire.Channel{
+		{ID: 0, Values: [3]uint16{0, 0, srgb16(0.2)}},
+		{ID: 1, Values: [3]uint16{0, 0, srgb16(0.5)}},
+		{ID: 2},
+	}
+	for i, ch := range f.Channels {
+		if ch != want[i] {
+			t.Errorf("channel %d = %v, want %v", ch.ID, ch.Values, want[i].Values)
+		}
+	}
+}
+
+func TestDithering(t *testing.T) {
+	s, frames := pipeStream(t, WithDithering(256))
+
+	// A red between the levels 10 and 11.
+	const low, high = 10 * 257, 11 * 257
+	in := Frame{0: color.RGBA64{R: low + 64, A: 0xffff}}
+	const n = 1000
+	count := map[uint16]int{}
+	for range n {
+		if err := s.Send(in); err != nil {
+			t.Fatal(err)
+		}
+		f, err := wire.Decode(<-frames)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count[f.Channels[0].Values[0]]++
+	}
+
+	// The intensities of the levels average to the intensity of the red.
+	lin := func(v float64) float64 { return huecolor.ToLinear(v / 0xffff) }
+	ratio := (lin(low+64) - lin(low)) / (lin(high) - lin(low))
+	if len(count) != 2 || math.Abs(float64(count[high])-ratio*n) > 1 {
+		t.Errorf("sent the reds %v, want %d at %d", count, int(math.Round(ratio*n)), high)
+	}
+	if in[0] != (color.RGBA64{R: low + 64, A: 0xffff}) {
+		t.Errorf("the frame sent was modified to %v", in[0])
+	}
+}
@@ -0,0 +1,134 @@
+package huestream
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+// Report summarizes the activity of a Stream over a period, see WithReport
+// and Stream.ReportEvery.
+type Report struct {
+	Start, End time.Time // The period covered.
+
+	FramesSent      uint64
+	FramesPerSecond float64
+	BytesSent       uint64 // Bytes of the messages written in the period.
+	BytesPerSecond  float64
+
+	// SendLatencyP50 and SendLatencyP99 are upper bounds of the median and
+	// 99th percentile of the writes of the period, see Stats.
+	SendLatencyP50 time.Duration
+	SendLatencyP99 time.Duration
+
+	// Drops counts the frames lost in the period: failed writes and slots
+	// skipped by the send loops.
+	Drops uint64
+
+	// Errors counts the failed writes of the period, and the transient
+	// errors retried by the background send paths.
+	Errors uint64
+
+	Reconnects uint64 // Sessions recovered in the period.
+
+	Sequence uint8 // The sequence number of the next message, at End.
+}
+
+// reporter computes the Reports of a Stream, each one covering the period
+// since the previous one.
+type reporter struct {
+	s           *Stream
+	prev        Stats
+	prevLatency histogramCounts
+	start       time.Time
+}
+
+// newReporter returns a reporter whose first Report starts now.
+func (s *Stream) newReporter() *reporter {
+	return &reporter{s: s, prev: s.Stats(), prevLatency: s.latency.snapshot(), start: s.clk.Now()}
+}
+
+// next returns the Report of the period since the previous call. The
+// counters of Stats only grow, recoveries included, so the period of a
+// reconnect is counted as any other.
+func (r *reporter) next() Report {
+	cur, latency, end := r.s.Stats(), r.s.latency.snapshot(), r.s.clk.Now()
+	prev, window := r.prev, latency.sub(r.prevLatency)
+	rep := Report{
+		Start:          r.start,
+		End:            end,
+		FramesSent:     cur.FramesSent - prev.FramesSent,
+		BytesSent:      cur.BytesSent - prev.BytesSent,
+		SendLatencyP50: window.quantile(0.50),
+		SendLatencyP99: window.quantile(0.99),
+		Drops: cur.SendErrors - prev.SendErrors +
+			cur.SkippedSlots - prev.SkippedSlots,
+		Errors: cur.SendErrors - prev.SendErrors +
+			cur.TransientErrors - prev.TransientErrors,
+		Reconnects: cur.Reconnects - prev.Reconnects,
+		Sequence:   cur.Sequence,
+	}
+	if secs := end.Sub(r.start).Seconds(); secs > 0 {
+		rep.FramesPerSecond = float64(rep.FramesSent) / secs
+		rep.BytesPerSecond = float64(rep.BytesSent) / secs
+	}
+	r.prev, r.prevLatency, r.start = cur, latency, end
+	return rep
+}
+
+// report hands f a Report on every tick of t until the Stream is closed or
+// done is closed, then stops t.
+//
+// It only loads atomics, so it never blocks the send path. A slow f delays
+// the next reports, the ticks it misses are dropped and their activity is
+// in the next Report. It runs on a goroutine Close doesn't wait for, so f
+// may close the Stream.
+func (s *Stream) report(r *reporter, t clock.Ticker, done <-chan struct{}, f func(Report)) {
+	defer t.Stop()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-done:
+			return
+		case <-t.C():
+		}
+		f(r.next())
+	}
+}
+
+func (s *Stream) logReport(r Report) {
+	s.logger().Info("stream report",
+		"duration", r.End.Sub(r.Start),
+		"frames", r.FramesSent,
+		"fps", r.FramesPerSecond,
+		"bytes", r.BytesSent,
+		"latency_p50", r.SendLatencyP50,
+		"latency_p99", r.SendLatencyP99,
+		"drops", r.Drops,
+		"errors", r.Errors,
+		"reconnects", r.Reconnects,
+	)
+}
+
+// ReportEvery starts a goroutine calling f every interval with the Report of
+// the activity of the Stream since the previous call, until ctx is done or
+// the Stream is closed. It is WithReport for the caller setting it up after
+// Start, or wanting several reporters. It returns ErrClosed after Close.
+//
+// f is called on the goroutine of the reporter: a slow f delays the next
+// calls, the ticks it misses are dropped and their activity is reported by
+// the next call. f may close the Stream, Close doesn't wait for a running f.
+func (s *Stream) ReportEvery(ctx context.Context, interval time.Duration, f func(Report)) error {
+	if interval <= 0 {
+		return fmt.Errorf("report interval %v not positive", interval)
+	}
+	if s.isClosed() {
+		return ErrClosed
+	}
+	// Taken now, the first call reports the activity from ReportEvery on.
+	go s.report(s.newReporter(), s.clk.NewTicker(interval), ctx.Done(), f)
+	return nil
+}
@@ -0,0 +1,185 @@
+package huestream
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+func TestReport(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	reports := make(chan Report, 4)
+	s, frames := pipeStream(t, withClock(clk), WithReport(time.Second, func(r Report) { reports <- r }))
+
+	clk.BlockUntil(1)
+	for range 5 {
+		if err := s.Send(Frame{0: color.White}); err != nil {
+			t.Fatal(err)
+		}
+		<-frames
+	}
+	clk.Advance(time.Second)
+
+	r := <-reports
+	if r.FramesSent != 5 || r.FramesPerSecond != 5 || r.End.Sub(r.Start) != time.Second {
+		t.Errorf("got %+v, want 5 frames in 1s", r)
+	}
+
+	// The next report only covers its own period.
+	clk.Advance(time.Second)
+	if r := <-reports; r.FramesSent != 0 || r.SendLatencyP99 != 0 {
+		t.Errorf("got %+v, want an empty period", r)
+	}
+}
+
+func TestReportStopsOnClose(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, _ := pipeStream(t, withClock(clk), WithReport(time.Second, func(Report) {}))
+	clk.BlockUntil(1)
+
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the reporter")
+	}
+}
+
+func TestCloseFromReport(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	var stream atomic.Pointer[Stream]
+	closed := make(chan error, 1)
+	s, _ := pipeStream(t, withClock(clk), WithReport(time.Second, func(Report) {
+		closed <- stream.Load().Close()
+	}))
+	stream.Store(s)
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close called from the report function deadlocked")
+	}
+}
+
+func TestReportEvery(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, frames := pipeStream(t, withClock(clk))
+	s.SetSequence(7)
+
+	reports := make(chan Report, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.ReportEvery(ctx, 2*time.Second, func(r Report) { reports <- r }); err != nil {
+		t.Fatal(err)
+	}
+	clk.BlockUntil(1)
+
+	var size uint64
+	for range 4 {
+		if err := s.Send(Frame{0: color.White}); err != nil {
+			t.Fatal(err)
+		}
+		size = uint64(len(<-frames))
+	}
+	clk.Advance(2 * time.Second)
+
+	want := Report{
+		Start:           time.Unix(0, 0),
+		End:             time.Unix(2, 0),
+		FramesSent:      4,
+		FramesPerSecond: 2,
+		BytesSent:       4 * size,
+		BytesPerSecond:  2 * float64(size),
+		Sequence:        11,
+	}
+	r := nextReport(t, reports)
+	// The bucket bounds of the latencies are tested by TestReport.
+	r.SendLatencyP50, r.SendLatencyP99 = 0, 0
+	if r != want {
+		t.Errorf("got %+v, want %+v", r, want)
+	}
+
+	// The next period only covers its own activity.
+	clk.Advance(2 * time.Second)
+	if r := nextReport(t, reports); r.FramesSent != 0 || r.BytesSent != 0 || r.Start != want.End {
+		t.Errorf("got %+v, want an empty period from %v", r, want.End)
+	}
+
+	cancel()
+	clk.Advance(2 * time.Second)
+	select {
+	case r := <-reports:
+		t.Errorf("got %+v after the context was canceled", r)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func nextReport(t *testing.T, reports <-chan Report) Report {
+	t.Helper()
+	select {
+	case r := <-reports:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("no report")
+		return Report{}
+	}
+}
+
+func TestCloseFromReportEvery(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, _ := pipeStream(t, withClock(clk))
+	closed := make(chan error, 1)
+	if err := s.ReportEvery(context.Background(), time.Second, func(Report) { closed <- s.Close() }); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close called from the report function deadlocked")
+	}
+}
+
+func TestReportEveryClosed(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, _ := pipeStream(t, withClock(clk))
+	if err := s.ReportEvery(context.Background(), time.Second, func(Report) {}); err != nil {
+		t.Fatal(err)
+	}
+	clk.BlockUntil(1)
+
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the reporter")
+	}
+
+	err := s.ReportEvery(context.Background(), time.Second, func(Report) {})
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("ReportEvery after Close returned %v, want ErrClosed", err)
+	}
+}
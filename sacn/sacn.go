@@ -0,0 +1,418 @@
+// Package sacn receives the DMX universes of sACN (ANSI E1.31) sources,
+// such as xLights or Falcon Player, and streams them to an entertainment
+// area:
+//
+//	r, err := sacn.Listen(sacn.Patch{0: {Universe: 1, Slot: 1}, 1: {Universe: 1, Slot: 4}})
+//	...
+//	err = r.Run(ctx, stream)
+//
+// A Patch maps every Hue channel to the slot of its red in a universe,
+// green and blue being the next two slots. The Receiver joins the multicast
+// groups of the patched universes, or listens for unicast with
+// WithListenAddr.
+//
+// The sources of a universe are merged as E1.31 specifies: the sources of
+// the highest priority win, and when several send at that priority every
+// slot takes the highest of their values. A source sending no packet for
+// the network data loss timeout, or terminating its stream, is dropped;
+// the slots of a universe without sources are black. The preview packets
+// are ignored.
+package sacn
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/wire"
+)
+
+// Port is the UDP port of sACN.
+const Port = 5568
+
+// E1.31 data packet constants.
+const (
+	headerSize = 126
+	dmxSlots   = 512
+
+	vectorRootData    = 0x00000004
+	vectorFramingData = 0x00000002
+	vectorDMPSet      = 0x02
+
+	optionPreview    = 1 << 7
+	optionTerminated = 1 << 6
+)
+
+var acnID = []byte("ASC-E1.17\x00\x00\x00")
+
+// Address is the DMX address of the red of a channel.
+type Address struct {
+	Universe uint16 // From 1 to 63999.
+	Slot     int    // From 1 to 510.
+}
+
+// Patch maps the Hue channel IDs to the address of their red.
+type Patch map[int]Address
+
+// UniverseStats holds counters about a universe.
+type UniverseStats struct {
+	Packets      uint64 // Data packets received.
+	OutOfOrder   uint64 // Packets older than the last one of their source, dropped.
+	Preview      uint64 // Preview packets, ignored.
+	SourceLosses uint64 // Sources dropped after the timeout or terminated.
+	Sources      int    // Active sources.
+}
+
+// Option configures a Receiver.
+type Option func(*Receiver)
+
+// WithRate sets the maximum rate of the frames, by default 50 Hz.
+func WithRate(hz float64) Option {
+	return func(r *Receiver) { r.period = time.Duration(float64(time.Second) / hz) }
+}
+
+// WithSourceTimeout sets the time without packets after which a source is
+// dropped, by default 2.5s, the network data loss timeout of E1.31.
+func WithSourceTimeout(d time.Duration) Option {
+	return func(r *Receiver) { r.timeout = d }
+}
+
+// WithInterface sets the interface of the multicast groups, by default the
+// one chosen by the system.
+func WithInterface(ifi *net.Interface) Option {
+	return func(r *Receiver) { r.ifi = ifi }
+}
+
+// WithListenAddr makes the Receiver listen for unicast packets on addr
+// instead of joining the multicast groups.
+func WithListenAddr(addr string) Option {
+	return func(r *Receiver) { r.listenAddr = addr }
+}
+
+// Receiver receives the universes of a Patch.
+type Receiver struct {
+	patch      Patch
+	period     time.Duration
+	timeout    time.Duration
+	ifi        *net.Interface
+	listenAddr string
+	conns      []net.PacketConn
+
+	mu        sync.Mutex // Guards the fields below.
+	universes map[uint16]*universe
+}
+
+// universe is the state of a patched universe.
+type universe struct {
+	sources map[[16]byte]*source // By CID.
+	stats   UniverseStats
+}
+
+type source struct {
+	priority byte
+	sequence byte
+	data     [dmxSlots]byte
+	last     time.Time
+}
+
+// packet is a parsed data packet.
+type packet struct {
+	cid      [16]byte
+	priority byte
+	sequence byte
+	options  byte
+	universe uint16
+	data     []byte
+}
+
+// Listen starts receiving the universes of patch.
+func Listen(patch Patch, opts ...Option) (*Receiver, error) {
+	if len(patch) > wire.MaxChannels {
+		return nil, fmt.Errorf("sacn: %w: maximum is %d, got %d", huestream.ErrTooManyChannels, wire.MaxChannels, len(patch))
+	}
+	r := &Receiver{
+		patch:     patch,
+		period:    time.Second / 50,
+		timeout:   2500 * time.Millisecond,
+		universes: make(map[uint16]*universe),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.timeout <= 0 {
+		return nil, fmt.Errorf("sacn: source timeout must be positive, got %v", r.timeout)
+	}
+	for id, a := range patch {
+		if a.Universe < 1 || a.Universe > 63999 {
+			return nil, fmt.Errorf("sacn: channel %d: universe %d out of 1 to 63999", id, a.Universe)
+		}
+		if a.Slot < 1 || a.Slot > dmxSlots-2 {
+			return nil, fmt.Errorf("sacn: channel %d: slot %d out of 1 to %d", id, a.Slot, dmxSlots-2)
+		}
+		r.universes[a.Universe] = &universe{sources: make(map[[16]byte]*source)}
+	}
+
+	if r.listenAddr != "" {
+		conn, err := net.ListenPacket("udp", r.listenAddr)
+		if err != nil {
+			return nil, fmt.Errorf("sacn: %w", err)
+		}
+		r.conns = append(r.conns, conn)
+		return r, nil
+	}
+	for u := range r.universes {
+		conn, err := net.ListenMulticastUDP("udp4", r.ifi, MulticastAddr(u))
+		if err != nil {
+			r.close()
+			return nil, fmt.Errorf("sacn: universe %d: %w", u, err)
+		}
+		r.conns = append(r.conns, conn)
+	}
+	return r, nil
+}
+
+// MulticastAddr returns the multicast address of the universe.
+func MulticastAddr(universe uint16) *net.UDPAddr {
+	return &net.UDPAddr{IP: net.IPv4(239, 255, byte(universe>>8), byte(universe)), Port: Port}
+}
+
+// Addr returns the address of the unicast listener, nil without
+// WithListenAddr.
+func (r *Receiver) Addr() net.Addr {
+	if r.listenAddr == "" {
+		return nil
+	}
+	return r.conns[0].LocalAddr()
+}
+
+// Stats returns the counters of the patched universes.
+func (r *Receiver) Stats() map[uint16]UniverseStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make(map[uint16]UniverseStats, len(r.universes))
+	for id, u := range r.universes {
+		s := u.stats
+		s.Sources = len(u.sources)
+		stats[id] = s
+	}
+	return stats
+}
+
+func (r *Receiver) close() {
+	for _, c := range r.conns {
+		c.Close()
+	}
+}
+
+// Run streams the merged universes to st until ctx is done or a send
+// fails. The Receiver stops listening when Run returns, it returns nil when
+// ctx is done.
+func (r *Receiver) Run(ctx context.Context, st huestream.Streamer) error {
+	packets := make(chan packet)
+	readErr := make(chan error, len(r.conns))
+	var wg sync.WaitGroup
+	for _, c := range r.conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readErr <- r.read(ctx, c, packets)
+		}()
+	}
+	defer func() {
+		r.close()
+		wg.Wait()
+	}()
+
+	expire := time.NewTicker(r.timeout / 4)
+	defer expire.Stop()
+	send := time.NewTimer(0)
+	defer send.Stop()
+
+	var last time.Time
+	dirty := false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			if err != nil {
+				return fmt.Errorf("sacn: %w", err)
+			}
+		case p := <-packets:
+			if r.apply(p, time.Now()) {
+				dirty = true
+			}
+		case now := <-expire.C:
+			if r.expire(now) {
+				dirty = true
+			}
+		case <-send.C:
+		}
+
+		if !dirty {
+			continue
+		}
+		if wait := r.period - time.Since(last); wait > 0 {
+			send.Reset(wait)
+			continue
+		}
+		last = time.Now()
+		dirty = false
+		if err := st.SendContext(ctx, r.frame()); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("sacn: %w", err)
+		}
+	}
+}
+
+// read reads the packets of c until it is closed.
+func (r *Receiver) read(ctx context.Context, c net.PacketConn, packets chan<- packet) error {
+	buf := make([]byte, headerSize+dmxSlots)
+	for {
+		n, _, err := c.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p, ok := parsePacket(buf[:n])
+		if !ok {
+			continue
+		}
+		p.data = append([]byte(nil), p.data...)
+		select {
+		case packets <- p:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// apply applies p received at now, it reports whether the frame changed.
+func (r *Receiver) apply(p packet, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.universes[p.universe]
+	if !ok {
+		return false
+	}
+	if p.options&optionPreview != 0 {
+		u.stats.Preview++
+		return false
+	}
+	s, ok := u.sources[p.cid]
+	if p.options&optionTerminated != 0 {
+		if ok {
+			delete(u.sources, p.cid)
+			u.stats.SourceLosses++
+		}
+		return ok
+	}
+	if ok {
+		// E1.31 6.7.2: a packet up to 20 behind the last one is late.
+		if d := int8(p.sequence - s.sequence); d <= 0 && d > -20 {
+			u.stats.OutOfOrder++
+			return false
+		}
+	} else {
+		s = new(source)
+		u.sources[p.cid] = s
+	}
+	u.stats.Packets++
+	s.priority, s.sequence, s.last = p.priority, p.sequence, now
+	s.data = [dmxSlots]byte{}
+	copy(s.data[:], p.data)
+	return true
+}
+
+// expire drops the sources silent for the timeout, it reports whether
+// the frame changed.
+func (r *Receiver) expire(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed := false
+	for _, u := range r.universes {
+		for cid, s := range u.sources {
+			if now.Sub(s.last) >= r.timeout {
+				delete(u.sources, cid)
+				u.stats.SourceLosses++
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// frame returns the frame of the merged universes.
+func (r *Receiver) frame() huestream.Frame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	merged := make(map[uint16]*[dmxSlots]byte, len(r.universes))
+	for id, u := range r.universes {
+		merged[id] = u.merge()
+	}
+	f := make(huestream.Frame, len(r.patch))
+	for id, a := range r.patch {
+		d := merged[a.Universe]
+		f[id] = color.RGBA{R: d[a.Slot-1], G: d[a.Slot], B: d[a.Slot+1], A: 255}
+	}
+	return f
+}
+
+// merge returns the slots of the universe: the highest of the values of
+// the sources of the highest priority.
+func (u *universe) merge() *[dmxSlots]byte {
+	var top byte
+	for _, s := range u.sources {
+		top = max(top, s.priority)
+	}
+	var d [dmxSlots]byte
+	for _, s := range u.sources {
+		if s.priority != top {
+			continue
+		}
+		for i, v := range s.data {
+			d[i] = max(d[i], v)
+		}
+	}
+	return &d
+}
+
+// parsePacket parses an E1.31 data packet with DMX data.
+func parsePacket(b []byte) (packet, bool) {
+	if len(b) < headerSize ||
+		!bytes.Equal(b[4:16], acnID) ||
+		binary.BigEndian.Uint32(b[18:]) != vectorRootData ||
+		binary.BigEndian.Uint32(b[40:]) != vectorFramingData ||
+		b[117] != vectorDMPSet ||
+		b[125] != 0 { // DMX start code.
+		return packet{}, false
+	}
+	count := int(binary.BigEndian.Uint16(b[123:])) // Start code included.
+	if count < 1 || count > dmxSlots+1 || len(b) < headerSize+count-1 {
+		return packet{}, false
+	}
+	p := packet{
+		priority: b[108],
+		sequence: b[111],
+		options:  b[112],
+		universe: binary.BigEndian.Uint16(b[113:]),
+		data:     b[headerSize : headerSize+count-1],
+	}
+	copy(p.cid[:], b[22:38])
+	return p, true
+}
@@ -0,0 +1,228 @@
+package sacn
+
+import (
+	"context"
+	"encoding/binary"
+	"image/color"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+// chanStreamer is a fake Streamer passing the frames sent to a channel.
+type chanStreamer chan huestream.Frame
+
+func (c chanStreamer) Send(f huestream.Frame) error { return c.SendContext(context.Background(), f) }
+
+func (c chanStreamer) SendContext(ctx context.Context, f huestream.Frame) error {
+	select {
+	case c <- f:
+	default: // The test does not read it.
+	}
+	return nil
+}
+
+func (c chanStreamer) Close() error { return nil }
+
+// dataPacket is an E1.31 data packet of a test source.
+type dataPacket struct {
+	cid      byte // First byte of the CID.
+	priority byte
+	sequence byte
+	options  byte
+	universe uint16
+	data     []byte
+}
+
+func (p dataPacket) bytes() []byte {
+	b := make([]byte, headerSize+len(p.data))
+	binary.BigEndian.PutUint16(b[0:], 0x0010)
+	copy(b[4:], acnID)
+	binary.BigEndian.PutUint16(b[16:], 0x7000|uint16(len(b)-16))
+	binary.BigEndian.PutUint32(b[18:], vectorRootData)
+	b[22] = p.cid
+	binary.BigEndian.PutUint16(b[38:], 0x7000|uint16(len(b)-38))
+	binary.BigEndian.PutUint32(b[40:], vectorFramingData)
+	copy(b[44:], "test source")
+	b[108] = p.priority
+	b[111] = p.sequence
+	b[112] = p.options
+	binary.BigEndian.PutUint16(b[113:], p.universe)
+	binary.BigEndian.PutUint16(b[115:], 0x7000|uint16(len(b)-115))
+	b[117] = vectorDMPSet
+	b[118] = 0xa1
+	binary.BigEndian.PutUint16(b[121:], 1)
+	binary.BigEndian.PutUint16(b[123:], uint16(len(p.data)+1))
+	copy(b[headerSize:], p.data)
+	return b
+}
+
+// start runs a Receiver on a local port and returns a function sending
+// packets to it.
+func start(t *testing.T, patch Patch, opts ...Option) (*Receiver, func(dataPacket), chanStreamer) {
+	t.Helper()
+
+	opts = append([]Option{WithListenAddr("127.0.0.1:0")}, opts...)
+	r, err := Listen(patch, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := make(chanStreamer, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx, st) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	conn, err := net.Dial("udp", r.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	send := func(p dataPacket) {
+		t.Helper()
+		if _, err := conn.Write(p.bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r, send, st
+}
+
+func next(t *testing.T, st chanStreamer) huestream.Frame {
+	t.Helper()
+
+	select {
+	case f := <-st:
+		return f
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame sent")
+		return nil
+	}
+}
+
+// waitFor returns the first frame whose channel id is want.
+func waitFor(t *testing.T, st chanStreamer, id int, want [3]uint32) huestream.Frame {
+	t.Helper()
+
+	for {
+		f := next(t, st)
+		if rgb(f[id]) == want {
+			return f
+		}
+	}
+}
+
+func rgb(c color.Color) [3]uint32 {
+	r, g, b, _ := c.RGBA()
+	return [3]uint32{r >> 8, g >> 8, b >> 8}
+}
+
+func TestReceive(t *testing.T) {
+	_, send, st := start(t, Patch{0: {Universe: 1, Slot: 1}, 1: {Universe: 2, Slot: 4}})
+
+	send(dataPacket{priority: 100, universe: 1, data: []byte{255, 128, 0}})
+	send(dataPacket{priority: 100, sequence: 1, universe: 2, data: []byte{0, 0, 0, 1, 2, 3}})
+	f := waitFor(t, st, 1, [3]uint32{1, 2, 3})
+	if got, want := rgb(f[0]), [3]uint32{255, 128, 0}; got != want {
+		t.Errorf("channel 0 = %v, want %v", got, want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	r, send, st := start(t, Patch{0: {Universe: 1, Slot: 1}})
+
+	// Equal priorities merge highest takes precedence.
+	send(dataPacket{cid: 1, priority: 100, universe: 1, data: []byte{200, 10, 0}})
+	send(dataPacket{cid: 2, priority: 100, universe: 1, data: []byte{50, 20, 5}})
+	waitFor(t, st, 0, [3]uint32{200, 20, 5})
+
+	// A higher priority wins whatever its values.
+	send(dataPacket{cid: 3, priority: 150, universe: 1, data: []byte{1, 1, 1}})
+	waitFor(t, st, 0, [3]uint32{1, 1, 1})
+
+	// Until it terminates its stream.
+	send(dataPacket{cid: 3, priority: 150, sequence: 1, options: optionTerminated, universe: 1})
+	waitFor(t, st, 0, [3]uint32{200, 20, 5})
+
+	s := r.Stats()[1]
+	if s.Sources != 2 || s.SourceLosses != 1 || s.Packets != 3 {
+		t.Errorf("stats = %+v, want 2 sources, 1 loss and 3 packets", s)
+	}
+}
+
+func TestSourceLoss(t *testing.T) {
+	r, send, st := start(t, Patch{0: {Universe: 1, Slot: 1}}, WithSourceTimeout(100*time.Millisecond))
+
+	send(dataPacket{priority: 100, universe: 1, data: []byte{9, 9, 9}})
+	waitFor(t, st, 0, [3]uint32{9, 9, 9})
+	waitFor(t, st, 0, [3]uint32{0, 0, 0})
+
+	if s := r.Stats()[1]; s.Sources != 0 || s.SourceLosses != 1 {
+		t.Errorf("stats = %+v, want no source and 1 loss", s)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	r, send, st := start(t, Patch{0: {Universe: 1, Slot: 1}})
+
+	send(dataPacket{priority: 100, sequence: 10, universe: 1, data: []byte{10}})
+	send(dataPacket{priority: 100, sequence: 5, universe: 1, data: []byte{5}})
+	send(dataPacket{priority: 100, sequence: 10, universe: 1, data: []byte{10}})
+	send(dataPacket{priority: 100, sequence: 11, options: optionPreview, universe: 1, data: []byte{11}})
+	// Far behind the last one, the source restarted.
+	send(dataPacket{priority: 100, sequence: 200, universe: 1, data: []byte{200}})
+	waitFor(t, st, 0, [3]uint32{200, 0, 0})
+
+	s := r.Stats()[1]
+	if s.Packets != 2 || s.OutOfOrder != 2 || s.Preview != 1 {
+		t.Errorf("stats = %+v, want 2 packets, 2 out of order and 1 preview", s)
+	}
+}
+
+func TestIgnoreOtherUniverses(t *testing.T) {
+	r, send, st := start(t, Patch{0: {Universe: 1, Slot: 1}})
+
+	send(dataPacket{priority: 100, universe: 7, data: []byte{7, 7, 7}})
+	send(dataPacket{priority: 100, universe: 1, data: []byte{1, 1, 1}})
+	waitFor(t, st, 0, [3]uint32{1, 1, 1})
+	if _, ok := r.Stats()[7]; ok {
+		t.Error("stats of universe 7, not patched")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	valid := dataPacket{priority: 100, universe: 1, data: []byte{1, 2, 3}}.bytes()
+	if _, ok := parsePacket(valid); !ok {
+		t.Fatal("valid packet rejected")
+	}
+	for name, mutate := range map[string]func(b []byte) []byte{
+		"short":      func(b []byte) []byte { return b[:headerSize-1] },
+		"identifier": func(b []byte) []byte { b[4] = 'X'; return b },
+		"start code": func(b []byte) []byte { b[125] = 0xdd; return b },
+		"count":      func(b []byte) []byte { b[124] = 200; return b },
+	} {
+		b := mutate(append([]byte(nil), valid...))
+		if _, ok := parsePacket(b); ok {
+			t.Errorf("%s: invalid packet accepted", name)
+		}
+	}
+}
+
+func TestListenInvalidPatch(t *testing.T) {
+	for _, p := range []Patch{
+		{0: {Universe: 0, Slot: 1}},
+		{0: {Universe: 64000, Slot: 1}},
+		{0: {Universe: 1, Slot: 0}},
+		{0: {Universe: 1, Slot: 511}},
+	} {
+		if _, err := Listen(p, WithListenAddr("127.0.0.1:0")); err == nil {
+			t.Errorf("Listen(%v) succeeded", p)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package huestream_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huetest"
+)
+
+// lastRequest returns the last request of b.
+func lastRequest(t *testing.T, b *huetest.Bridge) huetest.Request {
+	t.Helper()
+
+	reqs := b.Requests()
+	if len(reqs) == 0 {
+		t.Fatal("no request")
+	}
+	return reqs[len(reqs)-1]
+}
+
+func TestStartDynamicScene(t *testing.T) {
+	b := huetest.NewBridge(t)
+	ctx := context.Background()
+
+	if err := huestream.StartDynamicScene(ctx, b.Host, b.Username, huetest.SceneID, 0.8, b.Options()...); err != nil {
+		t.Fatal(err)
+	}
+	want := huetest.Request{
+		Method: "PUT",
+		Path:   "/clip/v2/resource/scene/" + huetest.SceneID,
+		Body:   `{"recall":{"action":"dynamic_palette"},"speed":0.8}`,
+	}
+	if got := lastRequest(t, b); got != want {
+		t.Errorf("got request %+v, want %+v", got, want)
+	}
+	scenes, err := huestream.Scenes(ctx, b.Host, b.Username, b.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenes) != 1 || scenes[0] != (huestream.Scene{ID: huetest.SceneID, Name: "huetest scene", Speed: 0.8}) {
+		t.Errorf("got scenes %+v", scenes)
+	}
+
+	if err := huestream.StartDynamicScene(ctx, b.Host, b.Username, huetest.SceneID, 1.5, b.Options()...); err == nil {
+		t.Error("speed out of range accepted")
+	}
+	if err := huestream.StartDynamicScene(ctx, b.Host, b.Username, "missing", 0.5, b.Options()...); err == nil {
+		t.Error("unknown scene accepted")
+	}
+
+	s, err := huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, b.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := huestream.StartDynamicScene(ctx, b.Host, b.Username, huetest.SceneID, 0.5, b.Options()...); !errors.Is(err, huestream.ErrStreamActive) {
+		t.Errorf("streamed lights: got %v, want ErrStreamActive", err)
+	}
+}
+
+func TestSetGroupColorLoop(t *testing.T) {
+	b := huetest.NewBridge(t)
+	ctx := context.Background()
+
+	for _, on := range []bool{true, false} {
+		if err := huestream.SetGroupColorLoop(ctx, b.Host, b.Username, b.AreaID, on, b.Options()...); err != nil {
+			t.Fatal(err)
+		}
+		want := huetest.Request{
+			Method: "PUT",
+			Path:   "/api/" + b.Username + "/groups/200/action",
+			Body:   map[bool]string{true: `{"effect":"colorloop"}`, false: `{"effect":"none"}`}[on],
+		}
+		if got := lastRequest(t, b); got != want {
+			t.Errorf("got request %+v, want %+v", got, want)
+		}
+		if b.ColorLoop() != on {
+			t.Errorf("color loop %v, want %v", b.ColorLoop(), on)
+		}
+	}
+
+	if err := huestream.SetGroupColorLoop(ctx, b.Host, b.Username, "missing", true, b.Options()...); !errors.Is(err, huestream.ErrAreaNotFound) {
+		t.Errorf("unknown area: got %v, want ErrAreaNotFound", err)
+	}
+
+	s, err := huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, b.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := huestream.SetGroupColorLoop(ctx, b.Host, b.Username, b.AreaID, true, b.Options()...); !errors.Is(err, huestream.ErrStreamActive) {
+		t.Errorf("streamed area: got %v, want ErrStreamActive", err)
+	}
+}
+
+func TestSmartSceneRestore(t *testing.T) {
+	b := huetest.NewBridge(t)
+	ctx := context.Background()
+
+	if err := huestream.SetSmartScene(ctx, b.Host, b.Username, huetest.SmartSceneID, true, b.Options()...); err != nil {
+		t.Fatal(err)
+	}
+	want := huetest.Request{
+		Method: "PUT",
+		Path:   "/clip/v2/resource/smart_scene/" + huetest.SmartSceneID,
+		Body:   `{"recall":{"action":"activate"}}`,
+	}
+	if got := lastRequest(t, b); got != want {
+		t.Errorf("got request %+v, want %+v", got, want)
+	}
+	scenes, err := huestream.SmartScenes(ctx, b.Host, b.Username, b.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantScene := huestream.SmartScene{ID: huetest.SmartSceneID, Name: "huetest natural light", Group: "room-0", Active: true}
+	if len(scenes) != 1 || scenes[0] != wantScene {
+		t.Errorf("got smart scenes %+v, want %+v", scenes, wantScene)
+	}
+
+	opts := append(b.Options(), huestream.WithSmartSceneRestore())
+	s, err := huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.SmartSceneActive() {
+		t.Fatal("smart scene active while streaming")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !b.SmartSceneActive() {
+		t.Error("smart scene not restored by Close")
+	}
+
+	// Without the option the lights are left to the bridge.
+	s, err = huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, b.Options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b.SmartSceneActive() {
+		t.Error("smart scene restored without WithSmartSceneRestore")
+	}
+}
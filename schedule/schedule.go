@@ -0,0 +1,309 @@
+// Package schedule plays effects on an entertainment area at the times of
+// cron-like entries, for installations running unattended:
+//
+//	s, err := schedule.New(start, ids, []schedule.Entry{
+//		{Name: "ambient", Spec: "0 18 * * mon-fri", Duration: 5 * time.Hour, Frames: warm},
+//		{Name: "party", Spec: "0 23 * * fri", Duration: 2 * time.Hour, Priority: 1, Frames: party},
+//		{Name: "cleaning", Spec: "0 20 * * wed", Duration: time.Hour, Priority: 2}, // Lights off.
+//	}, schedule.WithLocation(loc))
+//	...
+//	err = s.Run(ctx)
+//
+// The stream is started when an entry begins and closed when none is in
+// effect, so the area is free for the Hue app and other applications out
+// of the hours of the schedule. When entries overlap, the one of highest
+// priority is in effect, the latest started for entries of the same
+// priority. An entry without Frames streams nothing: it stops the session
+// while in effect, silencing the entries of lower priority.
+//
+// The times are wall clock times of the location of the schedule, the
+// daylight saving time transitions included: an entry of 18:00 for 5 hours
+// runs until 23:00 on the days the clocks change too.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/internal/clock"
+	"github.com/rschio/huestream/internal/testhooks"
+)
+
+// Rate is the rate at which the frames of the entries are played, in Hz.
+const Rate = 25
+
+// retryDelay is the time before starting the stream again after a failure.
+const retryDelay = time.Minute
+
+// Entry is an entry of a schedule, an effect played from the times of its
+// Spec for its Duration.
+type Entry struct {
+	Name     string
+	Spec     string        // A cron expression, see ParseSpec.
+	Duration time.Duration // In wall clock time.
+	Priority int           // Higher wins over the entries overlapping.
+
+	// Frames returns the frames of the effect for the channels ids. A
+	// finite sequence holds its last frame until the end of the entry,
+	// resent by the keepalive of the Stream if set. Nil streams nothing
+	// and closes the stream while the entry is in effect.
+	Frames func(ids []int) iter.Seq[huestream.Frame]
+}
+
+// StartFunc starts the stream to the area, when an entry begins. It is
+// usually a call to huestream.Start.
+type StartFunc func(ctx context.Context) (huestream.Streamer, error)
+
+// Option configures a Scheduler.
+type Option func(*config)
+
+type config struct {
+	location     *time.Location
+	clock        clock.Clock
+	errorHandler func(error)
+}
+
+// WithLocation sets the location of the times of the entries, time.Local by
+// default.
+func WithLocation(loc *time.Location) Option {
+	return func(c *config) { c.location = loc }
+}
+
+// withClock makes the Scheduler read the time and arm its timers with c
+// instead of the system clock, for tests. Other packages reach it with
+// huetest.Clock.ScheduleOption.
+func withClock(c clock.Clock) Option {
+	return func(cfg *config) { cfg.clock = c }
+}
+
+func init() {
+	testhooks.ScheduleClock = func(c clock.Clock) any { return withClock(c) }
+}
+
+// WithErrorHandler sets a function called with the failures of the
+// Scheduler: the starts of the stream, the sends of the frames and the
+// closes. A failed stream is started again a minute later if its entry is
+// still in effect.
+func WithErrorHandler(h func(error)) Option {
+	return func(c *config) { c.errorHandler = h }
+}
+
+// Scheduler plays the entries of a schedule on a stream it starts and
+// closes as they begin and end.
+type Scheduler struct {
+	start   StartFunc
+	ids     []int
+	entries []entry
+	cfg     config
+
+	mu      sync.Mutex
+	current string // The name of the entry in effect.
+}
+
+// entry is an Entry with its parsed Spec.
+type entry struct {
+	Entry
+	spec *Spec
+}
+
+// New returns a Scheduler of entries, playing on the channels ids of the
+// streams of start. It returns an error if a Spec is invalid or a Duration
+// is not positive.
+func New(start StartFunc, ids []int, entries []Entry, opts ...Option) (*Scheduler, error) {
+	s := &Scheduler{
+		start: start,
+		ids:   ids,
+		cfg:   config{location: time.Local, clock: clock.Real},
+	}
+	for _, opt := range opts {
+		opt(&s.cfg)
+	}
+	for _, e := range entries {
+		spec, err := ParseSpec(e.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("entry %s: %w", e.Name, err)
+		}
+		if e.Duration <= 0 {
+			return nil, fmt.Errorf("entry %s: duration %v is not positive", e.Name, e.Duration)
+		}
+		s.entries = append(s.entries, entry{Entry: e, spec: spec})
+	}
+	return s, nil
+}
+
+// Current returns the name of the entry in effect, "" if none.
+func (s *Scheduler) Current() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// wallAdd returns t plus d in wall clock time, so that 18:00 plus 5 hours
+// is 23:00 on the days of the daylight saving time transitions too.
+func wallAdd(t time.Time, d time.Duration) time.Time {
+	y, m, day := t.Date()
+	h, mi, sec := t.Clock()
+	return wallDate(y, m, day, h, mi, sec, t.Nanosecond()+int(d), t.Location())
+}
+
+// occurrence returns the start of the last occurrence of e in progress at
+// now, and its end, the latest of the occurrences in progress.
+func (e *entry) occurrence(now time.Time) (start, end time.Time, ok bool) {
+	// An occurrence in progress started at most Duration ago, plus the
+	// hour of a daylight saving time transition.
+	for t := e.spec.Next(now.Add(-e.Duration - time.Hour)); !t.IsZero() && !t.After(now); t = e.spec.Next(t) {
+		if te := wallAdd(t, e.Duration); te.After(now) {
+			start, ok = t, true
+			if te.After(end) {
+				end = te
+			}
+		}
+	}
+	return start, end, ok
+}
+
+// winner returns the index of the entry in effect at now, -1 if none, and
+// the next time the winner may change.
+func (s *Scheduler) winner(now time.Time) (win int, next time.Time) {
+	win = -1
+	var winStart time.Time
+	for i := range s.entries {
+		e := &s.entries[i]
+		if t := e.spec.Next(now); !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+		start, end, ok := e.occurrence(now)
+		if !ok {
+			continue
+		}
+		if next.IsZero() || end.Before(next) {
+			next = end
+		}
+		if win >= 0 {
+			w := &s.entries[win]
+			if e.Priority < w.Priority || e.Priority == w.Priority && !start.After(winStart) {
+				continue
+			}
+		}
+		win, winStart = i, start
+	}
+	return win, next
+}
+
+// player plays the frames of an entry on a goroutine.
+type player struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	failed chan error // Receives the failure of the frames, if any.
+}
+
+// failedChan returns the channel of the failure of p, nil if p is nil.
+func (p *player) failedChan() <-chan error {
+	if p == nil {
+		return nil
+	}
+	return p.failed
+}
+
+func (p *player) stop() {
+	if p != nil {
+		p.cancel()
+		<-p.done
+	}
+}
+
+// Run runs the schedule until ctx is done, then closes the stream and
+// returns ctx.Err().
+func (s *Scheduler) Run(ctx context.Context) error {
+	var (
+		st      huestream.Streamer
+		playing = -1 // The entry played on st.
+		p       *player
+		retry   time.Time // When to start the stream again.
+	)
+	closeStream := func() {
+		p.stop()
+		p, playing = nil, -1
+		if st != nil {
+			if err := st.Close(); err != nil {
+				s.report(fmt.Errorf("schedule: close: %w", err))
+			}
+			st = nil
+		}
+	}
+	defer closeStream()
+
+	for {
+		now := s.cfg.clock.Now().In(s.cfg.location)
+		win, next := s.winner(now)
+		s.mu.Lock()
+		s.current = ""
+		if win >= 0 {
+			s.current = s.entries[win].Name
+		}
+		s.mu.Unlock()
+
+		switch {
+		case win < 0 || s.entries[win].Frames == nil:
+			closeStream()
+		case win != playing && !now.Before(retry):
+			e := s.entries[win]
+			p.stop()
+			p, playing = nil, -1
+			if st == nil {
+				var err error
+				if st, err = s.start(ctx); err != nil {
+					s.report(fmt.Errorf("schedule: %s: start: %w", e.Name, err))
+					st, retry = nil, now.Add(retryDelay)
+					break
+				}
+			}
+			p, playing = s.play(st, e), win
+		}
+		if !retry.IsZero() && retry.After(now) && (next.IsZero() || retry.Before(next)) {
+			next = retry
+		}
+
+		var wake <-chan time.Time
+		stop := func() bool { return false }
+		if !next.IsZero() {
+			t := s.cfg.clock.NewTimer(next.Sub(now))
+			wake, stop = t.C(), t.Stop
+		}
+		select {
+		case <-ctx.Done():
+			stop()
+			return ctx.Err()
+		case <-wake:
+		case err := <-p.failedChan():
+			stop()
+			s.report(err)
+			closeStream()
+			retry = s.cfg.clock.Now().Add(retryDelay)
+		}
+	}
+}
+
+// play plays the frames of e on st until stopped.
+func (s *Scheduler) play(st huestream.Streamer, e entry) *player {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &player{cancel: cancel, done: make(chan struct{}), failed: make(chan error, 1)}
+	go func() {
+		defer close(p.done)
+		err := huestream.Play(ctx, st, e.Frames(s.ids), Rate)
+		if err != nil && ctx.Err() == nil {
+			p.failed <- fmt.Errorf("schedule: %s: %w", e.Name, err)
+		}
+	}()
+	return p
+}
+
+func (s *Scheduler) report(err error) {
+	if s.cfg.errorHandler != nil {
+		s.cfg.errorHandler(err)
+	}
+}
@@ -0,0 +1,225 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"iter"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/internal/clock"
+)
+
+// fakeStreams counts the streams started and closed, keeping the last
+// frame sent.
+type fakeStreams struct {
+	mu      sync.Mutex
+	started int
+	closed  int
+	last    huestream.Frame
+	fail    error // Returned by the next start, if set.
+}
+
+func (f *fakeStreams) start(ctx context.Context) (huestream.Streamer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail; err != nil {
+		f.fail = nil
+		return nil, err
+	}
+	f.started++
+	return fakeStream{f}, nil
+}
+
+func (f *fakeStreams) counts() (started, closed int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.started, f.closed
+}
+
+type fakeStream struct{ f *fakeStreams }
+
+func (s fakeStream) Send(frame huestream.Frame) error {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	s.f.last = frame
+	return nil
+}
+
+func (s fakeStream) SendContext(ctx context.Context, frame huestream.Frame) error {
+	return s.Send(frame)
+}
+
+func (s fakeStream) Close() error {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	s.f.closed++
+	return nil
+}
+
+// solid returns the frames of an effect holding c.
+func solid(c color.Color) func(ids []int) iter.Seq[huestream.Frame] {
+	return func(ids []int) iter.Seq[huestream.Frame] {
+		return func(yield func(huestream.Frame) bool) {
+			f := make(huestream.Frame)
+			for _, id := range ids {
+				f[id] = c
+			}
+			yield(f)
+		}
+	}
+}
+
+// runScheduler runs a Scheduler of entries in New York from now, returning
+// its clock and the Scheduler, stopped at the end of the test.
+func runScheduler(t *testing.T, streams *fakeStreams, now time.Time, entries []Entry, opts ...Option) (*clock.Fake, *Scheduler) {
+	t.Helper()
+	clk := clock.NewFake(now)
+	opts = append([]Option{WithLocation(now.Location()), withClock(clk)}, opts...)
+	s, err := New(streams.start, []int{0, 1}, entries, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Run: got %v, want context.Canceled", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("Run not stopped by the context")
+		}
+	})
+	clk.BlockUntil(1)
+	return clk, s
+}
+
+// check checks the entry in effect and the streams started and closed.
+func check(t *testing.T, s *Scheduler, streams *fakeStreams, current string, started, closed int) {
+	t.Helper()
+	if got := s.Current(); got != current {
+		t.Errorf("Current: got %q, want %q", got, current)
+	}
+	if s, c := streams.counts(); s != started || c != closed {
+		t.Errorf("got %d streams started and %d closed, want %d and %d", s, c, started, closed)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	ny := newYork(t)
+	streams := &fakeStreams{}
+	// A Friday.
+	clk, s := runScheduler(t, streams, time.Date(2026, 10, 16, 17, 0, 0, 0, ny), []Entry{
+		{Name: "ambient", Spec: "0 18 * * mon-fri", Duration: 5 * time.Hour, Frames: solid(color.White)},
+		{Name: "party", Spec: "0 23 * * fri", Duration: 2 * time.Hour, Priority: 1, Frames: solid(color.Black)},
+		{Name: "cleaning", Spec: "0 20 * * wed,fri", Duration: 30 * time.Minute, Priority: 2},
+	})
+	check(t, s, streams, "", 0, 0)
+
+	for _, step := range []struct {
+		advance         time.Duration
+		current         string
+		started, closed int
+	}{
+		{time.Hour, "ambient", 1, 0},        // 18:00.
+		{2 * time.Hour, "cleaning", 1, 1},   // 20:00, the lights off.
+		{30 * time.Minute, "ambient", 2, 1}, // 20:30, back on.
+		{150 * time.Minute, "party", 2, 1},  // 23:00, the same stream.
+		{2 * time.Hour, "", 2, 2},           // Saturday 1:00.
+		{65 * time.Hour, "ambient", 3, 2},   // Monday 18:00.
+	} {
+		clk.Advance(step.advance)
+		clk.BlockUntil(1)
+		check(t, s, streams, step.current, step.started, step.closed)
+	}
+}
+
+func TestSchedulerDST(t *testing.T) {
+	ny := newYork(t)
+	streams := &fakeStreams{}
+	// The night the clocks go back, 0:00 to 5:00 lasts 6 hours.
+	clk, s := runScheduler(t, streams, time.Date(2026, 10, 31, 23, 0, 0, 0, ny), []Entry{
+		{Name: "night", Spec: "0 0 * * *", Duration: 5 * time.Hour, Frames: solid(color.White)},
+	})
+	clk.Advance(time.Hour)
+	clk.BlockUntil(1)
+	check(t, s, streams, "night", 1, 0)
+
+	clk.Advance(5*time.Hour + 59*time.Minute) // 4:59 EST.
+	check(t, s, streams, "night", 1, 0)
+	clk.Advance(time.Minute)
+	clk.BlockUntil(1)
+	check(t, s, streams, "", 1, 1)
+	if got := clk.Now().In(ny).Format("15:04 MST"); got != "05:00 EST" {
+		t.Errorf("ended at %s, want 05:00 EST", got)
+	}
+}
+
+func TestSchedulerInProgress(t *testing.T) {
+	ny := newYork(t)
+	streams := &fakeStreams{}
+	// Started in the middle of an entry, it plays at once.
+	_, s := runScheduler(t, streams, time.Date(2026, 10, 16, 19, 0, 0, 0, ny), []Entry{
+		{Name: "ambient", Spec: "0 18 * * *", Duration: 5 * time.Hour, Frames: solid(color.White)},
+	})
+	check(t, s, streams, "ambient", 1, 0)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		streams.mu.Lock()
+		last := streams.last
+		streams.mu.Unlock()
+		if last != nil {
+			if len(last) != 2 || last[0] != color.White {
+				t.Errorf("got frame %v, want white on 0 and 1", last)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no frame sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerRetry(t *testing.T) {
+	ny := newYork(t)
+	var errs []error
+	var mu sync.Mutex
+	streams := &fakeStreams{fail: errors.New("bridge unreachable")}
+	clk, s := runScheduler(t, streams, time.Date(2026, 10, 16, 18, 0, 0, 0, ny), []Entry{
+		{Name: "ambient", Spec: "0 18 * * *", Duration: 5 * time.Hour, Frames: solid(color.White)},
+	}, WithErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}))
+	check(t, s, streams, "ambient", 0, 0)
+	mu.Lock()
+	if len(errs) != 1 || errs[0].Error() != "schedule: ambient: start: bridge unreachable" {
+		t.Errorf("got errors %v", errs)
+	}
+	mu.Unlock()
+
+	clk.Advance(retryDelay)
+	clk.BlockUntil(1)
+	check(t, s, streams, "ambient", 1, 0)
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, e := range []Entry{
+		{Name: "bad", Spec: "0 25 * * *", Duration: time.Hour},
+		{Name: "short", Spec: "@daily"},
+	} {
+		if _, err := New(nil, nil, []Entry{e}); err == nil {
+			t.Errorf("entry %s: no error", e.Name)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec is a parsed cron expression, the times at which an entry starts.
+type Spec struct {
+	minute, hour, dom, month, dow uint64 // Bit sets of the values allowed.
+
+	// Whether the day of the month and the day of the week are *: as for
+	// cron, when both are restricted a day matching either matches.
+	domStar, dowStar bool
+}
+
+// field is a field of a cron expression.
+type field struct {
+	name     string
+	min, max int
+	names    []string // The names of the values from min, if any.
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	dowField    = field{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// shortcuts are the cron expressions known by name.
+var shortcuts = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+// ParseSpec parses a cron expression of five fields, the minute, the hour,
+// the day of the month, the month and the day of the week:
+//
+//	0 18 * * mon-fri    at 18:00 on weekdays
+//	30 23 * * fri       at 23:30 on Fridays
+//	*/15 8-10 * * *     every 15 minutes from 8:00 to 10:45
+//	0 7 1,15 * *        at 7:00 on the 1st and the 15th
+//
+// A field is *, a value, a range as 1-5, or a list of them as 1-5,7, each
+// with an optional step as */15. The months and the days of the week may be
+// named by their first three letters, Sunday is 0 or 7. The shortcuts
+// @hourly, @daily, @weekly, @monthly and @yearly are also known.
+func ParseSpec(s string) (*Spec, error) {
+	expr := strings.TrimSpace(s)
+	if e, ok := shortcuts[strings.ToLower(expr)]; ok {
+		expr = e
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule: spec %q: %d fields, want 5: minute hour day-of-month month day-of-week", s, len(fields))
+	}
+	var spec Spec
+	var err error
+	for i, f := range []struct {
+		field
+		set *uint64
+	}{
+		{minuteField, &spec.minute},
+		{hourField, &spec.hour},
+		{domField, &spec.dom},
+		{monthField, &spec.month},
+		{dowField, &spec.dow},
+	} {
+		if *f.set, err = f.parse(strings.ToLower(fields[i])); err != nil {
+			return nil, fmt.Errorf("schedule: spec %q: %w", s, err)
+		}
+	}
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1 // Sunday is 0 or 7.
+	}
+	spec.domStar = fields[2] == "*"
+	spec.dowStar = fields[4] == "*"
+	return &spec, nil
+}
+
+// parse returns the bit set of the values of s.
+func (f field) parse(s string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%s field: invalid step %q", f.name, stepText)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			loText, hiText, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(loText); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(hiText); err != nil {
+					return 0, err
+				}
+				if hi < lo {
+					return 0, fmt.Errorf("%s field: range %q ends before it starts", f.name, rng)
+				}
+			} else if hasStep {
+				hi = f.max // As 5/15, from 5 to the end.
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// value parses a value of the field, a number or a name.
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if s == name {
+			return f.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s field: invalid value %q, want %d to %d", f.name, s, f.min, f.max)
+	}
+	return n, nil
+}
+
+// matchDay reports whether the spec runs on the day of t.
+func (s *Spec) matchDay(t time.Time) bool {
+	if s.month&(1<<t.Month()) == 0 {
+		return false
+	}
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<t.Weekday()) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// maxDays bounds the search of Next, enough for February 29 to come.
+const maxDays = 366 * 8
+
+// Next returns the first time after after at which the spec runs, in the
+// location of after, or the zero Time if it never runs, as on February 30.
+//
+// The times are wall clock times. On the day the clocks go forward, a time
+// in the hour skipped runs once the clocks have gone forward, as 2:30 at
+// 3:30. On the day they go back, a time in the hour repeated runs once, the
+// first time.
+func (s *Spec) Next(after time.Time) time.Time {
+	loc := after.Location()
+	y, m, d := after.Date()
+	for day := range maxDays {
+		// Noon is clear of the transitions of daylight saving time.
+		date := time.Date(y, m, d+day, 12, 0, 0, 0, loc)
+		if !s.matchDay(date) {
+			continue
+		}
+		var best time.Time
+		bestHour := -1
+		for h := range 24 {
+			if s.hour&(1<<h) == 0 {
+				continue
+			}
+			if bestHour >= 0 && h > bestHour+1 {
+				break // The times of a skipped hour move by an hour at most.
+			}
+			for mi := range 60 {
+				if s.minute&(1<<mi) == 0 {
+					continue
+				}
+				t := wallDate(date.Year(), date.Month(), date.Day(), h, mi, 0, 0, loc)
+				if t.After(after) && (best.IsZero() || t.Before(best)) {
+					best = t
+					if bestHour < 0 {
+						bestHour = h
+					}
+				}
+			}
+		}
+		if !best.IsZero() {
+			return best
+		}
+	}
+	return time.Time{}
+}
+
+// wallDate is time.Date, except for the wall clock times skipped when the
+// clocks go forward: they are moved forward with the clocks, as 2:30 to
+// 3:30, where time.Date moves them back.
+func wallDate(y int, m time.Month, d, h, mi, sec, nsec int, loc *time.Location) time.Time {
+	t := time.Date(y, m, d, h, mi, sec, nsec, loc)
+	want := time.Date(y, m, d, h, mi, sec, nsec, time.UTC)
+	got := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if got.Before(want) {
+		t = t.Add(want.Sub(got))
+	}
+	return t
+}
@@ -0,0 +1,112 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // The tests don't depend on the zoneinfo of the system.
+)
+
+// newYork returns the location of New York, whose clocks go forward on
+// 2026-03-08 at 2:00 and back on 2026-11-01 at 2:00.
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestParseSpecInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		spec, want string
+	}{
+		{"0 18 * *", "4 fields, want 5"},
+		{"60 * * * *", `minute field: invalid value "60", want 0 to 59`},
+		{"* 24 * * *", `hour field: invalid value "24"`},
+		{"* * 0 * *", `day of month field: invalid value "0"`},
+		{"* * * * mon-foo", `day of week field: invalid value "foo"`},
+		{"5-1 * * * *", `range "5-1" ends before it starts`},
+		{"*/0 * * * *", `minute field: invalid step "0"`},
+		{"@often", "1 fields, want 5"},
+	} {
+		_, err := ParseSpec(tc.spec)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ParseSpec(%q): got %v, want an error with %q", tc.spec, err, tc.want)
+		}
+	}
+}
+
+func TestSpecNext(t *testing.T) {
+	ny := newYork(t)
+	at := func(s string) time.Time {
+		t.Helper()
+		tm, err := time.ParseInLocation("2006-01-02 15:04 MST", s, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	for _, tc := range []struct {
+		spec        string
+		after, want string // "" never runs.
+	}{
+		{"0 18 * * mon-fri", "2026-10-16 18:00 EDT", "2026-10-19 18:00 EDT"},
+		{"0 18 * * MON-FRI", "2026-10-16 17:59 EDT", "2026-10-16 18:00 EDT"},
+		{"*/15 8-10 * * *", "2026-10-16 10:45 EDT", "2026-10-17 08:00 EDT"},
+		{"5/20 * * * *", "2026-10-16 10:26 EDT", "2026-10-16 10:45 EDT"},
+		{"0 0 * * 7", "2026-10-16 00:00 EDT", "2026-10-18 00:00 EDT"},
+		{"@daily", "2026-10-16 10:00 EDT", "2026-10-17 00:00 EDT"},
+
+		// Both days restricted: either matches.
+		{"0 0 13 * fri", "2026-10-17 00:00 EDT", "2026-10-23 00:00 EDT"},
+		{"0 0 13 * fri", "2026-11-07 00:00 EST", "2026-11-13 00:00 EST"},
+
+		{"0 0 29 feb *", "2026-01-01 00:00 EST", "2028-02-29 00:00 EST"},
+		{"0 0 30 2 *", "2026-01-01 00:00 EST", ""},
+
+		// The clocks go forward: 2:30 does not exist, it runs at 3:30.
+		{"30 2 * * *", "2026-03-08 00:00 EST", "2026-03-08 03:30 EDT"},
+		{"30 2 * * *", "2026-03-08 03:30 EDT", "2026-03-09 02:30 EDT"},
+		{"0,45 2-3 * * *", "2026-03-08 00:00 EST", "2026-03-08 03:00 EDT"},
+
+		// The clocks go back: 1:30 happens twice, it runs once.
+		{"30 1 * * *", "2026-11-01 00:00 EDT", "2026-11-01 01:30 EDT"},
+		{"30 1 * * *", "2026-11-01 01:30 EDT", "2026-11-02 01:30 EST"},
+		{"*/30 * * * *", "2026-11-01 01:30 EDT", "2026-11-01 02:00 EST"},
+	} {
+		spec, err := ParseSpec(tc.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := spec.Next(at(tc.after))
+		if tc.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%q after %s: got %v, want never", tc.spec, tc.after, got)
+			}
+			continue
+		}
+		if want := at(tc.want); !got.Equal(want) {
+			t.Errorf("%q after %s: got %v, want %v", tc.spec, tc.after, got, want)
+		}
+	}
+}
+
+func TestWallAdd(t *testing.T) {
+	ny := newYork(t)
+	for _, tc := range []struct {
+		start time.Time
+		d     time.Duration
+		want  time.Duration // Of real time.
+	}{
+		{time.Date(2026, 10, 16, 18, 0, 0, 0, ny), 5 * time.Hour, 5 * time.Hour},
+		{time.Date(2026, 3, 8, 1, 0, 0, 0, ny), 2 * time.Hour, time.Hour},      // To 3:00 EDT.
+		{time.Date(2026, 11, 1, 0, 0, 0, 0, ny), 5 * time.Hour, 6 * time.Hour}, // To 5:00 EST.
+		{time.Date(2026, 3, 8, 0, 30, 0, 0, ny), 2 * time.Hour, 2 * time.Hour}, // To 3:30 EDT, 2:30 skipped.
+	} {
+		if got := wallAdd(tc.start, tc.d).Sub(tc.start); got != tc.want {
+			t.Errorf("%v plus %v: got %v later, want %v", tc.start, tc.d, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"io"
+	"iter"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/effects"
+)
+
+// Rate is the rate of the frames of a script, in Hz, the rate of the
+// effects of the huestream command.
+const Rate = 25
+
+// effectList are the effects of the scripts, with the defaults of the
+// effect command of huestream.
+var effectList = map[string]func(ids []int, opts ...effects.Option) iter.Seq[huestream.Frame]{
+	"rainbow": func(ids []int, opts ...effects.Option) iter.Seq[huestream.Frame] {
+		return effects.Rainbow(ids, 10*Rate, 0.1)
+	},
+	"sparkle": func(ids []int, opts ...effects.Option) iter.Seq[huestream.Frame] {
+		return effects.Sparkle(ids, color.Black, 0.05, opts...)
+	},
+	"candle": effects.Candle,
+	"lightning": func(ids []int, opts ...effects.Option) iter.Seq[huestream.Frame] {
+		return effects.Lightning(ids, 0.02, opts...)
+	},
+}
+
+// fade is the color of a channel, fading from a color to another.
+type fade struct {
+	from, to   color.RGBA64
+	start, dur time.Duration
+}
+
+// at returns the color of the fade at t.
+func (f fade) at(t time.Duration) color.Color {
+	if f.dur <= 0 || t >= f.start+f.dur {
+		return f.to
+	}
+	k := float64(t-f.start) / float64(f.dur)
+	mix := func(a, b uint16) uint16 { return uint16(float64(a) + (float64(b)-float64(a))*k + 0.5) }
+	return color.RGBA64{
+		R: mix(f.from.R, f.to.R),
+		G: mix(f.from.G, f.to.G),
+		B: mix(f.from.B, f.to.B),
+		A: 0xffff,
+	}
+}
+
+// rgba64 converts c to an opaque color.RGBA64.
+func rgba64(c color.Color) color.RGBA64 {
+	r, g, b, _ := c.RGBA()
+	return color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: 0xffff}
+}
+
+// Frames returns the frames of the timeline at Rate, for the channels ids
+// of the steps of "all" and of the effects. The sequence ends with the last
+// step, after its fade or effect. The random effects take opts, as
+// effects.WithSeed to play the same show every time.
+func (tl Timeline) Frames(ids []int, opts ...effects.Option) iter.Seq[huestream.Frame] {
+	return func(yield func(huestream.Frame) bool) {
+		const period = time.Second / Rate
+		end := tl.Duration()
+		colors := make(map[int]fade)
+		var (
+			effect    func() (huestream.Frame, bool)
+			stop      = func() {}
+			effectEnd time.Duration
+		)
+		defer func() { stop() }()
+
+		steps := tl
+		for tick := 0; ; tick++ {
+			t := time.Duration(tick) * period
+			if t > end {
+				return
+			}
+			for len(steps) > 0 && steps[0].At <= t {
+				s := steps[0]
+				steps = steps[1:]
+				if s.Effect != "" {
+					stop()
+					effect, stop = iter.Pull(effectList[s.Effect](ids, opts...))
+					effectEnd = s.At + s.Duration
+					continue
+				}
+				set := []int{s.Channel}
+				if s.All {
+					set = ids
+				}
+				for _, id := range set {
+					from := color.RGBA64{A: 0xffff}
+					if f, ok := colors[id]; ok {
+						from = rgba64(f.at(s.At))
+					}
+					colors[id] = fade{from: from, to: rgba64(s.Color), start: s.At, dur: s.Fade}
+				}
+			}
+
+			frame := make(huestream.Frame, len(colors))
+			for id, f := range colors {
+				frame[id] = f.at(t)
+			}
+			if effect != nil && t < effectEnd {
+				if f, ok := effect(); ok {
+					for id, c := range f {
+						frame[id] = c
+					}
+				}
+			}
+			if !yield(frame) {
+				return
+			}
+		}
+	}
+}
+
+// Run parses the script of r and plays it on st, for the channels ids, as
+// the channels of the area of the Stream. It returns a *SyntaxError if the
+// script is invalid, ctx.Err() if ctx is done before its end.
+func Run(ctx context.Context, st huestream.Streamer, ids []int, r io.Reader) error {
+	tl, err := Parse(r)
+	if err != nil {
+		return err
+	}
+	if len(tl) == 0 {
+		return errors.New("script: no steps")
+	}
+	return huestream.Play(ctx, st, tl.Frames(ids), Rate)
+}
@@ -0,0 +1,302 @@
+// Package script parses and runs light scripts, a line-based format for
+// writing light sequences without Go:
+//
+//	# Fade the room in, flash channel 1, then a rainbow.
+//	0:00 all #000000
+//	0:05 ch1 #ff0000 fade 2s; 0:07 ch2 orange
+//	0:10 effect rainbow 30s
+//	0:40 all warm fade 5s
+//
+// A step starts with its time from the start of the script, as 0:05, 1:30
+// or 1:02:03, in hours, minutes and seconds, with optional fractions of a
+// second as 0:05.5. Several steps may share a line, separated by ";". A #
+// followed by a space or ending the line starts a comment, so that the
+// colors as #ff0000 are not comments. The steps are:
+//
+//	<time> all <color> [fade <duration>]     set every channel
+//	<time> ch<id> <color> [fade <duration>]  set the channel of the ID
+//	<time> effect <name> <duration>          play an effect on every channel
+//
+// A color is any form of huestream.ParseColor without spaces, as #ff8800,
+// rgb(255,136,0) or orange. A duration is a Go duration, as 2s or 1m30s. A
+// fade goes from the color before, black for a channel set for the first
+// time. The effects are rainbow, sparkle, candle and lightning, with the
+// defaults of the huestream command; once over, the channels get back their
+// colors. The steps are in time order, the script ends with its last step.
+//
+// The errors of Parse are a *SyntaxError, locating the fault and telling
+// what was expected.
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rschio/huestream"
+)
+
+// Timeline is a parsed script, its steps in time order.
+type Timeline []Step
+
+// Step is a step of a script, setting colors or playing an effect.
+type Step struct {
+	At   time.Duration // From the start of the script.
+	Line int           // Of the script, from 1.
+
+	// A color step sets the channel of ID Channel, or every channel if
+	// All, to Color, fading from its color before for Fade.
+	All     bool
+	Channel int
+	Color   color.Color
+	Fade    time.Duration
+
+	// An effect step plays the effect Effect on every channel for
+	// Duration.
+	Effect   string
+	Duration time.Duration
+}
+
+// end returns the time the step is over.
+func (s Step) end() time.Duration {
+	if s.Effect != "" {
+		return s.At + s.Duration
+	}
+	return s.At + s.Fade
+}
+
+// Duration returns the time the timeline takes to play, up to the end of
+// its last fade or effect.
+func (tl Timeline) Duration() time.Duration {
+	var d time.Duration
+	for _, s := range tl {
+		d = max(d, s.end())
+	}
+	return d
+}
+
+// SyntaxError is an error of a script, at a line and column from 1.
+type SyntaxError struct {
+	Line, Col int
+	Msg       string
+}
+
+func (e *SyntaxError) Error() string { return fmt.Sprintf("line %d:%d: %s", e.Line, e.Col, e.Msg) }
+
+// token is a word of a script, or ";".
+type token struct {
+	text string
+	col  int
+}
+
+// tokenize splits a line in tokens, up to its comment.
+func tokenize(line string) []token {
+	var toks []token
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			toks = append(toks, token{line[start:end], start + 1})
+			start = -1
+		}
+	}
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			flush(i)
+		case c == ';':
+			flush(i)
+			toks = append(toks, token{";", i + 1})
+		case c == '#' && start < 0 && (i+1 == len(line) || line[i+1] == ' ' || line[i+1] == '\t'):
+			return toks // A comment.
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	flush(len(line))
+	return toks
+}
+
+// parser parses the steps of a line.
+type parser struct {
+	line int
+	toks []token
+	end  int // The column after the last token, for the errors at the end.
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &SyntaxError{Line: p.line, Col: t.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// next returns the next token, or a token at the end of the line with an
+// empty text.
+func (p *parser) next() token {
+	if len(p.toks) == 0 {
+		return token{col: p.end}
+	}
+	t := p.toks[0]
+	p.toks = p.toks[1:]
+	return t
+}
+
+// got describes t in an error.
+func got(t token) string {
+	if t.text == "" {
+		return "the end of the line"
+	}
+	return strconv.Quote(t.text)
+}
+
+// Parse reads a script.
+func Parse(r io.Reader) (Timeline, error) {
+	var tl Timeline
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		p := &parser{line: n, toks: tokenize(line), end: len(strings.TrimRight(line, " \t\r")) + 1}
+		for len(p.toks) > 0 {
+			if p.toks[0].text == ";" {
+				p.next() // An empty step.
+				continue
+			}
+			start := p.toks[0]
+			s, err := p.step()
+			if err != nil {
+				return nil, err
+			}
+			if len(tl) > 0 && s.At < tl[len(tl)-1].At {
+				return nil, p.errorf(start, "time %s is before the step of line %d at %s", start.text, tl[len(tl)-1].Line, formatTime(tl[len(tl)-1].At))
+			}
+			tl = append(tl, s)
+			if t := p.next(); t.text != "" && t.text != ";" {
+				return nil, p.errorf(t, `expected ";" or the end of the line, got %s`, got(t))
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return tl, nil
+}
+
+// step parses a step.
+func (p *parser) step() (Step, error) {
+	s := Step{Line: p.line}
+	t := p.next()
+	at, ok := parseTime(t.text)
+	if !ok {
+		return s, p.errorf(t, "expected a time as 0:05 or 1:02:03, got %s", got(t))
+	}
+	s.At = at
+
+	t = p.next()
+	switch {
+	case t.text == "effect":
+		return s, p.effect(&s)
+	case t.text == "all":
+		s.All = true
+	case strings.HasPrefix(t.text, "ch"):
+		id, err := strconv.Atoi(t.text[2:])
+		if err != nil || id < 0 || id > math.MaxUint8 {
+			return s, p.errorf(t, "expected a channel as ch0 to ch255, got %s", got(t))
+		}
+		s.Channel = id
+	default:
+		return s, p.errorf(t, `expected "all", a channel as ch1 or "effect", got %s`, got(t))
+	}
+
+	t = p.next()
+	c, err := huestream.ParseColor(t.text)
+	if err != nil {
+		return s, p.errorf(t, "expected a color as #ff8800 or orange, got %s", got(t))
+	}
+	s.Color = c
+
+	if len(p.toks) > 0 && p.toks[0].text == "fade" {
+		p.next()
+		if s.Fade, _, err = p.duration(); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// effect parses the end of an effect step.
+func (p *parser) effect(s *Step) error {
+	t := p.next()
+	if _, ok := effectList[t.text]; !ok {
+		return p.errorf(t, "expected an effect among %s, got %s", strings.Join(effectNames(), ", "), got(t))
+	}
+	s.Effect = t.text
+	d, t, err := p.duration()
+	if err != nil {
+		return err
+	}
+	if d == 0 {
+		return p.errorf(t, "expected a duration of the effect above 0, got %s", got(t))
+	}
+	s.Duration = d
+	return nil
+}
+
+// duration parses a duration, and returns its token.
+func (p *parser) duration() (time.Duration, token, error) {
+	t := p.next()
+	d, err := time.ParseDuration(t.text)
+	if err != nil || d < 0 {
+		return 0, t, p.errorf(t, "expected a duration as 2s or 1m30s, got %s", got(t))
+	}
+	return d, t, nil
+}
+
+// parseTime parses a time as 0:05, 1:02:03 or 0:05.5.
+func parseTime(s string) (time.Duration, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	secs, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || secs < 0 || secs >= 60 || len(parts[len(parts)-1]) < 2 || strings.ContainsAny(parts[len(parts)-1], "eE+-") {
+		return 0, false
+	}
+	d := time.Duration(secs * float64(time.Second))
+	for i, unit := range []time.Duration{time.Minute, time.Hour} {
+		j := len(parts) - 2 - i
+		if j < 0 {
+			break
+		}
+		n, err := strconv.Atoi(parts[j])
+		if err != nil || n < 0 || j > 0 && n >= 60 || parts[j] == "" || parts[j][0] == '+' {
+			return 0, false
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, true
+}
+
+// formatTime formats d as a time of a script.
+func formatTime(d time.Duration) string {
+	secs := d - d.Truncate(time.Minute)
+	s := fmt.Sprintf("%d:%02d", int(d/time.Minute), int(secs/time.Second))
+	if frac := secs % time.Second; frac != 0 {
+		s += strings.TrimRight(fmt.Sprintf("%.3f", frac.Seconds())[1:], "0")
+	}
+	return s
+}
+
+// effectNames returns the names of the effects, sorted.
+func effectNames() []string {
+	names := make([]string, 0, len(effectList))
+	for name := range effectList {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,214 @@
+package script
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"image/color"
+	"iter"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/effects"
+)
+
+// header returns the value of the first line of the script at path, as
+// "# key: value".
+func header(t *testing.T, path, key string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Scan()
+	v, ok := strings.CutPrefix(sc.Text(), "# "+key+": ")
+	if !ok {
+		t.Fatalf("%s: first line %q, want # %s: ...", path, sc.Text(), key)
+	}
+	return v
+}
+
+func TestParseValid(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "valid", "*.script"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no valid scripts: %v", err)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			want, err := strconv.Atoi(header(t, path, "steps"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			tl, err := Parse(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tl) != want {
+				t.Errorf("got %d steps, want %d", len(tl), want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "invalid", "*.script"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no invalid scripts: %v", err)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			want := header(t, path, "want")
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			_, err = Parse(f)
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("got %v, want a *SyntaxError", err)
+			}
+			if err.Error() != want {
+				t.Errorf("got  %s\nwant %s", err, want)
+			}
+		})
+	}
+}
+
+func TestParseSteps(t *testing.T) {
+	tl, err := Parse(strings.NewReader("0:00.5 ch3 #ff0000 fade 250ms; 1:02:03 effect candle 1m\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Timeline{
+		{At: 500 * time.Millisecond, Line: 1, Channel: 3, Color: color.RGBA{255, 0, 0, 255}, Fade: 250 * time.Millisecond},
+		{At: time.Hour + 2*time.Minute + 3*time.Second, Line: 1, Effect: "candle", Duration: time.Minute},
+	}
+	if len(tl) != len(want) {
+		t.Fatalf("got %d steps, want %d", len(tl), len(want))
+	}
+	for i := range want {
+		if tl[i] != want[i] {
+			t.Errorf("step %d: got %+v, want %+v", i, tl[i], want[i])
+		}
+	}
+	if d := tl.Duration(); d != want[1].At+time.Minute {
+		t.Errorf("Duration: got %v", d)
+	}
+}
+
+// frames returns the frames of the script s for the channels ids.
+func frames(t *testing.T, s string, ids ...int) []huestream.Frame {
+	t.Helper()
+	tl, err := Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fs []huestream.Frame
+	for f := range tl.Frames(ids) {
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// red returns the red of c, from 0 to 0xffff.
+func red(c color.Color) uint32 {
+	r, _, _, _ := c.RGBA()
+	return r
+}
+
+func TestFramesFade(t *testing.T) {
+	fs := frames(t, "0:00 all red\n0:01 all black fade 2s\n", 1, 2)
+
+	// The last frame is at the end of the fade, 3s at Rate.
+	if len(fs) != 3*Rate+1 {
+		t.Fatalf("got %d frames, want %d", len(fs), 3*Rate+1)
+	}
+	for _, tick := range []int{0, Rate - 1, Rate, Rate + 5, 2 * Rate, 3*Rate - 1, 3 * Rate} {
+		// The fade runs from tick Rate to 3*Rate.
+		k := min(max(float64(tick-Rate)/(2*Rate), 0), 1)
+		want := uint32(math.Round(0xffff * (1 - k)))
+		for _, id := range []int{1, 2} {
+			if got := red(fs[tick][id]); got != want {
+				t.Errorf("tick %d, channel %d: red %#x, want %#x", tick, id, got, want)
+			}
+		}
+	}
+}
+
+func TestFramesFadeFromBlack(t *testing.T) {
+	fs := frames(t, "0:00 ch7 red fade 2s\n", 1)
+	if _, ok := fs[0][1]; ok {
+		t.Error("channel 1 set without a step")
+	}
+	if got := red(fs[Rate][7]); got != 0x8000 {
+		t.Errorf("halfway: red %#x, want %#x", got, 0x8000)
+	}
+}
+
+func TestFramesEffect(t *testing.T) {
+	fs := frames(t, "0:00 all red\n0:01 effect rainbow 1s\n0:03 all red\n", 1, 2)
+	next, stop := iter.Pull(effects.Rainbow([]int{1, 2}, 10*Rate, 0.1))
+	defer stop()
+	for tick, f := range fs {
+		want := huestream.Frame{1: color.RGBA{255, 0, 0, 255}, 2: color.RGBA{255, 0, 0, 255}}
+		if tick >= Rate && tick < 2*Rate {
+			want, _ = next()
+		}
+		for id, c := range want {
+			if !sameColor(f[id], c) {
+				t.Fatalf("tick %d, channel %d: got %v, want %v", tick, id, f[id], c)
+			}
+		}
+	}
+}
+
+// sameColor reports whether a and b are the same color.
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, _ := a.RGBA()
+	r2, g2, b2, _ := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2
+}
+
+// recorder is a Streamer keeping the frames sent.
+type recorder struct {
+	huestream.Streamer
+	frames []huestream.Frame
+}
+
+func (r *recorder) Send(f huestream.Frame) error {
+	r.frames = append(r.frames, f)
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	rec := &recorder{}
+	if err := Run(context.Background(), rec, []int{1}, strings.NewReader("0:00 all red\n0:00.2 all blue\n")); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.frames) != Rate/5+1 {
+		t.Fatalf("got %d frames, want %d", len(rec.frames), Rate/5+1)
+	}
+	if _, _, b, _ := rec.frames[len(rec.frames)-1][1].RGBA(); b != 0xffff {
+		t.Errorf("last frame %v, want blue", rec.frames[len(rec.frames)-1])
+	}
+
+	err := Run(context.Background(), rec, []int{1}, strings.NewReader("0:00 all red\nnope\n"))
+	var se *SyntaxError
+	if !errors.As(err, &se) || se.Line != 2 || se.Col != 1 {
+		t.Errorf("got %v, want a *SyntaxError at 2:1", err)
+	}
+}
@@ -0,0 +1,126 @@
+package huestream
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// DropPolicy is what Stream.Enqueue does with a frame when the send buffer
+// of WithSendBuffer is full, the producer outrunning the network.
+type DropPolicy int
+
+// The policies of a full send buffer.
+const (
+	// BlockWhenFull makes Enqueue wait for room in the buffer: no frame
+	// is lost, but the producer is slowed down to the pace of the network
+	// and a frame waits for up to the size of the buffer before being
+	// sent.
+	BlockWhenFull DropPolicy = iota
+
+	// DropOldest drops the oldest frame of the buffer for the new one: the
+	// lights show the freshest frames with the least latency, the frames
+	// in between are lost.
+	DropOldest
+
+	// DropNewest drops the new frame: the frames queued are sent in order,
+	// the latency bounded by the size of the buffer, but the latest state
+	// is lost until the next frame makes it into the buffer.
+	DropNewest
+)
+
+func (p DropPolicy) String() string {
+	switch p {
+	case BlockWhenFull:
+		return "block"
+	case DropOldest:
+		return "drop oldest"
+	case DropNewest:
+		return "drop newest"
+	}
+	return fmt.Sprintf("DropPolicy(%d)", int(p))
+}
+
+// sendQueue is the send buffer of WithSendBuffer, drained by the writer
+// goroutine of the Stream.
+type sendQueue struct {
+	policy DropPolicy
+	frames chan Frame
+	mu     sync.Mutex // Serializes the drops of DropOldest.
+
+	droppedOldest atomic.Uint64
+	droppedNewest atomic.Uint64
+}
+
+func newSendQueue(n int, p DropPolicy) *sendQueue {
+	return &sendQueue{policy: p, frames: make(chan Frame, n)}
+}
+
+// push adds f to the queue following its policy, or returns ErrClosed if
+// quit is closed while waiting for room.
+func (q *sendQueue) push(f Frame, quit <-chan struct{}) error {
+	switch q.policy {
+	case DropNewest:
+		select {
+		case q.frames <- f:
+		default:
+			q.droppedNewest.Add(1)
+		}
+		return nil
+	case DropOldest:
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		for {
+			select {
+			case q.frames <- f:
+				return nil
+			default:
+			}
+			select {
+			case <-q.frames:
+				q.droppedOldest.Add(1)
+			default: // Drained by the writer meanwhile.
+			}
+		}
+	}
+	select {
+	case q.frames <- f:
+		return nil
+	case <-quit:
+		return ErrClosed
+	}
+}
+
+// writeQueued sends the frames of q until the Stream is closed. The
+// failures are reported to the error handler.
+func (s *Stream) writeQueued(q *sendQueue) {
+	for {
+		select {
+		case f := <-q.frames:
+			if err := s.Send(f); err != nil && !errors.Is(err, ErrPaused) {
+				s.errs.report(fmt.Errorf("send buffer: %w", err))
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// Enqueue adds frame to the send buffer of WithSendBuffer, from which a
+// goroutine of the Stream sends the frames in order. It returns at once
+// unless the buffer is full with the BlockWhenFull policy. The failures of
+// the sends are reported to the error handler, the frames passed while
+// paused are dropped.
+//
+// Without WithSendBuffer, Enqueue is Send. It returns ErrClosed after
+// Close.
+func (s *Stream) Enqueue(frame Frame) error {
+	if s.queue == nil {
+		return s.Send(frame)
+	}
+	if s.isClosed() {
+		return ErrClosed
+	}
+	return s.queue.push(frame, s.quit)
+}
@@ -0,0 +1,141 @@
+package huestream
+
+import (
+	"errors"
+	"image/color"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/wire"
+)
+
+// slowStream returns a Stream with a send buffer of 2 frames whose writes
+// wait for release, and a channel receiving the red of every frame written
+// once its write starts.
+func slowStream(t *testing.T, p DropPolicy) (s *Stream, reds <-chan uint16, release chan<- struct{}) {
+	t.Helper()
+
+	written := make(chan uint16, 16)
+	rel := make(chan struct{})
+	conn := &funcConn{write: func(b []byte) (int, error) {
+		f, err := wire.Decode(b)
+		if err != nil {
+			return 0, err
+		}
+		written <- f.Channels[0].Values[0]
+		<-rel
+		return len(b), nil
+	}}
+	s = newStream(conn, nil, testAreaID, newConfig([]Option{WithSendBuffer(2, p)}))
+	t.Cleanup(func() {
+		close(rel)
+		s.Close()
+	})
+	return s, written, rel
+}
+
+// redFrame returns a frame of the red r.
+func redFrame(r uint16) Frame { return Frame{0: color.RGBA64{R: r, A: 0xffff}} }
+
+func nextWritten(t *testing.T, reds <-chan uint16) uint16 {
+	t.Helper()
+	select {
+	case r := <-reds:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame written")
+		return 0
+	}
+}
+
+func TestSendBufferDropPolicies(t *testing.T) {
+	tests := []struct {
+		policy DropPolicy
+		want   []uint16 // The frames written after the first one.
+		stats  func(Stats) uint64
+	}{
+		{DropOldest, []uint16{4, 5}, func(st Stats) uint64 { return st.FramesDroppedOldest }},
+		{DropNewest, []uint16{2, 3}, func(st Stats) uint64 { return st.FramesDroppedNewest }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			s, reds, release := slowStream(t, tt.policy)
+
+			// The first frame is taken by the writer, stuck in its write.
+			if err := s.Enqueue(redFrame(1)); err != nil {
+				t.Fatal(err)
+			}
+			if r := nextWritten(t, reds); r != 1 {
+				t.Fatalf("wrote frame %d first, want 1", r)
+			}
+			for r := range uint16(4) {
+				if err := s.Enqueue(redFrame(r + 2)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var got []uint16
+			for range tt.want {
+				release <- struct{}{}
+				got = append(got, nextWritten(t, reds))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("wrote %v after the first frame, want %v", got, tt.want)
+			}
+			if n := tt.stats(s.Stats()); n != 2 {
+				t.Errorf("%d frames dropped, want 2", n)
+			}
+		})
+	}
+}
+
+func TestSendBufferBlock(t *testing.T) {
+	s, reds, release := slowStream(t, BlockWhenFull)
+
+	if err := s.Enqueue(redFrame(1)); err != nil {
+		t.Fatal(err)
+	}
+	nextWritten(t, reds)
+	for r := range uint16(2) {
+		if err := s.Enqueue(redFrame(r + 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	enqueued := make(chan error, 1)
+	go func() { enqueued <- s.Enqueue(redFrame(4)) }()
+	select {
+	case err := <-enqueued:
+		t.Fatalf("Enqueue on a full buffer returned %v, want it blocked", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var got []uint16
+	for range 3 {
+		release <- struct{}{}
+		got = append(got, nextWritten(t, reds))
+	}
+	if want := []uint16{2, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("wrote %v after the first frame, want %v", got, want)
+	}
+	select {
+	case err := <-enqueued:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Enqueue still blocked")
+	}
+	if st := s.Stats(); st.FramesDroppedOldest+st.FramesDroppedNewest != 0 {
+		t.Errorf("got %+v, want no frame dropped", st)
+	}
+}
+
+func TestEnqueueClosed(t *testing.T) {
+	s, _, _ := slowStream(t, BlockWhenFull)
+	s.Close()
+	if err := s.Enqueue(redFrame(1)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Enqueue after Close returned %v, want ErrClosed", err)
+	}
+}
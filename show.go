@@ -0,0 +1,196 @@
+package huestream
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+// Cue is a frame of a recorded show, sent At the time from the start of
+// the show.
+type Cue struct {
+	At    time.Duration
+	Frame Frame
+}
+
+// The show format is JSON lines: a header, then a cue per line with the
+// time in seconds and the 16-bit RGB colors by channel ID.
+//
+//	{"format":"huestream-show","version":1}
+//	{"at":0.04,"frame":{"0":[65535,0,0],"1":[0,0,65535]}}
+const (
+	showFormat  = "huestream-show"
+	showVersion = 1
+)
+
+type showHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+type showCue struct {
+	At    float64              `json:"at"`
+	Frame map[string][3]uint16 `json:"frame"`
+}
+
+// ShowRecorder is a Streamer sending the frames to another Streamer while
+// recording them, with their time, in the show format. The show is played
+// back by ReadShow and PlayShow.
+type ShowRecorder struct {
+	st  Streamer
+	clk clock.Clock
+
+	mu    sync.Mutex // Guards the fields below.
+	w     *bufio.Writer
+	start time.Time // Of the first frame, the start of the show.
+	err   error     // The first error of the writing.
+}
+
+var _ Streamer = (*ShowRecorder)(nil)
+
+// NewShowRecorder returns a ShowRecorder sending to st and recording to w.
+// The time of the frames is read from the clock of st if it is a *Stream.
+func NewShowRecorder(st Streamer, w io.Writer) *ShowRecorder {
+	r := &ShowRecorder{st: st, clk: clock.Real, w: bufio.NewWriter(w)}
+	if s, ok := st.(*Stream); ok && s.clk != nil {
+		r.clk = s.clk
+	}
+	r.encode(showHeader{Format: showFormat, Version: showVersion})
+	return r
+}
+
+// Send records and sends idColors.
+func (r *ShowRecorder) Send(idColors Frame) error {
+	return r.SendContext(context.Background(), idColors)
+}
+
+// SendContext records and sends idColors. The frames are recorded even if
+// the send fails, a failure of the recording is returned by Close.
+func (r *ShowRecorder) SendContext(ctx context.Context, idColors Frame) error {
+	r.record(idColors)
+	return r.st.SendContext(ctx, idColors)
+}
+
+// Close closes the Streamer and flushes the recording, it returns the
+// first error of the recording joined with the one of the Streamer.
+func (r *ShowRecorder) Close() error {
+	r.mu.Lock()
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	err := r.err
+	r.mu.Unlock()
+
+	return errors.Join(r.st.Close(), err)
+}
+
+func (r *ShowRecorder) record(f Frame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clk.Now()
+	if r.start.IsZero() {
+		r.start = now
+	}
+	cue := showCue{At: now.Sub(r.start).Seconds(), Frame: make(map[string][3]uint16, len(f))}
+	for id, c := range f {
+		if c == nil {
+			continue
+		}
+		cr, cg, cb, _ := c.RGBA()
+		cue.Frame[strconv.Itoa(id)] = [3]uint16{uint16(cr), uint16(cg), uint16(cb)}
+	}
+	r.encode(cue)
+}
+
+// encode writes a line of v, it must be called with mu held.
+func (r *ShowRecorder) encode(v any) {
+	if r.err != nil {
+		return
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		r.err = err
+		return
+	}
+	if _, err := r.w.Write(append(b, '\n')); err != nil {
+		r.err = err
+	}
+}
+
+// ReadShow reads a show recorded by a ShowRecorder.
+func ReadShow(r io.Reader) ([]Cue, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+
+	var h showHeader
+	if !sc.Scan() {
+		return nil, fmt.Errorf("read show: %w", cmp.Or(sc.Err(), io.ErrUnexpectedEOF))
+	}
+	if err := json.Unmarshal(sc.Bytes(), &h); err != nil || h.Format != showFormat {
+		return nil, errors.New("read show: not a show")
+	}
+	if h.Version != showVersion {
+		return nil, fmt.Errorf("read show: unsupported version %d", h.Version)
+	}
+
+	var cues []Cue
+	for n := 2; sc.Scan(); n++ {
+		var c showCue
+		if err := json.Unmarshal(sc.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("read show: line %d: %w", n, err)
+		}
+		cue := Cue{At: time.Duration(c.At * float64(time.Second)), Frame: make(Frame, len(c.Frame))}
+		for key, v := range c.Frame {
+			id, err := strconv.Atoi(key)
+			if err != nil {
+				return nil, fmt.Errorf("read show: line %d: invalid channel %q", n, key)
+			}
+			cue.Frame[id] = color.RGBA64{R: v[0], G: v[1], B: v[2], A: 0xffff}
+		}
+		cues = append(cues, cue)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read show: %w", err)
+	}
+	return cues, nil
+}
+
+// PlayShow sends the frames of show to st with their original timing,
+// from the call. The clock of st is followed if it is a *Stream.
+//
+// PlayShow returns nil at the end of the show, ctx.Err() when ctx is done,
+// or the first error returned by SendContext.
+func PlayShow(ctx context.Context, st Streamer, show []Cue) error {
+	clk := clock.Real
+	if s, ok := st.(*Stream); ok && s.clk != nil {
+		clk = s.clk
+	}
+
+	start := clk.Now()
+	for _, cue := range show {
+		if d := cue.At - clk.Now().Sub(start); d > 0 {
+			t := clk.NewTimer(d)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			case <-t.C():
+			}
+		}
+		if err := st.SendContext(ctx, cue.Frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package huestream
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+func TestShowRecordAndRead(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, _ := pipeStream(t, withClock(clk))
+
+	var buf bytes.Buffer
+	r := NewShowRecorder(s, &buf)
+	red := Frame{0: color.RGBA{R: 255, A: 255}, 1: color.Black}
+	blue := Frame{1: color.RGBA64{B: 0x1234, A: 0xffff}}
+	if err := r.Send(red); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(1500 * time.Millisecond)
+	if err := r.Send(blue); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	show, err := ReadShow(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(show) != 2 || show[0].At != 0 || show[1].At != 1500*time.Millisecond {
+		t.Fatalf("got %+v, want cues at 0 and 1.5s", show)
+	}
+	for i, want := range []Frame{red, blue} {
+		got := show[i].Frame
+		if len(got) != len(want) {
+			t.Errorf("cue %d: got %v, want %v", i, got, want)
+		}
+		for id, c := range want {
+			if !sameColor(got[id], c) {
+				t.Errorf("cue %d, channel %d: got %v, want %v", i, id, got[id], c)
+			}
+		}
+	}
+}
+
+func TestReadShowInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		`{"format":"pcapng"}`,
+		`{"format":"huestream-show","version":2}`,
+		`{"format":"huestream-show","version":1}` + "\n" + `{"at":0,"frame":{"x":[0,0,0]}}`,
+	} {
+		if _, err := ReadShow(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadShow(%q) succeeded", in)
+		}
+	}
+}
+
+func TestPlayShow(t *testing.T) {
+	show := []Cue{
+		{At: 0, Frame: Frame{0: color.White}},
+		{At: 20 * time.Millisecond, Frame: Frame{0: color.Black}},
+	}
+
+	var r recordingStreamer
+	start := time.Now()
+	if err := PlayShow(context.Background(), &r, show); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("played in %v, want at least 20ms", d)
+	}
+	if len(r.frames) != 2 {
+		t.Errorf("sent %d frames, want 2", len(r.frames))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	show[0].At = time.Hour
+	if err := PlayShow(ctx, &r, show); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func sameColor(a, b color.Color) bool {
+	ar, ag, ab, _ := a.RGBA()
+	br, bg, bb, _ := b.RGBA()
+	return ar == br && ag == bg && ab == bb
+}
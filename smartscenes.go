@@ -0,0 +1,99 @@
+package huestream
+
+import (
+	"context"
+	"fmt"
+)
+
+// SmartScene is a smart scene of the bridge, as the natural light scene
+// following the time of the day, set up by the user in the Hue app.
+type SmartScene struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Group  string `json:"group"` // ID of the room or zone of the scene.
+	Active bool   `json:"active"`
+}
+
+// SmartScenes lists the smart scenes of the bridge at host, using the CLIP
+// v2 API.
+func SmartScenes(ctx context.Context, host, username string, opts ...Option) ([]SmartScene, error) {
+	c := newClient(host, username, "")
+	c.cfg = newConfig(opts)
+
+	var scenes []SmartScene
+	err := c.traced(ctx, "smart scenes", "", func(ctx context.Context) (err error) {
+		scenes, err = c.smartScenes(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("smart scenes: %w", err)
+	}
+	return scenes, nil
+}
+
+// SetSmartScene activates the smart scene sceneID, handing its lights back
+// to the schedule of the user, or deactivates it.
+//
+// See WithSmartSceneRestore to activate again the smart scenes stopped by
+// a stream when it closes.
+func SetSmartScene(ctx context.Context, host, username, sceneID string, active bool, opts ...Option) error {
+	c := newClient(host, username, "")
+	c.cfg = newConfig(opts)
+
+	err := c.traced(ctx, "smart scene", "", func(ctx context.Context) error {
+		return c.recallSmartScene(ctx, sceneID, active)
+	})
+	if err != nil {
+		return fmt.Errorf("smart scene %s: %w", sceneID, err)
+	}
+	return nil
+}
+
+func (c *client) smartScenes(ctx context.Context) ([]SmartScene, error) {
+	var scenes []struct {
+		ID       string `json:"id"`
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Group struct {
+			RID string `json:"rid"`
+		} `json:"group"`
+		State string `json:"state"`
+	}
+	if err := c.getResource(ctx, "smart_scene", &scenes); err != nil {
+		return nil, err
+	}
+	list := make([]SmartScene, len(scenes))
+	for i, s := range scenes {
+		list[i] = SmartScene{ID: s.ID, Name: s.Metadata.Name, Group: s.Group.RID, Active: s.State == "active"}
+	}
+	return list, nil
+}
+
+func (c *client) recallSmartScene(ctx context.Context, sceneID string, active bool) error {
+	var body struct {
+		Recall struct {
+			Action string `json:"action"`
+		} `json:"recall"`
+	}
+	body.Recall.Action = "deactivate"
+	if active {
+		body.Recall.Action = "activate"
+	}
+	return c.putResource(ctx, "smart_scene", sceneID, body)
+}
+
+// activeSmartScenes returns the IDs of the smart scenes active.
+func (c *client) activeSmartScenes(ctx context.Context) ([]string, error) {
+	scenes, err := c.smartScenes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, s := range scenes {
+		if s.Active {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids, nil
+}
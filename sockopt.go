@@ -0,0 +1,55 @@
+package huestream
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// DSCP values for WithDSCP.
+const (
+	DSCPExpedited = 46 // EF, the class of voice and real-time traffic.
+	DSCPAF41      = 34 // AF41, the class of interactive video.
+)
+
+// tuneSocket applies the socket options of the config to conn, the UDP
+// connection carrying the stream, and notes its effective send buffer.
+func (c *client) tuneSocket(conn net.Conn) error {
+	if c.cfg.udpSendBuffer > 0 {
+		wb, ok := conn.(interface{ SetWriteBuffer(int) error })
+		if !ok {
+			return fmt.Errorf("set UDP send buffer: the connection %T is not a socket", conn)
+		}
+		if err := wb.SetWriteBuffer(c.cfg.udpSendBuffer); err != nil {
+			return fmt.Errorf("set UDP send buffer to %d bytes: %w", c.cfg.udpSendBuffer, err)
+		}
+	}
+
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		if c.cfg.dscp != 0 {
+			return fmt.Errorf("set DSCP %d: the connection %T is not a socket", c.cfg.dscp, conn)
+		}
+		return nil
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		if c.cfg.dscp != 0 {
+			return fmt.Errorf("set DSCP %d: %w", c.cfg.dscp, err)
+		}
+		return nil // The send buffer of Stats is only informative.
+	}
+	if c.cfg.dscp != 0 {
+		ipv6 := false
+		if addr, ok := conn.RemoteAddr().(*net.UDPAddr); ok {
+			ipv6 = addr.IP.To4() == nil
+		}
+		if err := setTOS(raw, ipv6, c.cfg.dscp<<2); err != nil {
+			return fmt.Errorf("set DSCP %d: %w", c.cfg.dscp, err)
+		}
+	}
+	if n, err := sendBufferSize(raw); err == nil {
+		c.sendBuffer.Store(int64(n))
+	}
+	return nil
+}
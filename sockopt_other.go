@@ -0,0 +1,20 @@
+//go:build !(linux || darwin || freebsd || netbsd)
+
+package huestream
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// setTOS is not supported on this platform.
+func setTOS(raw syscall.RawConn, ipv6 bool, tos int) error {
+	return fmt.Errorf("%w on %s", errors.ErrUnsupported, runtime.GOOS)
+}
+
+// sendBufferSize is not supported on this platform.
+func sendBufferSize(raw syscall.RawConn) (int, error) {
+	return 0, fmt.Errorf("%w on %s", errors.ErrUnsupported, runtime.GOOS)
+}
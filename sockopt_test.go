@@ -0,0 +1,120 @@
+//go:build linux || darwin || freebsd || netbsd
+
+package huestream_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream"
+	"github.com/rschio/huestream/huetest"
+)
+
+func TestDSCP(t *testing.T) {
+	b := huetest.NewBridge(t)
+
+	var conn net.Conn
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var d net.Dialer
+		c, err := d.DialContext(ctx, network, addr)
+		conn = c
+		return c, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	opts := append(b.Options(), huestream.WithDialer(dial), huestream.WithDSCP(huestream.DSCPExpedited))
+	stream, err := huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	raw, err := conn.(syscall.Conn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tos int
+	raw.Control(func(fd uintptr) {
+		tos, err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := huestream.DSCPExpedited << 2; tos != want {
+		t.Errorf("TOS of the socket is %#x, want %#x", tos, want)
+	}
+}
+
+func TestDSCPNotASocket(t *testing.T) {
+	b := huetest.NewBridge(t)
+	dial := func(context.Context, string, string) (net.Conn, error) {
+		local, _ := net.Pipe()
+		return local, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	opts := append(b.Options(), huestream.WithDialer(dial), huestream.WithDSCP(huestream.DSCPAF41))
+	_, err := huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, opts...)
+	if err == nil || !strings.Contains(err.Error(), "set DSCP 34") {
+		t.Errorf("Start returned %v, want a DSCP failure", err)
+	}
+}
+
+// noRawConn is a socket whose raw connection can't be had.
+type noRawConn struct{ net.Conn }
+
+func (noRawConn) SyscallConn() (syscall.RawConn, error) {
+	return nil, errors.New("raw connection unavailable")
+}
+
+func TestSyscallConnError(t *testing.T) {
+	b := huetest.NewBridge(t)
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var d net.Dialer
+		c, err := d.DialContext(ctx, network, addr)
+		return noRawConn{c}, err
+	}
+	start := func(opts ...huestream.Option) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		opts = append(append(b.Options(), huestream.WithDialer(dial)), opts...)
+		stream, err := huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, opts...)
+		if err == nil {
+			stream.Close()
+		}
+		return err
+	}
+
+	if err := start(); err != nil {
+		t.Errorf("Start without socket options: %v", err)
+	}
+	if err := start(huestream.WithDSCP(huestream.DSCPAF41)); err == nil || !strings.Contains(err.Error(), "set DSCP 34") {
+		t.Errorf("Start with WithDSCP returned %v, want a DSCP failure", err)
+	}
+}
+
+func TestUDPSendBuffer(t *testing.T) {
+	b := huetest.NewBridge(t)
+	effective := func(size int) int {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		opts := append(b.Options(), huestream.WithUDPSendBuffer(size))
+		stream, err := huestream.Start(ctx, b.Host, b.Username, b.ClientKey, b.AreaID, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stream.Close()
+		return stream.Stats().UDPSendBuffer
+	}
+
+	small, large := effective(16<<10), effective(128<<10)
+	if small < 16<<10 || large <= small {
+		t.Errorf("effective sizes %d and %d for 16KiB and 128KiB, want growing from 16KiB", small, large)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build linux || darwin || freebsd || netbsd
+
+package huestream
+
+import "syscall"
+
+// setTOS sets the traffic class of the IPv4 or IPv6 socket of raw.
+func setTOS(raw syscall.RawConn, ipv6 bool, tos int) error {
+	var err error
+	ctrl := raw.Control(func(fd uintptr) {
+		if ipv6 {
+			err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+		} else {
+			err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+		}
+	})
+	if ctrl != nil {
+		return ctrl
+	}
+	return err
+}
+
+// sendBufferSize returns the size of the send buffer of the socket of raw,
+// as the kernel reports it.
+func sendBufferSize(raw syscall.RawConn) (int, error) {
+	var n int
+	var err error
+	ctrl := raw.Control(func(fd uintptr) {
+		n, err = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+	})
+	if ctrl != nil {
+		return 0, ctrl
+	}
+	return n, err
+}
@@ -0,0 +1,102 @@
+package huestream
+
+import "time"
+
+// Stats holds counters about a Stream since it started.
+type Stats struct {
+	FramesSent uint64 // Messages written to the bridge, keepalives included.
+	BytesSent  uint64 // Bytes of the messages written to the bridge.
+	SendErrors uint64 // Failed writes to the bridge.
+
+	// TransientErrors counts the transient write errors (see IsTransient)
+	// retried by the background send paths.
+	TransientErrors uint64
+
+	// SkippedSlots counts the send slots of PlaySeq and keepalive that were
+	// skipped because the loop woke up too late to honor them.
+	SkippedSlots uint64
+
+	// MeanJitter and MaxJitter measure how late the send loops woke up
+	// relative to their schedule.
+	MeanJitter time.Duration
+	MaxJitter  time.Duration
+
+	// AlignmentLosses counts the times a send loop fell too far behind the
+	// epoch set by WithEpoch and reverted to a free-running schedule.
+	AlignmentLosses uint64
+
+	// SendLatencyP50 and SendLatencyP99 are upper bounds of the median and
+	// 99th percentile of the time spent writing a message to the connection.
+	// They come from a histogram with power of two buckets, so they are only
+	// accurate to a factor of two.
+	SendLatencyP50 time.Duration
+	SendLatencyP99 time.Duration
+
+	// Reconnects counts the sessions recovered after a failure, see
+	// WithRecovery.
+	Reconnects uint64
+
+	// FramesSuppressed counts the frames not written because they repeated
+	// the last message, see WithDedup.
+	FramesSuppressed uint64
+
+	// FramesDroppedOldest and FramesDroppedNewest count the frames dropped
+	// from the send buffer of WithSendBuffer, with the DropOldest and
+	// DropNewest policies.
+	FramesDroppedOldest uint64
+	FramesDroppedNewest uint64
+
+	// ErrorsDropped counts the errors of the background goroutines not
+	// delivered because the error handler or channel fell behind, see
+	// WithErrorHandler.
+	ErrorsDropped uint64
+
+	// MirrorErrors counts the copies of WithMirror that failed.
+	MirrorErrors uint64
+
+	// UDPSendBuffer is the effective size of the send buffer of the UDP
+	// socket, in bytes, see WithUDPSendBuffer. It is zero if unknown, as
+	// on Windows or when the dialer of WithDialer does not return a socket.
+	UDPSendBuffer int
+
+	// Sequence is the sequence number of the next message, see
+	// Stream.SetSequence.
+	Sequence uint8
+}
+
+// Stats returns a snapshot of the Stream counters.
+func (s *Stream) Stats() Stats {
+	st := Stats{
+		FramesSent: s.framesSent.Load(),
+		BytesSent:  s.bytesSent.Load(),
+		SendErrors: s.sendErrors.Load(),
+
+		TransientErrors: s.transientErrors.Load(),
+		SkippedSlots:    s.timing.skipped.Load(),
+		MaxJitter:       time.Duration(s.timing.jitterMax.Load()),
+
+		AlignmentLosses: s.timing.unaligned.Load(),
+
+		SendLatencyP50: s.latency.quantile(0.50),
+		SendLatencyP99: s.latency.quantile(0.99),
+
+		Reconnects: s.reconnects.Load(),
+
+		FramesSuppressed: s.framesSuppressed.Load(),
+		ErrorsDropped:    s.errs.droppedErrors(),
+		MirrorErrors:     s.mirrorErrors.Load(),
+
+		Sequence: uint8(s.sequence.Load()),
+	}
+	if s.client != nil {
+		st.UDPSendBuffer = int(s.client.sendBuffer.Load())
+	}
+	if q := s.queue; q != nil {
+		st.FramesDroppedOldest = q.droppedOldest.Load()
+		st.FramesDroppedNewest = q.droppedNewest.Load()
+	}
+	if n := s.timing.wakeups.Load(); n > 0 {
+		st.MeanJitter = time.Duration(s.timing.jitterSum.Load() / int64(n))
+	}
+	return st
+}
@@ -0,0 +1,14 @@
+package huestream
+
+import "context"
+
+// Streamer is the sending surface of a Stream. Code taking a Streamer
+// instead of a *Stream can be tested with a fake or run against a
+// simulator, such as huesim.Terminal.
+type Streamer interface {
+	Send(Frame) error
+	SendContext(context.Context, Frame) error
+	Close() error
+}
+
+var _ Streamer = (*Stream)(nil)
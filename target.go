@@ -0,0 +1,234 @@
+package huestream
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"sync"
+	"time"
+)
+
+// targetRate is the rate, in Hz, of the frames sent toward the target of
+// SetTarget, the rate advised for the stream.
+const targetRate = 50
+
+// defaultSmoothing is the time constant of SetTarget without WithSmoothing.
+const defaultSmoothing = 100 * time.Millisecond
+
+// StalePolicy is what the Stream does with a target of SetTarget not
+// updated for a while, see WithStaleTarget.
+type StalePolicy int
+
+// The policies for a stale target.
+const (
+	StaleHold  StalePolicy = iota // Keep showing the target, the default.
+	StaleDecay                    // Fade to black, with the smoothing.
+)
+
+// Transition moves a channel to Color over Duration, see Stream.Transition.
+type Transition struct {
+	Color    color.Color
+	Duration time.Duration // Zero jumps to Color at the next frame.
+	Easing   Easing        // Nil is Linear.
+}
+
+// Easing maps the progress of a transition, from 0 to 1, to the fraction
+// of the way covered, 0 at the start and 1 at the end.
+type Easing func(t float64) float64
+
+// Linear covers the way at a constant pace.
+func Linear(t float64) float64 { return t }
+
+// EaseIn starts slowly and speeds up.
+func EaseIn(t float64) float64 { return t * t }
+
+// EaseOut starts fast and slows down.
+func EaseOut(t float64) float64 { return t * (2 - t) }
+
+// EaseInOut starts and ends slowly.
+func EaseInOut(t float64) float64 { return t * t * (3 - 2*t) }
+
+// rgb is a color of linear intensities, from 0 to 0xffff, kept as floats so
+// that slow moves are not lost to rounding.
+type rgb [3]float64
+
+// lerp returns the color a fraction t of the way from a to b.
+func lerp(a, b rgb, t float64) rgb {
+	for i := range a {
+		a[i] += (b[i] - a[i]) * t
+	}
+	return a
+}
+
+// follower is the state of the interpolating sender of a Stream, moving
+// each channel from its current color toward its target, or along its
+// transition.
+type follower struct {
+	mu      sync.Mutex
+	current map[int]rgb
+	target  map[int]rgb  // Of the channels not in a transition.
+	ramps   map[int]ramp // The transitions in flight.
+	set     time.Time    // When the target was last set.
+	last    time.Time    // When the last frame was built.
+	linear  bool         // Whether the colors are linear, see WithLinearInput.
+}
+
+// ramp is a transition of a channel.
+type ramp struct {
+	from, to rgb
+	start    time.Time
+	duration time.Duration
+	easing   Easing
+}
+
+func newFollower(now time.Time, linear bool) *follower {
+	return &follower{
+		linear:  linear,
+		current: make(map[int]rgb),
+		target:  make(map[int]rgb),
+		ramps:   make(map[int]ramp),
+		set:     now,
+		last:    now,
+	}
+}
+
+func (f *follower) setTarget(frame Frame, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, c := range frame {
+		delete(f.ramps, id)
+		f.target[id] = toLinear(c, f.linear)
+	}
+	f.set = now
+}
+
+func (f *follower) transition(ts map[int]Transition, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, t := range ts {
+		easing := t.Easing
+		if easing == nil {
+			easing = Linear
+		}
+		delete(f.target, id)
+		f.ramps[id] = ramp{
+			from:     f.current[id],
+			to:       toLinear(t.Color, f.linear),
+			start:    now,
+			duration: t.Duration,
+			easing:   easing,
+		}
+	}
+	f.set = now
+}
+
+// step moves the channels toward their targets for the time passed since
+// the last step, with a first order low-pass of time constant tau, and
+// returns the frame of their new colors. The targets older than stale are
+// taken as black if p is StaleDecay.
+func (f *follower) step(now time.Time, tau, stale time.Duration, p StalePolicy) Frame {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	alpha := 1.0
+	if dt := now.Sub(f.last); tau > 0 {
+		alpha = 1 - math.Exp(-dt.Seconds()/tau.Seconds())
+	}
+	f.last = now
+	decay := p == StaleDecay && stale > 0 && now.Sub(f.set) >= stale
+
+	frame := make(Frame, len(f.target)+len(f.ramps))
+	for id, r := range f.ramps {
+		progress := 1.0
+		if r.duration > 0 {
+			progress = min(now.Sub(r.start).Seconds()/r.duration.Seconds(), 1)
+		}
+		c := lerp(r.from, r.to, r.easing(progress))
+		if progress == 1 {
+			c = r.to
+			delete(f.ramps, id)
+			f.target[id] = r.to
+		}
+		f.current[id] = c
+		frame[id] = fromLinear(c, f.linear)
+	}
+	for id, target := range f.target {
+		if _, ok := frame[id]; ok {
+			continue // Its transition ended in this step.
+		}
+		if decay {
+			target = rgb{}
+		}
+		c := lerp(f.current[id], target, alpha)
+		f.current[id] = c
+		frame[id] = fromLinear(c, f.linear)
+	}
+	return frame
+}
+
+// SetTarget sets the colors the channels of frame move toward, the other
+// channels keep their target. It ends the transitions of the channels of
+// frame, see Stream.Transition. The first call starts a sender sending a
+// frame 50 times per second, each channel following its target with the
+// smoothing of WithSmoothing, from black for a channel new to the target.
+//
+// Unlike frames passed to Send, targets may come at any rate: the sender
+// upsamples them to a smooth output. What it does with a target not updated
+// for a while is set by WithStaleTarget. The failures of the sender are
+// reported to the error handler, it pauses with the Stream.
+//
+// SetTarget returns ErrClosed after Close.
+func (s *Stream) SetTarget(frame Frame) error {
+	return s.withFollower(func(f *follower) { f.setTarget(frame, s.clk.Now()) })
+}
+
+// Transition starts a transition for each channel of ts, from its current
+// color, the other channels are left as they are. The transitions run
+// together in the sender of SetTarget, each at its own pace:
+//
+//	s.Transition(map[int]huestream.Transition{
+//		0: {Color: red, Duration: 2 * time.Second},
+//		3: {Color: blue, Duration: 500 * time.Millisecond, Easing: huestream.EaseOut},
+//	})
+//
+// A transition replaces the one in flight on its channel, only. Once over,
+// the channel holds its color as if set by SetTarget, which also ends the
+// transitions of its channels. Transition returns ErrClosed after Close.
+func (s *Stream) Transition(ts map[int]Transition) error {
+	return s.withFollower(func(f *follower) { f.transition(ts, s.clk.Now()) })
+}
+
+// withFollower calls update with the follower of s, starting it on the
+// first call.
+func (s *Stream) withFollower(update func(*follower)) error {
+	s.followMu.Lock()
+	f := s.follower
+	start := f == nil
+	if start {
+		f = newFollower(s.clk.Now(), s.cfg.linearInput)
+		s.follower = f
+	}
+	s.followMu.Unlock()
+
+	if s.isClosed() {
+		return ErrClosed
+	}
+	update(f)
+	if start && !s.goBackground(func() { s.follow(f) }) {
+		return ErrClosed
+	}
+	return nil
+}
+
+// follow sends the frames of f at targetRate until the Stream is closed.
+func (s *Stream) follow(f *follower) {
+	p := s.newPacer(time.Second / targetRate)
+	for p.wait(s.quit) {
+		frame := f.step(s.clk.Now(), s.cfg.smoothing, s.cfg.staleAfter, s.cfg.stalePolicy)
+		err := s.Send(frame)
+		if err != nil && !errors.Is(err, ErrPaused) {
+			s.errs.report(fmt.Errorf("target: %w", err))
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package huestream
+
+import (
+	"image/color"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+	"github.com/rschio/huestream/wire"
+)
+
+// nextValues advances clk by one frame of the sender of SetTarget and
+// returns the values of the channels of the frame sent.
+func nextValues(t *testing.T, clk *clock.Fake, frames <-chan []byte) map[uint16][3]uint16 {
+	t.Helper()
+	clk.BlockUntil(1)
+	clk.Advance(time.Second / targetRate)
+	select {
+	case b := <-frames:
+		return decodeValues(t, b)
+	case <-time.After(time.Second):
+		t.Fatal("no frame sent")
+		return nil
+	}
+}
+
+// decodeValues returns the values of the channels of the message b.
+func decodeValues(t *testing.T, b []byte) map[uint16][3]uint16 {
+	t.Helper()
+	f, err := wire.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[uint16][3]uint16)
+	for _, ch := range f.Channels {
+		values[ch.ID] = ch.Values
+	}
+	return values
+}
+
+// nextRed is like nextValues for the red value of the channel 0.
+func nextRed(t *testing.T, clk *clock.Fake, frames <-chan []byte) uint16 {
+	t.Helper()
+	return nextValues(t, clk, frames)[0][0]
+}
+
+func TestSetTargetSmoothing(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, frames := pipeStream(t, withClock(clk), WithSmoothing(100*time.Millisecond))
+
+	if err := s.SetTarget(Frame{0: color.RGBA64{R: 0xffff, A: 0xffff}}); err != nil {
+		t.Fatal(err)
+	}
+	var red []uint16
+	for range 15 { // 300ms, 3 time constants.
+		red = append(red, nextRed(t, clk, frames))
+	}
+
+	for i := 1; i < len(red); i++ {
+		if red[i] <= red[i-1] {
+			t.Fatalf("red %v does not rise", red)
+		}
+	}
+	// The intensity is 1 - e^(-0.2) after a frame, 1 - e^(-3) after 3 time
+	// constants.
+	if got, want := red[0], srgb16(1-math.Exp(-0.2)); got != want {
+		t.Errorf("first frame red = %d, want %d", got, want)
+	}
+	if got, want := red[14], srgb16(1-math.Exp(-3)); got != want {
+		t.Errorf("red after 300ms = %d, want %d", got, want)
+	}
+}
+
+func TestSetTargetStale(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy StalePolicy
+		last   uint16 // Red 1s after the target.
+	}{
+		{"hold", StaleHold, 0xffff},
+		{"decay", StaleDecay, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := clock.NewFake(time.Unix(0, 0))
+			s, frames := pipeStream(t,
+				withClock(clk),
+				WithSmoothing(0),
+				WithStaleTarget(200*time.Millisecond, tt.policy),
+			)
+
+			if err := s.SetTarget(Frame{0: color.RGBA64{R: 0xffff, A: 0xffff}}); err != nil {
+				t.Fatal(err)
+			}
+			var red uint16
+			for i := range targetRate {
+				red = nextRed(t, clk, frames)
+				if i < 9 && red != 0xffff { // Up to 180ms.
+					t.Fatalf("red = %d at frame %d, want the target", red, i)
+				}
+			}
+			if red != tt.last {
+				t.Errorf("red = %d after 1s, want %d", red, tt.last)
+			}
+
+			// A new target ends the decay.
+			if err := s.SetTarget(Frame{0: color.RGBA64{R: 0x8000, A: 0xffff}}); err != nil {
+				t.Fatal(err)
+			}
+			if red := nextRed(t, clk, frames); red != 0x8000 {
+				t.Errorf("red = %d after a new target, want %d", red, 0x8000)
+			}
+		})
+	}
+}
+
+func TestSetTargetClosed(t *testing.T) {
+	s, _ := pipeStream(t)
+	s.Close()
+	if err := s.SetTarget(Frame{0: color.White}); err != ErrClosed {
+		t.Errorf("SetTarget after Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s, frames := pipeStream(t, withClock(clk))
+
+	red := color.RGBA64{R: 0xffff, A: 0xffff}
+	blue := color.RGBA64{B: 0xffff, A: 0xffff}
+	err := s.Transition(map[int]Transition{
+		0: {Color: red, Duration: 2 * time.Second},
+		3: {Color: blue, Duration: 500 * time.Millisecond, Easing: EaseIn},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v map[uint16][3]uint16
+	for range 10 { // 200ms.
+		v = nextValues(t, clk, frames)
+	}
+	if got, want := v[0][0], srgb16(0.1); got != want {
+		t.Errorf("channel 0 red = %d at 200ms, want %d", got, want)
+	}
+	if got, want := v[3][2], srgb16(0.4*0.4); got != want {
+		t.Errorf("channel 3 blue = %d at 200ms, want %d", got, want)
+	}
+
+	// Send channel 3 back to black, channel 0 goes on.
+	err = s.Transition(map[int]Transition{3: {Color: color.Black, Duration: 100 * time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 15 { // 500ms from the start.
+		v = nextValues(t, clk, frames)
+	}
+	if got, want := v[0][0], srgb16(0.25); got != want {
+		t.Errorf("channel 0 red = %d at 500ms, want %d", got, want)
+	}
+	if v[3] != [3]uint16{} {
+		t.Errorf("channel 3 = %v after its new transition, want black", v[3])
+	}
+
+	for range 75 { // 2s from the start.
+		v = nextValues(t, clk, frames)
+	}
+	if v[0] != [3]uint16{0xffff, 0, 0} {
+		t.Errorf("channel 0 = %v after 2s, want red", v[0])
+	}
+}
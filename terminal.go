@@ -0,0 +1,78 @@
+package huestream
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/pion/dtls/v3"
+)
+
+// terminal is the terminal state of a Stream, see Stream.Done.
+type terminal struct {
+	mu   sync.Mutex
+	done chan struct{} // Made on first use, so the zero Stream has one.
+	err  error
+}
+
+func (t *terminal) doneChan() chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done == nil {
+		t.done = make(chan struct{})
+	}
+	return t.done
+}
+
+// enter sets the cause of the terminal state, if not already set.
+func (t *terminal) enter(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return
+	}
+	if t.done == nil {
+		t.done = make(chan struct{})
+	}
+	t.err = err
+	close(t.done)
+}
+
+// Done returns a channel closed when the Stream ends: on Close, when the
+// connection is lost without WithRecovery, or when a recovery fails. Once
+// done the sends fail, Err tells why. Close it still, to stop the stream on
+// the bridge and release its resources.
+func (s *Stream) Done() <-chan struct{} { return s.term.doneChan() }
+
+// Err returns nil while Done is not closed, then the cause of the end of
+// the Stream: ErrClosed after Close, an error wrapping ErrConnLost and the
+// write failure for a lost connection, or the ErrRecoveryFailed reported to
+// the error handler. The first cause is kept, Close after another one does
+// not change it.
+func (s *Stream) Err() error {
+	s.term.mu.Lock()
+	defer s.term.mu.Unlock()
+	return s.term.err
+}
+
+// connLost reports whether err, a write failure, means the connection is
+// closed for good.
+func connLost(err error) bool {
+	return errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, dtls.ErrConnClosed)
+}
+
+// endOnFailure ends the Stream if err, a write failure, lost the connection
+// and no recovery is to bring it back.
+func (s *Stream) endOnFailure(err error) {
+	recovers := s.cfg.recovery > 0 && s.client != nil
+	if recovers || !connLost(err) || s.isClosed() {
+		return
+	}
+	s.logger().Warn("connection lost", "error", err)
+	s.term.enter(fmt.Errorf("%w: %w", ErrConnLost, err))
+}
@@ -0,0 +1,81 @@
+package huestream
+
+import (
+	"errors"
+	"image/color"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// waitDone waits for the end of s and returns its cause.
+func waitDone(t *testing.T, s *Stream) error {
+	t.Helper()
+	select {
+	case <-s.Done():
+		return s.Err()
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stream not done")
+		return nil
+	}
+}
+
+func TestErrClose(t *testing.T) {
+	s, _ := pipeStream(t)
+	select {
+	case <-s.Done():
+		t.Fatal("Done closed before Close")
+	default:
+	}
+	if err := s.Err(); err != nil {
+		t.Errorf("Err() = %v before Close, want nil", err)
+	}
+
+	s.Close()
+	if err := waitDone(t, s); !errors.Is(err, ErrClosed) {
+		t.Errorf("Err() = %v after Close, want ErrClosed", err)
+	}
+}
+
+func TestErrConnClosedRemotely(t *testing.T) {
+	local, remote := net.Pipe()
+	s := newStream(local, nil, testAreaID, newConfig(nil))
+	defer s.Close()
+	remote.Close()
+
+	if err := s.Send(Frame{0: color.White}); err == nil {
+		t.Fatal("Send on a closed conn should fail")
+	}
+	err := waitDone(t, s)
+	if !errors.Is(err, ErrConnLost) || !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Err() = %v, want ErrConnLost wrapping io.ErrClosedPipe", err)
+	}
+
+	// The first cause is kept.
+	s.Close()
+	if !errors.Is(s.Err(), ErrConnLost) {
+		t.Errorf("Err() = %v after Close, want ErrConnLost", s.Err())
+	}
+}
+
+func TestErrRecoveryFailed(t *testing.T) {
+	c := fakeBridge(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	dead := &funcConn{write: func(b []byte) (int, error) { return 0, net.ErrClosed }}
+	s := newStream(dead, c, testAreaID, newConfig([]Option{
+		WithIdleThreshold(0),
+		WithRecovery(100 * time.Millisecond),
+	}))
+	defer s.Close()
+
+	s.Send(Frame{0: color.White})
+	if err := s.Err(); err != nil {
+		t.Errorf("Err() = %v while recovering, want nil", err)
+	}
+	if err := waitDone(t, s); !errors.Is(err, ErrRecoveryFailed) {
+		t.Errorf("Err() = %v, want ErrRecoveryFailed", err)
+	}
+}
@@ -0,0 +1,89 @@
+package huestream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Tracer starts the spans tracing the calls of a Stream to the bridge, see
+// WithTracer. The otel submodule implements it with OpenTelemetry.
+//
+// The spans are:
+//   - "huestream.stream", from Start to Close, with the events "handshake",
+//     "recovered" and "close";
+//   - "huestream.start", "huestream.stop" and "huestream.configuration", a
+//     CLIP request each, children of the context of the caller.
+//
+// They have the attributes "bridge.host" and "huestream.area_id".
+type Tracer interface {
+	// Start starts a span, child of the span of ctx if any, and returns a
+	// context holding it.
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// AddEvent records an event in the span.
+	AddEvent(name string, attrs ...slog.Attr)
+	// End ends the span, as failed if err is not nil.
+	End(err error)
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string, _ ...slog.Attr) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) AddEvent(string, ...slog.Attr) {}
+func (noopSpan) End(error)                     {}
+
+// tracer returns the tracer set by WithTracer, or one doing nothing.
+func (c config) tracer() Tracer {
+	if c.trace == nil {
+		return noopTracer{}
+	}
+	return c.trace
+}
+
+// traced runs the CLIP request do in a span named "huestream."+name and
+// reports its duration to the metrics hook.
+func (c *client) traced(ctx context.Context, name, areaID string, do func(context.Context) error) error {
+	ctx, span := c.cfg.tracer().Start(ctx, "huestream."+name,
+		slog.String("bridge.host", c.host),
+		slog.String("huestream.area_id", areaID),
+	)
+	start := c.cfg.clock.Now()
+	err := do(ctx)
+	span.End(err)
+
+	if m, ok := c.cfg.metrics.(BridgeMetricsHook); ok {
+		m.RequestDuration(name, c.cfg.clock.Now().Sub(start), err)
+		var status *StatusError
+		if errors.As(err, &status) && status.Code == http.StatusTooManyRequests {
+			m.Throttled()
+		}
+	}
+	return err
+}
+
+// traceSpan returns the span of the Stream, the zero Stream has none.
+func (s *Stream) traceSpan() Span {
+	if s.span == nil {
+		return noopSpan{}
+	}
+	return s.span
+}
+
+// traceContext returns a context holding the span of the Stream, for the
+// requests made after Start, without its cancellation.
+func (s *Stream) traceContext() context.Context {
+	if s.spanCtx == nil {
+		return context.Background()
+	}
+	return context.WithoutCancel(s.spanCtx)
+}
@@ -0,0 +1,68 @@
+package huestream
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Retry policy of the asynchronous send paths for transient errors.
+const (
+	transientRetries = 4
+	transientBackoff = 2 * time.Millisecond // Doubled after each retry.
+)
+
+// IsTransient reports whether err is a write error that is likely to go away
+// on its own, such as a full socket buffer (ENOBUFS) or a network timeout,
+// so retrying the write shortly after is reasonable.
+//
+// The Stream applies this policy to its own background writes; callers of
+// Send can use it to decide whether an error is worth stopping for.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, net.ErrClosed) {
+		return false
+	}
+
+	switch {
+	case errors.Is(err, syscall.ENOBUFS),
+		errors.Is(err, syscall.EAGAIN),
+		errors.Is(err, syscall.ENOMEM),
+		errors.Is(err, syscall.EINTR):
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var tempErr interface{ Temporary() bool }
+	return errors.As(err, &tempErr) && tempErr.Temporary()
+}
+
+// writeRetry is like write but retries transient errors with a short
+// exponential backoff. It is meant for the background send paths, the error
+// is returned only when it is not transient or it persists.
+func (s *Stream) writeRetry(b []byte) error {
+	backoff := transientBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.write(b)
+		if err == nil || !IsTransient(err) {
+			return err
+		}
+		s.transientErrors.Add(1)
+		if attempt == transientRetries {
+			return err
+		}
+
+		t := s.clk.NewTimer(backoff)
+		select {
+		case <-t.C():
+		case <-s.quit:
+			t.Stop()
+			return err
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,85 @@
+package huestream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{io.EOF, false},
+		{net.ErrClosed, false},
+		{context.Canceled, false},
+		{syscall.ENOBUFS, true},
+		{os.NewSyscallError("write", syscall.ENOBUFS), true},
+		{&net.OpError{Op: "write", Net: "udp", Err: os.NewSyscallError("write", syscall.EAGAIN)}, true},
+		{fmt.Errorf("keepalive: %w", syscall.ENOBUFS), true},
+		{os.ErrDeadlineExceeded, true},
+		{syscall.ECONNREFUSED, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWriteRetryRecovers(t *testing.T) {
+	var calls int
+	conn := &funcConn{write: func(b []byte) (int, error) {
+		calls++
+		if calls <= 2 {
+			return 0, syscall.ENOBUFS
+		}
+		return len(b), nil
+	}}
+	s := newStream(conn, nil, testAreaID, newConfig(nil))
+
+	if err := s.writeRetry([]byte("frame")); err != nil {
+		t.Fatalf("writeRetry: %v", err)
+	}
+	if got := s.Stats().TransientErrors; got != 2 {
+		t.Errorf("TransientErrors = %d, want 2", got)
+	}
+}
+
+func TestWriteRetryGivesUp(t *testing.T) {
+	conn := &funcConn{write: func(b []byte) (int, error) {
+		return 0, syscall.ENOBUFS
+	}}
+	s := newStream(conn, nil, testAreaID, newConfig(nil))
+
+	if err := s.writeRetry([]byte("frame")); !errors.Is(err, syscall.ENOBUFS) {
+		t.Fatalf("got %v, want %v", err, syscall.ENOBUFS)
+	}
+	if got := s.Stats().TransientErrors; got != transientRetries+1 {
+		t.Errorf("TransientErrors = %d, want %d", got, transientRetries+1)
+	}
+}
+
+func TestWriteRetryPermanentError(t *testing.T) {
+	var calls int
+	conn := &funcConn{write: func(b []byte) (int, error) {
+		calls++
+		return 0, net.ErrClosed
+	}}
+	s := newStream(conn, nil, testAreaID, newConfig(nil))
+
+	if err := s.writeRetry([]byte("frame")); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("got %v, want %v", err, net.ErrClosed)
+	}
+	if calls != 1 {
+		t.Errorf("permanent error retried, %d writes", calls)
+	}
+}
@@ -0,0 +1,75 @@
+package huestream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// The version 1 API activates the stream of an entertainment group instead
+// of an entertainment configuration. It answers 200 to most failures and
+// reports them in the body.
+
+func (c *client) groupURL(groupID string) string {
+	return fmt.Sprintf("%s/api/%s/groups/%s", c.apiURL(), c.username, groupID)
+}
+
+// streamActionV1 activates or deactivates the stream of the group.
+func (c *client) streamActionV1(ctx context.Context, groupID string, active bool) error {
+	data := strings.NewReader(fmt.Sprintf(`{"stream":{"active":%t}}`, active))
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.groupURL(groupID), data)
+	if err != nil {
+		return err
+	}
+	return c.doV1(req)
+}
+
+// getGroupV1 checks that the bridge serves the group.
+func (c *client) getGroupV1(ctx context.Context, groupID string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.groupURL(groupID), nil)
+	if err != nil {
+		return err
+	}
+	return c.doV1(req)
+}
+
+func (c *client) doV1(req *http.Request) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Code: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return v1Error(body)
+}
+
+// v1Error returns the first error of a version 1 API response, which is a
+// list of results on failures and on writes.
+func v1Error(body []byte) error {
+	var results []struct {
+		Error *struct {
+			Type        int    `json:"type"`
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &results) != nil {
+		return nil // Not a list, the answer of a successful read.
+	}
+	for _, r := range results {
+		if r.Error != nil {
+			return &BridgeError{Type: r.Error.Type, Description: r.Error.Description}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package huestream
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"image/color"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/rschio/huestream/wire"
+)
+
+// TestMessageGolden encodes the same frame in both protocol versions.
+func TestMessageGolden(t *testing.T) {
+	frame := Frame{
+		3: color.RGBA64{R: 0xffff, G: 0x0000, B: 0x1234, A: 0xffff},
+		1: color.RGBA64{R: 0x0001, G: 0x8000, B: 0xfffe, A: 0xffff},
+	}
+	protocol := hex.EncodeToString([]byte("HueStream"))
+
+	tests := []struct {
+		version int
+		want    string
+	}{
+		{
+			version: wire.VersionMajor,
+			want: protocol + "0200" + "00" + "0000" + "00" + "00" +
+				hex.EncodeToString([]byte(testAreaID)) +
+				"01" + "00018000fffe" +
+				"03" + "ffff00001234",
+		},
+		{
+			version: wire.Version1,
+			want: protocol + "0100" + "00" + "0000" + "00" + "00" +
+				"00" + "0001" + "00018000fffe" +
+				"00" + "0003" + "ffff00001234",
+		},
+	}
+	for _, tt := range tests {
+		b, err := message{areaID: testAreaID, version: tt.version, idColors: frame}.MarshalBinary()
+		if err != nil {
+			t.Fatalf("version %d: %v", tt.version, err)
+		}
+		if got := hex.EncodeToString(b); got != tt.want {
+			t.Errorf("version %d:\n got %s\nwant %s", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestStreamActionV1(t *testing.T) {
+	var method, path, body string
+	c := fakeBridge(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(b)
+		io.WriteString(w, `[{"success":{"/groups/7/stream/active":true}}]`)
+	}))
+	c.cfg.version = wire.Version1
+
+	if err := c.startStream(context.Background(), "7"); err != nil {
+		t.Fatal(err)
+	}
+	if method != "PUT" || path != "/api/username/groups/7" || body != `{"stream":{"active":true}}` {
+		t.Errorf("start sent %s %s %s", method, path, body)
+	}
+
+	if err := c.stopStream(context.Background(), "7"); err != nil {
+		t.Fatal(err)
+	}
+	if body != `{"stream":{"active":false}}` {
+		t.Errorf("stop sent %s", body)
+	}
+}
+
+func TestStreamActionV1Error(t *testing.T) {
+	c := fakeBridge(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `[{"error":{"type":3,"address":"/groups/9","description":"resource, /groups/9, not available"}}]`)
+	}))
+	c.cfg.version = wire.Version1
+
+	if err := c.startStream(context.Background(), "9"); !errors.Is(err, ErrAreaNotFound) {
+		t.Errorf("startStream returned %v, want %v", err, ErrAreaNotFound)
+	}
+	if err := c.getConfiguration(context.Background(), "9"); !errors.Is(err, ErrAreaNotFound) {
+		t.Errorf("getConfiguration returned %v, want %v", err, ErrAreaNotFound)
+	}
+}
+
+func TestStartUnsupportedVersion(t *testing.T) {
+	_, err := Start(context.Background(), "127.0.0.1", "username", "clientkey", testAreaID, WithProtocolVersion(3))
+	if err == nil {
+		t.Fatal("Start should reject version 3")
+	}
+}
+
+func TestPauseBlackV1(t *testing.T) {
+	s, _ := pipeStream(t, WithProtocolVersion(wire.Version1), WithPauseBlack())
+	s.last, _ = s.message(Frame{2: color.White}).MarshalBinary()
+
+	got, err := s.holdMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := message{version: wire.Version1, idColors: Frame{2: color.Black}}.MarshalBinary()
+	if hex.EncodeToString(got) != hex.EncodeToString(want) {
+		t.Errorf("hold message %x, want %x", got, want)
+	}
+}
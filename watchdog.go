@@ -0,0 +1,57 @@
+package huestream
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultIdleThreshold is the default idle time after which the watchdog
+// fires, a bit below the ~10s after which the bridge ends the session.
+const defaultIdleThreshold = 8 * time.Second
+
+// watchdog watches for periods of threshold without any message sent.
+//
+// With keepalive enabled it sends the last frame again, otherwise it reports
+// ErrStreamIdle to the error handler, once per idle period.
+func (s *Stream) watchdog(threshold time.Duration) {
+	var warned time.Time // The lastSend already reported as idle.
+	for {
+		s.mu.Lock()
+		last := s.lastSend
+		s.mu.Unlock()
+
+		due := last.Add(threshold)
+		if last.Equal(warned) {
+			// Already reported, check again later for new frames.
+			due = s.clk.Now().Add(threshold)
+		}
+
+		t := s.clk.NewTimer(due.Sub(s.clk.Now()))
+		select {
+		case <-s.quit:
+			t.Stop()
+			return
+		case <-t.C():
+		}
+
+		s.mu.Lock()
+		idle := s.lastSend.Equal(last)
+		s.mu.Unlock()
+		if !idle {
+			continue
+		}
+
+		if s.cfg.keepAlive > 0 {
+			s.logger().Debug("stream idle, resending the last frame", "idle", threshold)
+			if err := s.resend(threshold); err != nil {
+				s.errs.report(fmt.Errorf("watchdog keepalive: %w", err))
+			}
+			continue
+		}
+		if !last.Equal(warned) {
+			s.logger().Warn("stream idle", "idle", threshold)
+			s.errs.report(fmt.Errorf("%w: no frame sent for %v", ErrStreamIdle, threshold))
+			warned = last
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package huestream
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/rschio/huestream/internal/clock"
+)
+
+func TestWatchdogReportsIdleOnce(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	errs := make(chan error, errorQueueSize)
+	s, frames := pipeStream(t,
+		withClock(clk),
+		WithIdleThreshold(8*time.Second),
+		WithErrorHandler(func(err error) { errs <- err }),
+	)
+
+	clk.BlockUntil(1)
+	clk.Advance(8 * time.Second)
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrStreamIdle) {
+			t.Fatalf("got %v, want %v", err, ErrStreamIdle)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("idle stream not reported")
+	}
+
+	// Still idle, but already reported.
+	clk.BlockUntil(1)
+	clk.Advance(8 * time.Second)
+	clk.BlockUntil(1)
+	select {
+	case err := <-errs:
+		t.Fatalf("reported twice: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// A new frame rearms the watchdog.
+	if err := s.Send(Frame{0: color.White}); err != nil {
+		t.Fatal(err)
+	}
+	<-frames
+	clk.Advance(8 * time.Second)
+	clk.BlockUntil(1)
+	clk.Advance(8 * time.Second)
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrStreamIdle) {
+			t.Fatalf("got %v, want %v", err, ErrStreamIdle)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("idle stream not reported after a new frame")
+	}
+}
+
+func TestWatchdogDisabledWithoutHandler(t *testing.T) {
+	s, _ := pipeStream(t)
+
+	// Without handler nor keepalive no background goroutine is started.
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a background goroutine is running")
+	}
+}
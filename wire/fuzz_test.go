@@ -0,0 +1,107 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// addGoldenSeeds adds the golden messages of testdata to the corpus of f.
+func addGoldenSeeds(f *testing.F) {
+	paths, _ := filepath.Glob(filepath.Join("testdata", "*.hex"))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		b, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			f.Fatalf("%s: %v", path, err)
+		}
+		f.Add(b)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	addGoldenSeeds(f)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		fr, err := Decode(b)
+		if err != nil {
+			return
+		}
+		// An accepted message is valid and encodes back to itself.
+		got, err := Encode(fr)
+		if err != nil {
+			t.Fatalf("Decode accepted an invalid frame %+v: %v", fr, err)
+		}
+		if !bytes.Equal(got, b) && !reservedBytesSet(b) {
+			t.Fatalf("Encode(Decode(%x)) = %x", b, got)
+		}
+	})
+}
+
+// reservedBytesSet reports whether the reserved bytes of the header of b or
+// its minor version are not zero, Decode ignores them.
+func reservedBytesSet(b []byte) bool {
+	return b[offVersion+1] != 0 || b[offSequence+1] != 0 || b[offSequence+2] != 0 || b[offColorSpace+1] != 0
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	addGoldenSeeds(f)
+	f.Fuzz(func(t *testing.T, seed []byte) {
+		fr := frameFromSeed(seed)
+		b, err := Encode(fr)
+		if err != nil {
+			t.Fatalf("Encode(%+v): %v", fr, err)
+		}
+		got, err := Decode(b)
+		if err != nil {
+			t.Fatalf("Decode(Encode(%+v)): %v", fr, err)
+		}
+		if !reflect.DeepEqual(got, fr) {
+			t.Fatalf("Decode(Encode(f)) = %+v, want %+v", got, fr)
+		}
+	})
+}
+
+// frameFromSeed builds a valid frame from arbitrary bytes.
+func frameFromSeed(seed []byte) Frame {
+	next := func() byte {
+		if len(seed) == 0 {
+			return 0
+		}
+		b := seed[0]
+		seed = seed[1:]
+		return b
+	}
+
+	fr := Frame{Header: Header{
+		Version:    VersionMajor,
+		Sequence:   next(),
+		ColorSpace: ColorSpace(next() % 2),
+		AreaID:     areaID,
+	}}
+	n := int(next()) % (MaxChannels + 1)
+	if next()%2 == 1 {
+		fr.Version, fr.AreaID = Version1, ""
+		n %= MaxChannelsV1 + 1
+	}
+
+	fr.Channels = make([]Channel, n)
+	for i := range fr.Channels {
+		c := &fr.Channels[i]
+		c.ID = binary.BigEndian.Uint16([]byte{next(), next()})
+		if fr.Version == VersionMajor {
+			c.ID &= 0xff
+		}
+		for j := range c.Values {
+			c.Values[j] = binary.BigEndian.Uint16([]byte{next(), next()})
+		}
+	}
+	return fr
+}
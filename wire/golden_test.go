@@ -0,0 +1,98 @@
+package wire
+
+import (
+	"encoding/hex"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+// goldenFrames are encoded to testdata/<name>.hex. Changing the encoding of
+// any of them is a protocol change, the golden files must only be updated
+// on purpose.
+var goldenFrames = map[string]Frame{
+	"empty": {
+		Header: Header{Version: VersionMajor, AreaID: areaID},
+	},
+	"single_channel": {
+		Header:   Header{Version: VersionMajor, Sequence: 1, AreaID: areaID},
+		Channels: []Channel{{ID: 0, Values: [3]uint16{0xffff, 0x8000, 0x0001}}},
+	},
+	"max_channels": {
+		Header:   Header{Version: VersionMajor, Sequence: 2, AreaID: areaID},
+		Channels: rampChannels(MaxChannels),
+	},
+	"extreme_values": {
+		Header: Header{Version: VersionMajor, Sequence: 255, AreaID: areaID},
+		Channels: []Channel{
+			{ID: 0, Values: [3]uint16{0, 0, 0}},
+			{ID: 255, Values: [3]uint16{0xffff, 0xffff, 0xffff}},
+		},
+	},
+	"xy": {
+		Header: Header{Version: VersionMajor, Sequence: 3, ColorSpace: ColorSpaceXY, AreaID: areaID},
+		Channels: []Channel{
+			{ID: 4, Values: [3]uint16{0x5555, 0x5555, 0xffff}}, // White.
+		},
+	},
+	"v1_empty": {
+		Header: Header{Version: Version1},
+	},
+	"v1_max_lights": {
+		Header:   Header{Version: Version1, Sequence: 4},
+		Channels: rampChannels(MaxChannelsV1),
+	},
+	"v1_xy": {
+		Header:   Header{Version: Version1, ColorSpace: ColorSpaceXY},
+		Channels: []Channel{{ID: 0xabcd, Values: [3]uint16{0x5555, 0x5555, 0xffff}}},
+	},
+}
+
+// rampChannels returns n channels with distinct IDs and values.
+func rampChannels(n int) []Channel {
+	cs := make([]Channel, n)
+	for i := range cs {
+		v := uint16(i * 0x0d0d)
+		cs[i] = Channel{ID: uint16(i), Values: [3]uint16{v, ^v, v >> 1}}
+	}
+	return cs
+}
+
+func TestGolden(t *testing.T) {
+	for name, f := range goldenFrames {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("testdata", name+".hex")
+
+			b, err := Encode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := hex.EncodeToString(b)
+
+			if *update {
+				if err := os.WriteFile(path, []byte(got+"\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if got != strings.TrimSpace(string(want)) {
+				t.Fatalf("encoding of %s changed:\n got %s\nwant %s", name, got, want)
+			}
+
+			dec, err := Decode(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reenc, _ := Encode(dec); hex.EncodeToString(reenc) != got {
+				t.Errorf("Decode lost data of %s", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+// Package wire implements the binary format of the HueStream messages sent
+// to a Hue Bridge over the Entertainment API.
+//
+// Version 2 addresses the channels of an entertainment configuration and is
+// the default. Version 1, spoken by older bridge firmware, addresses lights
+// by ID and has no configuration ID in the header.
+//
+// See https://developers.meethue.com/develop/hue-entertainment/hue-entertainment-api/#StreamCaption
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ProtocolName starts every message.
+const ProtocolName = "HueStream"
+
+// The versions of the protocol. VersionMajor is the latest one, both
+// versions have the minor version VersionMinor.
+const (
+	Version1     = 1
+	VersionMajor = 2
+	VersionMinor = 0
+)
+
+// Sizes and limits of a version 2 message.
+const (
+	HeaderSize     = 52 // The header, including the entertainment configuration ID.
+	ChannelSize    = 7  // A channel record: ID and three 16 bits values.
+	AreaIDSize     = 36 // The entertainment configuration ID, a UUID.
+	MaxChannels    = 20
+	MaxMessageSize = HeaderSize + MaxChannels*ChannelSize // 192 bytes.
+)
+
+// Sizes and limits of a version 1 message.
+const (
+	HeaderSizeV1     = 16 // The header, without configuration ID.
+	ChannelSizeV1    = 9  // A light record: device type, 16 bits ID and values.
+	MaxChannelsV1    = 10
+	MaxMessageSizeV1 = HeaderSizeV1 + MaxChannelsV1*ChannelSizeV1 // 106 bytes.
+)
+
+// ErrTooManyChannels is returned by Encode and Append for a frame with more
+// channels than a message of its version holds.
+var ErrTooManyChannels = errors.New("too many channels")
+
+// deviceLight is the device type of a version 1 record addressing a light.
+const deviceLight = 0x0
+
+// ColorSpace is the color space of the channel values.
+type ColorSpace uint8
+
+// The color spaces supported by the protocol.
+const (
+	ColorSpaceRGB ColorSpace = 0x0
+	ColorSpaceXY  ColorSpace = 0x1 // CIE xy and brightness.
+)
+
+// Offsets of the header fields.
+const (
+	offVersion    = len(ProtocolName)
+	offSequence   = offVersion + 2
+	offColorSpace = offSequence + 3 // After 2 reserved bytes.
+	offAreaID     = offColorSpace + 2
+)
+
+// Header is the header of a message.
+type Header struct {
+	Version    uint8 // The major version of the protocol, Version1 or VersionMajor.
+	Sequence   uint8 // Ignored by the bridge.
+	ColorSpace ColorSpace
+	AreaID     string // The entertainment configuration ID, empty in version 1.
+}
+
+// Channel sets the color of one channel (lamp or segment) of the area, or
+// of one light in version 1.
+type Channel struct {
+	ID uint16 // At most 255 in version 2.
+	// Values are R, G, B or x, y, brightness depending on the color space,
+	// each scaled to the full uint16 range.
+	Values [3]uint16
+}
+
+// Frame is a message, a header followed by up to MaxChannels channels
+// (MaxChannelsV1 in version 1).
+type Frame struct {
+	Header
+	Channels []Channel
+}
+
+// Encode returns the binary encoding of f.
+func Encode(f Frame) ([]byte, error) {
+	headerSize, channelSize := sizes(f.Version)
+	return Append(make([]byte, 0, headerSize+len(f.Channels)*channelSize), f)
+}
+
+// Append appends the binary encoding of f to dst.
+func Append(dst []byte, f Frame) ([]byte, error) {
+	if err := validate(f); err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ProtocolName...)
+	dst = append(dst, f.Version, VersionMinor)
+	dst = append(dst, f.Sequence)
+	dst = append(dst, 0x0, 0x0) // Reserved.
+	dst = append(dst, byte(f.ColorSpace))
+	dst = append(dst, 0x0) // Reserved.
+	dst = append(dst, f.AreaID...)
+
+	for _, c := range f.Channels {
+		if f.Version == Version1 {
+			dst = append(dst, deviceLight)
+			dst = binary.BigEndian.AppendUint16(dst, c.ID)
+		} else {
+			dst = append(dst, uint8(c.ID))
+		}
+		for _, v := range c.Values {
+			dst = binary.BigEndian.AppendUint16(dst, v)
+		}
+	}
+
+	return dst, nil
+}
+
+// Decode parses a message encoded by Encode.
+func Decode(b []byte) (Frame, error) {
+	var f Frame
+
+	if len(b) < HeaderSizeV1 {
+		return f, fmt.Errorf("message too short: %d bytes", len(b))
+	}
+	if string(b[:len(ProtocolName)]) != ProtocolName {
+		return f, errors.New("not a HueStream message")
+	}
+
+	f.Version = b[offVersion]
+	headerSize, channelSize := sizes(f.Version)
+	maxSize := headerSize + maxChannels(f.Version)*channelSize
+	if len(b) < headerSize {
+		return f, fmt.Errorf("message too short: %d bytes", len(b))
+	}
+	if len(b) > maxSize {
+		return f, fmt.Errorf("message too long: %d bytes, maximum is %d", len(b), maxSize)
+	}
+
+	f.Sequence = b[offSequence]
+	f.ColorSpace = ColorSpace(b[offColorSpace])
+	f.AreaID = string(b[offAreaID:headerSize])
+
+	body := b[headerSize:]
+	if len(body)%channelSize != 0 {
+		return f, fmt.Errorf("truncated channel record: %d trailing bytes", len(body)%channelSize)
+	}
+	f.Channels = make([]Channel, 0, len(body)/channelSize)
+	for ; len(body) > 0; body = body[channelSize:] {
+		var c Channel
+		rec := body
+		if f.Version == Version1 {
+			if rec[0] != deviceLight {
+				return Frame{}, fmt.Errorf("unsupported device type %d", rec[0])
+			}
+			c.ID = binary.BigEndian.Uint16(rec[1:])
+			rec = rec[3:]
+		} else {
+			c.ID = uint16(rec[0])
+			rec = rec[1:]
+		}
+		for i := range c.Values {
+			c.Values[i] = binary.BigEndian.Uint16(rec[2*i:])
+		}
+		f.Channels = append(f.Channels, c)
+	}
+
+	if err := validate(f); err != nil {
+		return Frame{}, err
+	}
+
+	return f, nil
+}
+
+func validate(f Frame) error {
+	if f.Version != Version1 && f.Version != VersionMajor {
+		return fmt.Errorf("unsupported version %d", f.Version)
+	}
+	if f.ColorSpace != ColorSpaceRGB && f.ColorSpace != ColorSpaceXY {
+		return fmt.Errorf("unknown color space %d", f.ColorSpace)
+	}
+	areaIDSize := AreaIDSize
+	if f.Version == Version1 {
+		areaIDSize = 0
+	}
+	if len(f.AreaID) != areaIDSize {
+		return fmt.Errorf("area ID must have %d characters in version %d, got %d", areaIDSize, f.Version, len(f.AreaID))
+	}
+	if n := maxChannels(f.Version); len(f.Channels) > n {
+		return fmt.Errorf("%w: maximum is %d, got %d", ErrTooManyChannels, n, len(f.Channels))
+	}
+	if f.Version == VersionMajor {
+		for _, c := range f.Channels {
+			if c.ID > 0xff {
+				return fmt.Errorf("channel ID %d does not fit in a byte", c.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// sizes returns the size of the header and of a channel record of version.
+func sizes(version uint8) (header, channel int) {
+	if version == Version1 {
+		return HeaderSizeV1, ChannelSizeV1
+	}
+	return HeaderSize, ChannelSize
+}
+
+func maxChannels(version uint8) int {
+	if version == Version1 {
+		return MaxChannelsV1
+	}
+	return MaxChannels
+}
@@ -0,0 +1,157 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+const areaID = "1a8d99cc-967b-44f2-9202-43f976c0fa6e"
+
+// header is the expected encoding of the header of the frames below.
+var header = hex.EncodeToString([]byte("HueStream")) +
+	"0200" + // Version 2.0.
+	"07" + // Sequence.
+	"0000" + // Reserved.
+	"00" + // RGB.
+	"00" + // Reserved.
+	hex.EncodeToString([]byte(areaID))
+
+func TestEncodeLayout(t *testing.T) {
+	f := Frame{
+		Header: Header{Version: VersionMajor, Sequence: 7, ColorSpace: ColorSpaceRGB, AreaID: areaID},
+		Channels: []Channel{
+			{ID: 0, Values: [3]uint16{0xffff, 0x0000, 0x1234}},
+			{ID: 19, Values: [3]uint16{0x0001, 0x8000, 0xfffe}},
+		},
+	}
+	want := header + "00ffff00001234" + "130001" + "8000fffe"
+
+	b, err := Encode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(b); got != want {
+		t.Errorf("Encode:\n got %s\nwant %s", got, want)
+	}
+
+	got, err := Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, f) {
+		t.Errorf("Decode(Encode(f)) = %+v, want %+v", got, f)
+	}
+}
+
+func TestEncodeLayoutV1(t *testing.T) {
+	f := Frame{
+		Header: Header{Version: Version1, Sequence: 7, ColorSpace: ColorSpaceXY},
+		Channels: []Channel{
+			{ID: 3, Values: [3]uint16{0xffff, 0x0000, 0x1234}},
+			{ID: 0x0102, Values: [3]uint16{0x0001, 0x8000, 0xfffe}},
+		},
+	}
+	want := hex.EncodeToString([]byte("HueStream")) +
+		"0100" + // Version 1.0.
+		"07" + // Sequence.
+		"0000" + // Reserved.
+		"01" + // XY.
+		"00" + // Reserved.
+		"00" + "0003" + "ffff00001234" + // Light 3.
+		"00" + "0102" + "00018000fffe" // Light 258.
+
+	b, err := Encode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(b); got != want {
+		t.Errorf("Encode:\n got %s\nwant %s", got, want)
+	}
+
+	got, err := Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, f) {
+		t.Errorf("Decode(Encode(f)) = %+v, want %+v", got, f)
+	}
+}
+
+func TestAppendReusesBuffer(t *testing.T) {
+	f := Frame{Header: Header{Version: VersionMajor, AreaID: areaID}}
+	dst := make([]byte, 0, MaxMessageSize)
+	b, err := Append(dst, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &b[0] != &dst[:1][0] {
+		t.Error("Append did not use the capacity of dst")
+	}
+	if len(b) != HeaderSize {
+		t.Errorf("empty frame has %d bytes, want %d", len(b), HeaderSize)
+	}
+}
+
+func TestEncodeInvalid(t *testing.T) {
+	tests := map[string]Frame{
+		"version":     {Header: Header{Version: VersionMajor + 1, AreaID: areaID}},
+		"color space": {Header: Header{Version: VersionMajor, ColorSpace: 9, AreaID: areaID}},
+		"area ID":     {Header: Header{Version: VersionMajor, AreaID: "short"}},
+		"channels":    {Header: Header{Version: VersionMajor, AreaID: areaID}, Channels: make([]Channel, MaxChannels+1)},
+		"channel ID":  {Header: Header{Version: VersionMajor, AreaID: areaID}, Channels: []Channel{{ID: 256}}},
+		"v1 area ID":  {Header: Header{Version: Version1, AreaID: areaID}},
+		"v1 channels": {Header: Header{Version: Version1}, Channels: make([]Channel, MaxChannelsV1+1)},
+	}
+	for name, f := range tests {
+		if _, err := Encode(f); err == nil {
+			t.Errorf("%s: Encode should fail", name)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	valid, _ := Encode(Frame{Header: Header{Version: VersionMajor, AreaID: areaID}, Channels: []Channel{{ID: 1}}})
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"short":     valid[:HeaderSize-1],
+		"protocol":  append([]byte("HueStreaX"), valid[9:]...),
+		"truncated": valid[:len(valid)-1],
+		"too long":  append(valid, make([]byte, MaxMessageSize)...),
+		"version":   bytes.Replace(valid, []byte("HueStream\x02"), []byte("HueStream\x05"), 1),
+	}
+	v1, _ := Encode(Frame{Header: Header{Version: Version1}, Channels: []Channel{{ID: 1}}})
+	tests["v1 short"] = v1[:HeaderSizeV1-1]
+	tests["v1 truncated"] = v1[:len(v1)-1]
+	tests["v1 device type"] = append(v1[:HeaderSizeV1:HeaderSizeV1], append([]byte{0x1}, v1[HeaderSizeV1+1:]...)...)
+
+	for name, b := range tests {
+		if _, err := Decode(b); err == nil {
+			t.Errorf("%s: Decode should fail", name)
+		}
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	f := Frame{Header: Header{Version: VersionMajor, AreaID: areaID}, Channels: make([]Channel, MaxChannels)}
+	b, err := Encode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != MaxMessageSize || MaxMessageSize != 192 {
+		t.Errorf("full frame has %d bytes, MaxMessageSize is %d, the spec says 192", len(b), MaxMessageSize)
+	}
+}
+
+func TestMaxMessageSizeV1(t *testing.T) {
+	f := Frame{Header: Header{Version: Version1}, Channels: make([]Channel, MaxChannelsV1)}
+	b, err := Encode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != MaxMessageSizeV1 {
+		t.Errorf("full frame has %d bytes, MaxMessageSizeV1 is %d", len(b), MaxMessageSizeV1)
+	}
+}
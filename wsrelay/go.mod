@@ -0,0 +1,15 @@
+module github.com/rschio/huestream/wsrelay
+
+go 1.23.2
+
+require github.com/rschio/huestream v0.0.0
+
+require (
+	github.com/coder/websocket v1.8.12
+	github.com/pion/dtls/v3 v3.0.4 // indirect
+	github.com/pion/logging v0.2.2 // indirect
+	github.com/pion/transport/v3 v3.0.7 // indirect
+	golang.org/x/crypto v0.28.0 // indirect
+)
+
+replace github.com/rschio/huestream => ../
@@ -0,0 +1,345 @@
+// Package wsrelay relays the frames received over WebSocket to the Streams
+// of the entertainment areas, for browser based lighting consoles sending
+// frames at 50 Hz:
+//
+//	h := wsrelay.New(host, username, clientKey)
+//	defer h.Close()
+//	http.Handle("/ws/{area}", h)
+//
+// A connection streams to the area of the {area} path wildcard, or of the
+// area query parameter. Its messages are frames, either binary, 7 bytes
+// per channel: the channel ID then the big-endian 16-bit red, green and
+// blue; or JSON text, the colors by channel ID, in a form of
+// huestream.ParseColor, and "all" for the channels without a color of
+// their own:
+//
+//	{"0":"#ff0000","1":"#0000ff"}
+//
+// Frames arriving faster than the maximum rate are coalesced, only the
+// last one is sent. The invalid frames and the send failures are reported
+// back as JSON text messages, {"error":"..."}, the connection stays open.
+//
+// The Stream of an area is started by its first connection and is kept
+// when the connections close, so that reloading the page does not flash
+// the lights, unless WithCloseOnDisconnect is set. Close stops the Streams.
+//
+// It is a separate module so that huestream does not depend on a
+// WebSocket library.
+package wsrelay
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/rschio/huestream"
+)
+
+// channelSize is the size of a channel in a binary frame.
+const channelSize = 7
+
+// readLimit is the size limit of the messages, above a JSON frame of every
+// channel.
+const readLimit = 4096
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithMaxRate sets the maximum rate of the frames sent to a Stream, by
+// default 50 Hz.
+func WithMaxRate(hz float64) Option {
+	return func(h *Handler) { h.period = time.Duration(float64(time.Second) / hz) }
+}
+
+// WithCloseOnDisconnect makes the Handler stop the Stream of an area when
+// its last connection closes.
+func WithCloseOnDisconnect() Option {
+	return func(h *Handler) { h.closeOnDisconnect = true }
+}
+
+// WithOriginPatterns sets the host patterns of the origins accepted besides
+// the one of the Handler, see websocket.AcceptOptions.
+func WithOriginPatterns(patterns ...string) Option {
+	return func(h *Handler) { h.originPatterns = patterns }
+}
+
+// WithStreamOptions sets the options passed to huestream.Start and
+// huestream.Areas.
+func WithStreamOptions(opts ...huestream.Option) Option {
+	return func(h *Handler) { h.streamOpts = opts }
+}
+
+// Handler is an http.Handler relaying the frames of WebSocket connections
+// to the areas of a bridge.
+type Handler struct {
+	host, username, clientKey string
+
+	period            time.Duration
+	closeOnDisconnect bool
+	originPatterns    []string
+	streamOpts        []huestream.Option
+
+	mu      sync.Mutex // Guards the fields below.
+	streams map[string]*areaStream
+	closed  bool
+}
+
+// areaStream is the Stream of an area and its connections.
+type areaStream struct {
+	area   huestream.Area
+	stream *huestream.Stream
+	conns  int
+}
+
+// New returns a Handler relaying to the areas of the bridge at host.
+func New(host, username, clientKey string, opts ...Option) *Handler {
+	h := &Handler{
+		host:      host,
+		username:  username,
+		clientKey: clientKey,
+		period:    time.Second / 50,
+		streams:   make(map[string]*areaStream),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Close stops the Streams, the later connections are refused.
+func (h *Handler) Close() error {
+	h.mu.Lock()
+	streams := h.streams
+	h.streams = make(map[string]*areaStream)
+	h.closed = true
+	h.mu.Unlock()
+
+	var errs []error
+	for _, as := range streams {
+		errs = append(errs, as.stream.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("area")
+	if id == "" {
+		id = r.URL.Query().Get("area")
+	}
+	if id == "" {
+		http.Error(w, "missing area", http.StatusBadRequest)
+		return
+	}
+	as, err := h.acquire(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), statusCode(err))
+		return
+	}
+	defer h.release(id, as)
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: h.originPatterns})
+	if err != nil {
+		return // Accept answered.
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(readLimit)
+
+	h.relay(r.Context(), conn, as)
+}
+
+// acquire returns the Stream of the area id, started if it is the first
+// connection.
+func (h *Handler) acquire(ctx context.Context, id string) (*areaStream, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, errClosed
+	}
+	if as, ok := h.streams[id]; ok {
+		as.conns++
+		return as, nil
+	}
+
+	// Starting under mu serializes the connections of a new area, it is
+	// the rare path.
+	areas, err := huestream.Areas(ctx, h.host, h.username, h.streamOpts...)
+	if err != nil {
+		return nil, err
+	}
+	as := &areaStream{conns: 1}
+	for _, a := range areas {
+		if a.ID == id {
+			as.area = a
+		}
+	}
+	if as.area.ID == "" {
+		return nil, fmt.Errorf("area %s: %w", id, huestream.ErrAreaNotFound)
+	}
+	opts := append([]huestream.Option{huestream.WithKeepAlive(time.Second)}, h.streamOpts...)
+	as.stream, err = huestream.Start(ctx, h.host, h.username, h.clientKey, id, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.streams[id] = as
+	return as, nil
+}
+
+// release ends a connection to the Stream of the area id.
+func (h *Handler) release(id string, as *areaStream) {
+	h.mu.Lock()
+	as.conns--
+	stop := h.closeOnDisconnect && as.conns == 0 && h.streams[id] == as
+	if stop {
+		delete(h.streams, id)
+	}
+	h.mu.Unlock()
+
+	if stop {
+		as.stream.Close()
+	}
+}
+
+// relay reads the frames of conn and sends them to the Stream at most at
+// the maximum rate, until conn closes.
+func (h *Handler) relay(ctx context.Context, conn *websocket.Conn, as *areaStream) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	// pending holds the last frame not sent yet.
+	pending := make(chan huestream.Frame, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.send(ctx, conn, as, pending)
+	}()
+	defer func() { <-done }()
+	defer cancel()
+
+	for {
+		typ, msg, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		f, err := decodeFrame(typ, msg, as.area)
+		if err != nil {
+			report(ctx, conn, err)
+			continue
+		}
+		// Replace the pending frame, if any.
+		select {
+		case <-pending:
+		default:
+		}
+		pending <- f
+	}
+}
+
+// send sends the pending frames, one per period at most.
+func (h *Handler) send(ctx context.Context, conn *websocket.Conn, as *areaStream, pending <-chan huestream.Frame) {
+	var last time.Time
+	for {
+		var f huestream.Frame
+		select {
+		case <-ctx.Done():
+			return
+		case f = <-pending:
+		}
+		if wait := h.period - time.Since(last); wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+			// A newer frame may have arrived while waiting.
+			select {
+			case f = <-pending:
+			default:
+			}
+		}
+		last = time.Now()
+		if err := as.stream.SendContext(ctx, f); err != nil && ctx.Err() == nil {
+			report(ctx, conn, err)
+		}
+	}
+}
+
+// Message is a message sent back to the connections.
+type Message struct {
+	Error string `json:"error"`
+}
+
+// report sends err back to conn.
+func report(ctx context.Context, conn *websocket.Conn, err error) {
+	b, _ := json.Marshal(Message{Error: err.Error()})
+	conn.Write(ctx, websocket.MessageText, b)
+}
+
+// decodeFrame decodes a binary or JSON frame for the area a.
+func decodeFrame(typ websocket.MessageType, msg []byte, a huestream.Area) (huestream.Frame, error) {
+	if typ == websocket.MessageBinary {
+		if len(msg)%channelSize != 0 {
+			return nil, fmt.Errorf("binary frame of %d bytes, not a multiple of %d", len(msg), channelSize)
+		}
+		f := make(huestream.Frame, len(msg)/channelSize)
+		for b := msg; len(b) > 0; b = b[channelSize:] {
+			f[int(b[0])] = color.RGBA64{
+				R: binary.BigEndian.Uint16(b[1:]),
+				G: binary.BigEndian.Uint16(b[3:]),
+				B: binary.BigEndian.Uint16(b[5:]),
+				A: 0xffff,
+			}
+		}
+		return f, nil
+	}
+
+	var colors map[string]string
+	if err := json.Unmarshal(msg, &colors); err != nil {
+		return nil, fmt.Errorf("JSON frame: %w", err)
+	}
+	f := make(huestream.Frame, len(colors))
+	for key, s := range colors {
+		c, err := huestream.ParseColor(s)
+		if err != nil {
+			return nil, err
+		}
+		if key == "all" {
+			for _, ch := range a.Channels {
+				if _, ok := f[ch.ID]; !ok {
+					f[ch.ID] = c
+				}
+			}
+			continue
+		}
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid channel %q", key)
+		}
+		f[id] = c
+	}
+	return f, nil
+}
+
+var errClosed = errors.New("handler closed")
+
+// statusCode returns the status code refusing a connection for err.
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, huestream.ErrAreaNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, huestream.ErrStreamActive):
+		return http.StatusConflict
+	case errors.Is(err, errClosed):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
@@ -0,0 +1,178 @@
+package wsrelay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/rschio/huestream/huetest"
+	"github.com/rschio/huestream/wire"
+)
+
+func newRelay(t *testing.T, opts ...Option) (*huetest.Bridge, *Handler, string) {
+	t.Helper()
+
+	b := huetest.NewBridge(t)
+	h := New(b.Host, b.Username, b.ClientKey, append(opts, WithStreamOptions(b.Options()...))...)
+	mux := http.NewServeMux()
+	mux.Handle("/ws/{area}", h)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		srv.Close()
+		h.Close()
+	})
+	return b, h, "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/" + b.AreaID
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.Dial(context.Background(), url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+	return conn
+}
+
+func nextFrame(t *testing.T, b *huetest.Bridge) wire.Frame {
+	t.Helper()
+
+	select {
+	case f := <-b.Frames():
+		return f
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame received")
+		return wire.Frame{}
+	}
+}
+
+func TestRelay(t *testing.T) {
+	b, _, url := newRelay(t)
+	conn := dial(t, url)
+	ctx := context.Background()
+
+	if err := conn.Write(ctx, websocket.MessageText, []byte(`{"all":"#ff0000","1":"#0000ff"}`)); err != nil {
+		t.Fatal(err)
+	}
+	f := nextFrame(t, b)
+	want := [][3]uint16{{0xffff, 0, 0}, {0, 0, 0xffff}, {0xffff, 0, 0}}
+	for i, ch := range f.Channels {
+		if ch.Values != want[i] {
+			t.Errorf("JSON frame: got %+v, want %v", f.Channels, want)
+			break
+		}
+	}
+
+	bin := []byte{2, 0x12, 0x34, 0, 0, 0xff, 0xff}
+	if err := conn.Write(ctx, websocket.MessageBinary, bin); err != nil {
+		t.Fatal(err)
+	}
+	for f = nextFrame(t, b); len(f.Channels) != 1; f = nextFrame(t, b) {
+		// Skip the keepalive resends of the JSON frame.
+	}
+	if ch := f.Channels[0]; ch.ID != 2 || ch.Values != [3]uint16{0x1234, 0, 0xffff} {
+		t.Errorf("binary frame: got %+v", f.Channels)
+	}
+}
+
+func TestRelayReportsErrors(t *testing.T) {
+	_, _, url := newRelay(t)
+	conn := dial(t, url)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, msg := range []struct {
+		typ  websocket.MessageType
+		data string
+	}{
+		{websocket.MessageText, `{"0":"reddish"}`},
+		{websocket.MessageBinary, "\x00\x01"},
+	} {
+		if err := conn.Write(ctx, msg.typ, []byte(msg.data)); err != nil {
+			t.Fatal(err)
+		}
+		_, b, err := conn.Read(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m Message
+		if err := json.Unmarshal(b, &m); err != nil || m.Error == "" {
+			t.Errorf("%q: got message %q, want an error", msg.data, b)
+		}
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		opts       []Option
+		wantActive bool
+	}{
+		{"keep", nil, true},
+		{"close", []Option{WithCloseOnDisconnect()}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _, url := newRelay(t, tt.opts...)
+			conn := dial(t, url)
+			if err := conn.Write(context.Background(), websocket.MessageText, []byte(`{"all":"#ffffff"}`)); err != nil {
+				t.Fatal(err)
+			}
+			nextFrame(t, b)
+			conn.Close(websocket.StatusNormalClosure, "")
+
+			deadline := time.Now().Add(5 * time.Second)
+			for b.Active() != tt.wantActive && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if b.Active() != tt.wantActive {
+				t.Errorf("stream active after the disconnection: %v, want %v", b.Active(), tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestUnknownArea(t *testing.T) {
+	_, _, url := newRelay(t)
+
+	_, resp, err := websocket.Dial(context.Background(), strings.Replace(url, huetest.AreaID, "missing", 1), nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("got %v, want a 404", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	b, _, url := newRelay(t, WithMaxRate(5))
+	conn := dial(t, url)
+
+	for i := range 20 {
+		msg := fmt.Sprintf(`{"0":"#%02x0000"}`, i+1)
+		if err := conn.Write(context.Background(), websocket.MessageText, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// At 5 Hz the first frame is sent at once and the last one 200ms later.
+	var frames []wire.Frame
+	timeout := time.After(500 * time.Millisecond)
+	for collecting := true; collecting; {
+		select {
+		case f := <-b.Frames():
+			frames = append(frames, f)
+		case <-timeout:
+			collecting = false
+		}
+	}
+	if len(frames) == 0 || len(frames) > 3 {
+		t.Fatalf("received %d frames, want 1 to 3", len(frames))
+	}
+	if last := frames[len(frames)-1].Channels[0].Values[0]; last != 20*0x101 {
+		t.Errorf("last frame red is %#x, want the last one sent %#x", last, 20*0x101)
+	}
+}